@@ -11,6 +11,7 @@ import (
 	"go-bank-api/repository"
 	"go-bank-api/router"
 	"go-bank-api/service"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -35,21 +36,93 @@ func Run() {
 		logger.Log.Fatalf("Error connecting to Redis: %v", err)
 	}
 	defer redisClient.Close()
+	rateProvider, err := service.NewConfiguredRateProvider()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring FX rates: %v", err)
+	}
+	keys, err := service.NewConfiguredKeyRing()
+	if err != nil {
+		logger.Log.Fatalf("Error loading JWT signing keys: %v", err)
+	}
+	passwordHasher, err := service.NewConfiguredPasswordHasher()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring password hashing: %v", err)
+	}
+	passwordPolicy, err := service.NewConfiguredPasswordPolicy()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring password policy: %v", err)
+	}
+	approvalPolicy, err := service.NewConfiguredApprovalPolicy()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring approvals: %v", err)
+	}
+	rateLimiter, err := service.NewConfiguredRateLimiter(redisClient)
+	if err != nil {
+		logger.Log.Fatalf("Error configuring rate limits: %v", err)
+	}
+	secretBox, err := service.NewConfiguredSecretBox()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring secret encryption: %v", err)
+	}
+	healthService, err := service.NewConfiguredHealthService(repository.NewHealthRepository(database), redisClient)
+	if err != nil {
+		logger.Log.Fatalf("Error configuring health checks: %v", err)
+	}
+	healthHandler := handler.NewHealthHandler(healthService)
+	auditService := service.NewAuditService(database, repository.NewAuditRepository(database))
+	auditHandler := handler.NewAuditHandler(auditService)
 	userRepo := repository.NewUserRepository(database)
 	tokenRepo := repository.NewTokenRepository(database)
-	authService := service.NewAuthService(userRepo, tokenRepo)
-	userService := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userRepo, userService, authService)
+	sessionRepo := repository.NewSessionRepository(database)
+	mfaRepo := repository.NewMFARepository(database)
+	mfaService := service.NewMFAService(database, mfaRepo, userRepo, redisClient, secretBox)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	denylist := service.NewAccessTokenDenylist(redisClient)
+	loginGuard := service.NewConfiguredLoginGuard(redisClient, repository.NewLoginEventRepository(database))
+	authService := service.NewAuthService(userRepo, tokenRepo, sessionRepo, denylist, keys, mfaService, loginGuard, passwordHasher, passwordPolicy, auditService)
+	roleService := service.NewRoleService(database, repository.NewRoleRepository(database), redisClient)
+	roleHandler := handler.NewRoleHandler(roleService)
+	userService := service.NewUserService(database, userRepo, roleService, denylist, auditService)
+	mailer, err := service.NewConfiguredMailer()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring mail: %v", err)
+	}
+	userTokenRepo := repository.NewUserTokenRepository(database)
+	verificationService := service.NewVerificationService(database, userRepo, userTokenRepo, passwordHasher, passwordPolicy, authService, mailer)
+	verificationHandler := handler.NewVerificationHandler(verificationService)
 	accountRepo := repository.NewAccountRepository(database)
-	// The real *redis.Client satisfies the ICacheClient interface implicitly.
-	accountService := service.NewAccountService(accountRepo, redisClient)
-	accountHandler := handler.NewAccountHandler(accountService)
 	transactionRepo := repository.NewTransactionRepository(database)
-	transactionService := service.NewTransactionService(database, accountRepo, transactionRepo)
+	ledgerRepo := repository.NewLedgerRepository(database)
+	ledgerService := service.NewLedgerService(database, accountRepo, transactionRepo, ledgerRepo, redisClient, auditService)
+	ledgerHandler := handler.NewLedgerHandler(ledgerService)
+	// The real *redis.Client satisfies the ICacheClient interface implicitly.
+	accountService := service.NewAccountService(accountRepo, redisClient, ledgerService, auditService)
+	approvalService := service.NewApprovalService(database, repository.NewApprovalRepository(database), accountRepo, accountService, userService, roleService, approvalPolicy)
+	approvalHandler := handler.NewApprovalHandler(approvalService)
+	userHandler := handler.NewUserHandler(userRepo, userService, authService, verificationService, approvalService)
+	accountHandler := handler.NewAccountHandler(accountService, approvalService)
+	payeeRepo := repository.NewPayeeRepository(database)
+	fxQuoteRepo := repository.NewFXQuoteRepository(database)
+	fxService := service.NewFXService(rateProvider, fxQuoteRepo, config.AppConfig.FX.SpreadBps, config.AppConfig.FX.QuoteTTL)
+	fxHandler := handler.NewFXHandler(fxService)
+	transactionService := service.NewTransactionService(database, accountRepo, transactionRepo, ledgerRepo, payeeRepo, fxQuoteRepo, fxService, auditService)
 	transactionHandler := handler.NewTransactionHandler(transactionService)
-	r := router.NewRouter(userHandler, accountHandler, transactionHandler)
+	payeeService := service.NewPayeeService(payeeRepo, transactionService)
+	payeeHandler := handler.NewPayeeHandler(payeeService)
+	idempotencyRepo := repository.NewIdempotencyRepository(database)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, redisClient, config.AppConfig.Idempotency.TTL, config.AppConfig.Idempotency.ClaimTTL)
+	jwksHandler := handler.NewJWKSHandler(keys)
+	r := router.NewRouter(userHandler, accountHandler, transactionHandler, ledgerHandler, payeeHandler, fxHandler, mfaHandler, verificationHandler, roleHandler, approvalHandler, auditHandler, healthHandler, jwksHandler, idempotencyService, rateLimiter, keys, denylist, roleService)
 	port := config.AppConfig.Server.Port
-	srv := &http.Server{Addr: ":" + port, Handler: r}
+	// Every request context derives from baseCtx, so cancelling it aborts the
+	// queries of requests still running when the shutdown grace period ends.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Addr:        ":" + port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
 	go func() {
 		logger.Log.Infof("Server starting on port :%s", port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -60,10 +133,19 @@ func Run() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Log.Warn("Shutdown signal received. Starting graceful shutdown...")
+	// Report not ready first and keep serving for a while, so load balancers
+	// take the instance out of rotation before it stops accepting connections.
+	healthService.SetShuttingDown()
+	time.Sleep(config.AppConfig.Health.ShutdownDelay)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Log.Fatalf("Server forced to shutdown: %v", err)
+		// Requests are still running after the grace period. Cancelling their
+		// contexts aborts their queries, so closing the database does not hang.
+		logger.Log.WithError(err).Error("Server forced to shutdown, cancelling in-flight requests")
+		cancelRequests()
+		srv.Close()
+		return
 	}
 	logger.Log.Info("Server exited properly")
 }
@@ -75,17 +157,79 @@ type TestApp struct {
 }
 
 func NewTestApp(db *sql.DB, redisClient *redis.Client) *TestApp {
+	rateProvider, err := service.NewConfiguredRateProvider()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring FX rates: %v", err)
+	}
+	keys, err := service.NewConfiguredKeyRing()
+	if err != nil {
+		logger.Log.Fatalf("Error loading JWT signing keys: %v", err)
+	}
+	passwordHasher, err := service.NewConfiguredPasswordHasher()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring password hashing: %v", err)
+	}
+	passwordPolicy, err := service.NewConfiguredPasswordPolicy()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring password policy: %v", err)
+	}
+	approvalPolicy, err := service.NewConfiguredApprovalPolicy()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring approvals: %v", err)
+	}
+	rateLimiter, err := service.NewConfiguredRateLimiter(redisClient)
+	if err != nil {
+		logger.Log.Fatalf("Error configuring rate limits: %v", err)
+	}
+	secretBox, err := service.NewConfiguredSecretBox()
+	if err != nil {
+		logger.Log.Fatalf("Error configuring secret encryption: %v", err)
+	}
+	healthService, err := service.NewConfiguredHealthService(repository.NewHealthRepository(db), redisClient)
+	if err != nil {
+		logger.Log.Fatalf("Error configuring health checks: %v", err)
+	}
+	healthHandler := handler.NewHealthHandler(healthService)
+	auditService := service.NewAuditService(db, repository.NewAuditRepository(db))
+	auditHandler := handler.NewAuditHandler(auditService)
 	userRepo := repository.NewUserRepository(db)
 	tokenRepo := repository.NewTokenRepository(db)
-	authService := service.NewAuthService(userRepo, tokenRepo)
-	userService := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userRepo, userService, authService)
+	sessionRepo := repository.NewSessionRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	mfaService := service.NewMFAService(db, mfaRepo, userRepo, redisClient, secretBox)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	denylist := service.NewAccessTokenDenylist(redisClient)
+	loginGuard := service.NewConfiguredLoginGuard(redisClient, repository.NewLoginEventRepository(db))
+	authService := service.NewAuthService(userRepo, tokenRepo, sessionRepo, denylist, keys, mfaService, loginGuard, passwordHasher, passwordPolicy, auditService)
+	roleService := service.NewRoleService(db, repository.NewRoleRepository(db), redisClient)
+	roleHandler := handler.NewRoleHandler(roleService)
+	userService := service.NewUserService(db, userRepo, roleService, denylist, auditService)
+	// Tests never deliver mail; messages only go to the log.
+	mailer := service.NewLogMailer(config.AppConfig.Mail.From, "")
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	verificationService := service.NewVerificationService(db, userRepo, userTokenRepo, passwordHasher, passwordPolicy, authService, mailer)
+	verificationHandler := handler.NewVerificationHandler(verificationService)
 	accountRepo := repository.NewAccountRepository(db)
-	accountService := service.NewAccountService(accountRepo, redisClient)
-	accountHandler := handler.NewAccountHandler(accountService)
 	transactionRepo := repository.NewTransactionRepository(db)
-	transactionService := service.NewTransactionService(db, accountRepo, transactionRepo)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	ledgerService := service.NewLedgerService(db, accountRepo, transactionRepo, ledgerRepo, redisClient, auditService)
+	ledgerHandler := handler.NewLedgerHandler(ledgerService)
+	accountService := service.NewAccountService(accountRepo, redisClient, ledgerService, auditService)
+	approvalService := service.NewApprovalService(db, repository.NewApprovalRepository(db), accountRepo, accountService, userService, roleService, approvalPolicy)
+	approvalHandler := handler.NewApprovalHandler(approvalService)
+	userHandler := handler.NewUserHandler(userRepo, userService, authService, verificationService, approvalService)
+	accountHandler := handler.NewAccountHandler(accountService, approvalService)
+	payeeRepo := repository.NewPayeeRepository(db)
+	fxQuoteRepo := repository.NewFXQuoteRepository(db)
+	fxService := service.NewFXService(rateProvider, fxQuoteRepo, config.AppConfig.FX.SpreadBps, config.AppConfig.FX.QuoteTTL)
+	fxHandler := handler.NewFXHandler(fxService)
+	transactionService := service.NewTransactionService(db, accountRepo, transactionRepo, ledgerRepo, payeeRepo, fxQuoteRepo, fxService, auditService)
 	transactionHandler := handler.NewTransactionHandler(transactionService)
-	r := router.NewRouter(userHandler, accountHandler, transactionHandler)
+	payeeService := service.NewPayeeService(payeeRepo, transactionService)
+	payeeHandler := handler.NewPayeeHandler(payeeService)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, redisClient, config.AppConfig.Idempotency.TTL, config.AppConfig.Idempotency.ClaimTTL)
+	jwksHandler := handler.NewJWKSHandler(keys)
+	r := router.NewRouter(userHandler, accountHandler, transactionHandler, ledgerHandler, payeeHandler, fxHandler, mfaHandler, verificationHandler, roleHandler, approvalHandler, auditHandler, healthHandler, jwksHandler, idempotencyService, rateLimiter, keys, denylist, roleService)
 	return &TestApp{Router: r, DB: db, RedisClient: redisClient}
 }
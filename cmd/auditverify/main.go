@@ -0,0 +1,42 @@
+// file: cmd/auditverify/main.go
+
+// Command auditverify checks the hash chain of the audit log. It prints the
+// verification report as JSON and exits with status 1 if the chain is broken.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go-bank-api/config"
+	"go-bank-api/db"
+	"go-bank-api/logger"
+	"go-bank-api/repository"
+	"go-bank-api/service"
+	"os"
+)
+
+func main() {
+	config.LoadConfig(".")
+	logger.Init()
+	// The report is the only thing written to stdout.
+	logger.Log.SetOutput(os.Stderr)
+	database, err := db.Connect()
+	if err != nil {
+		logger.Log.Fatalf("Error connecting to the database: %v", err)
+	}
+	defer database.Close()
+
+	auditService := service.NewAuditService(database, repository.NewAuditRepository(database))
+	report, err := auditService.VerifyChain(context.Background())
+	if err != nil {
+		logger.Log.Fatalf("Error verifying the audit log: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	if !report.Healthy {
+		database.Close()
+		os.Exit(1)
+	}
+}
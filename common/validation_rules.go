@@ -0,0 +1,35 @@
+package common
+
+import (
+	"go-bank-api/model"
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Custom validation tags for banking identifiers, usable in any request struct:
+//
+//	account_number  an int64 or string account number with a valid check digit
+//	iban            a string IBAN with valid mod-97 check digits
+func init() {
+	validate.RegisterValidation("account_number", validateAccountNumber)
+	validate.RegisterValidation("iban", validateIBAN)
+}
+
+func validateAccountNumber(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64:
+		return model.ValidateAccountNumber(field.Int()) == nil
+	case reflect.String:
+		_, err := model.ParseAccountNumber(field.String())
+		return err == nil
+	default:
+		return false
+	}
+}
+
+func validateIBAN(fl validator.FieldLevel) bool {
+	_, err := model.NormalizeIBAN(fl.Field().String())
+	return err == nil
+}
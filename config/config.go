@@ -2,6 +2,7 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,6 +14,8 @@ type Config struct {
 		User     string `mapstructure:"user"`
 		Password string `mapstructure:"password"`
 		Name     string `mapstructure:"name"`
+		// QueryTimeout bounds every database query; zero disables the limit.
+		QueryTimeout time.Duration `mapstructure:"query_timeout"`
 	} `mapstructure:"database"`
 
 	// Redis holds the connection details for the caching service.
@@ -26,9 +29,185 @@ type Config struct {
 		Port string `mapstructure:"port"`
 	} `mapstructure:"server"`
 
+	// JWT configures how access tokens are signed. Keys are RSA (RS256) or
+	// Ed25519 (EdDSA) private keys in PEM files, identified by kid. The key
+	// whose ActiveFrom (RFC 3339) passed most recently signs new tokens; keys
+	// scheduled for later are already published so verifiers can fetch them
+	// before the rotation, and a replaced key keeps verifying tokens for
+	// RotationOverlap. Until a key is active, tokens are signed with SecretKey
+	// (HS256).
+	// Tokens signed with SecretKey are accepted as long as it is set, so it can
+	// be removed once the last of them has expired.
 	JWT struct {
 		SecretKey string `mapstructure:"secret_key"`
+		Keys      []struct {
+			ID             string `mapstructure:"kid"`
+			PrivateKeyFile string `mapstructure:"private_key_file"`
+			ActiveFrom     string `mapstructure:"active_from"`
+		} `mapstructure:"keys"`
+		RotationOverlap time.Duration `mapstructure:"rotation_overlap"`
 	} `mapstructure:"jwt"`
+
+	// Password configures how passwords are hashed. Algorithm is "argon2id"
+	// or "bcrypt"; Argon2.Memory is in KiB. Stored hashes made with another
+	// algorithm or other parameters are replaced when their user next logs in.
+	//
+	// Policy sets the rules for new passwords. Lengths count characters.
+	// bcrypt refuses to hash passwords longer than 72 bytes, so with bcrypt new
+	// passwords are also limited to 72 bytes. ForbidPersonalInfo refuses
+	// passwords containing the username or the local part of the email
+	// address. BreachedListFile names a file of SHA-1 hashes of breached
+	// passwords, one per line in the Pwned Passwords download format
+	// (HASH:COUNT); passwords in it are refused. Leave it empty to skip the
+	// check.
+	Password struct {
+		Algorithm string `mapstructure:"algorithm"`
+		Argon2    struct {
+			Memory      uint32 `mapstructure:"memory"`
+			Iterations  uint32 `mapstructure:"iterations"`
+			Parallelism uint8  `mapstructure:"parallelism"`
+			SaltLength  uint32 `mapstructure:"salt_length"`
+			KeyLength   uint32 `mapstructure:"key_length"`
+		} `mapstructure:"argon2"`
+		BcryptCost int `mapstructure:"bcrypt_cost"`
+		Policy     struct {
+			MinLength          int    `mapstructure:"min_length"`
+			MaxLength          int    `mapstructure:"max_length"`
+			RequireUppercase   bool   `mapstructure:"require_uppercase"`
+			RequireLowercase   bool   `mapstructure:"require_lowercase"`
+			RequireDigit       bool   `mapstructure:"require_digit"`
+			RequireSymbol      bool   `mapstructure:"require_symbol"`
+			ForbidPersonalInfo bool   `mapstructure:"forbid_personal_info"`
+			BreachedListFile   string `mapstructure:"breached_list_file"`
+		} `mapstructure:"policy"`
+	} `mapstructure:"password"`
+
+	// Login configures brute-force protection. After DelayAfter failed
+	// logins for an email address, each further attempt has to wait a delay
+	// that starts at BaseDelay and doubles up to MaxDelay; after MaxFailures the
+	// address is locked for LockoutDuration. Failures are forgotten after
+	// FailureWindow without one. An IP address may fail MaxFailuresPerIP times
+	// per FailureWindow across all addresses.
+	Login struct {
+		MaxFailures      int           `mapstructure:"max_failures"`
+		DelayAfter       int           `mapstructure:"delay_after"`
+		BaseDelay        time.Duration `mapstructure:"base_delay"`
+		MaxDelay         time.Duration `mapstructure:"max_delay"`
+		LockoutDuration  time.Duration `mapstructure:"lockout_duration"`
+		FailureWindow    time.Duration `mapstructure:"failure_window"`
+		MaxFailuresPerIP int           `mapstructure:"max_failures_per_ip"`
+	} `mapstructure:"login"`
+
+	// MFA configures two-factor authentication. Issuer names the service in
+	// authenticator apps. EncryptionKey is the base64-encoded 32-byte key TOTP
+	// secrets are encrypted with (AES-256-GCM) before they are stored; generate
+	// one with `openssl rand -base64 32`. It is required, and can be set with
+	// the MFA_ENCRYPTION_KEY environment variable. Changing it makes every
+	// enrolled authenticator unusable.
+	MFA struct {
+		Issuer        string `mapstructure:"issuer"`
+		EncryptionKey string `mapstructure:"encryption_key"`
+	} `mapstructure:"mfa"`
+
+	// Mail configures outgoing email. Driver selects how mail is sent: "log"
+	// writes each message to a file in Dir, or to the log when Dir is empty,
+	// for local use; "smtp" sends it through SMTP. LinkBaseURL is the address
+	// of the frontend that links in emails point to.
+	Mail struct {
+		Driver      string `mapstructure:"driver"`
+		From        string `mapstructure:"from"`
+		Dir         string `mapstructure:"dir"`
+		LinkBaseURL string `mapstructure:"link_base_url"`
+		SMTP        struct {
+			Host     string `mapstructure:"host"`
+			Port     string `mapstructure:"port"`
+			Username string `mapstructure:"username"`
+			Password string `mapstructure:"password"`
+		} `mapstructure:"smtp"`
+	} `mapstructure:"mail"`
+
+	// IBAN configures the IBAN representation of account numbers. IBANs are
+	// only issued when BankCode is set, for currencies mapped to a country.
+	IBAN struct {
+		BankCode string `mapstructure:"bank_code"`
+		// Countries maps a currency to the ISO 3166 country code used in its
+		// IBANs. Viper lower-cases map keys, so look currencies up in lower case.
+		Countries map[string]string `mapstructure:"countries"`
+	} `mapstructure:"iban"`
+
+	// FX configures cross-currency transfers. Provider selects where mid-market
+	// rates come from: "static" serves the Rates table (pairs such as "usd/try"),
+	// "http" queries HTTP.URL. SpreadBps is the bank's margin in basis points
+	// and QuoteTTL how long a quoted rate stays locked.
+	FX struct {
+		Provider string            `mapstructure:"provider"`
+		Rates    map[string]string `mapstructure:"rates"`
+		HTTP     struct {
+			URL     string        `mapstructure:"url"`
+			Timeout time.Duration `mapstructure:"timeout"`
+		} `mapstructure:"http"`
+		SpreadBps int           `mapstructure:"spread_bps"`
+		QuoteTTL  time.Duration `mapstructure:"quote_ttl"`
+	} `mapstructure:"fx"`
+
+	// Idempotency controls how long responses to requests sent with an
+	// Idempotency-Key header are kept for replay. A key whose first request
+	// is still running stays claimed for ClaimTTL, after which it can be used
+	// again if no response was stored, e.g. because the API was stopped.
+	Idempotency struct {
+		TTL      time.Duration `mapstructure:"ttl"`
+		ClaimTTL time.Duration `mapstructure:"claim_ttl"`
+	} `mapstructure:"idempotency"`
+
+	// Approvals configures the four-eyes rule for sensitive admin actions,
+	// which a second admin has to approve before they take effect. Deposits
+	// above the threshold for the account's currency need approval; deposits
+	// in a currency without one always do. With RoleChanges set, role changes
+	// that grant the user permissions they do not hold need approval too.
+	// Requests nobody decides on expire after TTL.
+	Approvals struct {
+		// DepositThresholds maps a currency to a decimal amount. Viper
+		// lower-cases map keys, so look currencies up in lower case.
+		DepositThresholds map[string]string `mapstructure:"deposit_thresholds"`
+		RoleChanges       bool              `mapstructure:"role_changes"`
+		TTL               time.Duration     `mapstructure:"ttl"`
+	} `mapstructure:"approvals"`
+
+	// RateLimit configures request rate limiting. Routes name the rule that
+	// limits them: login, register, auth (token refresh, password reset and
+	// email verification) and transfers. A rule allows Limit requests per
+	// Window for each key, which is the client's IP address (ip), the
+	// authenticated user (user) or the client whose API key is in the
+	// X-API-Key header (api_key); requests without a user or a known API key
+	// are counted by IP address. Algorithm is sliding_window or token_bucket,
+	// which allows bursts of up to Limit requests and refills evenly over
+	// Window. Counters are kept in Redis, and in memory while Redis is
+	// unavailable.
+	//
+	// APIKeys maps each client name to the hex SHA-256 hash of its API key,
+	// so the keys themselves are not stored. Generate a key with
+	// `openssl rand -hex 32` and its hash with `printf %s "$KEY" | sha256sum`.
+	RateLimit struct {
+		Enabled bool              `mapstructure:"enabled"`
+		APIKeys map[string]string `mapstructure:"api_keys"`
+		Rules   map[string]struct {
+			Limit     int           `mapstructure:"limit"`
+			Window    time.Duration `mapstructure:"window"`
+			Key       string        `mapstructure:"key"`
+			Algorithm string        `mapstructure:"algorithm"`
+		} `mapstructure:"rules"`
+	} `mapstructure:"rate_limit"`
+
+	// Health configures the readiness probe. Each dependency check gives up
+	// after Timeout. The database schema has to be at least at the version of
+	// the newest migration in MigrationsDir. On shutdown the API reports not
+	// ready for ShutdownDelay before it stops accepting connections, so load
+	// balancers stop sending it traffic first.
+	Health struct {
+		Timeout       time.Duration `mapstructure:"timeout"`
+		MigrationsDir string        `mapstructure:"migrations_dir"`
+		ShutdownDelay time.Duration `mapstructure:"shutdown_delay"`
+	} `mapstructure:"health"`
 }
 
 var AppConfig Config
@@ -39,6 +218,57 @@ func LoadConfig(path string) {
 	viper.SetConfigType("yml")
 
 	viper.AutomaticEnv()
+	// Secrets come from the environment, as in .env.example.
+	viper.BindEnv("mfa.encryption_key", "MFA_ENCRYPTION_KEY")
+
+	viper.SetDefault("database.query_timeout", "5s")
+	viper.SetDefault("jwt.rotation_overlap", "30m")
+	viper.SetDefault("password.algorithm", "argon2id")
+	viper.SetDefault("password.argon2.memory", 64*1024)
+	viper.SetDefault("password.argon2.iterations", 3)
+	viper.SetDefault("password.argon2.parallelism", 2)
+	viper.SetDefault("password.argon2.salt_length", 16)
+	viper.SetDefault("password.argon2.key_length", 32)
+	viper.SetDefault("password.bcrypt_cost", 12)
+	viper.SetDefault("password.policy.min_length", 10)
+	viper.SetDefault("password.policy.max_length", 128)
+	viper.SetDefault("password.policy.require_lowercase", true)
+	viper.SetDefault("password.policy.require_digit", true)
+	viper.SetDefault("password.policy.forbid_personal_info", true)
+	viper.SetDefault("login.max_failures", 10)
+	viper.SetDefault("login.delay_after", 3)
+	viper.SetDefault("login.base_delay", "1s")
+	viper.SetDefault("login.max_delay", "30s")
+	viper.SetDefault("login.lockout_duration", "15m")
+	viper.SetDefault("login.failure_window", "15m")
+	viper.SetDefault("login.max_failures_per_ip", 100)
+	viper.SetDefault("mfa.issuer", "Go Bank")
+	viper.SetDefault("mail.driver", "log")
+	viper.SetDefault("mail.from", "no-reply@gobank.local")
+	viper.SetDefault("mail.link_base_url", "http://localhost:3000")
+	viper.SetDefault("mail.smtp.port", "587")
+	viper.SetDefault("iban.countries", map[string]string{"try": "TR"})
+	viper.SetDefault("fx.provider", "static")
+	viper.SetDefault("fx.http.timeout", "3s")
+	viper.SetDefault("fx.spread_bps", 50)
+	viper.SetDefault("fx.quote_ttl", "30s")
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.claim_ttl", "1m")
+	viper.SetDefault("approvals.deposit_thresholds", map[string]string{
+		"try": "50000", "usd": "5000", "eur": "5000", "gbp": "5000", "chf": "5000", "jpy": "500000",
+	})
+	viper.SetDefault("approvals.role_changes", true)
+	viper.SetDefault("approvals.ttl", "48h")
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.rules", map[string]interface{}{
+		"login":     map[string]interface{}{"limit": 10, "window": "1m", "key": "ip", "algorithm": "sliding_window"},
+		"register":  map[string]interface{}{"limit": 5, "window": "1h", "key": "ip", "algorithm": "sliding_window"},
+		"auth":      map[string]interface{}{"limit": 20, "window": "1m", "key": "ip", "algorithm": "sliding_window"},
+		"transfers": map[string]interface{}{"limit": 30, "window": "1m", "key": "user", "algorithm": "token_bucket"},
+	})
+	viper.SetDefault("health.timeout", "2s")
+	viper.SetDefault("health.migrations_dir", "db/migrations")
+	viper.SetDefault("health.shutdown_delay", "3s")
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Fatalf("Error reading config file, %s", err)
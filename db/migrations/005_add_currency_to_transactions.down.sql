@@ -0,0 +1,3 @@
+-- file: db/migrations/005_add_currency_to_transactions.down.sql
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
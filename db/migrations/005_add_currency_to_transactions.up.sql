@@ -0,0 +1,13 @@
+-- file: db/migrations/005_add_currency_to_transactions.up.sql
+
+-- Amounts are exact minor-unit values in the application; the currency is
+-- stored next to each amount so that a transaction can be read back without
+-- joining the account it was debited from.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency VARCHAR(3);
+
+UPDATE transactions t
+SET currency = a.currency
+FROM accounts a
+WHERE a.id = t.from_account_id AND t.currency IS NULL;
+
+ALTER TABLE transactions ALTER COLUMN currency SET NOT NULL;
@@ -0,0 +1,3 @@
+-- file: db/migrations/006_create_idempotency_keys_table.down.sql
+
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,24 @@
+-- file: db/migrations/006_create_idempotency_keys_table.up.sql
+
+-- Stores the first response produced for a client-supplied Idempotency-Key so
+-- that retries of money-moving requests are replayed instead of re-executed.
+-- status_code and response_body stay NULL while the first request is in flight.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_fingerprint VARCHAR(64) NOT NULL,
+    status_code INT,
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    CONSTRAINT uq_idempotency_user_key UNIQUE (user_id, idempotency_key),
+
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
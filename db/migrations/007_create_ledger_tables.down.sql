@@ -0,0 +1,15 @@
+-- file: db/migrations/007_create_ledger_tables.down.sql
+
+DROP TRIGGER IF EXISTS trg_postings_balanced ON postings;
+DROP FUNCTION IF EXISTS check_journal_entry_balanced();
+DROP TABLE IF EXISTS postings;
+DROP TABLE IF EXISTS journal_entries;
+
+DELETE FROM transactions WHERE kind = 'opening_balance';
+ALTER TABLE transactions DROP COLUMN IF EXISTS kind;
+
+DELETE FROM accounts WHERE kind <> 'customer';
+DROP INDEX IF EXISTS uq_system_account_kind_currency;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS chk_account_owner;
+ALTER TABLE accounts ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE accounts DROP COLUMN IF EXISTS kind;
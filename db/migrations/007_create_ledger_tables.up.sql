@@ -0,0 +1,124 @@
+-- file: db/migrations/007_create_ledger_tables.up.sql
+
+-- System accounts (cash/settlement, fee income) live in the accounts table so
+-- that transactions and postings can reference them, but no user owns them.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'customer';
+ALTER TABLE accounts ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE accounts ADD CONSTRAINT chk_account_owner CHECK ((kind = 'customer') = (user_id IS NOT NULL));
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_system_account_kind_currency ON accounts(kind, currency) WHERE kind <> 'customer';
+
+INSERT INTO accounts (user_id, account_number, currency, kind) VALUES
+    (NULL, 100, 'TRY', 'settlement'),
+    (NULL, 101, 'USD', 'settlement'),
+    (NULL, 102, 'EUR', 'settlement'),
+    (NULL, 103, 'GBP', 'settlement'),
+    (NULL, 104, 'CHF', 'settlement'),
+    (NULL, 105, 'JPY', 'settlement'),
+    (NULL, 200, 'TRY', 'fee_income'),
+    (NULL, 201, 'USD', 'fee_income'),
+    (NULL, 202, 'EUR', 'fee_income'),
+    (NULL, 203, 'GBP', 'fee_income'),
+    (NULL, 204, 'CHF', 'fee_income'),
+    (NULL, 205, 'JPY', 'fee_income')
+ON CONFLICT (account_number) DO NOTHING;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'transfer';
+
+-- A journal entry is one balanced money movement; every transaction row has exactly one.
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    transaction_id INT NOT NULL UNIQUE,
+    reverses_entry_id INT UNIQUE,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_transaction
+        FOREIGN KEY(transaction_id)
+        REFERENCES transactions(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_reverses_entry
+        FOREIGN KEY(reverses_entry_id)
+        REFERENCES journal_entries(id)
+        ON DELETE CASCADE
+);
+
+-- Postings are signed: positive amounts credit (increase) the account balance,
+-- negative amounts debit it.
+CREATE TABLE IF NOT EXISTS postings (
+    id SERIAL PRIMARY KEY,
+    journal_entry_id INT NOT NULL,
+    account_id INT NOT NULL,
+    amount NUMERIC(15, 2) NOT NULL CHECK (amount <> 0),
+    currency VARCHAR(3) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_journal_entry
+        FOREIGN KEY(journal_entry_id)
+        REFERENCES journal_entries(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_account
+        FOREIGN KEY(account_id)
+        REFERENCES accounts(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings(account_id);
+CREATE INDEX IF NOT EXISTS idx_postings_journal_entry_id ON postings(journal_entry_id);
+
+-- Enforce the double-entry invariant at commit time: the postings of an entry
+-- must sum to zero in every currency.
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM postings
+        WHERE journal_entry_id = NEW.journal_entry_id
+        GROUP BY currency
+        HAVING SUM(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.journal_entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_postings_balanced ON postings;
+CREATE CONSTRAINT TRIGGER trg_postings_balanced
+    AFTER INSERT OR UPDATE ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
+
+-- Existing balances were mutated in place without history. Record each of them
+-- as an opening balance funded from the settlement account of its currency.
+DO $$
+DECLARE
+    acc RECORD;
+    settlement_id INT;
+    txn_id INT;
+    entry_id INT;
+BEGIN
+    FOR acc IN SELECT id, balance, currency FROM accounts WHERE kind = 'customer' AND balance > 0 LOOP
+        SELECT id INTO settlement_id FROM accounts WHERE kind = 'settlement' AND currency = acc.currency;
+        IF settlement_id IS NULL THEN
+            RAISE EXCEPTION 'no settlement account for currency %', acc.currency;
+        END IF;
+
+        INSERT INTO transactions (from_account_id, to_account_id, amount, currency, kind)
+        VALUES (settlement_id, acc.id, acc.balance, acc.currency, 'opening_balance')
+        RETURNING id INTO txn_id;
+
+        INSERT INTO journal_entries (kind, transaction_id, description)
+        VALUES ('opening_balance', txn_id, 'Opening balance migrated from account balance')
+        RETURNING id INTO entry_id;
+
+        INSERT INTO postings (journal_entry_id, account_id, amount, currency) VALUES
+            (entry_id, acc.id, acc.balance, acc.currency),
+            (entry_id, settlement_id, -acc.balance, acc.currency);
+
+        UPDATE accounts SET balance = balance - acc.balance WHERE id = settlement_id;
+    END LOOP;
+END;
+$$;
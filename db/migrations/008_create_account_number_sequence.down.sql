@@ -0,0 +1,3 @@
+-- file: db/migrations/008_create_account_number_sequence.down.sql
+
+DROP SEQUENCE IF EXISTS account_number_seq;
@@ -0,0 +1,16 @@
+-- file: db/migrations/008_create_account_number_sequence.up.sql
+
+-- Account number bases are allocated atomically from a sequence instead of
+-- MAX(account_number) + 1, which raced between concurrent account creations.
+-- The application appends a Luhn check digit to each 10-digit base. Existing
+-- 10-digit numbers stay valid, and the sequence continues after the highest
+-- of them, so old and new numbers never share a base.
+CREATE SEQUENCE IF NOT EXISTS account_number_seq
+    MINVALUE 1000000000
+    MAXVALUE 9999999999
+    NO CYCLE;
+
+SELECT setval('account_number_seq', GREATEST(
+    (SELECT MAX(account_number) FROM accounts WHERE account_number BETWEEN 1000000000 AND 9999999999),
+    1000000000
+));
@@ -0,0 +1,3 @@
+-- file: db/migrations/009_create_payees_table.down.sql
+
+DROP TABLE IF EXISTS payees;
@@ -0,0 +1,25 @@
+-- file: db/migrations/009_create_payees_table.up.sql
+
+-- Saved beneficiaries a user transfers to repeatedly. Payees are addressed by
+-- the public account number; holder_name is the masked name the user was shown
+-- when saving the payee. A payee can only be used for transfers once the user
+-- has confirmed that name, which moves it from 'unverified' to 'verified'.
+CREATE TABLE IF NOT EXISTS payees (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    nickname VARCHAR(100) NOT NULL,
+    account_number BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    holder_name VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'unverified' CHECK (status IN ('unverified', 'verified')),
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_payees_user_account UNIQUE (user_id, account_number),
+
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- file: db/migrations/010_add_fx_transfers.down.sql
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS fx_quote_id,
+    DROP COLUMN IF EXISTS fx_spread_bps,
+    DROP COLUMN IF EXISTS fx_rate,
+    DROP COLUMN IF EXISTS fx_mid_rate,
+    DROP COLUMN IF EXISTS destination_currency,
+    DROP COLUMN IF EXISTS destination_amount;
+
+DROP TABLE IF EXISTS fx_quotes;
+
+DELETE FROM accounts WHERE kind = 'fx_position';
@@ -0,0 +1,45 @@
+-- file: db/migrations/010_add_fx_transfers.up.sql
+
+-- Cross-currency transfers move money through the bank's position account in
+-- each currency: the sender pays into the source currency position and the
+-- receiver is paid out of the target currency position.
+INSERT INTO accounts (user_id, account_number, currency, kind) VALUES
+    (NULL, 300, 'TRY', 'fx_position'),
+    (NULL, 301, 'USD', 'fx_position'),
+    (NULL, 302, 'EUR', 'fx_position'),
+    (NULL, 303, 'GBP', 'fx_position'),
+    (NULL, 304, 'CHF', 'fx_position'),
+    (NULL, 305, 'JPY', 'fx_position')
+ON CONFLICT (account_number) DO NOTHING;
+
+-- Quotes lock the price of a cross-currency transfer until expires_at. used_at
+-- is set by the transfer that consumes the quote.
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id INT NOT NULL,
+    source_amount NUMERIC(15, 2) NOT NULL CHECK (source_amount > 0),
+    source_currency VARCHAR(3) NOT NULL,
+    destination_amount NUMERIC(15, 2) NOT NULL CHECK (destination_amount > 0),
+    destination_currency VARCHAR(3) NOT NULL,
+    mid_rate NUMERIC(20, 8) NOT NULL,
+    rate NUMERIC(20, 8) NOT NULL,
+    spread_bps INT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- The transaction's amount and currency are the source side; these columns
+-- are only set for cross-currency transfers and their reversals.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS destination_amount NUMERIC(15, 2),
+    ADD COLUMN IF NOT EXISTS destination_currency VARCHAR(3),
+    ADD COLUMN IF NOT EXISTS fx_mid_rate NUMERIC(20, 8),
+    ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(20, 8),
+    ADD COLUMN IF NOT EXISTS fx_spread_bps INT,
+    ADD COLUMN IF NOT EXISTS fx_quote_id UUID REFERENCES fx_quotes(id) ON DELETE SET NULL;
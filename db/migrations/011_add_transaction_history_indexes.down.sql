@@ -0,0 +1,4 @@
+-- file: db/migrations/011_add_transaction_history_indexes.down.sql
+
+DROP INDEX IF EXISTS idx_transactions_to_account_created_at;
+DROP INDEX IF EXISTS idx_transactions_from_account_created_at;
@@ -0,0 +1,9 @@
+-- file: db/migrations/011_add_transaction_history_indexes.up.sql
+
+-- Account history is read newest first and paged on (created_at, id), from
+-- either side of the transaction.
+CREATE INDEX IF NOT EXISTS idx_transactions_from_account_created_at
+    ON transactions (from_account_id, created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_to_account_created_at
+    ON transactions (to_account_id, created_at DESC, id DESC);
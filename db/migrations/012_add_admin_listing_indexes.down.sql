@@ -0,0 +1,7 @@
+-- file: db/migrations/012_add_admin_listing_indexes.down.sql
+
+DROP INDEX IF EXISTS idx_accounts_currency_balance;
+DROP INDEX IF EXISTS idx_accounts_created_at;
+DROP INDEX IF EXISTS idx_users_lower_email;
+DROP INDEX IF EXISTS idx_users_lower_username;
+DROP INDEX IF EXISTS idx_users_created_at;
@@ -0,0 +1,11 @@
+-- file: db/migrations/012_add_admin_listing_indexes.up.sql
+
+-- The admin listings are paged on (created_at, id). Users are searched by a
+-- case-insensitive prefix of their username or email, which text_pattern_ops
+-- lets a LIKE 'prefix%' query use regardless of the database collation.
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_users_lower_username ON users (lower(username) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_lower_email ON users (lower(email) text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS idx_accounts_created_at ON accounts (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_accounts_currency_balance ON accounts (currency, balance);
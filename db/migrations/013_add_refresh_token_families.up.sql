@@ -0,0 +1,12 @@
+-- file: db/migrations/013_add_refresh_token_families.up.sql
+
+-- Refresh tokens are rotated on every use. All tokens descending from one
+-- login share a family_id; used_at marks a token that has been exchanged for
+-- its successor. Presenting a used token again means it was stolen, and the
+-- whole family is revoked.
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN IF NOT EXISTS used_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
@@ -0,0 +1,7 @@
+-- file: db/migrations/014_create_sessions_table.down.sql
+
+DROP INDEX IF EXISTS idx_refresh_tokens_session_id;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS session_id;
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,5 @@
+-- file: db/migrations/015_create_mfa_tables.down.sql
+
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+DROP TABLE IF EXISTS mfa_totp;
@@ -0,0 +1,5 @@
+-- file: db/migrations/016_create_user_tokens.down.sql
+
+DROP TABLE IF EXISTS user_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,3 @@
+-- file: db/migrations/017_create_login_events.down.sql
+
+DROP TABLE IF EXISTS login_events;
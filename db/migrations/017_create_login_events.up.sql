@@ -0,0 +1,26 @@
+-- file: db/migrations/017_create_login_events.up.sql
+
+-- Audit trail of failed logins, lockouts and unlocks. Failed logins are
+-- recorded for unknown email addresses too, so user_id is optional. actor_id
+-- is the admin who unlocked the account.
+CREATE TABLE IF NOT EXISTS login_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT,
+    email VARCHAR(255) NOT NULL,
+    event VARCHAR(32) NOT NULL CHECK (event IN ('login_failed', 'account_locked', 'account_unlocked')),
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    actor_id INT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user
+        FOREIGN KEY(user_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    CONSTRAINT fk_actor
+        FOREIGN KEY(actor_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_email_created_at ON login_events (email, created_at);
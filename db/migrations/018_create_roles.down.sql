@@ -0,0 +1,5 @@
+-- file: db/migrations/018_create_roles.down.sql
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_users_role;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
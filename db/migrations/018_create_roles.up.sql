@@ -0,0 +1,47 @@
+-- file: db/migrations/018_create_roles.up.sql
+
+-- Roles are named sets of permissions. Built-in roles keep the permissions
+-- granted here; admins define the others. Permission names are checked by the
+-- application, which knows which ones exist.
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    built_in BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (role, permission),
+
+    CONSTRAINT fk_role
+        FOREIGN KEY(role)
+        REFERENCES roles(name)
+        ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description, built_in) VALUES
+    ('admin', 'Full access to every admin operation', TRUE),
+    ('user', 'Customer without admin access', TRUE),
+    ('support', 'Support staff: read-only access to users and accounts', FALSE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'users:read'),
+    ('admin', 'users:role:write'),
+    ('admin', 'users:unlock'),
+    ('admin', 'accounts:read'),
+    ('admin', 'accounts:deposit'),
+    ('admin', 'accounts:fee'),
+    ('admin', 'transactions:reverse'),
+    ('admin', 'ledger:read'),
+    ('admin', 'roles:read'),
+    ('admin', 'roles:write'),
+    ('support', 'users:read'),
+    ('support', 'accounts:read')
+ON CONFLICT DO NOTHING;
+
+-- Every user's role must exist, and roles held by users cannot be deleted.
+ALTER TABLE users ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name);
@@ -0,0 +1,4 @@
+-- file: db/migrations/019_create_approval_requests.down.sql
+
+DELETE FROM role_permissions WHERE permission IN ('approvals:read', 'approvals:decide');
+DROP TABLE IF EXISTS approval_requests;
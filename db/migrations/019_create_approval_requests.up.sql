@@ -0,0 +1,41 @@
+-- file: db/migrations/019_create_approval_requests.up.sql
+
+-- Sensitive admin actions above the configured thresholds wait here until a
+-- second admin decides on them. Rows are never deleted, so the table is the
+-- history of every four-eyes decision. A pending request past expires_at is
+-- expired; the status column is only updated when someone decides.
+CREATE TABLE IF NOT EXISTS approval_requests (
+    id SERIAL PRIMARY KEY,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('deposit', 'role_change')),
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'executed', 'failed')),
+    maker_id INT NOT NULL,
+    checker_id INT,
+    comment VARCHAR(255) NOT NULL DEFAULT '',
+    failure TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_approval_maker
+        FOREIGN KEY(maker_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_approval_checker
+        FOREIGN KEY(checker_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    -- Four eyes: nobody decides on their own request.
+    CONSTRAINT chk_approval_checker CHECK (checker_id IS NULL OR checker_id <> maker_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_approval_requests_status ON approval_requests(status, created_at, id);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'approvals:read'),
+    ('admin', 'approvals:decide')
+ON CONFLICT DO NOTHING;
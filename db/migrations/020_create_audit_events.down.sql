@@ -0,0 +1,5 @@
+-- file: db/migrations/020_create_audit_events.down.sql
+
+DELETE FROM role_permissions WHERE permission = 'audit:read';
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS reject_audit_event_change();
@@ -0,0 +1,7 @@
+-- file: db/migrations/021_widen_mfa_totp_secret.down.sql
+
+-- Code from before this migration cannot read encrypted secrets, and they do
+-- not fit the narrower column. Their users lose two-factor authentication and
+-- have to enroll their authenticator again.
+DELETE FROM mfa_totp WHERE secret LIKE 'v1:%';
+ALTER TABLE mfa_totp ALTER COLUMN secret TYPE VARCHAR(64);
@@ -0,0 +1,5 @@
+-- file: db/migrations/021_widen_mfa_totp_secret.up.sql
+
+-- TOTP secrets are stored encrypted, which takes more room than the base32
+-- secret itself. Secrets stored before are encrypted when next read.
+ALTER TABLE mfa_totp ALTER COLUMN secret TYPE VARCHAR(255);
@@ -0,0 +1,13 @@
+-- file: db/migrations/022_restrict_approval_user_deletes.down.sql
+
+ALTER TABLE approval_requests
+    DROP CONSTRAINT fk_approval_maker,
+    DROP CONSTRAINT fk_approval_checker,
+    ADD CONSTRAINT fk_approval_maker
+        FOREIGN KEY(maker_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    ADD CONSTRAINT fk_approval_checker
+        FOREIGN KEY(checker_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE;
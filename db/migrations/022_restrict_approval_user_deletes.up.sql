@@ -0,0 +1,16 @@
+-- file: db/migrations/022_restrict_approval_user_deletes.up.sql
+
+-- Approval requests are the history of four-eyes decisions, so deleting a
+-- user must not delete the requests they made or decided. Users who took part
+-- in one can no longer be deleted.
+ALTER TABLE approval_requests
+    DROP CONSTRAINT fk_approval_maker,
+    DROP CONSTRAINT fk_approval_checker,
+    ADD CONSTRAINT fk_approval_maker
+        FOREIGN KEY(maker_id)
+        REFERENCES users(id)
+        ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_approval_checker
+        FOREIGN KEY(checker_id)
+        REFERENCES users(id)
+        ON DELETE RESTRICT;
@@ -0,0 +1,6 @@
+-- file: db/migrations/023_drop_approved_approval_status.down.sql
+
+ALTER TABLE approval_requests
+    DROP CONSTRAINT approval_requests_status_check,
+    ADD CONSTRAINT approval_requests_status_check
+        CHECK (status IN ('pending', 'approved', 'rejected', 'executed', 'failed'));
@@ -0,0 +1,15 @@
+-- file: db/migrations/023_drop_approved_approval_status.up.sql
+
+-- Approved requests are now carried out in the transaction that approves them,
+-- so no request stays approved. Any left over from before were interrupted
+-- while being carried out; nobody knows whether they took effect.
+UPDATE approval_requests
+SET status = 'failed',
+    failure = 'interrupted while being carried out; check whether it took effect before requesting it again',
+    updated_at = CURRENT_TIMESTAMP
+WHERE status = 'approved';
+
+ALTER TABLE approval_requests
+    DROP CONSTRAINT approval_requests_status_check,
+    ADD CONSTRAINT approval_requests_status_check
+        CHECK (status IN ('pending', 'rejected', 'executed', 'failed'));
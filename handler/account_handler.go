@@ -7,19 +7,22 @@ import (
 	"go-bank-api/model"
 	"go-bank-api/service"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
 // AccountHandler holds dependencies for account-related handlers.
 type AccountHandler struct {
-	service *service.AccountService
+	service   *service.AccountService
+	approvals *service.ApprovalService
 }
 
 // NewAccountHandler creates a new AccountHandler with its dependencies.
-func NewAccountHandler(service *service.AccountService) *AccountHandler {
-	return &AccountHandler{service: service}
+func NewAccountHandler(service *service.AccountService, approvals *service.ApprovalService) *AccountHandler {
+	return &AccountHandler{service: service, approvals: approvals}
 }
 
 // CreateAccount godoc
@@ -54,7 +57,7 @@ func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) *
 	})
 	log.Info("Create account request received")
 
-	account, err := h.service.CreateNewAccount(userID, req.Currency)
+	account, err := h.service.CreateNewAccount(r.Context(), userID, req.Currency)
 	if err != nil {
 		return common.NewAppError(http.StatusInternalServerError, "Could not create account", err)
 	}
@@ -85,7 +88,7 @@ func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) *c
 	log := logger.Log.WithField("user_id", userID)
 	log.Info("List user's own accounts request received")
 
-	accounts, err := h.service.ListAccountsForUser(userID)
+	accounts, err := h.service.ListAccountsForUser(r.Context(), userID)
 	if err != nil {
 		return common.NewAppError(http.StatusInternalServerError, "Could not retrieve accounts", err)
 	}
@@ -98,36 +101,107 @@ func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) *c
 }
 
 // GetAllAccounts godoc
-// @Summary      Get all accounts (Admin)
-// @Description  Retrieves a list of all bank accounts in the system. Admin access required.
+// @Summary      List accounts (Admin)
+// @Description  Retrieves one page of bank accounts, newest first by default, with the total number of matching accounts. Pass next_cursor from the response as cursor to fetch the following page. A balance range requires a currency. Requires the accounts:read permission.
 // @Tags         admin
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {array}   model.Account
+// @Param        user_id     query int    false "Only accounts owned by this user"
+// @Param        currency    query string false "Only accounts in this currency"
+// @Param        kind        query string false "customer, settlement, fee_income or fx_position"
+// @Param        min_balance query string false "Minimum balance, in the given currency"
+// @Param        max_balance query string false "Maximum balance, in the given currency"
+// @Param        from        query string false "Only accounts created at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param        to          query string false "Only accounts created before this time (RFC 3339), or on or before this date (YYYY-MM-DD)"
+// @Param        limit       query int    false "Page size (default 50, max 100)"
+// @Param        cursor      query string false "Cursor returned as next_cursor by the previous page"
+// @Param        sort        query string false "asc or desc (default)"
+// @Success      200  {object}  model.AccountPage
+// @Failure      400  {object}  common.AppError "Invalid query parameter"
 // @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
-// @Failure      403  {object}  common.AppError "Forbidden: User does not have admin privileges"
-// @Failure      500  {object}  common.AppError "Internal server error while retrieving all accounts"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      500  {object}  common.AppError "Internal server error while retrieving accounts"
 // @Router       /api/admin/accounts [get]
 func (h *AccountHandler) GetAllAccounts(w http.ResponseWriter, r *http.Request) *common.AppError {
 	adminID, _ := r.Context().Value(UserIDKey).(int)
 	log := logger.Log.WithField("admin_user_id", adminID)
-	log.Info("Admin request to list all accounts received")
+	log.Info("Admin request to list accounts received")
+
+	filter, appErr := parseAccountFilter(r.URL.Query())
+	if appErr != nil {
+		return appErr
+	}
 
-	accounts, err := h.service.GetAllAccounts()
+	page, err := h.service.ListAccounts(r.Context(), filter)
 	if err != nil {
-		return common.NewAppError(http.StatusInternalServerError, "Could not retrieve all accounts", err)
+		switch err {
+		case service.ErrInvalidAccountKind, service.ErrBalanceNeedsCurrency, service.ErrInvalidAmountRange, service.ErrInvalidDateRange,
+			model.ErrUnsupportedCurrency:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not retrieve accounts", err)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(accounts)
+	json.NewEncoder(w).Encode(page)
 
 	return nil
 }
 
+// parseAccountFilter reads the filters of the admin account listing from the
+// query string. Balances are read in the currency being filtered on.
+func parseAccountFilter(params url.Values) (model.AccountFilter, *common.AppError) {
+	filter := model.AccountFilter{
+		Currency: strings.ToUpper(params.Get("currency")),
+		Kind:     params.Get("kind"),
+	}
+	if v := params.Get("user_id"); v != "" {
+		userID, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, common.NewAppError(http.StatusBadRequest, "user_id must be an integer", err)
+		}
+		filter.UserID = userID
+	}
+
+	for _, bound := range []struct {
+		name string
+		dest **model.Money
+	}{{"min_balance", &filter.MinBalance}, {"max_balance", &filter.MaxBalance}} {
+		v := params.Get(bound.name)
+		if v == "" {
+			continue
+		}
+		if filter.Currency == "" {
+			return filter, common.NewAppError(http.StatusBadRequest, service.ErrBalanceNeedsCurrency.Error(), service.ErrBalanceNeedsCurrency)
+		}
+		balance, err := model.Amount(v).In(filter.Currency)
+		if err != nil {
+			return filter, common.NewAppError(http.StatusBadRequest, bound.name+": "+err.Error(), err)
+		}
+		*bound.dest = &balance
+	}
+
+	var appErr *common.AppError
+	if filter.Limit, appErr = parseLimit(params); appErr != nil {
+		return filter, appErr
+	}
+	if filter.After, appErr = parseCursor(params); appErr != nil {
+		return filter, appErr
+	}
+	if filter.From, filter.To, appErr = parseTimeRange(params); appErr != nil {
+		return filter, appErr
+	}
+	if filter.Ascending, appErr = parseSortOrder(params); appErr != nil {
+		return filter, appErr
+	}
+	return filter, nil
+}
+
 // DepositToAccount godoc
 // @Summary      Deposit funds into an account (Admin)
-// @Description  Deposits a specified amount into a user's account. Admin access is required.
+// @Description  Deposits a specified amount into a user's account. Deposits above the approval threshold for the account's currency are not made at once: they are stored as a pending approval request, which another admin has to approve. Requires the accounts:deposit permission.
 // @Tags         admin
 // @Accept       json
 // @Produce      json
@@ -135,9 +209,10 @@ func (h *AccountHandler) GetAllAccounts(w http.ResponseWriter, r *http.Request)
 // @Param        accountId path int true "Account ID to deposit funds into"
 // @Param        request body model.DepositRequest true "Deposit Amount"
 // @Success      200  {object}  model.Account "The updated account details"
-// @Failure      400  {object}  common.AppError "Invalid account ID or request body"
+// @Success      202  {object}  model.ApprovalRequest "The deposit awaits approval"
+// @Failure      400  {object}  common.AppError "Invalid account ID, request body or amount precision"
 // @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
-// @Failure      403  {object}  common.AppError "Forbidden: User does not have admin privileges"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
 // @Failure      404  {object}  common.AppError "Account with the specified ID not found"
 // @Failure      500  {object}  common.AppError "Internal server error while processing the deposit"
 // @Router       /api/admin/accounts/{accountId}/deposit [post]
@@ -148,6 +223,10 @@ func (h *AccountHandler) DepositToAccount(w http.ResponseWriter, r *http.Request
 	if err != nil {
 		return common.NewAppError(http.StatusBadRequest, "Invalid account ID in URL path", err)
 	}
+	adminID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
 
 	// Decode and validate the request body.
 	var req model.DepositRequest
@@ -158,22 +237,33 @@ func (h *AccountHandler) DepositToAccount(w http.ResponseWriter, r *http.Request
 	log := logger.Log.WithFields(logrus.Fields{
 		"target_account_id": accountID,
 		"amount":            req.Amount,
-		"admin_user_id":     r.Context().Value(UserIDKey),
+		"admin_user_id":     adminID,
 	})
 	log.Info("Admin deposit request received")
 
-	// Call the service to perform the deposit.
-	updatedAccount, err := h.service.DepositToAccount(accountID, req.Amount)
+	// Call the service to perform the deposit, or to ask for its approval.
+	updatedAccount, approval, err := h.approvals.Deposit(r.Context(), adminID, accountID, req.Amount)
 	if err != nil {
 		// Map service-level errors to appropriate HTTP status codes.
-		if err.Error() == "account not found" {
+		switch err {
+		case service.ErrAccountNotFound:
 			return common.NewAppError(http.StatusNotFound, err.Error(), err)
+		case service.ErrInvalidDepositAmount, model.ErrInvalidAmountFormat, model.ErrExcessPrecision, model.ErrAmountOutOfRange:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not process deposit", err)
 		}
-		return common.NewAppError(http.StatusInternalServerError, "Could not process deposit", err)
 	}
 
-	log.Info("Deposit successful")
 	w.Header().Set("Content-Type", "application/json")
+	if approval != nil {
+		log.WithField("approval_request_id", approval.ID).Info("Deposit awaits approval")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(approval)
+		return nil
+	}
+
+	log.Info("Deposit successful")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedAccount)
 
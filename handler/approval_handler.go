@@ -0,0 +1,213 @@
+// file: handler/approval_handler.go
+
+package handler
+
+import (
+	"encoding/json"
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+	"strconv"
+)
+
+// ApprovalHandler holds dependencies for the approval request handlers.
+type ApprovalHandler struct {
+	service *service.ApprovalService
+}
+
+// NewApprovalHandler creates a new ApprovalHandler with its dependencies.
+func NewApprovalHandler(s *service.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{service: s}
+}
+
+// approvalError maps approval service errors to HTTP errors.
+func approvalError(err error, fallback string) *common.AppError {
+	switch err {
+	case service.ErrApprovalNotFound:
+		return common.NewAppError(http.StatusNotFound, err.Error(), err)
+	case service.ErrApprovalNotPending, service.ErrApprovalExpired:
+		return common.NewAppError(http.StatusConflict, err.Error(), err)
+	case service.ErrSelfApproval, service.ErrApprovalForbidden, service.ErrPermissionEscalation:
+		return common.NewAppError(http.StatusForbidden, err.Error(), err)
+	case service.ErrInvalidApprovalStatus, service.ErrInvalidApprovalAction:
+		return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+	default:
+		return common.NewAppError(http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// parseApprovalID reads the approval request ID from the URL path.
+func parseApprovalID(r *http.Request) (int, *common.AppError) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, common.NewAppError(http.StatusBadRequest, "Invalid approval request ID in URL path", err)
+	}
+	return id, nil
+}
+
+// ListApprovalRequests godoc
+// @Summary      List approval requests
+// @Description  Retrieves one page of requests for approval, newest first by default, with the total number of matching requests. Pass next_cursor from the response as cursor to fetch the following page. Requires the approvals:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status   query string false "pending, rejected, expired, executed or failed"
+// @Param        action   query string false "deposit or role_change"
+// @Param        maker_id query int    false "Only requests made by this admin"
+// @Param        limit    query int    false "Page size (default 50, max 100)"
+// @Param        cursor   query string false "Cursor returned as next_cursor by the previous page"
+// @Param        sort     query string false "asc or desc (default)"
+// @Success      200  {object}  model.ApprovalPage
+// @Failure      400  {object}  common.AppError "Invalid query parameter"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/admin/approvals [get]
+func (h *ApprovalHandler) ListApprovalRequests(w http.ResponseWriter, r *http.Request) *common.AppError {
+	params := r.URL.Query()
+	filter := model.ApprovalFilter{Status: params.Get("status"), Action: params.Get("action")}
+	if v := params.Get("maker_id"); v != "" {
+		makerID, err := strconv.Atoi(v)
+		if err != nil || makerID <= 0 {
+			return common.NewAppError(http.StatusBadRequest, "maker_id must be a positive integer", err)
+		}
+		filter.MakerID = makerID
+	}
+	var appErr *common.AppError
+	if filter.Limit, appErr = parseLimit(params); appErr != nil {
+		return appErr
+	}
+	if filter.After, appErr = parseCursor(params); appErr != nil {
+		return appErr
+	}
+	if filter.Ascending, appErr = parseSortOrder(params); appErr != nil {
+		return appErr
+	}
+
+	page, err := h.service.ListApprovalRequests(r.Context(), filter)
+	if err != nil {
+		return approvalError(err, "Could not retrieve approval requests")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+	return nil
+}
+
+// GetApprovalRequest godoc
+// @Summary      Get an approval request
+// @Description  Retrieves a request for approval with its decision and outcome. Requires the approvals:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "Approval request ID"
+// @Success      200  {object}  model.ApprovalRequest
+// @Failure      400  {object}  common.AppError "Invalid approval request ID"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      404  {object}  common.AppError "Approval request not found"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/admin/approvals/{id} [get]
+func (h *ApprovalHandler) GetApprovalRequest(w http.ResponseWriter, r *http.Request) *common.AppError {
+	id, appErr := parseApprovalID(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	request, err := h.service.GetApprovalRequest(r.Context(), id)
+	if err != nil {
+		return approvalError(err, "Could not retrieve approval request")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(request)
+	return nil
+}
+
+// Approve godoc
+// @Summary      Approve a request
+// @Description  Approves a pending request another admin made and carries it out on the approver's authority. If carrying it out fails, the request is returned with status failed and the reason. Requires the approvals:decide permission and the permission the action itself requires.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path int                  true "Approval request ID"
+// @Param        request body model.ApproveRequest true "Optional comment"
+// @Success      200  {object}  model.ApprovalRequest "The decided request, executed or failed"
+// @Failure      400  {object}  common.AppError "Invalid approval request ID or request body"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Missing permission, or the request is the caller's own"
+// @Failure      404  {object}  common.AppError "Approval request not found"
+// @Failure      409  {object}  common.AppError "Approval request already decided or expired"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/admin/approvals/{id}/approve [post]
+func (h *ApprovalHandler) Approve(w http.ResponseWriter, r *http.Request) *common.AppError {
+	id, appErr := parseApprovalID(r)
+	if appErr != nil {
+		return appErr
+	}
+	checkerID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+	var req model.ApproveRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	checkerRole, _ := r.Context().Value(UserRoleKey).(string)
+	request, err := h.service.Approve(r.Context(), checkerID, checkerRole, id, req.Comment)
+	if err != nil {
+		return approvalError(err, "Could not approve request")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(request)
+	return nil
+}
+
+// Reject godoc
+// @Summary      Reject a request
+// @Description  Rejects a pending request another admin made, giving the reason. Requires the approvals:decide permission.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path int                 true "Approval request ID"
+// @Param        request body model.RejectRequest true "Reason for the rejection"
+// @Success      200  {object}  model.ApprovalRequest "The rejected request"
+// @Failure      400  {object}  common.AppError "Invalid approval request ID or request body"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Missing permission, or the request is the caller's own"
+// @Failure      404  {object}  common.AppError "Approval request not found"
+// @Failure      409  {object}  common.AppError "Approval request already decided or expired"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/admin/approvals/{id}/reject [post]
+func (h *ApprovalHandler) Reject(w http.ResponseWriter, r *http.Request) *common.AppError {
+	id, appErr := parseApprovalID(r)
+	if appErr != nil {
+		return appErr
+	}
+	checkerID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+	var req model.RejectRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	request, err := h.service.Reject(r.Context(), checkerID, id, req.Comment)
+	if err != nil {
+		return approvalError(err, "Could not reject request")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(request)
+	return nil
+}
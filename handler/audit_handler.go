@@ -0,0 +1,110 @@
+// file: handler/audit_handler.go
+
+package handler
+
+import (
+	"encoding/json"
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+	"strconv"
+)
+
+// AuditHandler holds dependencies for the audit log handlers.
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+// NewAuditHandler creates a new AuditHandler with its dependencies.
+func NewAuditHandler(s *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: s}
+}
+
+// ListAuditEvents godoc
+// @Summary      Search the audit log
+// @Description  Retrieves one page of audit events, newest first by default, with the total number of matching events. Pass next_cursor from the response as cursor to fetch the following page. Requires the audit:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        event       query string false "login, login_failed, logout, role_changed, deposit, transfer, fee, reversal or account_created"
+// @Param        actor_id    query int    false "Only events caused by this user"
+// @Param        target_type query string false "user, account or transaction"
+// @Param        target_id   query string false "Only events about the target with this ID"
+// @Param        request_id  query string false "Only events of the request with this X-Request-ID"
+// @Param        from        query string false "Only events at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param        to          query string false "Only events before this time (RFC 3339), or on or before this date (YYYY-MM-DD)"
+// @Param        limit       query int    false "Page size (default 50, max 100)"
+// @Param        cursor      query string false "Cursor returned as next_cursor by the previous page"
+// @Param        sort        query string false "asc or desc (default)"
+// @Success      200  {object}  model.AuditPage
+// @Failure      400  {object}  common.AppError "Invalid query parameter"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/admin/audit [get]
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) *common.AppError {
+	params := r.URL.Query()
+	filter := model.AuditFilter{
+		Event:      params.Get("event"),
+		TargetType: params.Get("target_type"),
+		TargetID:   params.Get("target_id"),
+		RequestID:  params.Get("request_id"),
+	}
+	if v := params.Get("actor_id"); v != "" {
+		actorID, err := strconv.Atoi(v)
+		if err != nil || actorID <= 0 {
+			return common.NewAppError(http.StatusBadRequest, "actor_id must be a positive integer", err)
+		}
+		filter.ActorID = actorID
+	}
+	var appErr *common.AppError
+	if filter.Limit, appErr = parseLimit(params); appErr != nil {
+		return appErr
+	}
+	if filter.After, appErr = parseCursor(params); appErr != nil {
+		return appErr
+	}
+	if filter.From, filter.To, appErr = parseTimeRange(params); appErr != nil {
+		return appErr
+	}
+	if filter.Ascending, appErr = parseSortOrder(params); appErr != nil {
+		return appErr
+	}
+
+	page, err := h.service.ListEvents(r.Context(), filter)
+	if err != nil {
+		if err == service.ErrInvalidDateRange {
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		}
+		return common.NewAppError(http.StatusInternalServerError, "Could not retrieve audit events", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+	return nil
+}
+
+// VerifyAuditLog godoc
+// @Summary      Verify the audit log
+// @Description  Checks the hash chain of the audit log and reports the first event that was changed, inserted or removed, if any. Requires the audit:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  model.AuditReport
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      500  {object}  common.AppError "Internal server error while verifying the audit log"
+// @Router       /api/admin/audit/verify [get]
+func (h *AuditHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) *common.AppError {
+	report, err := h.service.VerifyChain(r.Context())
+	if err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not verify audit log", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+	return nil
+}
@@ -3,68 +3,114 @@ package handler
 import (
 	"context"
 	"go-bank-api/common"
-	"go-bank-api/config"
 	"go-bank-api/model"
+	"go-bank-api/service"
 	"net/http"
 	"strings"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // contextKey defines a custom type for context keys to avoid collisions.
 type contextKey string
 
 const (
-	UserIDKey   contextKey = "userID"
-	UserRoleKey contextKey = "userRole"
+	UserIDKey    contextKey = "userID"
+	UserRoleKey  contextKey = "userRole"
+	SessionIDKey contextKey = "sessionID"
+	ClaimsKey    contextKey = "claims"
 )
 
-// AuthMiddleware validates the JWT from the Authorization header.
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			err := common.NewAppError(http.StatusUnauthorized, "Authorization header is required", nil)
-			err.Send(w)
-			return
-		}
+// AuthMiddleware validates the JWT from the Authorization header against the
+// keyring, which picks the verification key by the token's kid, and rejects
+// tokens revoked through the denylist. If the denylist cannot be checked the
+// request is refused rather than let through with a possibly revoked token.
+func AuthMiddleware(keys *service.KeyRing, denylist service.ITokenDenylist) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				err := common.NewAppError(http.StatusUnauthorized, "Authorization header is required", nil)
+				err.Send(w)
+				return
+			}
 
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || strings.ToLower(headerParts[0]) != "bearer" {
-			err := common.NewAppError(http.StatusUnauthorized, "Invalid authorization header format", nil)
-			err.Send(w)
-			return
-		}
+			headerParts := strings.Split(authHeader, " ")
+			if len(headerParts) != 2 || strings.ToLower(headerParts[0]) != "bearer" {
+				err := common.NewAppError(http.StatusUnauthorized, "Invalid authorization header format", nil)
+				err.Send(w)
+				return
+			}
 
-		tokenString := headerParts[1]
-		claims := &model.AppClaims{}
-		jwtKey := []byte(config.AppConfig.JWT.SecretKey)
+			tokenString := headerParts[1]
+			claims := &model.AppClaims{}
+			token, err := keys.Parse(tokenString, claims)
+			if err != nil || !token.Valid {
+				appErr := common.NewAppError(http.StatusUnauthorized, "Invalid or expired token", err)
+				appErr.Send(w)
+				return
+			}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
+			revoked, err := denylist.IsRevoked(r.Context(), claims)
+			if err != nil {
+				appErr := common.NewAppError(http.StatusServiceUnavailable, "Could not verify token, please try again later", err)
+				appErr.Send(w)
+				return
+			}
+			if revoked {
+				appErr := common.NewAppError(http.StatusUnauthorized, "Token has been revoked", nil)
+				appErr.Send(w)
+				return
+			}
+
+			// If the token is valid, store user info in the request context.
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, UserRoleKey, claims.Role)
+			ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
+			ctx = context.WithValue(ctx, ClaimsKey, claims)
+			// The audit log attributes what the request does to the user.
+			info := service.RequestInfoFrom(ctx)
+			info.ActorID = claims.UserID
+			ctx = service.WithRequestInfo(ctx, info)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
+	}
+}
 
-		if err != nil || !token.Valid {
-			appErr := common.NewAppError(http.StatusUnauthorized, "Invalid or expired token", err)
-			appErr.Send(w)
-			return
-		}
+// RequirePermission refuses requests from users whose role does not grant
+// every one of the permissions. It must run after AuthMiddleware. Permissions
+// are looked up on each request rather than read from the token, so changes
+// to a role apply at once.
+func RequirePermission(checker service.IPermissionChecker, permissions ...model.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(UserRoleKey).(string)
 
-		// If the token is valid, store user info in the request context.
-		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, UserRoleKey, claims.Role)
+			allowed, err := checker.HasPermissions(r.Context(), role, permissions...)
+			if err != nil {
+				appErr := common.NewAppError(http.StatusInternalServerError, "Could not check permissions", err)
+				appErr.Send(w)
+				return
+			}
+			if !allowed {
+				appErr := common.NewAppError(http.StatusForbidden, "Access denied. Missing permission.", nil)
+				appErr.Send(w)
+				return
+			}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
-// AdminMiddleware checks if the user has admin privileges.
-func AdminMiddleware(next http.Handler) http.Handler {
+// VerifiedEmailMiddleware refuses requests from users who had not verified
+// their email address when their access token was issued. It must run after
+// AuthMiddleware.
+func VerifiedEmailMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		role, ok := r.Context().Value(UserRoleKey).(string)
+		claims, ok := r.Context().Value(ClaimsKey).(*model.AppClaims)
 
-		if !ok || role != string(model.RoleAdmin) { // Using the constant for safety
-			err := common.NewAppError(http.StatusForbidden, "Access denied. Admin privileges required.", nil)
+		if !ok || !claims.EmailVerified {
+			err := common.NewAppError(http.StatusForbidden, "Please verify your email address first. If you already have, refresh your access token.", nil)
 			err.Send(w)
 			return
 		}
@@ -0,0 +1,65 @@
+// file: handler/fx_handler.go
+
+package handler
+
+import (
+	"encoding/json"
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+)
+
+// FXHandler holds dependencies for foreign exchange handlers.
+type FXHandler struct {
+	service *service.FXService
+}
+
+// NewFXHandler creates a new FXHandler with its dependencies.
+func NewFXHandler(s *service.FXService) *FXHandler {
+	return &FXHandler{service: s}
+}
+
+// CreateQuote godoc
+// @Summary      Quote a cross-currency transfer
+// @Description  Prices the conversion of an amount into another currency and locks the rate for a short time. Pass the returned id as quote_id when creating the transfer.
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        quote body model.FXQuoteRequest true "Source currency, target currency and amount"
+// @Success      201  {object}  model.FXQuote
+// @Failure      400  {object}  common.AppError "Invalid request body, currency or amount"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      422  {object}  common.AppError "No exchange rate available for this currency pair"
+// @Failure      500  {object}  common.AppError "Internal server error while pricing the conversion"
+// @Router       /api/fx/quotes [post]
+func (h *FXHandler) CreateQuote(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+
+	var req model.FXQuoteRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	quote, err := h.service.CreateQuote(r.Context(), userID, req)
+	if err != nil {
+		switch err {
+		case service.ErrRateUnavailable:
+			return common.NewAppError(http.StatusUnprocessableEntity, err.Error(), err)
+		case service.ErrSameCurrencyQuote, service.ErrInvalidAmount, service.ErrAmountTooSmall,
+			model.ErrUnsupportedCurrency, model.ErrInvalidAmountFormat, model.ErrExcessPrecision, model.ErrAmountOutOfRange:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not create quote", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quote)
+	return nil
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"encoding/json"
+	"go-bank-api/model"
+	"go-bank-api/service"
 	"net/http"
 )
 
@@ -18,3 +20,48 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "API is healthy and running"})
 }
+
+// HealthHandler holds dependencies for the liveness and readiness probes.
+type HealthHandler struct {
+	service *service.HealthService
+}
+
+// NewHealthHandler creates a new HealthHandler with its dependencies.
+func NewHealthHandler(s *service.HealthService) *HealthHandler {
+	return &HealthHandler{service: s}
+}
+
+// Live godoc
+// @Summary      Liveness probe
+// @Description  Reports that the process is running and serving HTTP. It does not check any dependency, so a failing database does not get the API restarted.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /health/live [get]
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+}
+
+// Ready godoc
+// @Summary      Readiness probe
+// @Description  Checks that Postgres and Redis answer and that the database schema is migrated to the version the API expects, each within a timeout, and reports the status and latency of every check. Responds 503 if any check fails or the API is shutting down.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  model.ReadinessReport "Ready to serve requests"
+// @Failure      503  {object}  model.ReadinessReport "A dependency is down or the API is shutting down"
+// @Router       /health/ready [get]
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.service.Ready(r.Context())
+
+	status := http.StatusOK
+	if report.Status != model.HealthReady {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,157 @@
+// file: handler/idempotency_middleware.go
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go-bank-api/common"
+	"go-bank-api/logger"
+	"go-bank-api/service"
+	"io"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// idempotencyRecorder passes a response through to the client while keeping a
+// copy of its status code and body so it can be stored for replays.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware makes the wrapped handler honour the Idempotency-Key header.
+// The first response for a given user and key is stored; retries with the same
+// request body receive that response again, and reusing the key with a different
+// body is rejected with 422. Requests without the header are passed through.
+// It must run after AuthMiddleware, as keys are scoped to the authenticated user.
+func IdempotencyMiddleware(idempotencyService *service.IdempotencyService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				common.NewAppError(http.StatusBadRequest, "Idempotency-Key must be at most 255 characters", nil).Send(w)
+				return
+			}
+
+			userID, ok := r.Context().Value(UserIDKey).(int)
+			if !ok {
+				common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil).Send(w)
+				return
+			}
+
+			// The whole body is fingerprinted, so a larger one is refused
+			// rather than cut short.
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentRequestBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					common.NewAppError(http.StatusRequestEntityTooLarge, "Request body must be at most 1 MB", err).Send(w)
+					return
+				}
+				common.NewAppError(http.StatusBadRequest, "Invalid request body", err).Send(w)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			log := logger.Log.WithFields(logrus.Fields{
+				"user_id":         userID,
+				"idempotency_key": key,
+				"path":            r.URL.Path,
+			})
+
+			record, err := idempotencyService.Begin(r.Context(), userID, key, requestFingerprint(r, body))
+			if err != nil {
+				switch err {
+				case service.ErrIdempotencyKeyReused:
+					common.NewAppError(http.StatusUnprocessableEntity, err.Error(), err).Send(w)
+				case service.ErrIdempotencyKeyInFlight:
+					common.NewAppError(http.StatusConflict, err.Error(), err).Send(w)
+				default:
+					common.NewAppError(http.StatusInternalServerError, "Could not process idempotency key", err).Send(w)
+				}
+				return
+			}
+
+			if record.Completed() {
+				log.Info("Replaying stored response for idempotent request")
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+				return
+			}
+
+			// The outcome must be recorded even if the client has gone away meanwhile.
+			ctx := context.WithoutCancel(r.Context())
+			// The key is released if the handler fails with a server error or
+			// panics, so that the client can retry at once. Once the handler has
+			// succeeded it is kept, so a retry cannot repeat the request; if its
+			// response cannot be stored, the claim runs out on its own.
+			release := true
+			defer func() {
+				if !release {
+					return
+				}
+				if err := idempotencyService.Release(ctx, record); err != nil {
+					log.WithError(err).Error("Failed to release idempotency key after server error")
+				}
+			}()
+
+			rec := &idempotencyRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.statusCode == 0 {
+				rec.statusCode = http.StatusOK
+			}
+			// Server errors are not stored so that the client can safely retry.
+			if rec.statusCode >= http.StatusInternalServerError {
+				return
+			}
+			release = false
+			if err := idempotencyService.Complete(ctx, record, rec.statusCode, rec.body.Bytes()); err != nil {
+				log.WithError(err).Error("Failed to store idempotent response")
+			}
+		})
+	}
+}
+
+// requestFingerprint identifies the request a key was first used with.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
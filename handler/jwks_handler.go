@@ -0,0 +1,36 @@
+// file: handler/jwks_handler.go
+
+package handler
+
+import (
+	"encoding/json"
+	"go-bank-api/common"
+	"go-bank-api/service"
+	"net/http"
+)
+
+// JWKSHandler publishes the public keys access tokens are signed with.
+type JWKSHandler struct {
+	keys *service.KeyRing
+}
+
+// NewJWKSHandler creates a new JWKSHandler.
+func NewJWKSHandler(keys *service.KeyRing) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  Lists the public keys that verify access tokens, identified by the kid in the token header. Keys scheduled for an upcoming rotation are listed before they sign, and replaced keys until the tokens they signed have expired.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  model.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) *common.AppError {
+	w.Header().Set("Content-Type", "application/json")
+	// Verifiers may cache the set briefly; new keys are published well before they sign.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+	return nil
+}
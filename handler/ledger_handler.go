@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"go-bank-api/common"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LedgerHandler holds dependencies for ledger administration handlers.
+type LedgerHandler struct {
+	service *service.LedgerService
+}
+
+// NewLedgerHandler creates a new LedgerHandler with its dependencies.
+func NewLedgerHandler(s *service.LedgerService) *LedgerHandler {
+	return &LedgerHandler{service: s}
+}
+
+// ChargeFee godoc
+// @Summary      Charge a fee to an account (Admin)
+// @Description  Moves a fee from a customer account into the bank's fee income account. Requires the accounts:fee permission.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        accountId path int true "Account ID to charge the fee to"
+// @Param        request body model.FeeRequest true "Fee amount and description"
+// @Success      201  {object}  model.Transaction "The fee transaction"
+// @Failure      400  {object}  common.AppError "Invalid account ID, request body, amount or insufficient funds"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      404  {object}  common.AppError "Account with the specified ID not found"
+// @Failure      500  {object}  common.AppError "Internal server error while charging the fee"
+// @Router       /api/admin/accounts/{accountId}/fees [post]
+func (h *LedgerHandler) ChargeFee(w http.ResponseWriter, r *http.Request) *common.AppError {
+	accountID, err := strconv.Atoi(r.PathValue("accountId"))
+	if err != nil {
+		return common.NewAppError(http.StatusBadRequest, "Invalid account ID in URL path", err)
+	}
+
+	var req model.FeeRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	log := logger.Log.WithFields(logrus.Fields{
+		"target_account_id": accountID,
+		"amount":            req.Amount,
+		"admin_user_id":     r.Context().Value(UserIDKey),
+	})
+	log.Info("Admin fee request received")
+
+	transaction, err := h.service.ChargeFee(r.Context(), accountID, req.Amount, req.Description)
+	if err != nil {
+		switch err {
+		case service.ErrAccountNotFound:
+			return common.NewAppError(http.StatusNotFound, err.Error(), err)
+		case service.ErrInvalidFeeAmount, service.ErrInsufficientFunds,
+			model.ErrInvalidAmountFormat, model.ErrExcessPrecision, model.ErrAmountOutOfRange:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not charge fee", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transaction)
+	return nil
+}
+
+// ReverseTransaction godoc
+// @Summary      Reverse a transaction (Admin)
+// @Description  Posts the opposite of a transfer, deposit or fee. A transaction can only be reversed once. Requires the transactions:reverse permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        transactionId path int true "ID of the transaction to reverse"
+// @Success      201  {object}  model.Transaction "The reversal transaction"
+// @Failure      400  {object}  common.AppError "Invalid transaction ID or insufficient funds to reverse"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      404  {object}  common.AppError "Transaction not found"
+// @Failure      409  {object}  common.AppError "Transaction already reversed or not reversible"
+// @Failure      500  {object}  common.AppError "Internal server error while reversing the transaction"
+// @Router       /api/admin/transactions/{transactionId}/reversal [post]
+func (h *LedgerHandler) ReverseTransaction(w http.ResponseWriter, r *http.Request) *common.AppError {
+	transactionID, err := strconv.Atoi(r.PathValue("transactionId"))
+	if err != nil {
+		return common.NewAppError(http.StatusBadRequest, "Invalid transaction ID in URL path", err)
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"transaction_id": transactionID,
+		"admin_user_id":  r.Context().Value(UserIDKey),
+	}).Info("Admin reversal request received")
+
+	reversal, err := h.service.ReverseTransaction(r.Context(), transactionID)
+	if err != nil {
+		switch err {
+		case service.ErrTransactionNotFound:
+			return common.NewAppError(http.StatusNotFound, err.Error(), err)
+		case service.ErrTransactionAlreadyReversed, service.ErrTransactionNotReversible:
+			return common.NewAppError(http.StatusConflict, err.Error(), err)
+		case service.ErrInsufficientFunds:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not reverse transaction", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reversal)
+	return nil
+}
+
+// VerifyLedger godoc
+// @Summary      Verify the ledger (Admin)
+// @Description  Checks that every account balance equals the sum of its postings and that every journal entry is balanced. Requires the ledger:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  model.LedgerReport
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      500  {object}  common.AppError "Internal server error while verifying the ledger"
+// @Router       /api/admin/ledger/verify [get]
+func (h *LedgerHandler) VerifyLedger(w http.ResponseWriter, r *http.Request) *common.AppError {
+	report, err := h.service.VerifyLedger(r.Context())
+	if err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not verify ledger", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+	return nil
+}
@@ -0,0 +1,79 @@
+// file: handler/listing.go
+
+package handler
+
+import (
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// parseLimit reads the optional page size from the limit query parameter.
+func parseLimit(params url.Values) (int, *common.AppError) {
+	v := params.Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return 0, common.NewAppError(http.StatusBadRequest, "limit must be a positive integer", err)
+	}
+	return limit, nil
+}
+
+// parseCursor decodes the optional cursor query parameter.
+func parseCursor(params url.Values) (*model.Cursor, *common.AppError) {
+	v := params.Get("cursor")
+	if v == "" {
+		return nil, nil
+	}
+	cursor, err := model.DecodeCursor(v)
+	if err != nil {
+		return nil, common.NewAppError(http.StatusBadRequest, err.Error(), err)
+	}
+	return &cursor, nil
+}
+
+// parseTimeRange reads the optional from and to query parameters. Each is an
+// RFC 3339 timestamp or a YYYY-MM-DD date; a date as the upper bound includes
+// the whole day.
+func parseTimeRange(params url.Values) (from, to *time.Time, appErr *common.AppError) {
+	for _, bound := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := params.Get(bound.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			day, dayErr := time.Parse(time.DateOnly, v)
+			if dayErr != nil {
+				return nil, nil, common.NewAppError(http.StatusBadRequest, bound.name+" must be an RFC 3339 timestamp or a YYYY-MM-DD date", err)
+			}
+			if bound.name == "to" {
+				day = day.AddDate(0, 0, 1)
+			}
+			t = day
+		}
+		*bound.dest = &t
+	}
+	return from, to, nil
+}
+
+// parseSortOrder reads the optional sort query parameter, asc or desc
+// (the default), and reports whether the listing is in ascending order.
+func parseSortOrder(params url.Values) (bool, *common.AppError) {
+	switch params.Get("sort") {
+	case "", "desc":
+		return false, nil
+	case "asc":
+		return true, nil
+	default:
+		return false, common.NewAppError(http.StatusBadRequest, "sort must be asc or desc", nil)
+	}
+}
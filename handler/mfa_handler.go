@@ -0,0 +1,105 @@
+// file: handler/mfa_handler.go
+
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+)
+
+// MFAHandler holds dependencies for two-factor authentication handlers.
+type MFAHandler struct {
+	mfaService *service.MFAService
+}
+
+// NewMFAHandler creates a new MFAHandler.
+func NewMFAHandler(mfaService *service.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+// EnrollTOTP godoc
+// @Summary      Start two-factor authentication enrollment
+// @Description  Generates a new TOTP secret for the authenticated user and returns it with an otpauth URI to scan into an authenticator app. Two-factor authentication is only turned on once the enrollment is confirmed with a code. Starting again replaces an unconfirmed enrollment.
+// @Tags         mfa
+// @Produce      json
+// @Security     BearerAuth
+// @Success      201  {object}  model.TOTPEnrollment
+// @Failure      401  {object}  common.AppError "Unauthorized"
+// @Failure      409  {object}  common.AppError "Two-factor authentication is already enabled"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/mfa/totp [post]
+func (h *MFAHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+
+	enrollment, err := h.mfaService.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case service.ErrMFAAlreadyEnabled:
+			return common.NewAppError(http.StatusConflict, err.Error(), err)
+		case sql.ErrNoRows:
+			return common.NewAppError(http.StatusUnauthorized, "User not found", err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not start two-factor enrollment", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(enrollment)
+	return nil
+}
+
+// ConfirmTOTP godoc
+// @Summary      Confirm two-factor authentication enrollment
+// @Description  Turns on two-factor authentication once a code from the newly enrolled authenticator app is presented, and returns recovery codes. The recovery codes are shown only this once; each can replace an authenticator code at login once.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.MFACodeRequest true "Code from the authenticator app"
+// @Success      200  {object}  model.RecoveryCodes
+// @Failure      400  {object}  common.AppError "Invalid request body or code"
+// @Failure      401  {object}  common.AppError "Unauthorized"
+// @Failure      404  {object}  common.AppError "No enrollment in progress"
+// @Failure      409  {object}  common.AppError "Two-factor authentication is already enabled"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/mfa/totp/confirm [post]
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+
+	var req model.MFACodeRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidMFACode:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		case service.ErrMFANotEnrolled:
+			return common.NewAppError(http.StatusNotFound, err.Error(), err)
+		case service.ErrMFAAlreadyEnabled:
+			return common.NewAppError(http.StatusConflict, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not confirm two-factor enrollment", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(codes)
+	return nil
+}
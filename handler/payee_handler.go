@@ -0,0 +1,236 @@
+// file: handler/payee_handler.go
+
+package handler
+
+import (
+	"encoding/json"
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+	"strconv"
+)
+
+// PayeeHandler holds dependencies for payee-related handlers.
+type PayeeHandler struct {
+	service *service.PayeeService
+}
+
+// NewPayeeHandler creates a new PayeeHandler with its dependencies.
+func NewPayeeHandler(s *service.PayeeService) *PayeeHandler {
+	return &PayeeHandler{service: s}
+}
+
+// payeeError maps payee service errors to HTTP errors.
+func payeeError(err error, fallback string) *common.AppError {
+	switch err {
+	case service.ErrPayeeNotFound, service.ErrReceiverAccountNotFound:
+		return common.NewAppError(http.StatusNotFound, err.Error(), err)
+	case service.ErrPayeeExists:
+		return common.NewAppError(http.StatusConflict, err.Error(), err)
+	case service.ErrCurrencyMismatch, service.ErrBeneficiaryRequired, model.ErrInvalidAccountNumber, model.ErrInvalidIBAN:
+		return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+	default:
+		return common.NewAppError(http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// CreatePayee godoc
+// @Summary      Save a payee
+// @Description  Looks up the beneficiary and saves it as an unverified payee of the authenticated user. The response carries the masked holder name to confirm via the verify endpoint.
+// @Tags         payees
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payee body model.CreatePayeeRequest true "Nickname and account number or IBAN of the payee"
+// @Success      201  {object}  model.Payee
+// @Failure      400  {object}  common.AppError "Invalid request body or currency mismatch"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      404  {object}  common.AppError "No account with that number or IBAN"
+// @Failure      409  {object}  common.AppError "A payee with this account number already exists"
+// @Failure      500  {object}  common.AppError "Internal server error while saving the payee"
+// @Router       /api/payees [post]
+func (h *PayeeHandler) CreatePayee(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+
+	var req model.CreatePayeeRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	payee, err := h.service.CreatePayee(r.Context(), userID, req)
+	if err != nil {
+		return payeeError(err, "Could not save payee")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payee)
+	return nil
+}
+
+// ListPayees godoc
+// @Summary      List payees
+// @Description  Retrieves the payees saved by the authenticated user.
+// @Tags         payees
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.Payee
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      500  {object}  common.AppError "Internal server error while retrieving payees"
+// @Router       /api/payees [get]
+func (h *PayeeHandler) ListPayees(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+
+	payees, err := h.service.ListPayees(r.Context(), userID)
+	if err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not retrieve payees", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payees)
+	return nil
+}
+
+// GetPayee godoc
+// @Summary      Get a payee
+// @Description  Retrieves one of the authenticated user's payees.
+// @Tags         payees
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payeeId path int true "Payee ID"
+// @Success      200  {object}  model.Payee
+// @Failure      400  {object}  common.AppError "Invalid payee ID in URL path"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      404  {object}  common.AppError "Payee not found"
+// @Failure      500  {object}  common.AppError "Internal server error while retrieving the payee"
+// @Router       /api/payees/{payeeId} [get]
+func (h *PayeeHandler) GetPayee(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, payeeID, appErr := payeeRequestIDs(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	payee, err := h.service.GetPayee(r.Context(), userID, payeeID)
+	if err != nil {
+		return payeeError(err, "Could not retrieve payee")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payee)
+	return nil
+}
+
+// UpdatePayee godoc
+// @Summary      Rename a payee
+// @Description  Changes the nickname of one of the authenticated user's payees.
+// @Tags         payees
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payeeId path int true "Payee ID"
+// @Param        payee body model.UpdatePayeeRequest true "The new nickname"
+// @Success      200  {object}  model.Payee
+// @Failure      400  {object}  common.AppError "Invalid payee ID or request body"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      404  {object}  common.AppError "Payee not found"
+// @Failure      500  {object}  common.AppError "Internal server error while updating the payee"
+// @Router       /api/payees/{payeeId} [patch]
+func (h *PayeeHandler) UpdatePayee(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, payeeID, appErr := payeeRequestIDs(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	var req model.UpdatePayeeRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	payee, err := h.service.RenamePayee(r.Context(), userID, payeeID, req.Nickname)
+	if err != nil {
+		return payeeError(err, "Could not update payee")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payee)
+	return nil
+}
+
+// VerifyPayee godoc
+// @Summary      Verify a payee
+// @Description  Confirms the payee's masked holder name, after which transfers can be made with its payee_id.
+// @Tags         payees
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payeeId path int true "Payee ID"
+// @Success      200  {object}  model.Payee
+// @Failure      400  {object}  common.AppError "Invalid payee ID in URL path"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      404  {object}  common.AppError "Payee not found"
+// @Failure      500  {object}  common.AppError "Internal server error while verifying the payee"
+// @Router       /api/payees/{payeeId}/verify [post]
+func (h *PayeeHandler) VerifyPayee(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, payeeID, appErr := payeeRequestIDs(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	payee, err := h.service.VerifyPayee(r.Context(), userID, payeeID)
+	if err != nil {
+		return payeeError(err, "Could not verify payee")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payee)
+	return nil
+}
+
+// DeletePayee godoc
+// @Summary      Delete a payee
+// @Description  Removes one of the authenticated user's payees.
+// @Tags         payees
+// @Security     BearerAuth
+// @Param        payeeId path int true "Payee ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  common.AppError "Invalid payee ID in URL path"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      404  {object}  common.AppError "Payee not found"
+// @Failure      500  {object}  common.AppError "Internal server error while deleting the payee"
+// @Router       /api/payees/{payeeId} [delete]
+func (h *PayeeHandler) DeletePayee(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, payeeID, appErr := payeeRequestIDs(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	if err := h.service.DeletePayee(r.Context(), userID, payeeID); err != nil {
+		return payeeError(err, "Could not delete payee")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// payeeRequestIDs extracts the authenticated user ID and the payee ID from the URL path.
+func payeeRequestIDs(r *http.Request) (int, int, *common.AppError) {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return 0, 0, common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+	payeeID, err := strconv.Atoi(r.PathValue("payeeId"))
+	if err != nil {
+		return 0, 0, common.NewAppError(http.StatusBadRequest, "Invalid payee ID in URL path", err)
+	}
+	return userID, payeeID, nil
+}
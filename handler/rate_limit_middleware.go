@@ -0,0 +1,69 @@
+// file: handler/rate_limit_middleware.go
+
+package handler
+
+import (
+	"fmt"
+	"go-bank-api/common"
+	"go-bank-api/service"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIKeyHeader carries the API key of a client calling on behalf of many users.
+const APIKeyHeader = "X-API-Key"
+
+// RateLimitMiddleware limits requests by the named rule of the limiter. Every
+// response carries the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers; requests over the limit are refused with 429
+// Too Many Requests and a Retry-After header. Routes whose rule is not
+// configured are not limited. Rules counting by user must run after
+// AuthMiddleware.
+func RateLimitMiddleware(limiter *service.RateLimiter, ruleName string) func(http.Handler) http.Handler {
+	rule, ok := limiter.Rule(ruleName)
+	return func(next http.Handler) http.Handler {
+		if !ok {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result := limiter.Allow(r.Context(), rule, rateLimitKey(r, rule.Key, limiter))
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit, ceilSeconds(rule.Window)))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				common.NewAppError(http.StatusTooManyRequests, "Too many requests, please try again later", nil).Send(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey returns what the request is counted by: the authenticated
+// user or the client whose API key the request carries, if the rule says so
+// and there is one, and the client's IP address otherwise. Only identities
+// the server has verified are used, since anything else the client sends can
+// change with every request.
+func rateLimitKey(r *http.Request, key string, limiter *service.RateLimiter) string {
+	switch key {
+	case service.RateLimitKeyUser:
+		if userID, ok := r.Context().Value(UserIDKey).(int); ok {
+			return "user:" + strconv.Itoa(userID)
+		}
+	case service.RateLimitKeyAPIKey:
+		if client, ok := limiter.APIKeyClient(r.Header.Get(APIKeyHeader)); ok {
+			return "api_key:" + client
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// ceilSeconds rounds a duration up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
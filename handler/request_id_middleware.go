@@ -0,0 +1,67 @@
+// file: handler/request_id_middleware.go
+
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go-bank-api/common"
+	"go-bank-api/service"
+	"net/http"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 100
+)
+
+// RequestIDMiddleware gives every request an ID, taken from the X-Request-ID
+// header if the client sent a usable one and generated otherwise, and returns
+// it in the same header. The ID, the client's IP address and user agent are
+// stored in the request context as a service.RequestInfo for the audit log.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			var err error
+			if requestID, err = newRequestID(); err != nil {
+				common.NewAppError(http.StatusInternalServerError, "Could not process request", err).Send(w)
+				return
+			}
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := service.WithRequestInfo(r.Context(), service.RequestInfo{
+			RequestID: requestID,
+			IPAddress: clientIP(r),
+			UserAgent: truncate(r.UserAgent(), 512),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID reports whether a client-supplied request ID is short and
+// made of letters, digits, '-', '_' and '.' only, so it is safe to log and store.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit request ID in hex.
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
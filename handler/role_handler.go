@@ -0,0 +1,196 @@
+// file: handler/role_handler.go
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+)
+
+// RoleHandler holds dependencies for the role management handlers.
+type RoleHandler struct {
+	service *service.RoleService
+}
+
+// NewRoleHandler creates a new RoleHandler with its dependencies.
+func NewRoleHandler(s *service.RoleService) *RoleHandler {
+	return &RoleHandler{service: s}
+}
+
+// roleError maps role service errors to HTTP errors.
+func roleError(err error, fallback string) *common.AppError {
+	if errors.Is(err, service.ErrUnknownPermission) {
+		return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+	}
+	switch err {
+	case service.ErrRoleNotFound:
+		return common.NewAppError(http.StatusNotFound, err.Error(), err)
+	case service.ErrRoleExists, service.ErrBuiltInRole, service.ErrRoleInUse:
+		return common.NewAppError(http.StatusConflict, err.Error(), err)
+	case service.ErrInvalidRoleName:
+		return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+	case service.ErrPermissionEscalation:
+		return common.NewAppError(http.StatusForbidden, err.Error(), err)
+	default:
+		return common.NewAppError(http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// ListPermissions godoc
+// @Summary      List permissions
+// @Description  Lists every permission roles can grant. Requires the roles:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.PermissionInfo
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Router       /api/admin/permissions [get]
+func (h *RoleHandler) ListPermissions(w http.ResponseWriter, r *http.Request) *common.AppError {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(model.Permissions)
+	return nil
+}
+
+// ListRoles godoc
+// @Summary      List roles
+// @Description  Lists every role with its permissions, built-in roles first. Requires the roles:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.RoleDefinition
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      500  {object}  common.AppError "Internal server error while retrieving roles"
+// @Router       /api/admin/roles [get]
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) *common.AppError {
+	roles, err := h.service.ListRoles(r.Context())
+	if err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not retrieve roles", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(roles)
+	return nil
+}
+
+// GetRole godoc
+// @Summary      Get a role
+// @Description  Retrieves a role with its permissions. Requires the roles:read permission.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        name path string true "Role name"
+// @Success      200  {object}  model.RoleDefinition
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      404  {object}  common.AppError "Role not found"
+// @Failure      500  {object}  common.AppError "Internal server error while retrieving the role"
+// @Router       /api/admin/roles/{name} [get]
+func (h *RoleHandler) GetRole(w http.ResponseWriter, r *http.Request) *common.AppError {
+	role, err := h.service.GetRole(r.Context(), r.PathValue("name"))
+	if err != nil {
+		return roleError(err, "Could not retrieve role")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(role)
+	return nil
+}
+
+// CreateRole godoc
+// @Summary      Create a role
+// @Description  Defines a new role as a set of permissions, which can then be assigned to users. Requires the roles:write permission, and every permission the role grants.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        role body model.CreateRoleRequest true "Name, description and permissions of the role"
+// @Success      201  {object}  model.RoleDefinition
+// @Failure      400  {object}  common.AppError "Invalid request body, role name or permission"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      409  {object}  common.AppError "A role with this name already exists"
+// @Failure      500  {object}  common.AppError "Internal server error while creating the role"
+// @Router       /api/admin/roles [post]
+func (h *RoleHandler) CreateRole(w http.ResponseWriter, r *http.Request) *common.AppError {
+	var req model.CreateRoleRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	actorRole, _ := r.Context().Value(UserRoleKey).(string)
+	role := &model.RoleDefinition{Name: req.Name, Description: req.Description, Permissions: req.Permissions}
+	if err := h.service.CreateRole(r.Context(), actorRole, role); err != nil {
+		return roleError(err, "Could not create role")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(role)
+	return nil
+}
+
+// UpdateRole godoc
+// @Summary      Update a role
+// @Description  Replaces the description and permissions of a role. Users holding the role get the new permissions at once. Built-in roles cannot be changed. Requires the roles:write permission, and every permission the role grants before and after the change.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        name path string true "Role name"
+// @Param        role body model.UpdateRoleRequest true "Description and permissions of the role"
+// @Success      200  {object}  model.RoleDefinition
+// @Failure      400  {object}  common.AppError "Invalid request body or permission"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      404  {object}  common.AppError "Role not found"
+// @Failure      409  {object}  common.AppError "Built-in role"
+// @Failure      500  {object}  common.AppError "Internal server error while updating the role"
+// @Router       /api/admin/roles/{name} [put]
+func (h *RoleHandler) UpdateRole(w http.ResponseWriter, r *http.Request) *common.AppError {
+	var req model.UpdateRoleRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	actorRole, _ := r.Context().Value(UserRoleKey).(string)
+	role := &model.RoleDefinition{Name: model.Role(r.PathValue("name")), Description: req.Description, Permissions: req.Permissions}
+	if err := h.service.UpdateRole(r.Context(), actorRole, role); err != nil {
+		return roleError(err, "Could not update role")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(role)
+	return nil
+}
+
+// DeleteRole godoc
+// @Summary      Delete a role
+// @Description  Deletes a role no user holds. Built-in roles cannot be deleted. Requires the roles:write permission.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        name path string true "Role name"
+// @Success      204  "No Content"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      404  {object}  common.AppError "Role not found"
+// @Failure      409  {object}  common.AppError "Built-in role, or role still held by users"
+// @Failure      500  {object}  common.AppError "Internal server error while deleting the role"
+// @Router       /api/admin/roles/{name} [delete]
+func (h *RoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) *common.AppError {
+	if err := h.service.DeleteRole(r.Context(), r.PathValue("name")); err != nil {
+		return roleError(err, "Could not delete role")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"go-bank-api/common"
+	"go-bank-api/model"
 	"go-bank-api/service"
 	"net/http"
 	"strconv"
@@ -20,18 +21,21 @@ func NewTransactionHandler(s *service.TransactionService) *TransactionHandler {
 
 // CreateTransfer godoc
 // @Summary      Transfer money from a specific account
-// @Description  Handles the transfer of a specified amount from a specific account to another. The user must own the 'from' account.
+// @Description  Handles the transfer of a specified amount from a specific account to another. The user must own the 'from' account. Transfers to an account in another currency are converted at the rate locked by quote_id, or at the current rate.
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        fromAccountId path int true "The ID of the account to transfer funds from"
-// @Param        transfer body service.TransferRequest true "Details of the financial transfer (to_account_id, amount)"
+// @Param        transfer body service.TransferRequest true "Details of the financial transfer (to_account_number, to_iban or payee_id, amount)"
 // @Success      201  {object}  model.Transaction
 // @Failure      400  {object}  common.AppError "Bad Request (e.g., invalid ID, insufficient funds, etc.)"
 // @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
 // @Failure      403  {object}  common.AppError "Forbidden: User does not own the source account"
-// @Failure      404  {object}  common.AppError "Sender or receiver account not found"
+// @Failure      404  {object}  common.AppError "Sender account, receiver account, payee or quote not found"
+// @Failure      409  {object}  common.AppError "Payee has not been verified, or the quote has expired or been used"
+// @Failure      422  {object}  common.AppError "No exchange rate available for this currency pair"
+// @Failure      429  {object}  common.AppError "Too many requests; see Retry-After"
 // @Failure      500  {object}  common.AppError "Internal server error while processing transfer"
 // @Router       /api/accounts/{fromAccountId}/transfers [post]
 func (h *TransactionHandler) CreateTransfer(w http.ResponseWriter, r *http.Request) *common.AppError {
@@ -54,11 +58,18 @@ func (h *TransactionHandler) CreateTransfer(w http.ResponseWriter, r *http.Reque
 	transaction, err := h.service.TransferMoney(r.Context(), userID, fromAccountID, req)
 	if err != nil {
 		switch err {
-		case service.ErrSenderAccountNotFound, service.ErrReceiverAccountNotFound:
+		case service.ErrSenderAccountNotFound, service.ErrReceiverAccountNotFound, service.ErrPayeeNotFound, service.ErrQuoteNotFound:
 			return common.NewAppError(http.StatusNotFound, err.Error(), err)
 		case service.ErrPermissionDenied:
 			return common.NewAppError(http.StatusForbidden, err.Error(), err)
-		case service.ErrInsufficientFunds, service.ErrCurrencyMismatch, service.ErrSameAccountTransfer, service.ErrInvalidAmount:
+		case service.ErrPayeeNotVerified, service.ErrQuoteExpired, service.ErrQuoteUsed:
+			return common.NewAppError(http.StatusConflict, err.Error(), err)
+		case service.ErrRateUnavailable:
+			return common.NewAppError(http.StatusUnprocessableEntity, err.Error(), err)
+		case service.ErrInsufficientFunds, service.ErrCurrencyMismatch, service.ErrSameAccountTransfer, service.ErrInvalidAmount,
+			service.ErrQuoteMismatch, service.ErrAmountTooSmall,
+			model.ErrInvalidAmountFormat, model.ErrExcessPrecision, model.ErrAmountOutOfRange,
+			model.ErrInvalidAccountNumber, model.ErrInvalidIBAN:
 			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
 		default:
 			return common.NewAppError(http.StatusInternalServerError, "Could not process transfer", err)
@@ -71,15 +82,58 @@ func (h *TransactionHandler) CreateTransfer(w http.ResponseWriter, r *http.Reque
 	return nil
 }
 
+// LookupBeneficiary godoc
+// @Summary      Look up a transfer beneficiary
+// @Description  Resolves an account number or IBAN to the receiving account and returns its masked holder name and currency, so the sender can confirm it before transferring.
+// @Tags         transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        account_number query string false "The beneficiary's account number"
+// @Param        iban           query string false "The beneficiary's IBAN"
+// @Success      200  {object}  model.Beneficiary
+// @Failure      400  {object}  common.AppError "Missing or invalid account number or IBAN"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      404  {object}  common.AppError "No account with that number or IBAN"
+// @Failure      500  {object}  common.AppError "Internal server error while looking up the beneficiary"
+// @Router       /api/beneficiaries/lookup [get]
+func (h *TransactionHandler) LookupBeneficiary(w http.ResponseWriter, r *http.Request) *common.AppError {
+	query := r.URL.Query()
+	beneficiary, err := h.service.LookupBeneficiary(r.Context(), query.Get("account_number"), query.Get("iban"))
+	if err != nil {
+		switch err {
+		case service.ErrReceiverAccountNotFound:
+			return common.NewAppError(http.StatusNotFound, err.Error(), err)
+		case service.ErrBeneficiaryRequired, model.ErrInvalidAccountNumber, model.ErrInvalidIBAN:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not look up beneficiary", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(beneficiary)
+	return nil
+}
+
 // ListTransactionsForAccount godoc
 // @Summary      List account transaction history
-// @Description  Retrieves the transaction history for a specific account owned by the authenticated user.
+// @Description  Retrieves one page of the transaction history for a specific account owned by the authenticated user, newest first by default. Pass next_cursor from the response as cursor to fetch the following page. Amount filters apply to the amount that moved in or out of this account, in its currency.
 // @Tags         transactions
 // @Produce      json
 // @Security     BearerAuth
-// @Param        accountId path int true "The ID of the account to retrieve transactions for"
-// @Success      200  {array}   model.Transaction "A list of transactions for the account"
-// @Failure      400  {object}  common.AppError "Invalid account ID in URL path"
+// @Param        accountId    path  int    true  "The ID of the account to retrieve transactions for"
+// @Param        limit        query int    false "Page size (default 50, max 100)"
+// @Param        cursor       query string false "Cursor returned as next_cursor by the previous page"
+// @Param        from         query string false "Only transactions at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param        to           query string false "Only transactions before this time (RFC 3339), or on or before this date (YYYY-MM-DD)"
+// @Param        min_amount   query string false "Minimum amount"
+// @Param        max_amount   query string false "Maximum amount"
+// @Param        direction    query string false "incoming or outgoing"
+// @Param        counterparty query string false "Account number of the other account"
+// @Param        sort         query string false "asc or desc (default)"
+// @Success      200  {object}  model.TransactionPage "A page of transactions for the account"
+// @Failure      400  {object}  common.AppError "Invalid account ID or query parameter"
 // @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
 // @Failure      403  {object}  common.AppError "Forbidden: User does not own the specified account"
 // @Failure      404  {object}  common.AppError "Account with the specified ID not found"
@@ -99,14 +153,22 @@ func (h *TransactionHandler) ListTransactionsForAccount(w http.ResponseWriter, r
 		return common.NewAppError(http.StatusBadRequest, "Invalid account ID in URL path", err)
 	}
 
+	query, appErr := parseTransactionQuery(r)
+	if appErr != nil {
+		return appErr
+	}
+
 	// Call the service to get the transactions, which includes the authorization check.
-	transactions, err := h.service.ListTransactionsForAccount(r.Context(), userID, accountID)
+	page, err := h.service.ListTransactionsForAccount(r.Context(), userID, accountID, query)
 	if err != nil {
 		switch err {
 		case service.ErrAccountNotFound:
 			return common.NewAppError(http.StatusNotFound, err.Error(), err)
 		case service.ErrPermissionDenied:
 			return common.NewAppError(http.StatusForbidden, err.Error(), err)
+		case model.ErrInvalidCursor, service.ErrInvalidDirection, service.ErrInvalidDateRange, service.ErrInvalidAmountRange,
+			model.ErrInvalidAmountFormat, model.ErrExcessPrecision, model.ErrAmountOutOfRange, model.ErrInvalidAccountNumber:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
 		default:
 			return common.NewAppError(http.StatusInternalServerError, "Could not retrieve transactions", err)
 		}
@@ -114,6 +176,31 @@ func (h *TransactionHandler) ListTransactionsForAccount(w http.ResponseWriter, r
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(transactions)
+	json.NewEncoder(w).Encode(page)
 	return nil
 }
+
+// parseTransactionQuery reads the paging, filtering and sorting options of a
+// transaction history request from its query string.
+func parseTransactionQuery(r *http.Request) (model.TransactionQuery, *common.AppError) {
+	params := r.URL.Query()
+	query := model.TransactionQuery{
+		Cursor:       params.Get("cursor"),
+		MinAmount:    model.Amount(params.Get("min_amount")),
+		MaxAmount:    model.Amount(params.Get("max_amount")),
+		Direction:    params.Get("direction"),
+		Counterparty: params.Get("counterparty"),
+	}
+
+	var appErr *common.AppError
+	if query.Limit, appErr = parseLimit(params); appErr != nil {
+		return query, appErr
+	}
+	if query.From, query.To, appErr = parseTimeRange(params); appErr != nil {
+		return query, appErr
+	}
+	if query.Ascending, appErr = parseSortOrder(params); appErr != nil {
+		return query, appErr
+	}
+	return query, nil
+}
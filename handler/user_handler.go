@@ -5,44 +5,54 @@ package handler
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"go-bank-api/common"
 	"go-bank-api/logger"
 	"go-bank-api/model"
 	"go-bank-api/repository"
 	"go-bank-api/service"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 )
 
 // UserHandler holds dependencies for user-related handlers.
 // It now includes AuthService to handle complex authentication logic like token generation.
+// The VerificationService mails new users the link to verify their email address.
 type UserHandler struct {
-	userRepo    repository.IUserRepository
-	userService *service.UserService
-	authService *service.AuthService
+	userRepo            repository.IUserRepository
+	userService         *service.UserService
+	authService         *service.AuthService
+	verificationService *service.VerificationService
+	approvals           *service.ApprovalService
 }
 
 // NewUserHandler creates a new UserHandler with its dependencies.
 // The signature is updated to accept an AuthService instance.
-func NewUserHandler(userRepo repository.IUserRepository, userService *service.UserService, authService *service.AuthService) *UserHandler {
+func NewUserHandler(userRepo repository.IUserRepository, userService *service.UserService, authService *service.AuthService, verificationService *service.VerificationService, approvals *service.ApprovalService) *UserHandler {
 	return &UserHandler{
-		userRepo:    userRepo,
-		userService: userService,
-		authService: authService,
+		userRepo:            userRepo,
+		userService:         userService,
+		authService:         authService,
+		verificationService: verificationService,
+		approvals:           approvals,
 	}
 }
 
 // Register godoc
 // @Summary      Register a new user
-// @Description  Creates a new user account
+// @Description  Creates a new user account and mails a link to verify the email address. Transfers are refused until the address is verified. The password has to follow the password policy; a refusal lists every rule it breaks.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        user body model.RegisterRequest true "User Registration Info"
 // @Success      201  {object}  model.User
-// @Failure      400  {object}  common.AppError
+// @Failure      400  {object}  common.AppError "Invalid request body, or password refused by the password policy"
+// @Failure      429  {object}  common.AppError "Too many requests; see Retry-After"
 // @Failure      500  {object}  common.AppError
 // @Router       /register [post]
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) *common.AppError {
@@ -54,22 +64,34 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) *common.A
 	log := logger.Log.WithFields(logrus.Fields{"username": req.Username, "email": req.Email})
 	log.Info("User registration attempt started")
 
-	hashedPassword, err := h.authService.HashPassword(req.Password)
-	if err != nil {
-		return common.NewAppError(http.StatusInternalServerError, "Could not process request", err)
-	}
-
 	user := &model.User{
 		Username: req.Username,
 		Email:    req.Email,
-		Password: hashedPassword,
 	}
+	if err := h.authService.CheckNewPassword(r.Context(), req.Password, user); err != nil {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return common.NewAppError(http.StatusBadRequest, policyErr.Error(), err)
+		}
+		return common.NewAppError(http.StatusInternalServerError, "Could not process request", err)
+	}
+
+	hashedPassword, err := h.authService.HashPassword(req.Password)
+	if err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not process request", err)
+	}
+	user.Password = hashedPassword
 
-	if err := h.userRepo.CreateUser(user); err != nil {
+	if err := h.userRepo.CreateUser(r.Context(), user); err != nil {
 		return common.NewAppError(http.StatusInternalServerError, "Could not create user", err)
 	}
 
 	log.WithField("user_id", user.ID).Info("User registered successfully")
+	// The account is usable without the email; a failed send can be retried
+	// through the resend endpoint.
+	if err := h.verificationService.SendEmailVerification(r.Context(), user); err != nil {
+		log.WithError(err).Warn("Failed to send email verification after registration")
+	}
 	w.WriteHeader(http.StatusCreated)
 	user.Password = ""
 	json.NewEncoder(w).Encode(user)
@@ -79,14 +101,15 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) *common.A
 
 // Login godoc
 // @Summary      User login
-// @Description  Authenticates a user and returns a JWT token
+// @Description  Authenticates a user, starts a session for the device and returns its tokens. Sessions on other devices stay logged in. After repeated failures further attempts for the email address are delayed and eventually locked out for a while. Users with two-factor authentication get mfa_required and an mfa_token instead, valid for 5 minutes, to present with a code at /login/mfa.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        credentials body model.LoginRequest true "User Credentials"
-// @Success      200  {object}  service.TokenPair
+// @Success      200  {object}  service.LoginResult
 // @Failure      400  {object}  common.AppError "Invalid request body"
 // @Failure      401  {object}  common.AppError "Invalid email or password"
+// @Failure      429  {object}  common.AppError "Too many requests or failed logins for this email or IP address, or account locked; see Retry-After"
 // @Failure      500  {object}  common.AppError "Internal server error"
 // @Router       /login [post]
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) *common.AppError {
@@ -98,29 +121,89 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) *common.AppE
 	log := logger.Log.WithField("email", req.Email)
 	log.Info("User login attempt started")
 
-	tokenPair, err := h.authService.AuthenticateUser(req.Email, req.Password)
+	device := model.DeviceInfo{
+		DeviceName: req.DeviceName,
+		UserAgent:  truncate(r.UserAgent(), 512),
+		IPAddress:  clientIP(r),
+	}
+	result, err := h.authService.AuthenticateUser(r.Context(), req.Email, req.Password, device)
 	if err != nil {
-		return common.NewAppError(http.StatusUnauthorized, "Invalid email or password", err)
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			log.WithField("locked", throttled.Locked).Warn("Login attempt refused after too many failures")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			return common.NewAppError(http.StatusTooManyRequests, throttled.Error(), err)
+		}
+		if err == service.ErrInvalidCredentials {
+			return common.NewAppError(http.StatusUnauthorized, "Invalid email or password", err)
+		}
+		return common.NewAppError(http.StatusInternalServerError, "Could not log in", err)
 	}
 
-	log.Info("User logged in successfully, token pair generated")
+	if result.MFARequired {
+		log.Info("Password accepted, waiting for two-factor authentication code")
+	} else {
+		log.Info("User logged in successfully, token pair generated")
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tokenPair)
+	json.NewEncoder(w).Encode(result)
+
+	return nil
+}
+
+// LoginMFA godoc
+// @Summary      Complete a login with two-factor authentication
+// @Description  Exchanges the mfa_token returned by /login and a code from the authenticator app, or an unused recovery code, for the session's tokens. After 5 invalid codes the mfa_token is discarded and the login has to start over. Invalid codes count as failed logins for the account, so they are delayed and eventually locked out like wrong passwords.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body model.MFALoginRequest true "MFA token and code"
+// @Success      200  {object}  service.TokenPair
+// @Failure      400  {object}  common.AppError "Invalid request body"
+// @Failure      401  {object}  common.AppError "Invalid code or invalid or expired MFA token"
+// @Failure      429  {object}  common.AppError "Too many requests or failed logins for this account or IP address, or account locked; see Retry-After"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /login/mfa [post]
+func (h *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request) *common.AppError {
+	var req model.MFALoginRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	device := model.DeviceInfo{UserAgent: truncate(r.UserAgent(), 512), IPAddress: clientIP(r)}
+	tokenPair, err := h.authService.CompleteMFALogin(r.Context(), req.MFAToken, req.Code, device)
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			return common.NewAppError(http.StatusTooManyRequests, throttled.Error(), err)
+		}
+		switch err {
+		case service.ErrInvalidMFACode, service.ErrInvalidMFAChallenge, service.ErrTooManyMFAAttempts:
+			return common.NewAppError(http.StatusUnauthorized, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not log in", err)
+		}
+	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokenPair)
 	return nil
 }
 
 // RefreshToken godoc
 // @Summary      Refresh access token
-// @Description  Provides a new access token using a valid refresh token.
+// @Description  Exchanges a valid refresh token for a new access token and a new refresh token. The presented refresh token can no longer be used; presenting it again revokes every refresh token issued since the login it came from.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body object{refresh_token=string} true "Refresh Token"
-// @Success      200  {object}  object{access_token=string}
+// @Success      200  {object}  service.TokenPair
 // @Failure      400  {object}  common.AppError "Invalid request body"
-// @Failure      401  {object}  common.AppError "Invalid or expired refresh token"
+// @Failure      401  {object}  common.AppError "Invalid, expired or already used refresh token"
+// @Failure      429  {object}  common.AppError "Too many requests; see Retry-After"
 // @Router       /api/token/refresh [post]
 func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) *common.AppError {
 	var req struct {
@@ -130,20 +213,25 @@ func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) *comm
 		return err
 	}
 
-	newAccessToken, err := h.authService.RefreshAccessToken(req.RefreshToken)
+	tokenPair, err := h.authService.RefreshAccessToken(r.Context(), req.RefreshToken)
 	if err != nil {
-		return common.NewAppError(http.StatusUnauthorized, err.Error(), err)
+		switch err {
+		case service.ErrInvalidRefreshToken, service.ErrExpiredRefreshToken, service.ErrRefreshTokenReused:
+			return common.NewAppError(http.StatusUnauthorized, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not refresh token", err)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"access_token": newAccessToken})
+	json.NewEncoder(w).Encode(tokenPair)
 	return nil
 }
 
 // Logout godoc
 // @Summary      User logout
-// @Description  Invalidates the user's session by deleting their refresh token.
+// @Description  Ends the session the access token belongs to and revokes its access tokens. Sessions on other devices stay logged in.
 // @Tags         auth
 // @Security     BearerAuth
 // @Success      204  "No Content"
@@ -151,13 +239,123 @@ func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) *comm
 // @Failure      500  {object}  common.AppError "Internal server error"
 // @Router       /api/logout [post]
 func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) *common.AppError {
+	claims, ok := r.Context().Value(ClaimsKey).(*model.AppClaims)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid token claims", nil)
+	}
+
+	if err := h.authService.LogoutUser(r.Context(), claims); err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not log out", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// ChangePassword godoc
+// @Summary      Change password
+// @Description  Replaces the authenticated user's password after checking the current one. The new password has to follow the password policy; a refusal lists every rule it breaks. Wrong current passwords count as failed logins and are throttled like them. Sessions on other devices are logged out; the current session stays logged in.
+// @Tags         auth
+// @Accept       json
+// @Security     BearerAuth
+// @Param        request body model.ChangePasswordRequest true "Current and new password"
+// @Success      204  "No Content"
+// @Failure      400  {object}  common.AppError "Invalid request body, new password same as the current one, or refused by the password policy"
+// @Failure      401  {object}  common.AppError "Unauthorized"
+// @Failure      403  {object}  common.AppError "Current password is incorrect"
+// @Failure      429  {object}  common.AppError "Too many failed attempts; see Retry-After"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/password [put]
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) *common.AppError {
+	claims, ok := r.Context().Value(ClaimsKey).(*model.AppClaims)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid token claims", nil)
+	}
+	var req model.ChangePasswordRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	device := model.DeviceInfo{UserAgent: truncate(r.UserAgent(), 512), IPAddress: clientIP(r)}
+	if err := h.authService.ChangePassword(r.Context(), claims, req.CurrentPassword, req.NewPassword, device); err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			return common.NewAppError(http.StatusTooManyRequests, throttled.Error(), err)
+		}
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return common.NewAppError(http.StatusBadRequest, policyErr.Error(), err)
+		}
+		switch err {
+		case service.ErrPasswordUnchanged:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		case service.ErrInvalidCredentials:
+			return common.NewAppError(http.StatusForbidden, "Current password is incorrect", err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not change password", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// ListSessions godoc
+// @Summary      List sessions
+// @Description  Retrieves the authenticated user's active sessions, one per logged-in device, most recently used first. The session of the current access token is marked as current.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   model.Session
+// @Failure      401  {object}  common.AppError "Unauthorized"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/sessions [get]
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) *common.AppError {
 	userID, ok := r.Context().Value(UserIDKey).(int)
 	if !ok {
 		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
 	}
+	sessionID, _ := r.Context().Value(SessionIDKey).(int)
 
-	if err := h.authService.LogoutUser(userID); err != nil {
-		return common.NewAppError(http.StatusInternalServerError, "Could not log out", err)
+	sessions, err := h.authService.ListSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not retrieve sessions", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessions)
+	return nil
+}
+
+// RevokeSession godoc
+// @Summary      Revoke a session
+// @Description  Ends one of the authenticated user's sessions, for example on a lost device. Its refresh and access tokens stop working immediately.
+// @Tags         auth
+// @Security     BearerAuth
+// @Param        id   path  int  true  "Session ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  common.AppError "Invalid session ID"
+// @Failure      401  {object}  common.AppError "Unauthorized"
+// @Failure      404  {object}  common.AppError "Session not found"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/sessions/{id} [delete]
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+	sessionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return common.NewAppError(http.StatusBadRequest, "Invalid session ID", err)
+	}
+
+	if err := h.authService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if err == service.ErrSessionNotFound {
+			return common.NewAppError(http.StatusNotFound, err.Error(), err)
+		}
+		return common.NewAppError(http.StatusInternalServerError, "Could not revoke session", err)
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -165,34 +363,63 @@ func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) *common.App
 }
 
 // GetAllUsers godoc
-// @Summary      Get all users
-// @Description  Retrieves a list of all users. Admin access required.
+// @Summary      List users
+// @Description  Retrieves one page of users, newest first by default, with the total number of matching users. Pass next_cursor from the response as cursor to fetch the following page. Requires the users:read permission.
 // @Tags         admin
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {array}   model.User
+// @Param        search query string false "Start of the username or email, ignoring case"
+// @Param        role   query string false "Role name, e.g. admin or user"
+// @Param        from   query string false "Only users created at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param        to     query string false "Only users created before this time (RFC 3339), or on or before this date (YYYY-MM-DD)"
+// @Param        limit  query int    false "Page size (default 50, max 100)"
+// @Param        cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param        sort   query string false "asc or desc (default)"
+// @Success      200  {object}  model.UserPage
+// @Failure      400  {object}  common.AppError "Invalid query parameter"
 // @Failure      401  {object}  common.AppError "Unauthorized"
 // @Failure      403  {object}  common.AppError "Forbidden"
 // @Failure      500  {object}  common.AppError "Internal server error"
 // @Router       /api/admin/users [get]
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) *common.AppError {
-	logger.Log.Info("Admin request to list all users received")
+	logger.Log.Info("Admin request to list users received")
 
-	users, err := h.userRepo.GetAllUsers()
+	params := r.URL.Query()
+	filter := model.UserFilter{Search: params.Get("search"), Role: params.Get("role")}
+	var appErr *common.AppError
+	if filter.Limit, appErr = parseLimit(params); appErr != nil {
+		return appErr
+	}
+	if filter.After, appErr = parseCursor(params); appErr != nil {
+		return appErr
+	}
+	if filter.From, filter.To, appErr = parseTimeRange(params); appErr != nil {
+		return appErr
+	}
+	if filter.Ascending, appErr = parseSortOrder(params); appErr != nil {
+		return appErr
+	}
+
+	page, err := h.userService.ListUsers(r.Context(), filter)
 	if err != nil {
-		return common.NewAppError(http.StatusInternalServerError, "Could not retrieve users", err)
+		switch err {
+		case service.ErrInvalidRole, service.ErrInvalidDateRange:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not retrieve users", err)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(page)
 
 	return nil
 }
 
 // UpdateUserRole godoc
 // @Summary      Update a user's role
-// @Description  Updates the role of a specific user. This is an admin-only endpoint.
+// @Description  Updates the role of a specific user and revokes the user's outstanding access tokens, which still carry the old role. Role changes granting the user new permissions are not made at once: they are stored as a pending approval request, which another admin has to approve. Requires the users:role:write permission, and every permission of both the user's current role and the new one.
 // @Tags         admin
 // @Accept       json
 // @Produce      json
@@ -200,9 +427,10 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) *commo
 // @Param        id   path      int  true  "User ID to be updated"
 // @Param        role body      model.UpdateUserRoleRequest true "The new role for the user"
 // @Success      200  {object}  map[string]string "{"message": "User role updated successfully"}"
-// @Failure      400  {object}  common.AppError "Invalid user ID in URL path or invalid request body"
+// @Success      202  {object}  model.ApprovalRequest "The role change awaits approval"
+// @Failure      400  {object}  common.AppError "Invalid user ID in URL path, invalid request body or unknown role"
 // @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
-// @Failure      403  {object}  common.AppError "Forbidden: User does not have admin privileges"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
 // @Failure      404  {object}  common.AppError "User with the specified ID not found"
 // @Failure      500  {object}  common.AppError "Internal server error while updating user role"
 // @Router       /api/admin/users/{id}/role [patch]
@@ -212,6 +440,10 @@ func (h *UserHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) *co
 	if err != nil {
 		return common.NewAppError(http.StatusBadRequest, "Invalid user ID in URL path", err)
 	}
+	adminID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
 
 	var req model.UpdateUserRoleRequest
 	if err := common.ValidateAndDecode(r, &req); err != nil {
@@ -221,15 +453,84 @@ func (h *UserHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) *co
 	log := logger.Log.WithFields(logrus.Fields{"user_id_to_update": userID, "new_role": req.Role})
 	log.Info("Admin request to update user role received")
 
-	if err := h.userService.UpdateUserRole(userID, req.Role); err != nil {
-		if err == sql.ErrNoRows {
+	actorRole, _ := r.Context().Value(UserRoleKey).(string)
+	approval, err := h.approvals.ChangeRole(r.Context(), adminID, actorRole, userID, req.Role)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
 			return common.NewAppError(http.StatusNotFound, "User with the specified ID not found", err)
+		case service.ErrInvalidRole:
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		case service.ErrPermissionEscalation:
+			return common.NewAppError(http.StatusForbidden, err.Error(), err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not update user role", err)
 		}
-		return common.NewAppError(http.StatusInternalServerError, "Could not update user role", err)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if approval != nil {
+		log.WithField("approval_request_id", approval.ID).Info("Role change awaits approval")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(approval)
+		return nil
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "User role updated successfully"})
 	return nil
 }
+
+// UnlockUser godoc
+// @Summary      Unlock a user's account
+// @Description  Lifts a lockout of the user's account after too many failed logins, and forgets the failures. Requires the users:unlock permission.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id   path      int  true  "User ID to unlock"
+// @Success      204  "No Content"
+// @Failure      400  {object}  common.AppError "Invalid user ID in URL path"
+// @Failure      401  {object}  common.AppError "Unauthorized: Invalid or missing token"
+// @Failure      403  {object}  common.AppError "Forbidden: Missing permission"
+// @Failure      404  {object}  common.AppError "User with the specified ID not found"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/admin/users/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return common.NewAppError(http.StatusBadRequest, "Invalid user ID in URL path", err)
+	}
+	adminID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+
+	if err := h.authService.UnlockUser(r.Context(), adminID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return common.NewAppError(http.StatusNotFound, "User with the specified ID not found", err)
+		}
+		return common.NewAppError(http.StatusInternalServerError, "Could not unlock user", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// clientIP returns the IP address the request came from. Forwarding headers
+// are not trusted, since any client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,139 @@
+// file: handler/verification_handler.go
+
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-bank-api/common"
+	"go-bank-api/model"
+	"go-bank-api/service"
+	"net/http"
+)
+
+// VerificationHandler holds dependencies for the password reset and email
+// verification handlers.
+type VerificationHandler struct {
+	verificationService *service.VerificationService
+}
+
+// NewVerificationHandler creates a new VerificationHandler.
+func NewVerificationHandler(verificationService *service.VerificationService) *VerificationHandler {
+	return &VerificationHandler{verificationService: verificationService}
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset link
+// @Description  Mails a link to choose a new password to the address, valid for one hour. The response is the same whether or not an account uses the address.
+// @Tags         auth
+// @Accept       json
+// @Param        request body model.ForgotPasswordRequest true "Email address of the account"
+// @Success      202  "Accepted"
+// @Failure      400  {object}  common.AppError "Invalid request body"
+// @Failure      429  {object}  common.AppError "Too many requests; see Retry-After"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /password/forgot [post]
+func (h *VerificationHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) *common.AppError {
+	var req model.ForgotPasswordRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	if err := h.verificationService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		return common.NewAppError(http.StatusInternalServerError, "Could not send password reset email", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// ResetPassword godoc
+// @Summary      Reset a forgotten password
+// @Description  Sets a new password with the token from a password reset link. The token works once; a password refused by the password policy does not use it up. The user is logged out on every device and has to log in with the new password.
+// @Tags         auth
+// @Accept       json
+// @Param        request body model.ResetPasswordRequest true "Reset token and new password"
+// @Success      204  "No Content"
+// @Failure      400  {object}  common.AppError "Invalid request body, invalid or expired token, or password refused by the password policy"
+// @Failure      429  {object}  common.AppError "Too many requests; see Retry-After"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /password/reset [post]
+func (h *VerificationHandler) ResetPassword(w http.ResponseWriter, r *http.Request) *common.AppError {
+	var req model.ResetPasswordRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	if err := h.verificationService.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return common.NewAppError(http.StatusBadRequest, policyErr.Error(), err)
+		}
+		if err == service.ErrInvalidUserToken {
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		}
+		return common.NewAppError(http.StatusInternalServerError, "Could not reset password", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// RequestEmailVerification godoc
+// @Summary      Resend the email verification link
+// @Description  Mails the authenticated user a new link to verify their email address, valid for 48 hours. Earlier links stop working.
+// @Tags         auth
+// @Security     BearerAuth
+// @Success      202  "Accepted"
+// @Failure      401  {object}  common.AppError "Unauthorized"
+// @Failure      409  {object}  common.AppError "Email address is already verified"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /api/email/verification [post]
+func (h *VerificationHandler) RequestEmailVerification(w http.ResponseWriter, r *http.Request) *common.AppError {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	if !ok {
+		return common.NewAppError(http.StatusUnauthorized, "Invalid user ID in token", nil)
+	}
+
+	if err := h.verificationService.RequestEmailVerification(r.Context(), userID); err != nil {
+		switch err {
+		case service.ErrEmailAlreadyVerified:
+			return common.NewAppError(http.StatusConflict, err.Error(), err)
+		case sql.ErrNoRows:
+			return common.NewAppError(http.StatusUnauthorized, "User not found", err)
+		default:
+			return common.NewAppError(http.StatusInternalServerError, "Could not send verification email", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// VerifyEmail godoc
+// @Summary      Verify an email address
+// @Description  Marks the email address as verified with the token from a verification link. Access tokens issued before still carry the unverified state; refresh them to make transfers.
+// @Tags         auth
+// @Accept       json
+// @Param        request body model.VerifyEmailRequest true "Verification token"
+// @Success      204  "No Content"
+// @Failure      400  {object}  common.AppError "Invalid request body, or invalid or expired token"
+// @Failure      429  {object}  common.AppError "Too many requests; see Retry-After"
+// @Failure      500  {object}  common.AppError "Internal server error"
+// @Router       /email/verify [post]
+func (h *VerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) *common.AppError {
+	var req model.VerifyEmailRequest
+	if err := common.ValidateAndDecode(r, &req); err != nil {
+		return err
+	}
+
+	if err := h.verificationService.VerifyEmail(r.Context(), req.Token); err != nil {
+		if err == service.ErrInvalidUserToken {
+			return common.NewAppError(http.StatusBadRequest, err.Error(), err)
+		}
+		return common.NewAppError(http.StatusInternalServerError, "Could not verify email address", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -2,11 +2,49 @@ package model
 
 import "time"
 
+// Account is a bank account. System accounts (see AccountKind*) have no owning
+// user, in which case UserID is zero. IBAN is derived from the account number
+// and is only set when IBANs are configured for the account's currency.
 type Account struct {
 	ID            int       `json:"id"`
 	UserID        int       `json:"user_id"`
 	AccountNumber int64     `json:"account_number"`
-	Balance       float64   `json:"balance"`
+	IBAN          string    `json:"iban,omitempty"`
+	Balance       Money     `json:"balance"`
 	Currency      string    `json:"currency"`
+	Kind          string    `json:"kind"`
 	CreatedAt     time.Time `json:"created_at"`
 }
+
+// Beneficiary is what a sender is shown about a receiving account before a
+// transfer. The holder's name is masked so a lookup does not reveal it in full.
+type Beneficiary struct {
+	AccountNumber int64  `json:"account_number"`
+	IBAN          string `json:"iban,omitempty"`
+	Currency      string `json:"currency"`
+	HolderName    string `json:"holder_name" example:"a***"`
+}
+
+// AccountFilter selects one page of accounts for the admin listing. A balance
+// range only makes sense within one currency, so MinBalance and MaxBalance
+// carry the currency being filtered on.
+type AccountFilter struct {
+	UserID     int
+	Currency   string
+	Kind       string
+	MinBalance *Money
+	MaxBalance *Money
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	After      *Cursor
+	Ascending  bool
+}
+
+// AccountPage is one page of the admin account listing. Total counts every
+// account matching the filter, across all pages.
+type AccountPage struct {
+	Accounts   []*Account `json:"accounts"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,153 @@
+// file: model/account_number.go
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAccountNumber = errors.New("invalid account number")
+	ErrInvalidIBAN          = errors.New("invalid IBAN")
+)
+
+// Account numbers are an allocated 10-digit base followed by a Luhn check
+// digit. Accounts opened before check digits were introduced keep their plain
+// 10-digit numbers, which are still accepted.
+const (
+	accountNumberBaseMin = 1000000000
+	accountNumberBaseMax = 9999999999
+	accountNumberMin     = accountNumberBaseMin * 10
+	accountNumberMax     = accountNumberBaseMax*10 + 9
+)
+
+// ibanAccountDigits is the width the account number is zero-padded to in the BBAN.
+const ibanAccountDigits = 16
+
+// luhnCheckDigit returns the digit that makes digits+checkDigit pass the Luhn check.
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true // The rightmost payload digit is doubled once the check digit is appended.
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// NewAccountNumber appends a Luhn check digit to an allocated 10-digit base.
+func NewAccountNumber(base int64) (int64, error) {
+	if base < accountNumberBaseMin || base > accountNumberBaseMax {
+		return 0, fmt.Errorf("account number base %d out of range: %w", base, ErrInvalidAccountNumber)
+	}
+	return base*10 + int64(luhnCheckDigit(strconv.FormatInt(base, 10))), nil
+}
+
+// ValidateAccountNumber checks that n is a customer account number: either an
+// 11-digit number with a valid check digit, or a legacy 10-digit number.
+func ValidateAccountNumber(n int64) error {
+	switch {
+	case n >= accountNumberBaseMin && n <= accountNumberBaseMax:
+		return nil
+	case n >= accountNumberMin && n <= accountNumberMax:
+		digits := strconv.FormatInt(n, 10)
+		if luhnCheckDigit(digits[:len(digits)-1]) != int(digits[len(digits)-1]-'0') {
+			return ErrInvalidAccountNumber
+		}
+		return nil
+	default:
+		return ErrInvalidAccountNumber
+	}
+}
+
+// ParseAccountNumber parses and validates an account number as typed by a
+// customer. Spaces and dashes used for grouping are ignored.
+func ParseAccountNumber(s string) (int64, error) {
+	s = strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if !isDigits(s) {
+		return 0, ErrInvalidAccountNumber
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAccountNumber
+	}
+	if err := ValidateAccountNumber(n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// FormatIBAN builds the IBAN of an account: the country code, two ISO 7064
+// mod-97 check digits, then a BBAN of the bank code and the zero-padded
+// account number.
+func FormatIBAN(countryCode, bankCode string, accountNumber int64) (string, error) {
+	if len(countryCode) != 2 || !isUpperAlpha(countryCode) || bankCode == "" || !isAlphanumeric(bankCode) {
+		return "", ErrInvalidIBAN
+	}
+	if err := ValidateAccountNumber(accountNumber); err != nil {
+		return "", err
+	}
+	bban := fmt.Sprintf("%s%0*d", bankCode, ibanAccountDigits, accountNumber)
+	check := 98 - mod97(bban+countryCode+"00")
+	return fmt.Sprintf("%s%02d%s", countryCode, check, bban), nil
+}
+
+// NormalizeIBAN removes grouping spaces and upper-cases s, then verifies the
+// IBAN structure and its check digits.
+func NormalizeIBAN(s string) (string, error) {
+	iban := strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if len(iban) < 5 || len(iban) > 34 || !isUpperAlpha(iban[:2]) || !isDigits(iban[2:4]) || !isAlphanumeric(iban[4:]) {
+		return "", ErrInvalidIBAN
+	}
+	if mod97(iban[4:]+iban[:4]) != 1 {
+		return "", ErrInvalidIBAN
+	}
+	return iban, nil
+}
+
+// AccountNumberFromIBAN extracts the account number from an IBAN issued by the
+// bank with the given bank code.
+func AccountNumberFromIBAN(iban, bankCode string) (int64, error) {
+	iban, err := NormalizeIBAN(iban)
+	if err != nil {
+		return 0, err
+	}
+	bban := iban[4:]
+	if len(bban) != len(bankCode)+ibanAccountDigits || !strings.HasPrefix(bban, bankCode) {
+		return 0, ErrInvalidIBAN
+	}
+	return ParseAccountNumber(bban[len(bankCode):])
+}
+
+// mod97 computes the ISO 7064 MOD 97-10 remainder of s, where letters count
+// as two-digit numbers (A=10 ... Z=35).
+func mod97(s string) int {
+	rem := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			rem = (rem*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			rem = (rem*100 + int(r-'A') + 10) % 97
+		}
+	}
+	return rem
+}
+
+func isUpperAlpha(s string) bool {
+	return s != "" && strings.Trim(s, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
+
+func isAlphanumeric(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
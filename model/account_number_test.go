@@ -0,0 +1,87 @@
+// file: model/account_number_test.go
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAccountNumber(t *testing.T) {
+	// 7992739871 is the textbook Luhn example; its check digit is 3.
+	n, err := NewAccountNumber(7992739871)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(79927398713), n)
+	assert.NoError(t, ValidateAccountNumber(n))
+
+	_, err = NewAccountNumber(999)
+	assert.ErrorIs(t, err, ErrInvalidAccountNumber)
+}
+
+func TestValidateAccountNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  int64
+		wantErr error
+	}{
+		{"valid check digit", 79927398713, nil},
+		{"wrong check digit", 79927398714, ErrInvalidAccountNumber},
+		{"transposed digits", 97927398713, ErrInvalidAccountNumber},
+		{"legacy 10-digit number", 1000000026, nil},
+		{"too short", 12345, ErrInvalidAccountNumber},
+		{"too long", 799273987130, ErrInvalidAccountNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, ValidateAccountNumber(tt.number))
+		})
+	}
+}
+
+func TestParseAccountNumber(t *testing.T) {
+	n, err := ParseAccountNumber("7992 7398 713")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(79927398713), n)
+
+	_, err = ParseAccountNumber("79927398714")
+	assert.Equal(t, ErrInvalidAccountNumber, err)
+
+	_, err = ParseAccountNumber("7992739871a")
+	assert.Equal(t, ErrInvalidAccountNumber, err)
+}
+
+func TestIBAN(t *testing.T) {
+	t.Run("known valid IBAN", func(t *testing.T) {
+		iban, err := NormalizeIBAN("gb82 west 1234 5698 7654 32")
+		assert.NoError(t, err)
+		assert.Equal(t, "GB82WEST12345698765432", iban)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		iban, err := FormatIBAN("TR", "000610", 79927398713)
+		assert.NoError(t, err)
+		assert.Len(t, iban, 26)
+
+		_, err = NormalizeIBAN(iban)
+		assert.NoError(t, err)
+
+		n, err := AccountNumberFromIBAN(iban, "000610")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(79927398713), n)
+	})
+
+	t.Run("wrong check digits", func(t *testing.T) {
+		_, err := NormalizeIBAN("GB83WEST12345698765432")
+		assert.Equal(t, ErrInvalidIBAN, err)
+	})
+
+	t.Run("other bank", func(t *testing.T) {
+		iban, err := FormatIBAN("TR", "000620", 79927398713)
+		assert.NoError(t, err)
+
+		_, err = AccountNumberFromIBAN(iban, "000610")
+		assert.Equal(t, ErrInvalidIBAN, err)
+	})
+}
@@ -0,0 +1,83 @@
+// file: model/approval.go
+
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Actions that can require approval.
+const (
+	ApprovalActionDeposit    = "deposit"
+	ApprovalActionRoleChange = "role_change"
+)
+
+// Statuses of an approval request. A pending request expires once its
+// ExpiresAt passes. An approved request is carried out in the transaction that
+// records the approval, so it is executed, or failed if carrying it out failed.
+const (
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusRejected = "rejected"
+	ApprovalStatusExpired  = "expired"
+	ApprovalStatusExecuted = "executed"
+	ApprovalStatusFailed   = "failed"
+)
+
+// ApprovalRequest is a sensitive admin action one admin (the maker) asked for,
+// waiting for or decided by another admin (the checker). Payload holds the
+// details of the action: a DepositApproval or a RoleChangeApproval. Requests
+// are kept after they are decided, as the history of four-eyes decisions.
+type ApprovalRequest struct {
+	ID        int             `json:"id"`
+	Action    string          `json:"action"`
+	Payload   json.RawMessage `json:"payload" swaggertype:"object"`
+	Status    string          `json:"status"`
+	MakerID   int             `json:"maker_id"`
+	CheckerID *int            `json:"checker_id,omitempty"`
+	Comment   string          `json:"comment,omitempty"`
+	Failure   string          `json:"failure,omitempty"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	DecidedAt *time.Time      `json:"decided_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// DepositApproval is the payload of a deposit awaiting approval.
+type DepositApproval struct {
+	AccountID int   `json:"account_id"`
+	Amount    Money `json:"amount"`
+}
+
+// RoleChangeApproval is the payload of a role change awaiting approval.
+type RoleChangeApproval struct {
+	UserID int  `json:"user_id"`
+	Role   Role `json:"role"`
+}
+
+// ApprovalDecision is a checker's decision on a pending request: the status
+// it moves to, the checker's comment and, for failed requests, the reason.
+type ApprovalDecision struct {
+	Status    string
+	CheckerID int
+	Comment   string
+	Failure   string
+}
+
+// ApprovalFilter selects one page of approval requests.
+type ApprovalFilter struct {
+	Status    string
+	Action    string
+	MakerID   int
+	Limit     int
+	After     *Cursor
+	Ascending bool
+}
+
+// ApprovalPage is one page of approval requests. Total counts every request
+// matching the filter, across all pages.
+type ApprovalPage struct {
+	Requests   []*ApprovalRequest `json:"requests"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
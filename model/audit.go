@@ -0,0 +1,153 @@
+// file: model/audit.go
+
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Kinds of audit events.
+const (
+	AuditEventLogin          = "login"
+	AuditEventLoginFailed    = "login_failed"
+	AuditEventLogout         = "logout"
+	AuditEventRoleChanged    = "role_changed"
+	AuditEventDeposit        = "deposit"
+	AuditEventTransfer       = "transfer"
+	AuditEventFee            = "fee"
+	AuditEventReversal       = "reversal"
+	AuditEventAccountCreated = "account_created"
+)
+
+// Kinds of objects an audit event can be about.
+const (
+	AuditTargetUser        = "user"
+	AuditTargetAccount     = "account"
+	AuditTargetTransaction = "transaction"
+)
+
+// AuditGenesisHash is the PrevHash of the first event in the audit log.
+var AuditGenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// AuditEvent is an entry in the append-only audit log of security and money
+// events. ActorID is the user who caused the event, if known; Before and After
+// hold the state of the target around the event. Each event's Hash covers its
+// fields and the Hash of the event before it, so changing, inserting or
+// deleting an event breaks the chain from that point on.
+type AuditEvent struct {
+	ID         int             `json:"id"`
+	Event      string          `json:"event"`
+	ActorID    *int            `json:"actor_id,omitempty"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"created_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// ComputeHash returns the hash the event should have: the SHA-256 of PrevHash
+// followed by the event's fields as JSON. Before and After are hashed in
+// canonical form, so the hash survives the database reformatting them, and
+// CreatedAt is hashed in UTC at the microsecond precision the database keeps.
+func (e *AuditEvent) ComputeHash() (string, error) {
+	before, err := canonicalJSON(e.Before)
+	if err != nil {
+		return "", err
+	}
+	after, err := canonicalJSON(e.After)
+	if err != nil {
+		return "", err
+	}
+	fields, err := json.Marshal(struct {
+		Event      string          `json:"event"`
+		ActorID    *int            `json:"actor_id"`
+		TargetType string          `json:"target_type"`
+		TargetID   string          `json:"target_id"`
+		IPAddress  string          `json:"ip_address"`
+		UserAgent  string          `json:"user_agent"`
+		RequestID  string          `json:"request_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		CreatedAt  string          `json:"created_at"`
+	}{
+		Event:      e.Event,
+		ActorID:    e.ActorID,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IPAddress:  e.IPAddress,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		Before:     before,
+		After:      after,
+		CreatedAt:  e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(e.PrevHash))
+	h.Write(fields)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalJSON re-encodes a JSON document with object keys sorted and
+// insignificant whitespace removed. Numbers are kept as written. Empty input
+// stays empty.
+func canonicalJSON(doc json.RawMessage) (json.RawMessage, error) {
+	if len(doc) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// AuditFilter selects one page of audit events. Zero values match everything.
+type AuditFilter struct {
+	Event      string
+	ActorID    int
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	After      *Cursor
+	Ascending  bool
+}
+
+// AuditPage is one page of audit events. Total counts every event matching
+// the filter, across all pages.
+type AuditPage struct {
+	Events     []*AuditEvent `json:"events"`
+	Total      int           `json:"total"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// AuditReport is the result of verifying the hash chain of the audit log.
+// FirstBrokenID is the first event whose hash or link to the previous event
+// does not match, and LastHash the hash of the last event checked, which can
+// be compared with a copy kept elsewhere to detect events cut off the end.
+type AuditReport struct {
+	CheckedAt     time.Time `json:"checked_at"`
+	Healthy       bool      `json:"healthy"`
+	EventsChecked int       `json:"events_checked"`
+	FirstBrokenID int       `json:"first_broken_id,omitempty"`
+	Problem       string    `json:"problem,omitempty"`
+	LastHash      string    `json:"last_hash"`
+}
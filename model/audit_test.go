@@ -0,0 +1,48 @@
+// file: model/audit_test.go
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditEvent_ComputeHash(t *testing.T) {
+	actorID := 4
+	event := AuditEvent{
+		Event:      AuditEventDeposit,
+		ActorID:    &actorID,
+		TargetType: AuditTargetAccount,
+		TargetID:   "12",
+		After:      json.RawMessage(`{"balance":{"amount":"100.00","currency":"USD"},"transaction_id":7}`),
+		CreatedAt:  time.Date(2025, 3, 14, 9, 26, 53, 589793238, time.UTC),
+		PrevHash:   AuditGenesisHash,
+	}
+	hash, err := event.ComputeHash()
+	assert.NoError(t, err)
+	assert.Len(t, hash, 64)
+
+	// The database reorders keys, reformats the JSON and keeps microseconds
+	// in its own time zone; none of that changes the hash.
+	stored := event
+	stored.After = json.RawMessage(`{"transaction_id": 7, "balance": {"currency": "USD", "amount": "100.00"}}`)
+	stored.CreatedAt = event.CreatedAt.Truncate(time.Microsecond).In(time.FixedZone("UTC+3", 3*60*60))
+	storedHash, err := stored.ComputeHash()
+	assert.NoError(t, err)
+	assert.Equal(t, hash, storedHash)
+
+	changed := stored
+	changed.TargetID = "13"
+	changedHash, err := changed.ComputeHash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changedHash)
+
+	relinked := stored
+	relinked.PrevHash = changedHash
+	relinkedHash, err := relinked.ComputeHash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, relinkedHash)
+}
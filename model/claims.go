@@ -2,8 +2,15 @@ package model
 
 import "github.com/golang-jwt/jwt/v5"
 
+// AppClaims are the claims of an access token. SessionID identifies the
+// session the token was issued to, and the registered ID claim (jti) the token
+// itself, so that either can be revoked before the token expires.
+// EmailVerified is whether the user had verified their email address when the
+// token was issued.
 type AppClaims struct {
-	UserID int    `json:"user_id"`
-	Role   string `json:"role"`
+	UserID        int    `json:"user_id"`
+	Role          string `json:"role"`
+	SessionID     int    `json:"sid,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
 	jwt.RegisteredClaims
 }
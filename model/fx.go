@@ -0,0 +1,164 @@
+// file: model/fx.go
+
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// RateDecimals is the number of decimal places exchange rates are kept to.
+const RateDecimals = 8
+
+const rateScale = 100_000_000 // 10^RateDecimals
+
+// Rate is an exchange rate, the number of units of the target currency one
+// unit of the source currency buys, as a fixed-point number with RateDecimals
+// decimal places. Like Money it never passes through float64.
+type Rate int64
+
+// ParseRate converts a positive decimal string such as "32.4512" into a Rate.
+// Digits beyond RateDecimals are rounded half up.
+func ParseRate(s string) (Rate, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 || strings.ContainsAny(s, "eE/") {
+		return 0, ErrInvalidRate
+	}
+	scaled := roundHalfUp(new(big.Rat).Mul(r, big.NewRat(rateScale, 1)))
+	if !scaled.IsInt64() || scaled.Sign() <= 0 {
+		return 0, ErrInvalidRate
+	}
+	return Rate(scaled.Int64()), nil
+}
+
+// roundHalfUp rounds a non-negative rational to the nearest integer.
+func roundHalfUp(r *big.Rat) *big.Int {
+	num := new(big.Int).Mul(r.Num(), big.NewInt(2))
+	num.Add(num, r.Denom())
+	den := new(big.Int).Mul(r.Denom(), big.NewInt(2))
+	return num.Quo(num, den)
+}
+
+// String formats the rate with all RateDecimals decimal places, e.g. "32.45120000".
+func (r Rate) String() string {
+	digits := strconv.FormatInt(int64(r), 10)
+	if len(digits) <= RateDecimals {
+		digits = strings.Repeat("0", RateDecimals-len(digits)+1) + digits
+	}
+	return digits[:len(digits)-RateDecimals] + "." + digits[len(digits)-RateDecimals:]
+}
+
+// Inverse returns the rate for the opposite direction, rounded half up.
+func (r Rate) Inverse() (Rate, error) {
+	if r <= 0 {
+		return 0, ErrInvalidRate
+	}
+	inverse := roundHalfUp(big.NewRat(rateScale*rateScale, int64(r)))
+	if inverse.Sign() <= 0 || !inverse.IsInt64() {
+		return 0, ErrInvalidRate
+	}
+	return Rate(inverse.Int64()), nil
+}
+
+// WithSpread returns the rate a customer is given once the bank's spread, in
+// basis points, has been taken off the mid-market rate. It rounds down, in the
+// bank's favour.
+func (r Rate) WithSpread(spreadBps int) Rate {
+	adjusted := new(big.Int).Mul(big.NewInt(int64(r)), big.NewInt(int64(10000-spreadBps)))
+	return Rate(adjusted.Quo(adjusted, big.NewInt(10000)).Int64())
+}
+
+// Convert converts a positive amount into the target currency at the rate.
+// The result is rounded down to the target currency's minor unit, so the bank
+// never pays out more than the rate allows.
+func (r Rate) Convert(amount Money, targetCurrency string) (Money, error) {
+	sourceExp, err := CurrencyExponent(amount.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	targetExp, err := CurrencyExponent(targetCurrency)
+	if err != nil {
+		return Money{}, err
+	}
+	num := new(big.Int).Mul(big.NewInt(amount.MinorUnits), big.NewInt(int64(r)))
+	num.Mul(num, pow10(targetExp))
+	den := new(big.Int).Mul(big.NewInt(rateScale), pow10(sourceExp))
+	converted := num.Quo(num, den)
+	if !converted.IsInt64() {
+		return Money{}, ErrAmountOutOfRange
+	}
+	return NewMoney(converted.Int64(), targetCurrency), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// MarshalJSON encodes the rate as a decimal string.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON decodes a rate from a decimal string.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, sending the rate to NUMERIC columns as an
+// exact decimal string.
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// FXDetails records how a cross-currency transaction was priced. The
+// transaction's own amount is the source amount.
+type FXDetails struct {
+	DestinationAmount Money   `json:"destination_amount"`
+	MidRate           Rate    `json:"mid_rate" swaggertype:"string" example:"32.45120000"`
+	Rate              Rate    `json:"rate" swaggertype:"string" example:"32.28894400"`
+	SpreadBps         int     `json:"spread_bps" example:"50"`
+	QuoteID           *string `json:"quote_id,omitempty"`
+}
+
+// FXQuote locks the price of a cross-currency transfer of a given amount
+// until it expires. A quote can be used for one transfer only.
+type FXQuote struct {
+	ID                string     `json:"id"`
+	UserID            int        `json:"user_id"`
+	SourceAmount      Money      `json:"source_amount"`
+	DestinationAmount Money      `json:"destination_amount"`
+	MidRate           Rate       `json:"mid_rate" swaggertype:"string" example:"32.45120000"`
+	Rate              Rate       `json:"rate" swaggertype:"string" example:"32.28894400"`
+	SpreadBps         int        `json:"spread_bps" example:"50"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	UsedAt            *time.Time `json:"used_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// Details returns the pricing of a transfer made with the quote.
+func (q *FXQuote) Details() *FXDetails {
+	id := q.ID
+	return &FXDetails{
+		DestinationAmount: q.DestinationAmount,
+		MidRate:           q.MidRate,
+		Rate:              q.Rate,
+		SpreadBps:         q.SpreadBps,
+		QuoteID:           &id,
+	}
+}
@@ -0,0 +1,72 @@
+// file: model/fx_test.go
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Rate
+		wantErr error
+	}{
+		{"whole rate", "32", 3200000000, nil},
+		{"decimal rate", "32.4512", 3245120000, nil},
+		{"small rate", "0.00030769", 30769, nil},
+		{"rounds beyond eight decimals", "1.123456785", 112345679, nil},
+		{"zero", "0", 0, ErrInvalidRate},
+		{"negative", "-1.5", 0, ErrInvalidRate},
+		{"exponent notation", "1e3", 0, ErrInvalidRate},
+		{"fraction", "1/3", 0, ErrInvalidRate},
+		{"garbage", "abc", 0, ErrInvalidRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRate(tt.input)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRate_Arithmetic(t *testing.T) {
+	rate, _ := ParseRate("32.50")
+	assert.Equal(t, "32.50000000", rate.String())
+
+	inverse, err := rate.Inverse()
+	assert.NoError(t, err)
+	assert.Equal(t, "0.03076923", inverse.String())
+
+	assert.Equal(t, "32.33750000", rate.WithSpread(50).String())
+
+	converted, err := rate.Convert(NewMoney(10000, "USD"), "TRY")
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(325000, "TRY"), converted)
+
+	// Conversions round down to the target currency's minor unit.
+	yen, _ := ParseRate("151.237")
+	converted, err = yen.Convert(NewMoney(1999, "USD"), "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(3023, "JPY"), converted)
+
+	_, err = rate.Convert(NewMoney(100, "USD"), "XXX")
+	assert.Equal(t, ErrUnsupportedCurrency, err)
+}
+
+func TestRate_JSONRoundTrip(t *testing.T) {
+	rate, _ := ParseRate("1.0825")
+	data, err := json.Marshal(rate)
+	assert.NoError(t, err)
+	assert.Equal(t, `"1.08250000"`, string(data))
+
+	var decoded Rate
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, rate, decoded)
+}
@@ -0,0 +1,45 @@
+// file: model/health.go
+
+package model
+
+// Statuses reported by the readiness probe.
+const (
+	HealthReady        = "ready"
+	HealthNotReady     = "not_ready"
+	HealthShuttingDown = "shutting_down"
+	HealthUp           = "up"
+	HealthDown         = "down"
+)
+
+// DependencyHealth is the outcome of checking one dependency. LatencyMS is
+// how long the check took, in milliseconds; Error says why a dependency is
+// down.
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// MigrationHealth is the outcome of checking the database schema. Version is
+// the version the database is at and Expected the newest migration the API
+// ships with. A dirty schema is one a migration failed half way through.
+type MigrationHealth struct {
+	DependencyHealth
+	Version  uint `json:"version"`
+	Expected uint `json:"expected"`
+	Dirty    bool `json:"dirty"`
+}
+
+// ReadinessChecks holds the outcome of each dependency check.
+type ReadinessChecks struct {
+	Database   *DependencyHealth `json:"database,omitempty"`
+	Redis      *DependencyHealth `json:"redis,omitempty"`
+	Migrations *MigrationHealth  `json:"migrations,omitempty"`
+}
+
+// ReadinessReport is the response of the readiness probe. The dependencies are
+// not checked while the API is shutting down.
+type ReadinessReport struct {
+	Status string          `json:"status"`
+	Checks ReadinessChecks `json:"checks"`
+}
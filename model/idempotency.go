@@ -0,0 +1,23 @@
+// file: model/idempotency.go
+
+package model
+
+import "time"
+
+// IdempotencyRecord holds the stored outcome of a request made with an Idempotency-Key.
+// A record without a StatusCode belongs to a request that is still being processed.
+type IdempotencyRecord struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	Key          string    `json:"key"`
+	Fingerprint  string    `json:"fingerprint"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody []byte    `json:"response_body"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Completed reports whether a response has been stored for the record.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,21 @@
+// file: model/jwks.go
+
+package model
+
+// JWK is the public half of a token signing key in JSON Web Key format
+// (RFC 7517). N and E are set for RSA keys, Crv and X for Ed25519 keys.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
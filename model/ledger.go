@@ -0,0 +1,110 @@
+// file: model/ledger.go
+
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// Account kinds. Customer accounts belong to a user; system accounts are owned
+// by the bank and act as the counterparty of deposits, fees and similar movements.
+const (
+	AccountKindCustomer   = "customer"
+	AccountKindSettlement = "settlement"  // Cash/settlement: funds entering or leaving the bank.
+	AccountKindFeeIncome  = "fee_income"  // Fees charged to customers.
+	AccountKindFXPosition = "fx_position" // The bank's position in a currency from cross-currency transfers.
+)
+
+// Kinds of money movement. Every transaction row has exactly one journal entry
+// of the same kind.
+const (
+	EntryKindTransfer       = "transfer"
+	EntryKindDeposit        = "deposit"
+	EntryKindFee            = "fee"
+	EntryKindReversal       = "reversal"
+	EntryKindOpeningBalance = "opening_balance"
+)
+
+var (
+	ErrEntryTooFewPostings = errors.New("a journal entry needs at least two postings")
+	ErrEntryNotBalanced    = errors.New("journal entry postings do not sum to zero in every currency")
+	ErrEntryZeroPosting    = errors.New("journal entry postings must not be zero")
+)
+
+// Posting is one leg of a journal entry. The amount is signed: a positive amount
+// credits the account (increases its balance), a negative amount debits it.
+type Posting struct {
+	ID             int       `json:"id"`
+	JournalEntryID int       `json:"journal_entry_id"`
+	AccountID      int       `json:"account_id"`
+	Amount         Money     `json:"amount"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Debit creates a posting that decreases the account's balance by amount.
+func Debit(accountID int, amount Money) Posting {
+	return Posting{AccountID: accountID, Amount: amount.Negate()}
+}
+
+// Credit creates a posting that increases the account's balance by amount.
+func Credit(accountID int, amount Money) Posting {
+	return Posting{AccountID: accountID, Amount: amount}
+}
+
+// JournalEntry records a single balanced money movement.
+type JournalEntry struct {
+	ID              int       `json:"id"`
+	Kind            string    `json:"kind"`
+	TransactionID   int       `json:"transaction_id"`
+	ReversesEntryID *int      `json:"reverses_entry_id,omitempty"`
+	Description     string    `json:"description"`
+	Postings        []Posting `json:"postings"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Validate checks the double-entry invariant: at least two non-zero postings
+// whose amounts sum to zero in each currency.
+func (e *JournalEntry) Validate() error {
+	if len(e.Postings) < 2 {
+		return ErrEntryTooFewPostings
+	}
+	sums := make(map[string]Money)
+	for _, p := range e.Postings {
+		if p.Amount.IsZero() {
+			return ErrEntryZeroPosting
+		}
+		sum, ok := sums[p.Amount.Currency]
+		if !ok {
+			sum = NewMoney(0, p.Amount.Currency)
+		}
+		next, err := sum.Add(p.Amount)
+		if err != nil {
+			return err
+		}
+		sums[p.Amount.Currency] = next
+	}
+	for _, sum := range sums {
+		if !sum.IsZero() {
+			return ErrEntryNotBalanced
+		}
+	}
+	return nil
+}
+
+// BalanceMismatch describes an account whose stored balance differs from the
+// sum of its postings.
+type BalanceMismatch struct {
+	AccountID     int    `json:"account_id"`
+	Balance       string `json:"balance"`
+	PostedBalance string `json:"posted_balance"`
+	Currency      string `json:"currency"`
+}
+
+// LedgerReport is the result of verifying account balances against postings.
+type LedgerReport struct {
+	CheckedAt         time.Time         `json:"checked_at"`
+	Healthy           bool              `json:"healthy"`
+	BalanceMismatches []BalanceMismatch `json:"balance_mismatches"`
+	UnbalancedEntries []int             `json:"unbalanced_entries"`
+}
@@ -0,0 +1,26 @@
+// file: model/login_event.go
+
+package model
+
+import "time"
+
+// Kinds of login events.
+const (
+	LoginEventFailed   = "login_failed"
+	LoginEventLocked   = "account_locked"
+	LoginEventUnlocked = "account_unlocked"
+)
+
+// LoginEvent is an entry in the audit trail of failed logins, lockouts and
+// unlocks. UserID is zero for failed logins with an unknown email address, and
+// ActorID is the admin who unlocked the account.
+type LoginEvent struct {
+	ID        int64     `json:"id"`
+	UserID    int       `json:"user_id,omitempty"`
+	Email     string    `json:"email"`
+	Event     string    `json:"event"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	ActorID   int       `json:"actor_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
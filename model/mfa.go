@@ -0,0 +1,29 @@
+// file: model/mfa.go
+
+package model
+
+import "time"
+
+// TOTPCredential is a user's TOTP authenticator. It is only enforced at login
+// once ConfirmedAt is set. LastUsedStep is the time step of the last accepted
+// code; codes from that step or earlier are rejected.
+type TOTPCredential struct {
+	UserID       int        `json:"user_id"`
+	Secret       string     `json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TOTPEnrollment is returned when a user starts enrolling an authenticator
+// app. OTPAuthURI is usually shown as a QR code.
+type TOTPEnrollment struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/Go%20Bank:user@example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=Go%20Bank&algorithm=SHA1&digits=6&period=30"`
+}
+
+// RecoveryCodes are shown to the user once, when two-factor authentication is
+// turned on. Each code can replace an authenticator code at login once.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
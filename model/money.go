@@ -0,0 +1,228 @@
+// file: model/money.go
+
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedCurrency   = errors.New("unsupported currency")
+	ErrInvalidAmountFormat   = errors.New("amount must be a plain decimal number")
+	ErrExcessPrecision       = errors.New("amount has more decimal places than the currency allows")
+	ErrAmountOutOfRange      = errors.New("amount is out of range")
+	ErrMoneyCurrencyMismatch = errors.New("cannot combine amounts in different currencies")
+)
+
+// currencyExponents maps supported ISO 4217 currency codes to the number of
+// decimal places (minor unit exponent) the currency allows.
+var currencyExponents = map[string]int{
+	"TRY": 2,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CHF": 2,
+	"JPY": 0,
+}
+
+// CurrencyExponent returns the number of decimal places allowed for a currency.
+func CurrencyExponent(currency string) (int, error) {
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+	return exp, nil
+}
+
+// Money is an exact monetary value: an integer number of minor units
+// (e.g. cents) in a given ISO 4217 currency. It never passes through float64.
+type Money struct {
+	MinorUnits int64
+	Currency   string
+}
+
+// NewMoney creates a Money value from an amount already expressed in minor units.
+func NewMoney(minorUnits int64, currency string) Money {
+	return Money{MinorUnits: minorUnits, Currency: currency}
+}
+
+// ParseMoney converts a decimal string such as "150.75" into Money.
+// Trailing zeros beyond the currency's precision are accepted ("100.00" JPY),
+// but any significant digit beyond it is rejected with ErrExcessPrecision.
+func ParseMoney(s, currency string) (Money, error) {
+	exp, err := CurrencyExponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if !isDigits(intPart) || (hasDot && !isDigits(fracPart)) {
+		return Money{}, ErrInvalidAmountFormat
+	}
+
+	if len(fracPart) > exp {
+		if strings.Trim(fracPart[exp:], "0") != "" {
+			return Money{}, ErrExcessPrecision
+		}
+		fracPart = fracPart[:exp]
+	}
+	fracPart += strings.Repeat("0", exp-len(fracPart))
+
+	minorUnits, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return Money{}, ErrAmountOutOfRange
+	}
+	if negative {
+		minorUnits = -minorUnits
+	}
+	return Money{MinorUnits: minorUnits, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decimal formats the amount as a plain decimal string with exactly the
+// currency's number of decimal places, e.g. "150.75" or "-3.00".
+func (m Money) Decimal() string {
+	exp := currencyExponents[m.Currency]
+
+	sign := ""
+	abs := uint64(m.MinorUnits)
+	if m.MinorUnits < 0 {
+		sign = "-"
+		abs = uint64(-(m.MinorUnits + 1)) + 1 // Avoids overflow on math.MinInt64.
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String returns a human readable representation, e.g. "150.75 TRY".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool     { return m.MinorUnits == 0 }
+func (m Money) IsPositive() bool { return m.MinorUnits > 0 }
+func (m Money) IsNegative() bool { return m.MinorUnits < 0 }
+
+// Negate returns the amount with its sign flipped.
+func (m Money) Negate() Money {
+	return Money{MinorUnits: -m.MinorUnits, Currency: m.Currency}
+}
+
+// Add returns m + other. Both values must be in the same currency.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrMoneyCurrencyMismatch
+	}
+	if (other.MinorUnits > 0 && m.MinorUnits > math.MaxInt64-other.MinorUnits) ||
+		(other.MinorUnits < 0 && m.MinorUnits < math.MinInt64-other.MinorUnits) {
+		return Money{}, ErrAmountOutOfRange
+	}
+	return Money{MinorUnits: m.MinorUnits + other.MinorUnits, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other. Both values must be in the same currency.
+func (m Money) Sub(other Money) (Money, error) {
+	if other.MinorUnits == math.MinInt64 {
+		return Money{}, ErrAmountOutOfRange
+	}
+	return m.Add(other.Negate())
+}
+
+// moneyJSON is the wire representation of Money. The amount is encoded as a
+// decimal string so that JSON clients never have to round-trip through floats.
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes Money as {"amount": "150.75", "currency": "TRY"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes the representation produced by MarshalJSON.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Currency == "" && (raw.Amount == "" || raw.Amount == "0") {
+		*m = Money{}
+		return nil
+	}
+	parsed, err := ParseMoney(raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, sending the amount to NUMERIC columns as an
+// exact decimal string.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// Amount is a decimal amount supplied by a client whose currency is implied by
+// the account it applies to. It keeps the literal text of the JSON number or
+// string so it can be converted to Money exactly with ParseMoney.
+type Amount string
+
+// UnmarshalJSON accepts both JSON numbers (150.75) and strings ("150.75").
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*a = Amount(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*a = Amount(n.String())
+	return nil
+}
+
+// In converts the amount to Money in the given currency.
+func (a Amount) In(currency string) (Money, error) {
+	return ParseMoney(string(a), currency)
+}
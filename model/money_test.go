@@ -0,0 +1,86 @@
+// file: model/money_test.go
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{"whole amount", "500", "TRY", NewMoney(50000, "TRY"), nil},
+		{"two decimals", "150.75", "USD", NewMoney(15075, "USD"), nil},
+		{"one decimal", "0.1", "EUR", NewMoney(10, "EUR"), nil},
+		{"negative", "-3.20", "EUR", NewMoney(-320, "EUR"), nil},
+		{"trailing zeros beyond precision", "100.000", "TRY", NewMoney(10000, "TRY"), nil},
+		{"zero-decimal currency", "1200.00", "JPY", NewMoney(1200, "JPY"), nil},
+		{"excess precision", "10.005", "TRY", Money{}, ErrExcessPrecision},
+		{"excess precision for zero-decimal currency", "10.5", "JPY", Money{}, ErrExcessPrecision},
+		{"exponent notation", "1e3", "TRY", Money{}, ErrInvalidAmountFormat},
+		{"missing integer part", ".50", "TRY", Money{}, ErrInvalidAmountFormat},
+		{"empty", "", "TRY", Money{}, ErrInvalidAmountFormat},
+		{"out of range", "99999999999999999999", "TRY", Money{}, ErrAmountOutOfRange},
+		{"unsupported currency", "1.00", "XXX", Money{}, ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMoney(tt.input, tt.currency)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	balance := NewMoney(50000, "TRY")
+
+	sum, err := balance.Add(NewMoney(15075, "TRY"))
+	assert.NoError(t, err)
+	assert.Equal(t, "650.75", sum.Decimal())
+
+	diff, err := balance.Sub(NewMoney(50001, "TRY"))
+	assert.NoError(t, err)
+	assert.True(t, diff.IsNegative())
+	assert.Equal(t, "-0.01", diff.Decimal())
+
+	_, err = balance.Add(NewMoney(1, "USD"))
+	assert.Equal(t, ErrMoneyCurrencyMismatch, err)
+}
+
+func TestMoney_JSONRoundTrip(t *testing.T) {
+	original := NewMoney(15075, "USD")
+
+	data, err := json.Marshal(original)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"150.75","currency":"USD"}`, string(data))
+
+	var decoded Money
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, original, decoded)
+}
+
+func TestAmount_UnmarshalJSON(t *testing.T) {
+	var req struct {
+		Amount Amount `json:"amount"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 150.75}`), &req))
+	assert.Equal(t, Amount("150.75"), req.Amount)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "0.10"}`), &req))
+	assert.Equal(t, Amount("0.10"), req.Amount)
+
+	money, err := req.Amount.In("EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(10, "EUR"), money)
+}
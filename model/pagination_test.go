@@ -0,0 +1,26 @@
+// file: model/pagination_test.go
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2025, 3, 14, 9, 26, 53, 589793000, time.UTC), ID: 42}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, 42, decoded.ID)
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "bm8tc2VwYXJhdG9y", Cursor{CreatedAt: time.Now()}.Encode()} {
+		_, err := DecodeCursor(s)
+		assert.Equal(t, ErrInvalidCursor, err, s)
+	}
+}
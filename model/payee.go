@@ -0,0 +1,31 @@
+// file: model/payee.go
+
+package model
+
+import "time"
+
+const (
+	PayeeStatusUnverified = "unverified"
+	PayeeStatusVerified   = "verified"
+)
+
+// Payee is a beneficiary a user has saved for repeated transfers. HolderName
+// is the masked name shown to the user when the payee was saved; the payee can
+// only be used for transfers after the user has confirmed it.
+type Payee struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	Nickname      string     `json:"nickname"`
+	AccountNumber int64      `json:"account_number"`
+	Currency      string     `json:"currency"`
+	HolderName    string     `json:"holder_name"`
+	Status        string     `json:"status"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Verified reports whether the user has confirmed the payee.
+func (p *Payee) Verified() bool {
+	return p.Status == PayeeStatusVerified
+}
@@ -0,0 +1,57 @@
+// file: model/permission.go
+
+package model
+
+// Permission allows one kind of privileged operation. Roles grant sets of
+// permissions; routes require them.
+type Permission string
+
+const (
+	PermissionUsersRead           Permission = "users:read"
+	PermissionUsersRoleWrite      Permission = "users:role:write"
+	PermissionUsersUnlock         Permission = "users:unlock"
+	PermissionAccountsRead        Permission = "accounts:read"
+	PermissionAccountsDeposit     Permission = "accounts:deposit"
+	PermissionAccountsFee         Permission = "accounts:fee"
+	PermissionTransactionsReverse Permission = "transactions:reverse"
+	PermissionLedgerRead          Permission = "ledger:read"
+	PermissionRolesRead           Permission = "roles:read"
+	PermissionRolesWrite          Permission = "roles:write"
+	PermissionApprovalsRead       Permission = "approvals:read"
+	PermissionApprovalsDecide     Permission = "approvals:decide"
+	PermissionAuditRead           Permission = "audit:read"
+)
+
+// PermissionInfo describes a permission for the admin API.
+type PermissionInfo struct {
+	Name        Permission `json:"name"`
+	Description string     `json:"description"`
+}
+
+// Permissions lists every permission the API checks. Roles can only grant
+// permissions from this list.
+var Permissions = []PermissionInfo{
+	{PermissionUsersRead, "List users"},
+	{PermissionUsersRoleWrite, "Change the role of users"},
+	{PermissionUsersUnlock, "Unlock accounts locked after failed logins"},
+	{PermissionAccountsRead, "List all accounts"},
+	{PermissionAccountsDeposit, "Deposit funds into accounts"},
+	{PermissionAccountsFee, "Charge fees to accounts"},
+	{PermissionTransactionsReverse, "Reverse transactions"},
+	{PermissionLedgerRead, "Verify the ledger"},
+	{PermissionRolesRead, "List roles and permissions"},
+	{PermissionRolesWrite, "Create, change and delete roles"},
+	{PermissionApprovalsRead, "List requests for approval"},
+	{PermissionApprovalsDecide, "Approve or reject other admins' requests"},
+	{PermissionAuditRead, "Search and verify the audit log"},
+}
+
+// Valid reports whether the permission is one the API checks.
+func (p Permission) Valid() bool {
+	for _, info := range Permissions {
+		if info.Name == p {
+			return true
+		}
+	}
+	return false
+}
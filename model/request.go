@@ -4,26 +4,125 @@ package model
 
 // RegisterRequest defines the payload for creating a new user.
 // It includes validation tags to ensure data integrity at the entry point.
+// The password is checked against the configurable password policy instead.
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
 }
 
-// LoginRequest defines the payload for user authentication.
+// LoginRequest defines the payload for user authentication. The optional
+// device name labels the session in the user's session list.
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=8"`
+	DeviceName string `json:"device_name,omitempty" validate:"omitempty,max=100" example:"Work laptop"`
+}
+
+// MFACodeRequest defines the payload for confirming a TOTP enrollment with a
+// code from the authenticator app.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric" example:"123456"`
+}
+
+// MFALoginRequest defines the payload for the second step of a login with
+// two-factor authentication. Code is a code from the authenticator app or one
+// of the recovery codes.
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32" example:"123456"`
+}
+
+// ForgotPasswordRequest defines the payload for requesting a password reset link.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest defines the payload for choosing a new password with
+// the token from a password reset link. The password is checked against the
+// password policy.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,max=128"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ChangePasswordRequest defines the payload for an authenticated user
+// changing their password. The new password is checked against the password
+// policy.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// VerifyEmailRequest defines the payload for verifying an email address with
+// the token from a verification link.
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
 }
 
 // UpdateUserRoleRequest defines the payload for updating a user's role.
 // Using a dedicated struct instead of an inline anonymous struct in the handler
 // improves code clarity, reusability, and compatibility with tooling like swag.
 type UpdateUserRoleRequest struct {
-	Role Role `json:"role" validate:"required,oneof=admin user"`
+	Role Role `json:"role" validate:"required,max=20" example:"support"`
+}
+
+// CreateRoleRequest defines the payload for defining a new role.
+type CreateRoleRequest struct {
+	Name        Role         `json:"name" validate:"required,max=20" example:"support"`
+	Description string       `json:"description" validate:"max=255" example:"Support staff"`
+	Permissions []Permission `json:"permissions" validate:"required,dive,required" example:"users:read"`
+}
+
+// UpdateRoleRequest defines the payload for replacing the description and
+// permissions of a role.
+type UpdateRoleRequest struct {
+	Description string       `json:"description" validate:"max=255" example:"Support staff"`
+	Permissions []Permission `json:"permissions" validate:"required,dive,required" example:"users:read"`
 }
 
 // DepositRequest defines the payload for an admin depositing funds into an account.
+// The amount is interpreted in the target account's currency.
 type DepositRequest struct {
-	Amount float64 `json:"amount" validate:"required,gt=0"`
+	Amount Amount `json:"amount" validate:"required" swaggertype:"string" example:"500.00"`
+}
+
+// FeeRequest defines the payload for an admin charging a fee to an account.
+// The amount is interpreted in the target account's currency.
+type FeeRequest struct {
+	Amount      Amount `json:"amount" validate:"required" swaggertype:"string" example:"2.50"`
+	Description string `json:"description" validate:"required,max=255" example:"Monthly maintenance fee"`
+}
+
+// ApproveRequest defines the payload for approving a request for approval.
+type ApproveRequest struct {
+	Comment string `json:"comment" validate:"max=255" example:"Matches the signed deposit slip"`
+}
+
+// RejectRequest defines the payload for rejecting a request for approval.
+type RejectRequest struct {
+	Comment string `json:"comment" validate:"required,max=255" example:"No supporting documents"`
+}
+
+// CreatePayeeRequest defines the payload for saving a payee. The payee is
+// addressed by exactly one of account_number or iban; currency, when given,
+// must match the payee's account.
+type CreatePayeeRequest struct {
+	Nickname      string `json:"nickname" validate:"required,max=100" example:"Mom"`
+	AccountNumber string `json:"account_number,omitempty" validate:"required_without=IBAN,omitempty,account_number" example:"10000000256"`
+	IBAN          string `json:"iban,omitempty" validate:"omitempty,iban,excluded_with=AccountNumber"`
+	Currency      string `json:"currency,omitempty" validate:"omitempty,len=3" example:"TRY"`
+}
+
+// UpdatePayeeRequest defines the payload for renaming a payee.
+type UpdatePayeeRequest struct {
+	Nickname string `json:"nickname" validate:"required,max=100" example:"Mom"`
+}
+
+// FXQuoteRequest defines the payload for quoting a cross-currency transfer.
+// The amount is interpreted in the source currency.
+type FXQuoteRequest struct {
+	SourceCurrency string `json:"source_currency" validate:"required,len=3" example:"USD"`
+	TargetCurrency string `json:"target_currency" validate:"required,len=3,nefield=SourceCurrency" example:"TRY"`
+	Amount         Amount `json:"amount" validate:"required" swaggertype:"string" example:"100.00"`
 }
@@ -1,8 +1,40 @@
 package model
 
+import "time"
+
 type Role string
 
+// Built-in roles. Their permissions are fixed by migrations and cannot be
+// changed through the API; other roles are defined by admins.
 const (
 	RoleAdmin Role = "admin"
 	RoleUser  Role = "user"
 )
+
+// RoleDefinition is a role as stored in the database: a named set of
+// permissions. Users hold exactly one role.
+type RoleDefinition struct {
+	Name        Role         `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+	BuiltIn     bool         `json:"built_in"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// HasPermissions reports whether the role grants every one of the permissions.
+func (r *RoleDefinition) HasPermissions(permissions ...Permission) bool {
+	for _, want := range permissions {
+		found := false
+		for _, have := range r.Permissions {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
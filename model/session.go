@@ -0,0 +1,26 @@
+// file: model/session.go
+
+package model
+
+import "time"
+
+// Session is one login of a user on one device. Current marks the session the
+// request listing the sessions was made from.
+type Session struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	DeviceName string     `json:"device_name" example:"Work laptop"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current"`
+}
+
+// DeviceInfo describes the device a login comes from.
+type DeviceInfo struct {
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+}
@@ -4,11 +4,18 @@ package model
 
 import "time"
 
-// RefreshToken holds the data for a refresh token in the database.
+// RefreshToken holds the data for a refresh token in the database. It belongs
+// to the session it was issued to. Each refresh replaces the token with a new
+// one in the same family; UsedAt marks a token that has been replaced, and
+// RevokedAt a token whose family or session has been revoked.
 type RefreshToken struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	TokenHash string    `json:"-"` // The hash is not exposed in JSON responses.
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	SessionID int        `json:"session_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"` // The hash is not exposed in JSON responses.
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
 }
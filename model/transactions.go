@@ -4,10 +4,58 @@ import (
 	"time"
 )
 
+// Transaction is a money movement between two accounts. FX is set for
+// cross-currency transfers, in which case Amount is the source amount.
 type Transaction struct {
-	ID            int       `json:"id"`
-	FromAccountID int       `json:"from_account_id"`
-	ToAccountID   int       `json:"to_account_id"`
-	Amount        float64   `json:"amount"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            int        `json:"id"`
+	Kind          string     `json:"kind"`
+	FromAccountID int        `json:"from_account_id"`
+	ToAccountID   int        `json:"to_account_id"`
+	Amount        Money      `json:"amount"`
+	FX            *FXDetails `json:"fx,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Transaction directions relative to the account whose history is listed.
+const (
+	DirectionIncoming = "incoming"
+	DirectionOutgoing = "outgoing"
+)
+
+// TransactionQuery holds the paging, filtering and sorting options a client
+// passes when listing an account's transactions. Amounts are in the account's
+// currency; Counterparty is the other account's number.
+type TransactionQuery struct {
+	Limit        int
+	Cursor       string
+	From         *time.Time
+	To           *time.Time
+	MinAmount    Amount
+	MaxAmount    Amount
+	Direction    string
+	Counterparty string
+	Ascending    bool
+}
+
+// TransactionFilter selects one page of an account's transactions. The amount
+// of a transaction is the amount that moved in or out of the account, which
+// for incoming cross-currency transfers is the destination amount.
+type TransactionFilter struct {
+	AccountID                 int
+	Limit                     int
+	After                     *Cursor
+	From                      *time.Time
+	To                        *time.Time
+	MinAmount                 *Money
+	MaxAmount                 *Money
+	Direction                 string
+	CounterpartyAccountNumber int64
+	Ascending                 bool
+}
+
+// TransactionPage is one page of a transaction history. NextCursor is empty
+// on the last page.
+type TransactionPage struct {
+	Transactions []*Transaction `json:"transactions"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
 }
@@ -2,11 +2,34 @@ package model
 
 import "time"
 
+// User is a registered user. EmailVerifiedAt is unset until the user follows
+// the link mailed to them; unverified users cannot make transfers.
 type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"`
-	Email     string    `json:"email"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              int        `json:"id"`
+	Username        string     `json:"username"`
+	Password        string     `json:"-"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// UserFilter selects one page of users for the admin listing. Search matches
+// the start of the username or email, ignoring case.
+type UserFilter struct {
+	Search    string
+	Role      string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	After     *Cursor
+	Ascending bool
+}
+
+// UserPage is one page of the admin user listing. Total counts every user
+// matching the filter, across all pages.
+type UserPage struct {
+	Users      []*User `json:"users"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"next_cursor,omitempty"`
 }
@@ -2,23 +2,29 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"go-bank-api/logger"
 	"go-bank-api/model"
 
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
 // IAccountRepository defines the contract for all account database operations.
 type IAccountRepository interface {
-	CreateAccount(account *model.Account) error
-	GetAccountByID(accountID int) (*model.Account, error)
-	GetAccountsByUserID(userID int) ([]*model.Account, error)
-	GetAllAccounts() ([]*model.Account, error)
-	GetAccountForUpdate(tx *sql.Tx, accountID int) (*model.Account, error)
-	UpdateAccountBalance(tx *sql.Tx, accountID int, newBalance float64) error
-	DepositToAccount(accountID int, amount float64) (*model.Account, error)
-	GetLastAccountNumber() (int64, error)
+	CreateAccount(ctx context.Context, account *model.Account) error
+	GetAccountByID(ctx context.Context, accountID int) (*model.Account, error)
+	GetAccountByNumber(ctx context.Context, accountNumber int64) (*model.Account, error)
+	GetHolderName(ctx context.Context, accountID int) (string, error)
+	GetAccountsByUserID(ctx context.Context, userID int) ([]*model.Account, error)
+	ListAccounts(ctx context.Context, filter model.AccountFilter) ([]*model.Account, int, error)
+	GetAccountForUpdate(ctx context.Context, tx *sql.Tx, accountID int) (*model.Account, error)
+	GetAccountsForUpdate(ctx context.Context, tx *sql.Tx, accountIDs ...int) (map[int]*model.Account, error)
+	GetSystemAccountForUpdate(ctx context.Context, tx *sql.Tx, kind, currency string) (*model.Account, error)
+	GetSystemAccountsForUpdate(ctx context.Context, tx *sql.Tx, kind string, currencies ...string) (map[string]*model.Account, error)
+	NextAccountNumberBase(ctx context.Context) (int64, error)
 }
 
 // AccountRepository implements IAccountRepository.
@@ -30,30 +36,54 @@ func NewAccountRepository(db *sql.DB) *AccountRepository {
 	return &AccountRepository{DB: db}
 }
 
-// GetLastAccountNumber retrieves the highest account number from the database.
-func (r *AccountRepository) GetLastAccountNumber() (int64, error) {
-	log := logger.Log
-	log.Info("Executing query to get the last account number")
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	var lastAccountNumber sql.NullInt64 // Use sql.NullInt64 to handle the case where the table is empty.
-	query := `SELECT MAX(account_number) FROM accounts`
-	err := r.DB.QueryRow(query).Scan(&lastAccountNumber)
+// accountColumns lists the columns read by scanAccount, in order.
+const accountColumns = `id, user_id, account_number, balance, currency, kind, created_at`
 
+// scanAccount reads a row selected with accountColumns. The NUMERIC balance is
+// scanned as text and converted to Money exactly, without a float64 step.
+// System accounts have no owner, so their UserID is left at zero.
+func scanAccount(row rowScanner) (*model.Account, error) {
+	var account model.Account
+	var userID sql.NullInt64
+	var balance string
+	if err := row.Scan(&account.ID, &userID, &account.AccountNumber, &balance, &account.Currency, &account.Kind, &account.CreatedAt); err != nil {
+		return nil, err
+	}
+	account.UserID = int(userID.Int64)
+	money, err := model.ParseMoney(balance, account.Currency)
 	if err != nil {
-		log.WithError(err).Error("Failed to execute query for the last account number")
-		return 0, err
+		return nil, fmt.Errorf("invalid balance %q for account %d: %w", balance, account.ID, err)
 	}
+	account.Balance = money
+	return &account, nil
+}
 
-	if !lastAccountNumber.Valid {
-		// No accounts exist yet, so we can start from a base number.
-		return 1000000000, nil // Start from a fixed number
-	}
+// NextAccountNumberBase allocates the next account number base from the
+// account_number_seq sequence. Allocation is atomic, so concurrent callers
+// always receive distinct bases.
+func (r *AccountRepository) NextAccountNumberBase(ctx context.Context) (int64, error) {
+	log := logger.Log
+	log.Info("Executing query to allocate the next account number base")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
-	return lastAccountNumber.Int64, nil
+	var base int64
+	query := `SELECT nextval('account_number_seq')`
+	if err := r.DB.QueryRowContext(ctx, query).Scan(&base); err != nil {
+		log.WithError(err).Error("Failed to allocate an account number base")
+		return 0, err
+	}
+	return base, nil
 }
 
 // CreateAccount adds a new account to the database.
-func (r *AccountRepository) CreateAccount(account *model.Account) error {
+func (r *AccountRepository) CreateAccount(ctx context.Context, account *model.Account) error {
 	log := logger.Log.WithFields(logrus.Fields{
 		"user_id":        account.UserID,
 		"account_number": account.AccountNumber,
@@ -61,22 +91,35 @@ func (r *AccountRepository) CreateAccount(account *model.Account) error {
 	})
 	log.Info("Executing query to create a new account")
 
-	query := `INSERT INTO accounts (user_id, account_number, currency) VALUES ($1, $2, $3) RETURNING id, balance, created_at`
-	err := r.DB.QueryRow(query, account.UserID, account.AccountNumber, account.Currency).Scan(&account.ID, &account.Balance, &account.CreatedAt)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var balance string
+	query := `INSERT INTO accounts (user_id, account_number, currency) VALUES ($1, $2, $3) RETURNING id, balance, kind, created_at`
+	err := r.DB.QueryRowContext(ctx, query, account.UserID, account.AccountNumber, account.Currency).Scan(&account.ID, &balance, &account.Kind, &account.CreatedAt)
 	if err != nil {
 		log.WithError(err).Error("Failed to execute create account query")
 		return err
 	}
+
+	account.Balance, err = model.ParseMoney(balance, account.Currency)
+	if err != nil {
+		log.WithError(err).Error("Failed to parse balance of the new account")
+		return err
+	}
 	return nil
 }
 
 // GetAccountsByUserID retrieves all accounts for a specific user.
-func (r *AccountRepository) GetAccountsByUserID(userID int) ([]*model.Account, error) {
+func (r *AccountRepository) GetAccountsByUserID(ctx context.Context, userID int) ([]*model.Account, error) {
 	log := logger.Log.WithField("user_id", userID)
 	log.Info("Executing query to get accounts by user ID")
 
-	query := `SELECT id, user_id, account_number, balance, currency, created_at FROM accounts WHERE user_id = $1`
-	rows, err := r.DB.Query(query, userID)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE user_id = $1`
+	rows, err := r.DB.QueryContext(ctx, query, userID)
 	if err != nil {
 		log.WithError(err).Error("Failed to execute query for accounts by user ID")
 		return nil, err
@@ -85,57 +128,93 @@ func (r *AccountRepository) GetAccountsByUserID(userID int) ([]*model.Account, e
 
 	var accounts []*model.Account
 	for rows.Next() {
-		var acc model.Account
-		if err := rows.Scan(&acc.ID, &acc.UserID, &acc.AccountNumber, &acc.Balance, &acc.Currency, &acc.CreatedAt); err != nil {
+		acc, err := scanAccount(rows)
+		if err != nil {
 			log.WithError(err).Error("Failed to scan account row")
 			return nil, err
 		}
-		accounts = append(accounts, &acc)
+		accounts = append(accounts, acc)
 	}
 	return accounts, nil
 }
 
-// GetAllAccounts retrieves all accounts from the database. Admin only.
-func (r *AccountRepository) GetAllAccounts() ([]*model.Account, error) {
-	log := logger.Log
-	log.Info("Executing query to get all accounts")
+// ListAccounts retrieves one page of the accounts matching the filter, newest
+// first unless the filter asks for ascending order, together with the number
+// of accounts matching the filter across all pages. It fetches one row beyond
+// the limit; callers use it to tell whether another page follows. Admin only.
+func (r *AccountRepository) ListAccounts(ctx context.Context, filter model.AccountFilter) ([]*model.Account, int, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":  filter.UserID,
+		"currency": filter.Currency,
+		"kind":     filter.Kind,
+		"limit":    filter.Limit,
+	})
+	log.Info("Executing query to list accounts")
 
-	query := `SELECT id, user_id, account_number, balance, currency, created_at FROM accounts`
-	rows, err := r.DB.Query(query)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	q := &listQuery{}
+	if filter.UserID != 0 {
+		q.where("user_id = " + q.arg(filter.UserID))
+	}
+	if filter.Currency != "" {
+		q.where("currency = " + q.arg(filter.Currency))
+	}
+	if filter.Kind != "" {
+		q.where("kind = " + q.arg(filter.Kind))
+	}
+	if filter.MinBalance != nil {
+		q.where("balance >= " + q.arg(*filter.MinBalance))
+	}
+	if filter.MaxBalance != nil {
+		q.where("balance <= " + q.arg(*filter.MaxBalance))
+	}
+	if filter.From != nil {
+		q.where("created_at >= " + q.arg(*filter.From))
+	}
+	if filter.To != nil {
+		q.where("created_at < " + q.arg(*filter.To))
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM accounts` + q.whereClause()
+	if err := r.DB.QueryRowContext(ctx, countQuery, q.args...).Scan(&total); err != nil {
+		log.WithError(err).Error("Failed to execute query to count accounts")
+		return nil, 0, err
+	}
+
+	pageClause := q.page(filter.After, filter.Limit, filter.Ascending)
+	query := `SELECT ` + accountColumns + ` FROM accounts` + q.whereClause() + pageClause
+	rows, err := r.DB.QueryContext(ctx, query, q.args...)
 	if err != nil {
-		log.WithError(err).Error("Failed to execute query for all accounts")
-		return nil, err
+		log.WithError(err).Error("Failed to execute query to list accounts")
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var accounts []*model.Account
 	for rows.Next() {
-		var acc model.Account
-		if err := rows.Scan(&acc.ID, &acc.UserID, &acc.AccountNumber, &acc.Balance, &acc.Currency, &acc.CreatedAt); err != nil {
+		acc, err := scanAccount(rows)
+		if err != nil {
 			log.WithError(err).Error("Failed to scan account row")
-			return nil, err
+			return nil, 0, err
 		}
-		accounts = append(accounts, &acc)
+		accounts = append(accounts, acc)
 	}
-	return accounts, nil
+	return accounts, total, rows.Err()
 }
 
 // GetAccountByID retrieves a single account by its primary key ID without locking.
-func (r *AccountRepository) GetAccountByID(accountID int) (*model.Account, error) {
+func (r *AccountRepository) GetAccountByID(ctx context.Context, accountID int) (*model.Account, error) {
 	log := logger.Log.WithField("account_id", accountID)
 	log.Info("Executing query to get account by ID")
 
-	account := &model.Account{}
-	query := `SELECT id, user_id, account_number, balance, currency, created_at FROM accounts WHERE id = $1`
-	err := r.DB.QueryRow(query, accountID).Scan(
-		&account.ID,
-		&account.UserID,
-		&account.AccountNumber,
-		&account.Balance,
-		&account.Currency,
-		&account.CreatedAt,
-	)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1`
+	account, err := scanAccount(r.DB.QueryRowContext(ctx, query, accountID))
 	if err != nil {
 		if err != sql.ErrNoRows {
 			log.WithError(err).Error("Failed to execute get account by ID query")
@@ -145,14 +224,55 @@ func (r *AccountRepository) GetAccountByID(accountID int) (*model.Account, error
 	return account, nil
 }
 
+// GetAccountByNumber retrieves a customer account by its public account number
+// without locking. System accounts are never returned.
+func (r *AccountRepository) GetAccountByNumber(ctx context.Context, accountNumber int64) (*model.Account, error) {
+	log := logger.Log.WithField("account_number", accountNumber)
+	log.Info("Executing query to get account by number")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE account_number = $1 AND kind = 'customer'`
+	account, err := scanAccount(r.DB.QueryRowContext(ctx, query, accountNumber))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get account by number query")
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetHolderName retrieves the username of the user who owns an account.
+func (r *AccountRepository) GetHolderName(ctx context.Context, accountID int) (string, error) {
+	log := logger.Log.WithField("account_id", accountID)
+	log.Info("Executing query to get account holder name")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var name string
+	query := `SELECT u.username FROM accounts a JOIN users u ON u.id = a.user_id WHERE a.id = $1`
+	if err := r.DB.QueryRowContext(ctx, query, accountID).Scan(&name); err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get account holder name query")
+		}
+		return "", err
+	}
+	return name, nil
+}
+
 // GetAccountForUpdate locks and retrieves an account row within a transaction.
-func (r *AccountRepository) GetAccountForUpdate(tx *sql.Tx, accountID int) (*model.Account, error) {
+func (r *AccountRepository) GetAccountForUpdate(ctx context.Context, tx *sql.Tx, accountID int) (*model.Account, error) {
 	log := logger.Log.WithField("account_id", accountID)
 	log.Info("Executing query to get account for update")
 
-	account := &model.Account{}
-	query := `SELECT id, user_id, balance, currency FROM accounts WHERE id = $1 FOR UPDATE`
-	err := tx.QueryRow(query, accountID).Scan(&account.ID, &account.UserID, &account.Balance, &account.Currency)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1 FOR UPDATE`
+	account, err := scanAccount(tx.QueryRowContext(ctx, query, accountID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Info("Account not found for update")
@@ -164,56 +284,99 @@ func (r *AccountRepository) GetAccountForUpdate(tx *sql.Tx, accountID int) (*mod
 	return account, nil
 }
 
-// UpdateAccountBalance updates an account's balance within a transaction.
-func (r *AccountRepository) UpdateAccountBalance(tx *sql.Tx, accountID int, newBalance float64) error {
+// GetAccountsForUpdate locks and retrieves several account rows within a
+// transaction. All rows are locked by one statement in a fixed global order,
+// customer accounts first and system accounts last, each by ascending ID, so
+// two transactions locking overlapping accounts can never wait on each other
+// in a cycle. Accounts that do not exist are absent from the returned map.
+func (r *AccountRepository) GetAccountsForUpdate(ctx context.Context, tx *sql.Tx, accountIDs ...int) (map[int]*model.Account, error) {
+	log := logger.Log.WithField("account_ids", accountIDs)
+	log.Info("Executing query to get accounts for update")
+
+	ids := make([]int64, len(accountIDs))
+	for i, id := range accountIDs {
+		ids[i] = int64(id)
+	}
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = ANY($1) ORDER BY kind <> 'customer', id FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		log.WithError(err).Error("Failed to execute get accounts for update query")
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make(map[int]*model.Account, len(accountIDs))
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan account row")
+			return nil, err
+		}
+		accounts[acc.ID] = acc
+	}
+	return accounts, rows.Err()
+}
+
+// GetSystemAccountsForUpdate locks and retrieves the bank-owned accounts of the
+// given kind for several currencies within a transaction, keyed by currency.
+// Like GetAccountsForUpdate it locks all rows in one statement by ascending ID.
+// Currencies without a system account are absent from the returned map.
+func (r *AccountRepository) GetSystemAccountsForUpdate(ctx context.Context, tx *sql.Tx, kind string, currencies ...string) (map[string]*model.Account, error) {
 	log := logger.Log.WithFields(logrus.Fields{
-		"account_id":  accountID,
-		"new_balance": newBalance,
+		"account_kind": kind,
+		"currencies":   currencies,
 	})
-	log.Info("Executing query to update account balance")
+	log.Info("Executing query to get system accounts for update")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
-	query := `UPDATE accounts SET balance = $1 WHERE id = $2`
-	_, err := tx.Exec(query, newBalance, accountID)
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE kind = $1 AND currency = ANY($2) ORDER BY id FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, kind, pq.Array(currencies))
 	if err != nil {
-		log.WithError(err).Error("Failed to execute update account balance query")
-		return err
+		log.WithError(err).Error("Failed to execute get system accounts for update query")
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+
+	accounts := make(map[string]*model.Account, len(currencies))
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan account row")
+			return nil, err
+		}
+		accounts[acc.Currency] = acc
+	}
+	return accounts, rows.Err()
 }
 
-// DepositToAccount adds a specified amount to an account's balance.
-func (r *AccountRepository) DepositToAccount(accountID int, amount float64) (*model.Account, error) {
+// GetSystemAccountForUpdate locks and retrieves the bank-owned account of the
+// given kind (e.g. settlement) for a currency within a transaction. Callers
+// lock customer accounts first, matching the order of GetAccountsForUpdate.
+func (r *AccountRepository) GetSystemAccountForUpdate(ctx context.Context, tx *sql.Tx, kind, currency string) (*model.Account, error) {
 	log := logger.Log.WithFields(logrus.Fields{
-		"account_id": accountID,
-		"amount":     amount,
+		"account_kind": kind,
+		"currency":     currency,
 	})
-	log.Info("Executing query to deposit funds")
-
-	var updatedAccount model.Account
-	query := `
-		UPDATE accounts 
-		SET balance = balance + $1 
-		WHERE id = $2 
-		RETURNING id, user_id, account_number, balance, currency, created_at`
-
-	err := r.DB.QueryRow(query, amount, accountID).Scan(
-		&updatedAccount.ID,
-		&updatedAccount.UserID,
-		&updatedAccount.AccountNumber,
-		&updatedAccount.Balance,
-		&updatedAccount.Currency,
-		&updatedAccount.CreatedAt,
-	)
+	log.Info("Executing query to get system account for update")
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE kind = $1 AND currency = $2 FOR UPDATE`
+	account, err := scanAccount(tx.QueryRowContext(ctx, query, kind, currency))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Info("Account not found for deposit")
-			return nil, err
+			log.Error("System account is missing")
+		} else {
+			log.WithError(err).Error("Failed to execute get system account for update query")
 		}
-		log.WithError(err).Error("Failed to execute deposit query")
 		return nil, err
 	}
-
-	log.Info("Funds deposited successfully")
-	return &updatedAccount, nil
+	return account, nil
 }
@@ -0,0 +1,176 @@
+// file: repository/approval_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IApprovalRepository defines the contract for approval request database operations.
+type IApprovalRepository interface {
+	CreateApprovalRequest(ctx context.Context, request *model.ApprovalRequest) error
+	GetApprovalRequest(ctx context.Context, id int) (*model.ApprovalRequest, error)
+	ListApprovalRequests(ctx context.Context, filter model.ApprovalFilter) ([]*model.ApprovalRequest, int, error)
+	DecideApprovalRequest(ctx context.Context, tx *sql.Tx, id int, decision model.ApprovalDecision) (*model.ApprovalRequest, error)
+}
+
+// ApprovalRepository implements IApprovalRepository.
+type ApprovalRepository struct {
+	DB *sql.DB
+}
+
+// NewApprovalRepository creates a new ApprovalRepository.
+func NewApprovalRepository(db *sql.DB) *ApprovalRepository {
+	return &ApprovalRepository{DB: db}
+}
+
+// approvalStatus is the status of a request as the API reports it: pending
+// requests past their expiry are expired.
+const approvalStatus = `CASE WHEN status = 'pending' AND expires_at <= CURRENT_TIMESTAMP THEN 'expired' ELSE status END`
+
+const approvalColumns = `id, action, payload, ` + approvalStatus + `, maker_id, checker_id, comment, failure, expires_at, decided_at, created_at, updated_at`
+
+// scanApprovalRequest reads a row selected with approvalColumns.
+func scanApprovalRequest(row rowScanner) (*model.ApprovalRequest, error) {
+	var request model.ApprovalRequest
+	var payload []byte
+	var checkerID sql.NullInt64
+	var decidedAt sql.NullTime
+	err := row.Scan(&request.ID, &request.Action, &payload, &request.Status, &request.MakerID, &checkerID,
+		&request.Comment, &request.Failure, &request.ExpiresAt, &decidedAt, &request.CreatedAt, &request.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	request.Payload = payload
+	if checkerID.Valid {
+		id := int(checkerID.Int64)
+		request.CheckerID = &id
+	}
+	if decidedAt.Valid {
+		request.DecidedAt = &decidedAt.Time
+	}
+	return &request, nil
+}
+
+// CreateApprovalRequest inserts a new pending request.
+func (r *ApprovalRepository) CreateApprovalRequest(ctx context.Context, request *model.ApprovalRequest) error {
+	log := logger.Log.WithFields(logrus.Fields{"action": request.Action, "maker_id": request.MakerID})
+	log.Info("Executing query to create a new approval request")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO approval_requests (action, payload, maker_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, created_at, updated_at`
+	err := r.DB.QueryRowContext(ctx, query, request.Action, []byte(request.Payload), request.MakerID, request.ExpiresAt).
+		Scan(&request.ID, &request.Status, &request.CreatedAt, &request.UpdatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create approval request query")
+		return err
+	}
+	return nil
+}
+
+// GetApprovalRequest retrieves an approval request by ID.
+func (r *ApprovalRepository) GetApprovalRequest(ctx context.Context, id int) (*model.ApprovalRequest, error) {
+	log := logger.Log.WithField("approval_request_id", id)
+	log.Info("Executing query to get approval request")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + approvalColumns + ` FROM approval_requests WHERE id = $1`
+	request, err := scanApprovalRequest(r.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get approval request query")
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// ListApprovalRequests returns one page of the approval requests matching the
+// filter, plus the total number of matches.
+func (r *ApprovalRepository) ListApprovalRequests(ctx context.Context, filter model.ApprovalFilter) ([]*model.ApprovalRequest, int, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"status": filter.Status,
+		"action": filter.Action,
+		"limit":  filter.Limit,
+	})
+	log.Info("Executing query to list approval requests")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	q := &listQuery{}
+	if filter.Status != "" {
+		q.where("(" + approvalStatus + ") = " + q.arg(filter.Status))
+	}
+	if filter.Action != "" {
+		q.where("action = " + q.arg(filter.Action))
+	}
+	if filter.MakerID != 0 {
+		q.where("maker_id = " + q.arg(filter.MakerID))
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM approval_requests` + q.whereClause()
+	if err := r.DB.QueryRowContext(ctx, countQuery, q.args...).Scan(&total); err != nil {
+		log.WithError(err).Error("Failed to execute query to count approval requests")
+		return nil, 0, err
+	}
+
+	pageClause := q.page(filter.After, filter.Limit, filter.Ascending)
+	query := `SELECT ` + approvalColumns + ` FROM approval_requests` + q.whereClause() + pageClause
+	rows, err := r.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute query to list approval requests")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var requests []*model.ApprovalRequest
+	for rows.Next() {
+		request, err := scanApprovalRequest(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan approval request row")
+			return nil, 0, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, total, rows.Err()
+}
+
+// DecideApprovalRequest records the checker's decision on a request that is
+// still pending and has not expired, within tx. It returns sql.ErrNoRows if
+// there is no such request, so of two concurrent decisions only one takes
+// effect.
+func (r *ApprovalRepository) DecideApprovalRequest(ctx context.Context, tx *sql.Tx, id int, decision model.ApprovalDecision) (*model.ApprovalRequest, error) {
+	log := logger.Log.WithFields(logrus.Fields{"approval_request_id": id, "status": decision.Status, "checker_id": decision.CheckerID})
+	log.Info("Executing query to decide approval request")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE approval_requests
+		SET status = $2, checker_id = $3, comment = $4, failure = $5, decided_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP
+		RETURNING ` + approvalColumns
+	request, err := scanApprovalRequest(tx.QueryRowContext(ctx, query, id, decision.Status, decision.CheckerID, decision.Comment, decision.Failure))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute decide approval request query")
+		}
+		return nil, err
+	}
+	return request, nil
+}
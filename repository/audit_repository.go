@@ -0,0 +1,215 @@
+// file: repository/audit_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// auditChainLock is the key of the advisory lock that serializes appends to
+// the audit log, so every event links to the one committed before it.
+//
+// The lock is held until the appending transaction commits, so audited
+// transactions, which include every transfer and deposit, commit one at a
+// time however many accounts they touch. Their throughput is bounded by one
+// append and one commit per event, a few hundred a second against a nearby
+// database. Callers therefore append as the very last statement before
+// committing. If that is not enough, events have to be written unchained to
+// an outbox and chained by a single background writer instead.
+const auditChainLock = 0x61756469
+
+// IAuditRepository defines the contract for audit log database operations.
+type IAuditRepository interface {
+	LockChain(ctx context.Context, tx *sql.Tx) (string, error)
+	AppendEvent(ctx context.Context, tx *sql.Tx, event *model.AuditEvent) error
+	ListEvents(ctx context.Context, filter model.AuditFilter) ([]*model.AuditEvent, int, error)
+	GetEventsAfter(ctx context.Context, afterID, limit int) ([]*model.AuditEvent, error)
+}
+
+// AuditRepository implements IAuditRepository.
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+// NewAuditRepository creates a new AuditRepository.
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+const auditColumns = `id, event, actor_id, target_type, target_id, ip_address, user_agent, request_id, before, after, created_at, prev_hash, hash`
+
+// scanAuditEvent reads a row selected with auditColumns.
+func scanAuditEvent(row rowScanner) (*model.AuditEvent, error) {
+	var event model.AuditEvent
+	var actorID sql.NullInt64
+	var before, after []byte
+	err := row.Scan(&event.ID, &event.Event, &actorID, &event.TargetType, &event.TargetID, &event.IPAddress,
+		&event.UserAgent, &event.RequestID, &before, &after, &event.CreatedAt, &event.PrevHash, &event.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		event.ActorID = &id
+	}
+	event.Before, event.After = before, after
+	return &event, nil
+}
+
+// nullJSON returns doc as a query argument, NULL if it is empty.
+func nullJSON(doc []byte) interface{} {
+	if len(doc) == 0 {
+		return nil
+	}
+	return doc
+}
+
+// LockChain takes the lock on the end of the audit log for the rest of tx and
+// returns the hash of the last event, or model.AuditGenesisHash if there is none.
+// The hash is read in a statement of its own, after the lock is granted, so it
+// sees the events committed while waiting for it.
+func (r *AuditRepository) LockChain(ctx context.Context, tx *sql.Tx) (string, error) {
+	logger.Log.Info("Executing queries to lock the audit log")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		logger.Log.WithError(err).Error("Failed to lock the audit log")
+		return "", err
+	}
+
+	var hash string
+	err := tx.QueryRowContext(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return model.AuditGenesisHash, nil
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to execute query to get the last audit event hash")
+		return "", err
+	}
+	return hash, nil
+}
+
+// AppendEvent inserts an event, its hashes already computed, within tx.
+func (r *AuditRepository) AppendEvent(ctx context.Context, tx *sql.Tx, event *model.AuditEvent) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"event":       event.Event,
+		"target_type": event.TargetType,
+		"target_id":   event.TargetID,
+	})
+	log.Info("Executing query to append an audit event")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO audit_events (event, actor_id, target_type, target_id, ip_address, user_agent, request_id, before, after, created_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`
+	err := tx.QueryRowContext(ctx, query, event.Event, event.ActorID, event.TargetType, event.TargetID, event.IPAddress,
+		event.UserAgent, event.RequestID, nullJSON(event.Before), nullJSON(event.After), event.CreatedAt, event.PrevHash, event.Hash).
+		Scan(&event.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute append audit event query")
+		return err
+	}
+	return nil
+}
+
+// ListEvents returns one page of the audit events matching the filter, plus
+// the total number of matches.
+func (r *AuditRepository) ListEvents(ctx context.Context, filter model.AuditFilter) ([]*model.AuditEvent, int, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"event":    filter.Event,
+		"actor_id": filter.ActorID,
+		"limit":    filter.Limit,
+	})
+	log.Info("Executing query to list audit events")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	q := &listQuery{}
+	if filter.Event != "" {
+		q.where("event = " + q.arg(filter.Event))
+	}
+	if filter.ActorID != 0 {
+		q.where("actor_id = " + q.arg(filter.ActorID))
+	}
+	if filter.TargetType != "" {
+		q.where("target_type = " + q.arg(filter.TargetType))
+	}
+	if filter.TargetID != "" {
+		q.where("target_id = " + q.arg(filter.TargetID))
+	}
+	if filter.RequestID != "" {
+		q.where("request_id = " + q.arg(filter.RequestID))
+	}
+	if filter.From != nil {
+		q.where("created_at >= " + q.arg(*filter.From))
+	}
+	if filter.To != nil {
+		q.where("created_at < " + q.arg(*filter.To))
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM audit_events` + q.whereClause()
+	if err := r.DB.QueryRowContext(ctx, countQuery, q.args...).Scan(&total); err != nil {
+		log.WithError(err).Error("Failed to execute query to count audit events")
+		return nil, 0, err
+	}
+
+	pageClause := q.page(filter.After, filter.Limit, filter.Ascending)
+	query := `SELECT ` + auditColumns + ` FROM audit_events` + q.whereClause() + pageClause
+	rows, err := r.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute query to list audit events")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []*model.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan audit event row")
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, rows.Err()
+}
+
+// GetEventsAfter returns up to limit events with IDs above afterID, in chain order.
+func (r *AuditRepository) GetEventsAfter(ctx context.Context, afterID, limit int) ([]*model.AuditEvent, error) {
+	log := logger.Log.WithFields(logrus.Fields{"after_id": afterID, "limit": limit})
+	log.Info("Executing query to get audit events")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + auditColumns + ` FROM audit_events WHERE id > $1 ORDER BY id LIMIT $2`
+	rows, err := r.DB.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute query to get audit events")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*model.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan audit event row")
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,117 @@
+// file: repository/fx_quote_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IFXQuoteRepository defines the contract for FX quote database operations.
+type IFXQuoteRepository interface {
+	CreateQuote(ctx context.Context, quote *model.FXQuote) error
+	GetQuoteForUpdate(ctx context.Context, tx *sql.Tx, quoteID string) (*model.FXQuote, error)
+	MarkQuoteUsed(ctx context.Context, tx *sql.Tx, quoteID string) error
+}
+
+// FXQuoteRepository implements IFXQuoteRepository.
+type FXQuoteRepository struct {
+	DB *sql.DB
+}
+
+// NewFXQuoteRepository creates a new FXQuoteRepository.
+func NewFXQuoteRepository(db *sql.DB) *FXQuoteRepository {
+	return &FXQuoteRepository{DB: db}
+}
+
+// CreateQuote inserts a new quote, filling in its generated ID and creation time.
+func (r *FXQuoteRepository) CreateQuote(ctx context.Context, quote *model.FXQuote) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":       quote.UserID,
+		"source_amount": quote.SourceAmount.String(),
+		"target":        quote.DestinationAmount.Currency,
+	})
+	log.Info("Executing query to create an FX quote")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO fx_quotes (user_id, source_amount, source_currency, destination_amount, destination_currency,
+			mid_rate, rate, spread_bps, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+	err := r.DB.QueryRowContext(ctx, query, quote.UserID, quote.SourceAmount, quote.SourceAmount.Currency,
+		quote.DestinationAmount, quote.DestinationAmount.Currency, quote.MidRate, quote.Rate, quote.SpreadBps, quote.ExpiresAt).
+		Scan(&quote.ID, &quote.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create FX quote query")
+		return err
+	}
+	return nil
+}
+
+// GetQuoteForUpdate locks and retrieves a quote within a transaction, so that
+// two transfers cannot both use it.
+func (r *FXQuoteRepository) GetQuoteForUpdate(ctx context.Context, tx *sql.Tx, quoteID string) (*model.FXQuote, error) {
+	log := logger.Log.WithField("quote_id", quoteID)
+	log.Info("Executing query to get FX quote for update")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, source_amount, source_currency, destination_amount, destination_currency,
+			mid_rate, rate, spread_bps, expires_at, used_at, created_at
+		FROM fx_quotes WHERE id = $1 FOR UPDATE`
+
+	quote := &model.FXQuote{}
+	var sourceAmount, sourceCurrency, destAmount, destCurrency, midRate, rate string
+	var usedAt sql.NullTime
+	err := tx.QueryRowContext(ctx, query, quoteID).Scan(&quote.ID, &quote.UserID, &sourceAmount, &sourceCurrency,
+		&destAmount, &destCurrency, &midRate, &rate, &quote.SpreadBps, &quote.ExpiresAt, &usedAt, &quote.CreatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get FX quote for update query")
+		}
+		return nil, err
+	}
+
+	if quote.SourceAmount, err = model.ParseMoney(sourceAmount, sourceCurrency); err != nil {
+		return nil, fmt.Errorf("invalid source amount %q for quote %s: %w", sourceAmount, quote.ID, err)
+	}
+	if quote.DestinationAmount, err = model.ParseMoney(destAmount, destCurrency); err != nil {
+		return nil, fmt.Errorf("invalid destination amount %q for quote %s: %w", destAmount, quote.ID, err)
+	}
+	if quote.MidRate, err = model.ParseRate(midRate); err != nil {
+		return nil, fmt.Errorf("invalid mid rate %q for quote %s: %w", midRate, quote.ID, err)
+	}
+	if quote.Rate, err = model.ParseRate(rate); err != nil {
+		return nil, fmt.Errorf("invalid rate %q for quote %s: %w", rate, quote.ID, err)
+	}
+	if usedAt.Valid {
+		quote.UsedAt = &usedAt.Time
+	}
+	return quote, nil
+}
+
+// MarkQuoteUsed records that a transfer has consumed the quote.
+func (r *FXQuoteRepository) MarkQuoteUsed(ctx context.Context, tx *sql.Tx, quoteID string) error {
+	log := logger.Log.WithField("quote_id", quoteID)
+	log.Info("Executing query to mark FX quote as used")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE fx_quotes SET used_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, quoteID); err != nil {
+		log.WithError(err).Error("Failed to execute mark FX quote used query")
+		return err
+	}
+	return nil
+}
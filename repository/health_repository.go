@@ -0,0 +1,53 @@
+// file: repository/health_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+)
+
+// IHealthRepository defines the contract for checking the database's health.
+type IHealthRepository interface {
+	Ping(ctx context.Context) error
+	GetSchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+}
+
+// HealthRepository implements IHealthRepository.
+type HealthRepository struct {
+	DB *sql.DB
+}
+
+// NewHealthRepository creates a new HealthRepository.
+func NewHealthRepository(db *sql.DB) *HealthRepository {
+	return &HealthRepository{DB: db}
+}
+
+// Ping checks that a connection to the database can be made. Probes call it
+// every few seconds, so it logs at debug level only.
+func (r *HealthRepository) Ping(ctx context.Context) error {
+	logger.Log.Debug("Pinging the database")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return r.DB.PingContext(ctx)
+}
+
+// GetSchemaVersion returns the version of the last migration applied to the
+// database and whether it failed half way through, as recorded by migrate.
+func (r *HealthRepository) GetSchemaVersion(ctx context.Context) (uint, bool, error) {
+	logger.Log.Debug("Executing query to get the schema version")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var version int64
+	var dirty bool
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	if err := r.DB.QueryRowContext(ctx, query).Scan(&version, &dirty); err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
@@ -0,0 +1,140 @@
+// file: repository/idempotency_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IIdempotencyRepository defines the contract for idempotency key database operations.
+type IIdempotencyRepository interface {
+	Claim(ctx context.Context, record *model.IdempotencyRecord) (bool, error)
+	GetByKey(ctx context.Context, userID int, key string) (*model.IdempotencyRecord, error)
+	Complete(ctx context.Context, id int, statusCode int, body []byte, expiresAt time.Time) error
+	Delete(ctx context.Context, id int) error
+}
+
+// IdempotencyRepository implements IIdempotencyRepository.
+type IdempotencyRepository struct {
+	DB *sql.DB
+}
+
+// NewIdempotencyRepository creates a new IdempotencyRepository.
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+// Claim inserts an in-flight record for the user's key. An existing record is
+// only taken over when it has expired. It returns false, without error, when a
+// live record already exists for the key.
+func (r *IdempotencyRepository) Claim(ctx context.Context, record *model.IdempotencyRecord) (bool, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":         record.UserID,
+		"idempotency_key": record.Key,
+	})
+	log.Info("Executing query to claim an idempotency key")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_fingerprint, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE
+		SET request_fingerprint = EXCLUDED.request_fingerprint,
+			status_code = NULL,
+			response_body = NULL,
+			created_at = CURRENT_TIMESTAMP,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < CURRENT_TIMESTAMP
+		RETURNING id, created_at`
+
+	err := r.DB.QueryRowContext(ctx, query, record.UserID, record.Key, record.Fingerprint, record.ExpiresAt).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		log.WithError(err).Error("Failed to execute claim idempotency key query")
+		return false, err
+	}
+	return true, nil
+}
+
+// GetByKey retrieves the record stored for a user's idempotency key.
+func (r *IdempotencyRepository) GetByKey(ctx context.Context, userID int, key string) (*model.IdempotencyRecord, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"idempotency_key": key,
+	})
+	log.Info("Executing query to get idempotency record by key")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	record := &model.IdempotencyRecord{}
+	var statusCode sql.NullInt64
+	query := `
+		SELECT id, user_id, idempotency_key, request_fingerprint, status_code, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2`
+	err := r.DB.QueryRowContext(ctx, query, userID, key).Scan(
+		&record.ID,
+		&record.UserID,
+		&record.Key,
+		&record.Fingerprint,
+		&statusCode,
+		&record.ResponseBody,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get idempotency record query")
+		}
+		return nil, err
+	}
+	record.StatusCode = int(statusCode.Int64)
+	return record, nil
+}
+
+// Complete stores the response produced for a claimed record and keeps it
+// until expiresAt.
+func (r *IdempotencyRepository) Complete(ctx context.Context, id int, statusCode int, body []byte, expiresAt time.Time) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"idempotency_record_id": id,
+		"status_code":           statusCode,
+	})
+	log.Info("Executing query to store idempotent response")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE idempotency_keys SET status_code = $1, response_body = $2, expires_at = $3 WHERE id = $4`
+	if _, err := r.DB.ExecContext(ctx, query, statusCode, body, expiresAt, id); err != nil {
+		log.WithError(err).Error("Failed to execute store idempotent response query")
+		return err
+	}
+	return nil
+}
+
+// Delete removes a record, releasing the key so that the request can be retried.
+func (r *IdempotencyRepository) Delete(ctx context.Context, id int) error {
+	log := logger.Log.WithField("idempotency_record_id", id)
+	log.Info("Executing query to delete idempotency record")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM idempotency_keys WHERE id = $1`
+	if _, err := r.DB.ExecContext(ctx, query, id); err != nil {
+		log.WithError(err).Error("Failed to execute delete idempotency record query")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// file: repository/ledger_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ILedgerRepository defines the contract for journal entry and posting database operations.
+type ILedgerRepository interface {
+	PostEntry(ctx context.Context, tx *sql.Tx, entry *model.JournalEntry) error
+	GetEntryByTransactionID(ctx context.Context, tx *sql.Tx, transactionID int) (*model.JournalEntry, error)
+	IsEntryReversed(ctx context.Context, tx *sql.Tx, entryID int) (bool, error)
+	GetBalanceMismatches(ctx context.Context) ([]model.BalanceMismatch, error)
+	GetUnbalancedEntryIDs(ctx context.Context) ([]int, error)
+}
+
+// LedgerRepository implements ILedgerRepository.
+type LedgerRepository struct {
+	DB *sql.DB
+}
+
+// NewLedgerRepository creates a new LedgerRepository.
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{DB: db}
+}
+
+// PostEntry records a journal entry with its postings and applies each posting
+// to the balance of its account, all within the caller's transaction. Account
+// balances are only ever changed through this method, so they always equal the
+// sum of their postings. The entry must satisfy model.JournalEntry.Validate; the
+// database re-checks the invariant when the transaction commits.
+func (r *LedgerRepository) PostEntry(ctx context.Context, tx *sql.Tx, entry *model.JournalEntry) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"kind":           entry.Kind,
+		"transaction_id": entry.TransactionID,
+		"postings":       len(entry.Postings),
+	})
+	log.Info("Executing queries to post a journal entry")
+
+	if err := entry.Validate(); err != nil {
+		log.WithError(err).Error("Refusing to post an invalid journal entry")
+		return err
+	}
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO journal_entries (kind, transaction_id, reverses_entry_id, description) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := tx.QueryRowContext(ctx, query, entry.Kind, entry.TransactionID, entry.ReversesEntryID, entry.Description).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create journal entry query")
+		return err
+	}
+
+	for i := range entry.Postings {
+		posting := &entry.Postings[i]
+		posting.JournalEntryID = entry.ID
+
+		query = `INSERT INTO postings (journal_entry_id, account_id, amount, currency) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+		err = tx.QueryRowContext(ctx, query, entry.ID, posting.AccountID, posting.Amount, posting.Amount.Currency).Scan(&posting.ID, &posting.CreatedAt)
+		if err != nil {
+			log.WithError(err).WithField("account_id", posting.AccountID).Error("Failed to execute create posting query")
+			return err
+		}
+
+		query = `UPDATE accounts SET balance = balance + $1 WHERE id = $2 AND currency = $3`
+		result, err := tx.ExecContext(ctx, query, posting.Amount, posting.AccountID, posting.Amount.Currency)
+		if err != nil {
+			log.WithError(err).WithField("account_id", posting.AccountID).Error("Failed to apply posting to account balance")
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("posting to account %d in %s: %w", posting.AccountID, posting.Amount.Currency, sql.ErrNoRows)
+		}
+	}
+	return nil
+}
+
+// GetEntryByTransactionID retrieves the journal entry, with its postings, that
+// records the given transaction.
+func (r *LedgerRepository) GetEntryByTransactionID(ctx context.Context, tx *sql.Tx, transactionID int) (*model.JournalEntry, error) {
+	log := logger.Log.WithField("transaction_id", transactionID)
+	log.Info("Executing query to get journal entry by transaction ID")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	entry := &model.JournalEntry{}
+	var reversesEntryID sql.NullInt64
+	query := `SELECT id, kind, transaction_id, reverses_entry_id, description, created_at FROM journal_entries WHERE transaction_id = $1`
+	err := tx.QueryRowContext(ctx, query, transactionID).Scan(&entry.ID, &entry.Kind, &entry.TransactionID, &reversesEntryID, &entry.Description, &entry.CreatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get journal entry query")
+		}
+		return nil, err
+	}
+	if reversesEntryID.Valid {
+		id := int(reversesEntryID.Int64)
+		entry.ReversesEntryID = &id
+	}
+
+	query = `SELECT id, journal_entry_id, account_id, amount, currency, created_at FROM postings WHERE journal_entry_id = $1 ORDER BY id`
+	rows, err := tx.QueryContext(ctx, query, entry.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute get postings query")
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var posting model.Posting
+		var amount, currency string
+		if err := rows.Scan(&posting.ID, &posting.JournalEntryID, &posting.AccountID, &amount, &currency, &posting.CreatedAt); err != nil {
+			log.WithError(err).Error("Failed to scan posting row")
+			return nil, err
+		}
+		if posting.Amount, err = model.ParseMoney(amount, currency); err != nil {
+			return nil, fmt.Errorf("invalid amount %q for posting %d: %w", amount, posting.ID, err)
+		}
+		entry.Postings = append(entry.Postings, posting)
+	}
+	return entry, rows.Err()
+}
+
+// IsEntryReversed reports whether a reversal has already been posted for an entry.
+func (r *LedgerRepository) IsEntryReversed(ctx context.Context, tx *sql.Tx, entryID int) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var reversed bool
+	query := `SELECT EXISTS (SELECT 1 FROM journal_entries WHERE reverses_entry_id = $1)`
+	if err := tx.QueryRowContext(ctx, query, entryID).Scan(&reversed); err != nil {
+		logger.Log.WithError(err).WithField("journal_entry_id", entryID).Error("Failed to execute entry reversal check query")
+		return false, err
+	}
+	return reversed, nil
+}
+
+// GetBalanceMismatches returns every account whose stored balance differs from
+// the sum of its postings.
+func (r *LedgerRepository) GetBalanceMismatches(ctx context.Context) ([]model.BalanceMismatch, error) {
+	log := logger.Log
+	log.Info("Executing query to verify account balances against postings")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT a.id, a.balance::TEXT, COALESCE(SUM(p.amount), 0)::TEXT, a.currency
+		FROM accounts a
+		LEFT JOIN postings p ON p.account_id = a.id
+		GROUP BY a.id
+		HAVING a.balance <> COALESCE(SUM(p.amount), 0)
+		ORDER BY a.id`
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute balance verification query")
+		return nil, err
+	}
+	defer rows.Close()
+
+	mismatches := []model.BalanceMismatch{}
+	for rows.Next() {
+		var m model.BalanceMismatch
+		if err := rows.Scan(&m.AccountID, &m.Balance, &m.PostedBalance, &m.Currency); err != nil {
+			log.WithError(err).Error("Failed to scan balance mismatch row")
+			return nil, err
+		}
+		mismatches = append(mismatches, m)
+	}
+	return mismatches, rows.Err()
+}
+
+// GetUnbalancedEntryIDs returns the IDs of journal entries whose postings do not
+// sum to zero in some currency. The commit-time trigger should make this empty.
+func (r *LedgerRepository) GetUnbalancedEntryIDs(ctx context.Context) ([]int, error) {
+	log := logger.Log
+	log.Info("Executing query to find unbalanced journal entries")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT DISTINCT journal_entry_id
+		FROM postings
+		GROUP BY journal_entry_id, currency
+		HAVING SUM(amount) <> 0
+		ORDER BY journal_entry_id`
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute unbalanced entries query")
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			log.WithError(err).Error("Failed to scan unbalanced entry row")
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,55 @@
+// file: repository/listing.go
+
+package repository
+
+import (
+	"fmt"
+	"go-bank-api/model"
+	"strings"
+)
+
+// listQuery builds the WHERE clause of a filtered listing together with its
+// positional arguments.
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg adds a query argument and returns its placeholder.
+func (q *listQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where adds a condition; all conditions must hold.
+func (q *listQuery) where(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+// whereClause returns the conditions as a WHERE clause, or nothing if there are none.
+func (q *listQuery) whereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// page restricts the query to rows past the cursor and returns the ORDER BY
+// and LIMIT clauses of a listing keyed on (created_at, id). It asks for one
+// row beyond the limit so callers can tell whether another page follows.
+func (q *listQuery) page(after *model.Cursor, limit int, ascending bool) string {
+	order, cmp := "DESC", "<"
+	if ascending {
+		order, cmp = "ASC", ">"
+	}
+	if after != nil {
+		q.where(fmt.Sprintf("(created_at, id) %s (%s, %s)", cmp, q.arg(after.CreatedAt), q.arg(after.ID)))
+	}
+	return " ORDER BY created_at " + order + ", id " + order + " LIMIT " + q.arg(limit+1)
+}
+
+// prefixPattern returns a LIKE pattern matching strings that start with s,
+// with LIKE's wildcards in s escaped.
+func prefixPattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+}
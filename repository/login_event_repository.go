@@ -0,0 +1,59 @@
+// file: repository/login_event_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ILoginEventRepository defines the contract for login audit event database operations.
+type ILoginEventRepository interface {
+	Create(ctx context.Context, event *model.LoginEvent) error
+}
+
+// LoginEventRepository implements ILoginEventRepository.
+type LoginEventRepository struct {
+	DB *sql.DB
+}
+
+// NewLoginEventRepository creates a new LoginEventRepository.
+func NewLoginEventRepository(db *sql.DB) *LoginEventRepository {
+	return &LoginEventRepository{DB: db}
+}
+
+// Create appends an event to the login audit trail.
+func (r *LoginEventRepository) Create(ctx context.Context, event *model.LoginEvent) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id": event.UserID,
+		"event":   event.Event,
+	})
+	log.Info("Executing query to create a login event")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var userID, actorID interface{}
+	if event.UserID != 0 {
+		userID = event.UserID
+	}
+	if event.ActorID != 0 {
+		actorID = event.ActorID
+	}
+
+	query := `
+		INSERT INTO login_events (user_id, email, event, ip_address, user_agent, actor_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+	err := r.DB.QueryRowContext(ctx, query, userID, event.Email, event.Event, event.IPAddress, event.UserAgent, actorID).
+		Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create login event query")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// file: repository/mfa_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// IMFARepository defines the contract for two-factor authentication database operations.
+type IMFARepository interface {
+	SavePendingTOTP(ctx context.Context, userID int, secret string) error
+	GetTOTP(ctx context.Context, userID int) (*model.TOTPCredential, error)
+	ConfirmTOTP(ctx context.Context, tx *sql.Tx, userID int, step int64) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	ReplaceTOTPSecret(ctx context.Context, userID int, oldSecret, newSecret string) error
+	ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+}
+
+// MFARepository implements IMFARepository.
+type MFARepository struct {
+	DB *sql.DB
+}
+
+// NewMFARepository creates a new MFARepository.
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{DB: db}
+}
+
+// SavePendingTOTP stores the secret of an authenticator that is being
+// enrolled, replacing an earlier enrollment that was never confirmed. It
+// returns sql.ErrNoRows if the user already has a confirmed authenticator.
+func (r *MFARepository) SavePendingTOTP(ctx context.Context, userID int, secret string) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to save pending TOTP enrollment")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO mfa_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+			SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+			WHERE mfa_totp.confirmed_at IS NULL`
+	result, err := r.DB.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute save pending TOTP query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after saving pending TOTP")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTOTP retrieves the user's authenticator, confirmed or not.
+func (r *MFARepository) GetTOTP(ctx context.Context, userID int) (*model.TOTPCredential, error) {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to get TOTP credential")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	credential := &model.TOTPCredential{}
+	var confirmedAt sql.NullTime
+	query := `SELECT user_id, secret, confirmed_at, last_used_step, created_at FROM mfa_totp WHERE user_id = $1`
+	err := r.DB.QueryRowContext(ctx, query, userID).Scan(&credential.UserID, &credential.Secret, &confirmedAt,
+		&credential.LastUsedStep, &credential.CreatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get TOTP credential query")
+		}
+		return nil, err
+	}
+	if confirmedAt.Valid {
+		credential.ConfirmedAt = &confirmedAt.Time
+	}
+	return credential, nil
+}
+
+// ConfirmTOTP turns on two-factor authentication with the pending
+// authenticator, recording the step of the code that confirmed it. It returns
+// sql.ErrNoRows if there is no pending authenticator.
+func (r *MFARepository) ConfirmTOTP(ctx context.Context, tx *sql.Tx, userID int, step int64) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to confirm TOTP enrollment")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE mfa_totp SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute confirm TOTP query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after confirming TOTP")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UseTOTPStep records the step of an accepted code. It returns sql.ErrNoRows
+// if a code of that step or a later one was already accepted, so that each
+// code works only once even when presented concurrently.
+func (r *MFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	log := logger.Log.WithFields(logrus.Fields{"user_id": userID, "step": step})
+	log.Info("Executing query to record used TOTP step")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE mfa_totp SET last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`
+	result, err := r.DB.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute record used TOTP step query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after recording used TOTP step")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReplaceTOTPSecret stores the user's secret in another form, such as
+// encrypted, if it is still stored as oldSecret. It returns sql.ErrNoRows if
+// the secret changed meanwhile.
+func (r *MFARepository) ReplaceTOTPSecret(ctx context.Context, userID int, oldSecret, newSecret string) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to replace stored TOTP secret")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE mfa_totp SET secret = $3 WHERE user_id = $1 AND secret = $2`
+	result, err := r.DB.ExecContext(ctx, query, userID, oldSecret, newSecret)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute replace TOTP secret query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after replacing TOTP secret")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes deletes the user's recovery codes and stores new ones.
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	log := logger.Log.WithFields(logrus.Fields{"user_id": userID, "codes": len(codeHashes)})
+	log.Info("Executing query to replace recovery codes")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.WithError(err).Error("Failed to execute delete recovery codes query")
+		return err
+	}
+	query := `INSERT INTO mfa_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`
+	if _, err := tx.ExecContext(ctx, query, userID, pq.Array(codeHashes)); err != nil {
+		log.WithError(err).Error("Failed to execute insert recovery codes query")
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode marks one of the user's recovery codes as used. It returns
+// sql.ErrNoRows if the code does not exist or was already used.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to use a recovery code")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := r.DB.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute use recovery code query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after using recovery code")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
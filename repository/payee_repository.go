@@ -0,0 +1,176 @@
+// file: repository/payee_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IPayeeRepository defines the contract for payee database operations.
+type IPayeeRepository interface {
+	CreatePayee(ctx context.Context, payee *model.Payee) error
+	GetPayeeByID(ctx context.Context, payeeID int) (*model.Payee, error)
+	GetPayeesByUserID(ctx context.Context, userID int) ([]*model.Payee, error)
+	UpdateNickname(ctx context.Context, payeeID int, nickname string) error
+	MarkVerified(ctx context.Context, payeeID int) error
+	DeletePayee(ctx context.Context, payeeID int) error
+}
+
+// PayeeRepository implements IPayeeRepository.
+type PayeeRepository struct {
+	DB *sql.DB
+}
+
+// NewPayeeRepository creates a new PayeeRepository.
+func NewPayeeRepository(db *sql.DB) *PayeeRepository {
+	return &PayeeRepository{DB: db}
+}
+
+const payeeColumns = `id, user_id, nickname, account_number, currency, holder_name, status, verified_at, created_at, updated_at`
+
+func scanPayee(row rowScanner) (*model.Payee, error) {
+	payee := &model.Payee{}
+	var verifiedAt sql.NullTime
+	if err := row.Scan(&payee.ID, &payee.UserID, &payee.Nickname, &payee.AccountNumber, &payee.Currency,
+		&payee.HolderName, &payee.Status, &verifiedAt, &payee.CreatedAt, &payee.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if verifiedAt.Valid {
+		payee.VerifiedAt = &verifiedAt.Time
+	}
+	return payee, nil
+}
+
+// CreatePayee inserts a new, unverified payee.
+func (r *PayeeRepository) CreatePayee(ctx context.Context, payee *model.Payee) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":        payee.UserID,
+		"account_number": payee.AccountNumber,
+	})
+	log.Info("Executing query to create a new payee")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO payees (user_id, nickname, account_number, currency, holder_name)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at, updated_at`
+	err := r.DB.QueryRowContext(ctx, query, payee.UserID, payee.Nickname, payee.AccountNumber, payee.Currency, payee.HolderName).
+		Scan(&payee.ID, &payee.Status, &payee.CreatedAt, &payee.UpdatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create payee query")
+		return err
+	}
+	return nil
+}
+
+// GetPayeeByID retrieves a single payee by its ID.
+func (r *PayeeRepository) GetPayeeByID(ctx context.Context, payeeID int) (*model.Payee, error) {
+	log := logger.Log.WithField("payee_id", payeeID)
+	log.Info("Executing query to get payee by ID")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + payeeColumns + ` FROM payees WHERE id = $1`
+	payee, err := scanPayee(r.DB.QueryRowContext(ctx, query, payeeID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get payee by ID query")
+		}
+		return nil, err
+	}
+	return payee, nil
+}
+
+// GetPayeesByUserID retrieves all payees saved by a user, ordered by nickname.
+func (r *PayeeRepository) GetPayeesByUserID(ctx context.Context, userID int) ([]*model.Payee, error) {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to get payees by user ID")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + payeeColumns + ` FROM payees WHERE user_id = $1 ORDER BY nickname, id`
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute get payees by user ID query")
+		return nil, err
+	}
+	defer rows.Close()
+
+	payees := []*model.Payee{}
+	for rows.Next() {
+		payee, err := scanPayee(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan payee row")
+			return nil, err
+		}
+		payees = append(payees, payee)
+	}
+	return payees, rows.Err()
+}
+
+// UpdateNickname renames a payee. It returns sql.ErrNoRows if the payee does not exist.
+func (r *PayeeRepository) UpdateNickname(ctx context.Context, payeeID int, nickname string) error {
+	log := logger.Log.WithField("payee_id", payeeID)
+	log.Info("Executing query to update payee nickname")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE payees SET nickname = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	return r.execAffectingOne(ctx, log, query, nickname, payeeID)
+}
+
+// MarkVerified records that the user has confirmed the payee. It returns
+// sql.ErrNoRows if the payee does not exist.
+func (r *PayeeRepository) MarkVerified(ctx context.Context, payeeID int) error {
+	log := logger.Log.WithField("payee_id", payeeID)
+	log.Info("Executing query to mark payee as verified")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE payees SET status = 'verified', verified_at = COALESCE(verified_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	return r.execAffectingOne(ctx, log, query, payeeID)
+}
+
+// DeletePayee removes a payee. It returns sql.ErrNoRows if the payee does not exist.
+func (r *PayeeRepository) DeletePayee(ctx context.Context, payeeID int) error {
+	log := logger.Log.WithField("payee_id", payeeID)
+	log.Info("Executing query to delete payee")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM payees WHERE id = $1`
+	return r.execAffectingOne(ctx, log, query, payeeID)
+}
+
+// execAffectingOne runs a statement that targets a single payee and reports
+// sql.ErrNoRows when no row was affected.
+func (r *PayeeRepository) execAffectingOne(ctx context.Context, log *logrus.Entry, query string, args ...interface{}) error {
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute payee update query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected for payee update")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+// file: repository/query_timeout.go
+
+package repository
+
+import (
+	"context"
+	"go-bank-api/config"
+)
+
+// withQueryTimeout bounds ctx by the configured database query timeout, so a
+// slow query is cancelled even if the caller set no deadline. With a zero
+// timeout only the caller's own deadline and cancellation apply.
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := config.AppConfig.Database.QueryTimeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,195 @@
+// file: repository/role_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// IRoleRepository defines the contract for role database operations.
+type IRoleRepository interface {
+	ListRoles(ctx context.Context) ([]*model.RoleDefinition, error)
+	GetRole(ctx context.Context, name string) (*model.RoleDefinition, error)
+	CreateRole(ctx context.Context, tx *sql.Tx, role *model.RoleDefinition) error
+	UpdateRole(ctx context.Context, tx *sql.Tx, role *model.RoleDefinition) error
+	SetPermissions(ctx context.Context, tx *sql.Tx, name string, permissions []model.Permission) error
+	DeleteRole(ctx context.Context, name string) error
+}
+
+// RoleRepository implements IRoleRepository.
+type RoleRepository struct {
+	DB *sql.DB
+}
+
+// NewRoleRepository creates a new RoleRepository.
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{DB: db}
+}
+
+// roleColumns selects a role together with its permissions, sorted, as an
+// array; roles without permissions get an empty one.
+const roleColumns = `
+	r.name, r.description, r.built_in, r.created_at, r.updated_at,
+	COALESCE(ARRAY(SELECT p.permission FROM role_permissions p WHERE p.role = r.name ORDER BY p.permission), '{}')`
+
+// scanRole reads a row selected with roleColumns.
+func scanRole(row rowScanner) (*model.RoleDefinition, error) {
+	var role model.RoleDefinition
+	var permissions []string
+	if err := row.Scan(&role.Name, &role.Description, &role.BuiltIn, &role.CreatedAt, &role.UpdatedAt, pq.Array(&permissions)); err != nil {
+		return nil, err
+	}
+	role.Permissions = make([]model.Permission, len(permissions))
+	for i, p := range permissions {
+		role.Permissions[i] = model.Permission(p)
+	}
+	return &role, nil
+}
+
+// ListRoles retrieves every role, built-in roles first.
+func (r *RoleRepository) ListRoles(ctx context.Context) ([]*model.RoleDefinition, error) {
+	logger.Log.Info("Executing query to list roles")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + roleColumns + ` FROM roles r ORDER BY r.built_in DESC, r.name`
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to execute list roles query")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*model.RoleDefinition
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to scan role row")
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// GetRole retrieves a role by name.
+func (r *RoleRepository) GetRole(ctx context.Context, name string) (*model.RoleDefinition, error) {
+	log := logger.Log.WithField("role", name)
+	log.Info("Executing query to get role")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + roleColumns + ` FROM roles r WHERE r.name = $1`
+	role, err := scanRole(r.DB.QueryRowContext(ctx, query, name))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get role query")
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+// CreateRole inserts a new role without permissions; see SetPermissions.
+func (r *RoleRepository) CreateRole(ctx context.Context, tx *sql.Tx, role *model.RoleDefinition) error {
+	log := logger.Log.WithField("role", role.Name)
+	log.Info("Executing query to create a new role")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO roles (name, description)
+		VALUES ($1, $2)
+		RETURNING built_in, created_at, updated_at`
+	err := tx.QueryRowContext(ctx, query, role.Name, role.Description).Scan(&role.BuiltIn, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create role query")
+		return err
+	}
+	return nil
+}
+
+// UpdateRole changes the description of a role that is not built in. It
+// returns sql.ErrNoRows if there is no such role.
+func (r *RoleRepository) UpdateRole(ctx context.Context, tx *sql.Tx, role *model.RoleDefinition) error {
+	log := logger.Log.WithField("role", role.Name)
+	log.Info("Executing query to update role")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE roles SET description = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE name = $2 AND NOT built_in
+		RETURNING created_at, updated_at`
+	err := tx.QueryRowContext(ctx, query, role.Description, role.Name).Scan(&role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute update role query")
+		}
+		return err
+	}
+	return nil
+}
+
+// SetPermissions replaces the permissions of a role.
+func (r *RoleRepository) SetPermissions(ctx context.Context, tx *sql.Tx, name string, permissions []model.Permission) error {
+	log := logger.Log.WithFields(logrus.Fields{"role": name, "permissions": permissions})
+	log.Info("Executing query to set role permissions")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role = $1`, name); err != nil {
+		log.WithError(err).Error("Failed to execute delete role permissions query")
+		return err
+	}
+	names := make([]string, len(permissions))
+	for i, p := range permissions {
+		names[i] = string(p)
+	}
+	query := `
+		INSERT INTO role_permissions (role, permission)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, name, pq.Array(names)); err != nil {
+		log.WithError(err).Error("Failed to execute insert role permissions query")
+		return err
+	}
+	return nil
+}
+
+// DeleteRole deletes a role that is not built in, together with its
+// permissions. It returns sql.ErrNoRows if there is no such role; deleting a
+// role some user still holds fails with a foreign key violation.
+func (r *RoleRepository) DeleteRole(ctx context.Context, name string) error {
+	log := logger.Log.WithField("role", name)
+	log.Info("Executing query to delete role")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM roles WHERE name = $1 AND NOT built_in`, name)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute delete role query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after role deletion")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
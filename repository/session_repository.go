@@ -0,0 +1,191 @@
+// file: repository/session_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ISessionRepository defines the contract for session database operations.
+type ISessionRepository interface {
+	CreateSession(ctx context.Context, session *model.Session) error
+	GetSessionByID(ctx context.Context, sessionID int) (*model.Session, error)
+	GetActiveSessionsByUserID(ctx context.Context, userID int) ([]*model.Session, error)
+	TouchSession(ctx context.Context, sessionID int) error
+	RevokeSession(ctx context.Context, sessionID int) error
+	RevokeUserSessions(ctx context.Context, userID int) error
+}
+
+// SessionRepository implements ISessionRepository.
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+// NewSessionRepository creates a new SessionRepository.
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+// sessionColumns lists the columns read by scanSession, in order.
+const sessionColumns = `id, user_id, device_name, user_agent, ip_address, created_at, last_used_at, revoked_at`
+
+// scanSession reads a row selected with sessionColumns.
+func scanSession(row rowScanner) (*model.Session, error) {
+	var s model.Session
+	var revokedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.UserID, &s.DeviceName, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return &s, nil
+}
+
+// CreateSession inserts a new session, filling in its generated ID and timestamps.
+func (r *SessionRepository) CreateSession(ctx context.Context, session *model.Session) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":     session.UserID,
+		"device_name": session.DeviceName,
+		"ip_address":  session.IPAddress,
+	})
+	log.Info("Executing query to create a new session")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO sessions (user_id, device_name, user_agent, ip_address)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_used_at`
+	err := r.DB.QueryRowContext(ctx, query, session.UserID, session.DeviceName, session.UserAgent, session.IPAddress).
+		Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create session query")
+		return err
+	}
+	return nil
+}
+
+// GetSessionByID retrieves a session by its ID, whether or not it has been revoked.
+func (r *SessionRepository) GetSessionByID(ctx context.Context, sessionID int) (*model.Session, error) {
+	log := logger.Log.WithField("session_id", sessionID)
+	log.Info("Executing query to get session by ID")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	session, err := scanSession(r.DB.QueryRowContext(ctx, query, sessionID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get session by ID query")
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetActiveSessionsByUserID retrieves the sessions of a user that have not
+// been revoked, most recently used first.
+func (r *SessionRepository) GetActiveSessionsByUserID(ctx context.Context, userID int) ([]*model.Session, error) {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to get active sessions for user")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 AND revoked_at IS NULL ORDER BY last_used_at DESC`
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute query for active sessions")
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*model.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan session row")
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records that a session has just been used.
+func (r *SessionRepository) TouchSession(ctx context.Context, sessionID int) error {
+	log := logger.Log.WithField("session_id", sessionID)
+	log.Info("Executing query to update session last used time")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE sessions SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if _, err := r.DB.ExecContext(ctx, query, sessionID); err != nil {
+		log.WithError(err).Error("Failed to execute touch session query")
+		return err
+	}
+	return nil
+}
+
+// RevokeSession revokes a session together with its refresh tokens, in one
+// statement. It returns sql.ErrNoRows if the session does not exist or was
+// already revoked.
+func (r *SessionRepository) RevokeSession(ctx context.Context, sessionID int) error {
+	log := logger.Log.WithField("session_id", sessionID)
+	log.Info("Executing query to revoke session")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND revoked_at IS NULL
+			RETURNING id
+		), tokens AS (
+			UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			WHERE session_id IN (SELECT id FROM revoked) AND revoked_at IS NULL
+		)
+		SELECT COUNT(*) FROM revoked`
+	var revoked int
+	if err := r.DB.QueryRowContext(ctx, query, sessionID).Scan(&revoked); err != nil {
+		log.WithError(err).Error("Failed to execute revoke session query")
+		return err
+	}
+	if revoked == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes all of the user's sessions together with all of
+// their refresh tokens, including tokens issued before sessions existed.
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, userID int) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to revoke all sessions of a user")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND revoked_at IS NULL
+		)
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := r.DB.ExecContext(ctx, query, userID); err != nil {
+		log.WithError(err).Error("Failed to execute revoke user sessions query")
+		return err
+	}
+	return nil
+}
@@ -3,6 +3,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"go-bank-api/logger"
 	"go-bank-api/model"
@@ -12,9 +13,11 @@ import (
 
 // ITokenRepository defines the contract for refresh token database operations.
 type ITokenRepository interface {
-	Create(token *model.RefreshToken) error
-	GetByTokenHash(tokenHash string) (*model.RefreshToken, error)
-	DeleteByUserID(userID int) error
+	Create(ctx context.Context, token *model.RefreshToken) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	MarkUsed(ctx context.Context, tokenID int) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	DeleteByUserID(ctx context.Context, userID int) error
 }
 
 // TokenRepository implements ITokenRepository.
@@ -27,16 +30,33 @@ func NewTokenRepository(db *sql.DB) *TokenRepository {
 	return &TokenRepository{DB: db}
 }
 
-// Create inserts a new refresh token record into the database.
-func (r *TokenRepository) Create(token *model.RefreshToken) error {
+// Create inserts a new refresh token record into the database. A token
+// without a FamilyID starts a new family, whose generated ID is filled in.
+func (r *TokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
 	log := logger.Log.WithFields(logrus.Fields{
 		"user_id":    token.UserID,
+		"family_id":  token.FamilyID,
 		"expires_at": token.ExpiresAt,
 	})
 	log.Info("Executing query to create a new refresh token")
 
-	query := `INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id, created_at`
-	err := r.DB.QueryRow(query, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var sessionID, familyID interface{}
+	if token.SessionID != 0 {
+		sessionID = token.SessionID
+	}
+	if token.FamilyID != "" {
+		familyID = token.FamilyID
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, session_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, COALESCE($4::uuid, gen_random_uuid()), $5)
+		RETURNING id, family_id, created_at`
+	err := r.DB.QueryRowContext(ctx, query, token.UserID, sessionID, token.TokenHash, familyID, token.ExpiresAt).
+		Scan(&token.ID, &token.FamilyID, &token.CreatedAt)
 	if err != nil {
 		log.WithError(err).Error("Failed to execute create refresh token query")
 		return err
@@ -45,30 +65,91 @@ func (r *TokenRepository) Create(token *model.RefreshToken) error {
 }
 
 // GetByTokenHash retrieves a refresh token by its hashed value.
-func (r *TokenRepository) GetByTokenHash(tokenHash string) (*model.RefreshToken, error) {
+func (r *TokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
 	log := logger.Log.WithField("token_hash", tokenHash)
 	log.Info("Executing query to get refresh token by hash")
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	token := &model.RefreshToken{}
-	query := `SELECT id, user_id, token_hash, expires_at, created_at FROM refresh_tokens WHERE token_hash = $1`
-	err := r.DB.QueryRow(query, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt)
+	var sessionID sql.NullInt64
+	var usedAt, revokedAt sql.NullTime
+	query := `
+		SELECT id, user_id, session_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1`
+	err := r.DB.QueryRowContext(ctx, query, tokenHash).Scan(&token.ID, &token.UserID, &sessionID, &token.FamilyID, &token.TokenHash,
+		&token.ExpiresAt, &usedAt, &revokedAt, &token.CreatedAt)
 	if err != nil {
 		if err != sql.ErrNoRows {
 			log.WithError(err).Error("Failed to execute get refresh token by hash query")
 		}
 		return nil, err // Return sql.ErrNoRows if not found
 	}
+	token.SessionID = int(sessionID.Int64)
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
 	return token, nil
 }
 
+// MarkUsed records that a refresh token has been exchanged for its successor.
+// It returns sql.ErrNoRows if the token was already used or revoked, so that
+// of two concurrent refreshes with the same token only one succeeds.
+func (r *TokenRepository) MarkUsed(ctx context.Context, tokenID int) error {
+	log := logger.Log.WithField("token_id", tokenID)
+	log.Info("Executing query to mark refresh token as used")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`
+	result, err := r.DB.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute mark refresh token used query")
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after marking refresh token used")
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeFamily revokes every refresh token in a family.
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	log := logger.Log.WithField("family_id", familyID)
+	log.Info("Executing query to revoke refresh token family")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := r.DB.ExecContext(ctx, query, familyID); err != nil {
+		log.WithError(err).Error("Failed to execute revoke refresh token family query")
+		return err
+	}
+	return nil
+}
+
 // DeleteByUserID deletes all refresh tokens for a specific user.
 // This is used for logging out from all sessions.
-func (r *TokenRepository) DeleteByUserID(userID int) error {
+func (r *TokenRepository) DeleteByUserID(ctx context.Context, userID int) error {
 	log := logger.Log.WithField("user_id", userID)
 	log.Info("Executing query to delete all refresh tokens for a user")
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `DELETE FROM refresh_tokens WHERE user_id = $1`
-	_, err := r.DB.Exec(query, userID)
+	_, err := r.DB.ExecContext(ctx, query, userID)
 	if err != nil {
 		log.WithError(err).Error("Failed to execute delete refresh tokens query")
 		return err
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"go-bank-api/logger"
 	"go-bank-api/model"
 
@@ -10,8 +12,9 @@ import (
 
 // ITransactionRepository defines the contract for transaction database operations.
 type ITransactionRepository interface {
-	CreateTransaction(tx *sql.Tx, transaction *model.Transaction) error
-	GetTransactionsByAccountID(accountID int) ([]*model.Transaction, error) // Correct signature
+	CreateTransaction(ctx context.Context, tx *sql.Tx, transaction *model.Transaction) error
+	GetTransactionByID(ctx context.Context, transactionID int) (*model.Transaction, error)
+	ListTransactions(ctx context.Context, filter model.TransactionFilter) ([]*model.Transaction, error)
 }
 
 // TransactionRepository implements ITransactionRepository.
@@ -23,16 +26,75 @@ func NewTransactionRepository(db *sql.DB) *TransactionRepository {
 	return &TransactionRepository{DB: db}
 }
 
-func (r *TransactionRepository) CreateTransaction(tx *sql.Tx, transaction *model.Transaction) error {
+// transactionColumns lists the columns read by scanTransaction, in order.
+const transactionColumns = `id, kind, from_account_id, to_account_id, amount, currency,
+	destination_amount, destination_currency, fx_mid_rate, fx_rate, fx_spread_bps, fx_quote_id, created_at`
+
+// scanTransaction reads a transaction row, converting the NUMERIC amount and
+// its currency into an exact Money value. The FX columns are only set for
+// cross-currency transfers.
+func scanTransaction(row rowScanner) (*model.Transaction, error) {
+	var t model.Transaction
+	var amount, currency string
+	var destAmount, destCurrency, midRate, rate, quoteID sql.NullString
+	var spreadBps sql.NullInt64
+	if err := row.Scan(&t.ID, &t.Kind, &t.FromAccountID, &t.ToAccountID, &amount, &currency,
+		&destAmount, &destCurrency, &midRate, &rate, &spreadBps, &quoteID, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	money, err := model.ParseMoney(amount, currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q for transaction %d: %w", amount, t.ID, err)
+	}
+	t.Amount = money
+
+	if destAmount.Valid {
+		fx := &model.FXDetails{SpreadBps: int(spreadBps.Int64)}
+		if fx.DestinationAmount, err = model.ParseMoney(destAmount.String, destCurrency.String); err != nil {
+			return nil, fmt.Errorf("invalid destination amount %q for transaction %d: %w", destAmount.String, t.ID, err)
+		}
+		if fx.MidRate, err = model.ParseRate(midRate.String); err != nil {
+			return nil, fmt.Errorf("invalid mid rate %q for transaction %d: %w", midRate.String, t.ID, err)
+		}
+		if fx.Rate, err = model.ParseRate(rate.String); err != nil {
+			return nil, fmt.Errorf("invalid rate %q for transaction %d: %w", rate.String, t.ID, err)
+		}
+		if quoteID.Valid {
+			fx.QuoteID = &quoteID.String
+		}
+		t.FX = fx
+	}
+	return &t, nil
+}
+
+func (r *TransactionRepository) CreateTransaction(ctx context.Context, tx *sql.Tx, transaction *model.Transaction) error {
 	log := logger.Log.WithFields(logrus.Fields{
 		"from_account_id": transaction.FromAccountID,
 		"to_account_id":   transaction.ToAccountID,
-		"amount":          transaction.Amount,
+		"amount":          transaction.Amount.String(),
+		"kind":            transaction.Kind,
 	})
 	log.Info("Executing query to create a new transaction")
 
-	query := `INSERT INTO transactions (from_account_id, to_account_id, amount) VALUES ($1, $2, $3) RETURNING id, created_at`
-	err := tx.QueryRow(query, transaction.FromAccountID, transaction.ToAccountID, transaction.Amount).Scan(&transaction.ID, &transaction.CreatedAt)
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var destAmount, destCurrency, midRate, rate, spreadBps, quoteID interface{}
+	if fx := transaction.FX; fx != nil {
+		destAmount, destCurrency = fx.DestinationAmount, fx.DestinationAmount.Currency
+		midRate, rate, spreadBps = fx.MidRate, fx.Rate, fx.SpreadBps
+		if fx.QuoteID != nil {
+			quoteID = *fx.QuoteID
+		}
+	}
+
+	query := `
+		INSERT INTO transactions (kind, from_account_id, to_account_id, amount, currency,
+			destination_amount, destination_currency, fx_mid_rate, fx_rate, fx_spread_bps, fx_quote_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`
+	err := tx.QueryRowContext(ctx, query, transaction.Kind, transaction.FromAccountID, transaction.ToAccountID, transaction.Amount, transaction.Amount.Currency,
+		destAmount, destCurrency, midRate, rate, spreadBps, quoteID).Scan(&transaction.ID, &transaction.CreatedAt)
 	if err != nil {
 		log.WithError(err).Error("Failed to execute create transaction query")
 		return err
@@ -40,33 +102,89 @@ func (r *TransactionRepository) CreateTransaction(tx *sql.Tx, transaction *model
 	return nil
 }
 
-// GetTransactionsByAccountID retrieves all transactions for a specific account, returning a slice of pointers.
-func (r *TransactionRepository) GetTransactionsByAccountID(accountID int) ([]*model.Transaction, error) {
-	log := logger.Log.WithField("account_id", accountID)
-	log.Info("Executing query to get transactions by account ID")
+// GetTransactionByID retrieves a single transaction by its primary key ID.
+func (r *TransactionRepository) GetTransactionByID(ctx context.Context, transactionID int) (*model.Transaction, error) {
+	log := logger.Log.WithField("transaction_id", transactionID)
+	log.Info("Executing query to get transaction by ID")
 
-	query := `
-		SELECT id, from_account_id, to_account_id, amount, created_at 
-		FROM transactions 
-		WHERE from_account_id = $1 OR to_account_id = $1
-		ORDER BY created_at DESC`
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
-	rows, err := r.DB.Query(query, accountID)
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id = $1`
+	transaction, err := scanTransaction(r.DB.QueryRowContext(ctx, query, transactionID))
 	if err != nil {
-		log.WithError(err).Error("Failed to execute query for transactions by account ID")
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute get transaction by ID query")
+		}
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// ListTransactions retrieves one page of an account's transactions, newest
+// first unless the filter asks for ascending order. Pages are keyed on
+// (created_at, id) so that rows inserted while a client pages through the
+// history neither repeat nor go missing. It fetches one row beyond the limit;
+// callers use it to tell whether another page follows.
+func (r *TransactionRepository) ListTransactions(ctx context.Context, filter model.TransactionFilter) ([]*model.Transaction, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"account_id": filter.AccountID,
+		"limit":      filter.Limit,
+		"direction":  filter.Direction,
+	})
+	log.Info("Executing query to list transactions for account")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	q := &listQuery{}
+	q.arg(filter.AccountID)
+	switch filter.Direction {
+	case model.DirectionIncoming:
+		q.where("to_account_id = $1")
+	case model.DirectionOutgoing:
+		q.where("from_account_id = $1")
+	default:
+		q.where("(from_account_id = $1 OR to_account_id = $1)")
+	}
+	if filter.From != nil {
+		q.where("created_at >= " + q.arg(*filter.From))
+	}
+	if filter.To != nil {
+		q.where("created_at < " + q.arg(*filter.To))
+	}
+	// The amount that moved in or out of this account: incoming cross-currency
+	// transfers arrive as their destination amount.
+	const accountAmount = `CASE WHEN to_account_id = $1 AND destination_amount IS NOT NULL THEN destination_amount ELSE amount END`
+	if filter.MinAmount != nil {
+		q.where(accountAmount + " >= " + q.arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		q.where(accountAmount + " <= " + q.arg(*filter.MaxAmount))
+	}
+	if filter.CounterpartyAccountNumber != 0 {
+		q.where(`CASE WHEN from_account_id = $1 THEN to_account_id ELSE from_account_id END =
+			(SELECT id FROM accounts WHERE account_number = ` + q.arg(filter.CounterpartyAccountNumber) + `)`)
+	}
+	pageClause := q.page(filter.After, filter.Limit, filter.Ascending)
+
+	query := `SELECT ` + transactionColumns + ` FROM transactions` + q.whereClause() + pageClause
+
+	rows, err := r.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute query to list transactions for account")
 		return nil, err
 	}
 	defer rows.Close()
 
-	var transactions []*model.Transaction // Correct type: slice of pointers
+	var transactions []*model.Transaction
 	for rows.Next() {
-		var t model.Transaction
-		if err := rows.Scan(&t.ID, &t.FromAccountID, &t.ToAccountID, &t.Amount, &t.CreatedAt); err != nil {
+		t, err := scanTransaction(rows)
+		if err != nil {
 			log.WithError(err).Error("Failed to scan transaction row")
 			return nil, err
 		}
-		transactions = append(transactions, &t) // Correctly append the pointer
+		transactions = append(transactions, t)
 	}
-
-	return transactions, nil
+	return transactions, rows.Err()
 }
@@ -3,20 +3,25 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"go-bank-api/logger"
 	"go-bank-api/model"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
 // IUserRepository defines the contract for user database operations.
 type IUserRepository interface {
-	CreateUser(user *model.User) error
-	GetUserByEmail(email string) (*model.User, error)
-	GetUserByID(id int) (*model.User, error)
-	GetAllUsers() ([]*model.User, error)
-	UpdateUserRole(userID int, newRole string) error
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	GetUserByID(ctx context.Context, id int) (*model.User, error)
+	ListUsers(ctx context.Context, filter model.UserFilter) ([]*model.User, int, error)
+	UpdateUserRole(ctx context.Context, tx *sql.Tx, userID int, newRole string) error
+	UpdatePassword(ctx context.Context, tx *sql.Tx, userID int, passwordHash string) error
+	ReplacePasswordHash(ctx context.Context, userID int, oldHash, newHash string) error
+	MarkEmailVerified(ctx context.Context, tx *sql.Tx, userID int) error
 }
 
 type UserRepository struct {
@@ -27,15 +32,18 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
-func (r *UserRepository) CreateUser(user *model.User) error {
+func (r *UserRepository) CreateUser(ctx context.Context, user *model.User) error {
 	log := logger.Log.WithFields(logrus.Fields{
 		"username": user.Username,
 		"email":    user.Email,
 	})
 	log.Info("Executing query to create a new user")
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `INSERT INTO users (username, email, password) VALUES ($1, $2, $3) RETURNING id, created_at, role`
-	err := r.DB.QueryRow(query, user.Username, user.Email, user.Password).Scan(&user.ID, &user.CreatedAt, &user.Role)
+	err := r.DB.QueryRowContext(ctx, query, user.Username, user.Email, user.Password).Scan(&user.ID, &user.CreatedAt, &user.Role)
 	if err != nil {
 		log.WithError(err).Error("Failed to execute create user query")
 		return err
@@ -43,13 +51,17 @@ func (r *UserRepository) CreateUser(user *model.User) error {
 	return nil
 }
 
-func (r *UserRepository) GetUserByEmail(email string) (*model.User, error) {
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	log := logger.Log.WithField("email", email)
 	log.Info("Executing query to get user by email")
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	user := &model.User{}
-	query := `SELECT id, username, email, password, role, created_at FROM users WHERE email=$1`
-	err := r.DB.QueryRow(query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
+	var emailVerifiedAt sql.NullTime
+	query := `SELECT id, username, email, password, role, email_verified_at, created_at FROM users WHERE email=$1`
+	err := r.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &emailVerifiedAt, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Info("User not found in database")
@@ -58,61 +70,111 @@ func (r *UserRepository) GetUserByEmail(email string) (*model.User, error) {
 		}
 		return nil, err
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
 	return user, nil
 }
 
 // GetUserByID retrieves a user by their primary key ID.
-func (r *UserRepository) GetUserByID(id int) (*model.User, error) {
+func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*model.User, error) {
 	log := logger.Log.WithField("user_id", id)
 	log.Info("Executing query to get user by ID")
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	user := &model.User{}
-	query := `SELECT id, username, email, password, role, created_at FROM users WHERE id=$1`
-	err := r.DB.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
+	var emailVerifiedAt sql.NullTime
+	query := `SELECT id, username, email, password, role, email_verified_at, created_at FROM users WHERE id=$1`
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &emailVerifiedAt, &user.CreatedAt)
 	if err != nil {
 		if err != sql.ErrNoRows {
 			log.WithError(err).Error("Failed to execute get user by ID query")
 		}
 		return nil, err
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
 	return user, nil
 }
 
-// GetAllUsers retrieves all users from the database. For admin use only.
-func (r *UserRepository) GetAllUsers() ([]*model.User, error) {
-	log := logger.Log
-	log.Info("Executing query to get all users")
+// ListUsers retrieves one page of the users matching the filter, newest first
+// unless the filter asks for ascending order, together with the number of
+// users matching the filter across all pages. It fetches one row beyond the
+// limit; callers use it to tell whether another page follows. For admin use only.
+func (r *UserRepository) ListUsers(ctx context.Context, filter model.UserFilter) ([]*model.User, int, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"search": filter.Search,
+		"role":   filter.Role,
+		"limit":  filter.Limit,
+	})
+	log.Info("Executing query to list users")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	q := &listQuery{}
+	if filter.Search != "" {
+		pattern := q.arg(prefixPattern(strings.ToLower(filter.Search)))
+		q.where("(lower(username) LIKE " + pattern + " OR lower(email) LIKE " + pattern + ")")
+	}
+	if filter.Role != "" {
+		q.where("role = " + q.arg(filter.Role))
+	}
+	if filter.From != nil {
+		q.where("created_at >= " + q.arg(*filter.From))
+	}
+	if filter.To != nil {
+		q.where("created_at < " + q.arg(*filter.To))
+	}
 
-	query := `SELECT id, username, email, role, created_at FROM users`
-	rows, err := r.DB.Query(query)
+	var total int
+	countQuery := `SELECT COUNT(*) FROM users` + q.whereClause()
+	if err := r.DB.QueryRowContext(ctx, countQuery, q.args...).Scan(&total); err != nil {
+		log.WithError(err).Error("Failed to execute query to count users")
+		return nil, 0, err
+	}
+
+	pageClause := q.page(filter.After, filter.Limit, filter.Ascending)
+	query := `SELECT id, username, email, role, email_verified_at, created_at FROM users` + q.whereClause() + pageClause
+	rows, err := r.DB.QueryContext(ctx, query, q.args...)
 	if err != nil {
-		log.WithError(err).Error("Failed to execute query for all users")
-		return nil, err
+		log.WithError(err).Error("Failed to execute query to list users")
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var users []*model.User
 	for rows.Next() {
 		var user model.User
-		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.CreatedAt); err != nil {
+		var emailVerifiedAt sql.NullTime
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &emailVerifiedAt, &user.CreatedAt); err != nil {
 			log.WithError(err).Error("Failed to scan user row")
-			return nil, err
+			return nil, 0, err
+		}
+		if emailVerifiedAt.Valid {
+			user.EmailVerifiedAt = &emailVerifiedAt.Time
 		}
 		users = append(users, &user)
 	}
-	return users, nil
+	return users, total, rows.Err()
 }
 
-// UpdateUserRole updates a user's role in the database.
-func (r *UserRepository) UpdateUserRole(userID int, newRole string) error {
+// UpdateUserRole updates a user's role within tx.
+func (r *UserRepository) UpdateUserRole(ctx context.Context, tx *sql.Tx, userID int, newRole string) error {
 	log := logger.Log.WithFields(logrus.Fields{
 		"user_id":  userID,
 		"new_role": newRole,
 	})
 	log.Info("Executing query to update user role")
 
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE users SET role = $1 WHERE id = $2`
-	result, err := r.DB.Exec(query, newRole, userID)
+	result, err := tx.ExecContext(ctx, query, newRole, userID)
 	if err != nil {
 		log.WithError(err).Error("Failed to execute update user role query")
 		return err
@@ -131,3 +193,87 @@ func (r *UserRepository) UpdateUserRole(userID int, newRole string) error {
 	log.Info("User role updated successfully")
 	return nil
 }
+
+// UpdatePassword replaces the user's password hash.
+func (r *UserRepository) UpdatePassword(ctx context.Context, tx *sql.Tx, userID int, passwordHash string) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to update user password")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+	result, err := tx.ExecContext(ctx, query, passwordHash, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute update user password query")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after password update")
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReplacePasswordHash replaces the user's password hash, when rehashing the
+// password or changing it. It returns sql.ErrNoRows if the stored hash is no
+// longer oldHash, so that a password changed in the meantime is not overwritten.
+func (r *UserRepository) ReplacePasswordHash(ctx context.Context, userID int, oldHash, newHash string) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to replace user password hash")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`
+	result, err := r.DB.ExecContext(ctx, query, newHash, userID, oldHash)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute replace user password hash query")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after password hash replacement")
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkEmailVerified records that the user verified their email address. The
+// time of the first verification is kept.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, tx *sql.Tx, userID int) error {
+	log := logger.Log.WithField("user_id", userID)
+	log.Info("Executing query to mark user email as verified")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP) WHERE id = $1`
+	result, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute mark email verified query")
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.WithError(err).Error("Failed to get rows affected after marking email verified")
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// file: repository/user_token_repository.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IUserTokenRepository defines the contract for password reset and email
+// verification token database operations.
+type IUserTokenRepository interface {
+	Create(ctx context.Context, tx *sql.Tx, token *model.UserToken) error
+	Consume(ctx context.Context, tx *sql.Tx, purpose, tokenHash string) (*model.UserToken, error)
+	InvalidateForUser(ctx context.Context, tx *sql.Tx, userID int, purpose string) error
+}
+
+// UserTokenRepository implements IUserTokenRepository.
+type UserTokenRepository struct {
+	DB *sql.DB
+}
+
+// NewUserTokenRepository creates a new UserTokenRepository.
+func NewUserTokenRepository(db *sql.DB) *UserTokenRepository {
+	return &UserTokenRepository{DB: db}
+}
+
+// Create inserts a new token record into the database.
+func (r *UserTokenRepository) Create(ctx context.Context, tx *sql.Tx, token *model.UserToken) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":    token.UserID,
+		"purpose":    token.Purpose,
+		"expires_at": token.ExpiresAt,
+	})
+	log.Info("Executing query to create a new user token")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+	err := tx.QueryRowContext(ctx, query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to execute create user token query")
+		return err
+	}
+	return nil
+}
+
+// Consume marks the token with the hash as used and returns it. It returns
+// sql.ErrNoRows if there is no such token for the purpose, or it has expired
+// or was already used, so that each token works only once even when presented
+// concurrently.
+func (r *UserTokenRepository) Consume(ctx context.Context, tx *sql.Tx, purpose, tokenHash string) (*model.UserToken, error) {
+	log := logger.Log.WithField("purpose", purpose)
+	log.Info("Executing query to consume a user token")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	token := &model.UserToken{}
+	var usedAt sql.NullTime
+	query := `
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at`
+	err := tx.QueryRowContext(ctx, query, purpose, tokenHash).Scan(&token.ID, &token.UserID, &token.Purpose,
+		&token.TokenHash, &token.ExpiresAt, &usedAt, &token.CreatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to execute consume user token query")
+		}
+		return nil, err
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return token, nil
+}
+
+// InvalidateForUser marks the user's unused tokens for the purpose as used, so
+// that only the most recently mailed token works.
+func (r *UserTokenRepository) InvalidateForUser(ctx context.Context, tx *sql.Tx, userID int, purpose string) error {
+	log := logger.Log.WithFields(logrus.Fields{"user_id": userID, "purpose": purpose})
+	log.Info("Executing query to invalidate user tokens")
+
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, userID, purpose); err != nil {
+		log.WithError(err).Error("Failed to execute invalidate user tokens query")
+		return err
+	}
+	return nil
+}
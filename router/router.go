@@ -4,6 +4,8 @@ package router
 
 import (
 	"go-bank-api/handler"
+	"go-bank-api/model"
+	"go-bank-api/service"
 	"net/http"
 
 	_ "go-bank-api/docs" // docs is generated by Swag CLI
@@ -12,49 +14,130 @@ import (
 )
 
 // NewRouter sets up all application routes and their corresponding handlers.
-func NewRouter(userHandler *handler.UserHandler, accountHandler *handler.AccountHandler, transactionHandler *handler.TransactionHandler) http.Handler {
+func NewRouter(userHandler *handler.UserHandler, accountHandler *handler.AccountHandler, transactionHandler *handler.TransactionHandler, ledgerHandler *handler.LedgerHandler, payeeHandler *handler.PayeeHandler, fxHandler *handler.FXHandler, mfaHandler *handler.MFAHandler, verificationHandler *handler.VerificationHandler, roleHandler *handler.RoleHandler, approvalHandler *handler.ApprovalHandler, auditHandler *handler.AuditHandler, healthHandler *handler.HealthHandler, jwksHandler *handler.JWKSHandler, idempotencyService *service.IdempotencyService, rateLimiter *service.RateLimiter, keys *service.KeyRing, denylist service.ITokenDenylist, permissions service.IPermissionChecker) http.Handler {
 	mux := http.NewServeMux()
 
+	// Authenticated routes reject access tokens that were revoked before they expired.
+	auth := handler.AuthMiddleware(keys, denylist)
+
+	// Admin routes require permissions granted by the caller's role.
+	can := func(required ...model.Permission) func(http.Handler) http.Handler {
+		return handler.RequirePermission(permissions, required...)
+	}
+
+	// Money-moving endpoints honour the Idempotency-Key header so that client retries are safe.
+	idempotent := handler.IdempotencyMiddleware(idempotencyService)
+
+	// Rate limits are configured per rule; see config.RateLimit.
+	limit := func(rule string) func(http.Handler) http.Handler {
+		return handler.RateLimitMiddleware(rateLimiter, rule)
+	}
+
 	// --- Public Routes ---
-	mux.Handle("POST /register", handler.ErrorHandlingMiddleware(userHandler.Register))
-	mux.Handle("POST /login", handler.ErrorHandlingMiddleware(userHandler.Login))
-	mux.Handle("POST /api/token/refresh", handler.ErrorHandlingMiddleware(userHandler.RefreshToken))
+	mux.Handle("POST /register", limit("register")(handler.ErrorHandlingMiddleware(userHandler.Register)))
+	mux.Handle("POST /login", limit("login")(handler.ErrorHandlingMiddleware(userHandler.Login)))
+	mux.Handle("POST /login/mfa", limit("login")(handler.ErrorHandlingMiddleware(userHandler.LoginMFA)))
+	mux.Handle("POST /api/token/refresh", limit("auth")(handler.ErrorHandlingMiddleware(userHandler.RefreshToken)))
+	mux.Handle("POST /password/forgot", limit("auth")(handler.ErrorHandlingMiddleware(verificationHandler.ForgotPassword)))
+	mux.Handle("POST /password/reset", limit("auth")(handler.ErrorHandlingMiddleware(verificationHandler.ResetPassword)))
+	mux.Handle("POST /email/verify", limit("auth")(handler.ErrorHandlingMiddleware(verificationHandler.VerifyEmail)))
+	mux.Handle("GET /.well-known/jwks.json", handler.ErrorHandlingMiddleware(jwksHandler.GetJWKS))
 
 	// --- Authenticated Routes (Requires a valid Access Token) ---
-	mux.Handle("POST /api/logout", handler.AuthMiddleware(handler.ErrorHandlingMiddleware(userHandler.Logout)))
-	mux.Handle("GET /api/accounts", handler.AuthMiddleware(handler.ErrorHandlingMiddleware(accountHandler.ListAccounts)))
-	mux.Handle("POST /api/accounts", handler.AuthMiddleware(handler.ErrorHandlingMiddleware(accountHandler.CreateAccount)))
-	mux.Handle("POST /api/accounts/{fromAccountId}/transfers", handler.AuthMiddleware(handler.ErrorHandlingMiddleware(transactionHandler.CreateTransfer)))
-	mux.Handle("GET /api/accounts/{accountId}/transactions", handler.AuthMiddleware(handler.ErrorHandlingMiddleware(transactionHandler.ListTransactionsForAccount)))
-
-	// --- Admin-only Routes (Requires Admin Role) ---
-	mux.Handle("GET /api/admin/users", handler.AuthMiddleware(handler.AdminMiddleware(handler.ErrorHandlingMiddleware(userHandler.GetAllUsers))))
+	mux.Handle("POST /api/logout", auth(handler.ErrorHandlingMiddleware(userHandler.Logout)))
+	mux.Handle("PUT /api/password", auth(handler.ErrorHandlingMiddleware(userHandler.ChangePassword)))
+	mux.Handle("GET /api/sessions", auth(handler.ErrorHandlingMiddleware(userHandler.ListSessions)))
+	mux.Handle("DELETE /api/sessions/{id}", auth(handler.ErrorHandlingMiddleware(userHandler.RevokeSession)))
+	mux.Handle("POST /api/email/verification", auth(handler.ErrorHandlingMiddleware(verificationHandler.RequestEmailVerification)))
+	mux.Handle("POST /api/mfa/totp", auth(handler.ErrorHandlingMiddleware(mfaHandler.EnrollTOTP)))
+	mux.Handle("POST /api/mfa/totp/confirm", auth(handler.ErrorHandlingMiddleware(mfaHandler.ConfirmTOTP)))
+	mux.Handle("GET /api/accounts", auth(handler.ErrorHandlingMiddleware(accountHandler.ListAccounts)))
+	mux.Handle("POST /api/accounts", auth(handler.ErrorHandlingMiddleware(accountHandler.CreateAccount)))
+	mux.Handle("POST /api/accounts/{fromAccountId}/transfers", auth(limit("transfers")(handler.VerifiedEmailMiddleware(idempotent(handler.ErrorHandlingMiddleware(transactionHandler.CreateTransfer))))))
+	mux.Handle("GET /api/accounts/{accountId}/transactions", auth(handler.ErrorHandlingMiddleware(transactionHandler.ListTransactionsForAccount)))
+	mux.Handle("POST /api/fx/quotes", auth(handler.ErrorHandlingMiddleware(fxHandler.CreateQuote)))
+	mux.Handle("GET /api/beneficiaries/lookup", auth(handler.ErrorHandlingMiddleware(transactionHandler.LookupBeneficiary)))
+	mux.Handle("GET /api/payees", auth(handler.ErrorHandlingMiddleware(payeeHandler.ListPayees)))
+	mux.Handle("POST /api/payees", auth(handler.ErrorHandlingMiddleware(payeeHandler.CreatePayee)))
+	mux.Handle("GET /api/payees/{payeeId}", auth(handler.ErrorHandlingMiddleware(payeeHandler.GetPayee)))
+	mux.Handle("PATCH /api/payees/{payeeId}", auth(handler.ErrorHandlingMiddleware(payeeHandler.UpdatePayee)))
+	mux.Handle("DELETE /api/payees/{payeeId}", auth(handler.ErrorHandlingMiddleware(payeeHandler.DeletePayee)))
+	mux.Handle("POST /api/payees/{payeeId}/verify", auth(handler.ErrorHandlingMiddleware(payeeHandler.VerifyPayee)))
+
+	// --- Admin Routes (Require Permissions) ---
+	mux.Handle("GET /api/admin/users", auth(can(model.PermissionUsersRead)(handler.ErrorHandlingMiddleware(userHandler.GetAllUsers))))
 	mux.Handle("PATCH /api/admin/users/{id}/role",
-		handler.AuthMiddleware(
-			handler.AdminMiddleware(
+		auth(
+			can(model.PermissionUsersRoleWrite)(
 				handler.ErrorHandlingMiddleware(userHandler.UpdateUserRole),
 			),
 		),
 	)
+	mux.Handle("POST /api/admin/users/{id}/unlock",
+		auth(
+			can(model.PermissionUsersUnlock)(
+				handler.ErrorHandlingMiddleware(userHandler.UnlockUser),
+			),
+		),
+	)
 	mux.Handle("GET /api/admin/accounts",
-		handler.AuthMiddleware(
-			handler.AdminMiddleware(
+		auth(
+			can(model.PermissionAccountsRead)(
 				handler.ErrorHandlingMiddleware(accountHandler.GetAllAccounts),
 			),
 		),
 	)
 
 	mux.Handle("POST /api/admin/accounts/{accountId}/deposit",
-		handler.AuthMiddleware(
-			handler.AdminMiddleware(
-				handler.ErrorHandlingMiddleware(accountHandler.DepositToAccount),
+		auth(
+			can(model.PermissionAccountsDeposit)(
+				idempotent(handler.ErrorHandlingMiddleware(accountHandler.DepositToAccount)),
+			),
+		),
+	)
+	mux.Handle("POST /api/admin/accounts/{accountId}/fees",
+		auth(
+			can(model.PermissionAccountsFee)(
+				idempotent(handler.ErrorHandlingMiddleware(ledgerHandler.ChargeFee)),
+			),
+		),
+	)
+	mux.Handle("POST /api/admin/transactions/{transactionId}/reversal",
+		auth(
+			can(model.PermissionTransactionsReverse)(
+				idempotent(handler.ErrorHandlingMiddleware(ledgerHandler.ReverseTransaction)),
+			),
+		),
+	)
+	mux.Handle("GET /api/admin/ledger/verify",
+		auth(
+			can(model.PermissionLedgerRead)(
+				handler.ErrorHandlingMiddleware(ledgerHandler.VerifyLedger),
 			),
 		),
 	)
 
+	mux.Handle("GET /api/admin/permissions", auth(can(model.PermissionRolesRead)(handler.ErrorHandlingMiddleware(roleHandler.ListPermissions))))
+	mux.Handle("GET /api/admin/roles", auth(can(model.PermissionRolesRead)(handler.ErrorHandlingMiddleware(roleHandler.ListRoles))))
+	mux.Handle("GET /api/admin/roles/{name}", auth(can(model.PermissionRolesRead)(handler.ErrorHandlingMiddleware(roleHandler.GetRole))))
+	mux.Handle("POST /api/admin/roles", auth(can(model.PermissionRolesWrite)(handler.ErrorHandlingMiddleware(roleHandler.CreateRole))))
+	mux.Handle("PUT /api/admin/roles/{name}", auth(can(model.PermissionRolesWrite)(handler.ErrorHandlingMiddleware(roleHandler.UpdateRole))))
+	mux.Handle("DELETE /api/admin/roles/{name}", auth(can(model.PermissionRolesWrite)(handler.ErrorHandlingMiddleware(roleHandler.DeleteRole))))
+
+	mux.Handle("GET /api/admin/approvals", auth(can(model.PermissionApprovalsRead)(handler.ErrorHandlingMiddleware(approvalHandler.ListApprovalRequests))))
+	mux.Handle("GET /api/admin/approvals/{id}", auth(can(model.PermissionApprovalsRead)(handler.ErrorHandlingMiddleware(approvalHandler.GetApprovalRequest))))
+	mux.Handle("POST /api/admin/approvals/{id}/approve", auth(can(model.PermissionApprovalsDecide)(handler.ErrorHandlingMiddleware(approvalHandler.Approve))))
+	mux.Handle("POST /api/admin/approvals/{id}/reject", auth(can(model.PermissionApprovalsDecide)(handler.ErrorHandlingMiddleware(approvalHandler.Reject))))
+
+	mux.Handle("GET /api/admin/audit", auth(can(model.PermissionAuditRead)(handler.ErrorHandlingMiddleware(auditHandler.ListAuditEvents))))
+	mux.Handle("GET /api/admin/audit/verify", auth(can(model.PermissionAuditRead)(handler.ErrorHandlingMiddleware(auditHandler.VerifyAuditLog))))
+
 	// --- Health & Documentation ---
 	mux.HandleFunc("GET /health", handler.HealthCheck)
+	mux.HandleFunc("GET /health/live", healthHandler.Live)
+	mux.HandleFunc("GET /health/ready", healthHandler.Ready)
 	mux.Handle("GET /swagger/", httpSwagger.WrapHandler)
 
-	return mux
+	// Every request gets an ID, returned in X-Request-ID and kept in the audit log.
+	return handler.RequestIDMiddleware(mux)
 }
@@ -4,20 +4,27 @@ package router_test
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"go-bank-api/app"
 	"go-bank-api/config"
+	"go-bank-api/handler"
 	"go-bank-api/logger"
 	"go-bank-api/model"
 	"go-bank-api/repository"
 	"go-bank-api/service"
 	"log"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -36,7 +43,11 @@ var testRedisClient *redis.Client
 func TestMain(m *testing.M) {
 	logger.Init()
 	config.LoadConfig("../")
-	authService = service.NewAuthService(nil, nil)
+	passwordHasher, err := service.NewConfiguredPasswordHasher()
+	if err != nil {
+		log.Fatalf("could not configure password hashing: %v", err)
+	}
+	authService = service.NewAuthService(nil, nil, nil, nil, nil, nil, nil, passwordHasher, nil, nil)
 
 	// --- Database Connection ---
 	testDbConnStr := fmt.Sprintf("postgres://%s:%s@localhost:5434/%s_test?sslmode=disable",
@@ -70,6 +81,20 @@ func TestMain(m *testing.M) {
 		log.Fatalf("could not connect to test redis: %v", err)
 	}
 
+	// Cross-currency tests price from a fixed rate table.
+	config.AppConfig.FX.Provider = "static"
+	config.AppConfig.FX.Rates = map[string]string{"usd/try": "32.50"}
+	config.AppConfig.FX.SpreadBps = 0
+	config.AppConfig.FX.QuoteTTL = time.Minute
+	// Tests log in and register far more often than real clients; rate
+	// limiting is tested on an app of its own.
+	config.AppConfig.RateLimit.Enabled = false
+	// The readiness probe expects the schema at the newest migration, which
+	// is found relative to the working directory.
+	config.AppConfig.Health.MigrationsDir = "../db/migrations"
+	// TOTP secrets are encrypted with a fixed key in tests.
+	config.AppConfig.MFA.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
 	testApp = app.NewTestApp(db, testRedisClient)
 
 	// --- Run Tests ---
@@ -107,7 +132,7 @@ func createUserForTest(t *testing.T, username, email, password string) model.Use
 		Password: hashedPassword,
 	}
 	err := testApp.DB.QueryRow(
-		`INSERT INTO users (username, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		`INSERT INTO users (username, email, password, email_verified_at) VALUES ($1, $2, $3, CURRENT_TIMESTAMP) RETURNING id`,
 		user.Username, user.Email, user.Password,
 	).Scan(&user.ID)
 	assert.NoError(t, err)
@@ -123,7 +148,7 @@ func createUserWithRoleForTest(t *testing.T, username, email, password string, r
 		Role:     string(role),
 	}
 	err := testApp.DB.QueryRow(
-		`INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id`,
+		`INSERT INTO users (username, email, password, role, email_verified_at) VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP) RETURNING id`,
 		user.Username, user.Email, user.Password, user.Role,
 	).Scan(&user.ID)
 	assert.NoError(t, err)
@@ -144,14 +169,32 @@ func loginUserForTest(t *testing.T, email, password string) string {
 	return response.AccessToken
 }
 
+// createUserTokenForTest stores a password reset or email verification token
+// for the user, as if it had been mailed to them, and returns it.
+func createUserTokenForTest(t *testing.T, userID int, purpose string) string {
+	token := fmt.Sprintf("%s-token-%d-%d", purpose, userID, time.Now().UnixNano())
+	hash := sha256.Sum256([]byte(token))
+	_, err := testApp.DB.Exec(
+		`INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)`,
+		userID, purpose, base64.URLEncoding.EncodeToString(hash[:]), time.Now().Add(time.Hour),
+	)
+	assert.NoError(t, err)
+	return token
+}
+
 func cleanupUser(t *testing.T, email string) {
-	_, err := testApp.DB.Exec("DELETE FROM users WHERE email = $1", email)
+	// Approval requests keep users from being deleted, as they are history.
+	_, err := testApp.DB.Exec(`
+		DELETE FROM approval_requests
+		WHERE maker_id IN (SELECT id FROM users WHERE email = $1) OR checker_id IN (SELECT id FROM users WHERE email = $1)`, email)
+	assert.NoError(t, err, "Failed to clean up approval requests")
+	_, err = testApp.DB.Exec("DELETE FROM users WHERE email = $1", email)
 	assert.NoError(t, err, "Failed to clean up user")
 }
 
 func createAccountForTest(t *testing.T, userID int, currency string) model.Account {
-	accountService := service.NewAccountService(repository.NewAccountRepository(testApp.DB), testRedisClient)
-	account, err := accountService.CreateNewAccount(userID, currency)
+	accountService := service.NewAccountService(repository.NewAccountRepository(testApp.DB), testRedisClient, nil, service.NewAuditService(testApp.DB, repository.NewAuditRepository(testApp.DB)))
+	account, err := accountService.CreateNewAccount(context.Background(), userID, currency)
 	assert.NoError(t, err)
 	return *account
 }
@@ -167,6 +210,59 @@ func TestHealthCheck_Integration(t *testing.T) {
 	assert.JSONEq(t, expectedBody, rr.Body.String())
 }
 
+func TestHealthProbes_Integration(t *testing.T) {
+	t.Run("Live", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/health/live", nil)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"status":"alive"}`, rr.Body.String())
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/health/ready", nil)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var report model.ReadinessReport
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		assert.Equal(t, model.HealthReady, report.Status)
+		if assert.NotNil(t, report.Checks.Database) && assert.NotNil(t, report.Checks.Redis) && assert.NotNil(t, report.Checks.Migrations) {
+			assert.Equal(t, model.HealthUp, report.Checks.Database.Status)
+			assert.Equal(t, model.HealthUp, report.Checks.Redis.Status)
+			assert.Equal(t, model.HealthUp, report.Checks.Migrations.Status)
+			assert.Equal(t, report.Checks.Migrations.Expected, report.Checks.Migrations.Version)
+		}
+	})
+
+	t.Run("ShuttingDown", func(t *testing.T) {
+		healthService := service.NewHealthService(repository.NewHealthRepository(testApp.DB), testRedisClient, 1, time.Second)
+		healthService.SetShuttingDown()
+		healthHandler := handler.NewHealthHandler(healthService)
+
+		req, _ := http.NewRequest("GET", "/health/ready", nil)
+		rr := httptest.NewRecorder()
+		healthHandler.Ready(rr, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Contains(t, rr.Body.String(), model.HealthShuttingDown)
+	})
+}
+
+func TestJWKS_Integration(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	rr := httptest.NewRecorder()
+	testApp.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var set model.JWKSet
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &set))
+	assert.NotNil(t, set.Keys)
+	for _, key := range set.Keys {
+		assert.NotEmpty(t, key.Kid)
+	}
+}
+
 func TestRegister_Integration(t *testing.T) {
 	requestBody := `{"username":"integration_test_user","email":"integration@test.com","password":"password123"}`
 	req, _ := http.NewRequest("POST", "/register", strings.NewReader(requestBody))
@@ -226,6 +322,35 @@ func TestCreateAccount_Integration(t *testing.T) {
 		assert.NoError(t, err, "Account should be created in the database")
 		assert.Equal(t, "USD", currency)
 	})
+
+	t.Run("concurrent creations get distinct valid numbers", func(t *testing.T) {
+		const creations = 10
+		var wg sync.WaitGroup
+		results := make(chan *httptest.ResponseRecorder, creations)
+		for i := 0; i < creations; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequest("POST", "/api/accounts", strings.NewReader(`{"currency": "TRY"}`))
+				req.Header.Set("Authorization", "Bearer "+token)
+				rr := httptest.NewRecorder()
+				testApp.Router.ServeHTTP(rr, req)
+				results <- rr
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		numbers := make(map[int64]bool)
+		for rr := range results {
+			assert.Equal(t, http.StatusCreated, rr.Code)
+			var account model.Account
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &account))
+			assert.NoError(t, model.ValidateAccountNumber(account.AccountNumber))
+			numbers[account.AccountNumber] = true
+		}
+		assert.Len(t, numbers, creations)
+	})
 }
 
 func TestListAccounts_Caching_Integration(t *testing.T) {
@@ -283,11 +408,61 @@ func TestTransfer_Integration(t *testing.T) {
 	// --- Test Execution ---
 	senderToken := loginUserForTest(t, sender.Email, "password123")
 
+	t.Run("deposit appears in account history", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/accounts/%d/transactions", senderAccount.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+senderToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var page model.TransactionPage
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		assert.Empty(t, page.NextCursor)
+		if assert.Len(t, page.Transactions, 1) {
+			history := page.Transactions
+			assert.Equal(t, model.EntryKindDeposit, history[0].Kind)
+			assert.Equal(t, senderAccount.ID, history[0].ToAccountID)
+			assert.Equal(t, "500.00", history[0].Amount.Decimal())
+		}
+	})
+
+	t.Run("account history can be filtered by direction", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/accounts/%d/transactions?direction=outgoing&limit=10", senderAccount.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+senderToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var page model.TransactionPage
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		assert.Empty(t, page.Transactions)
+
+		req, _ = http.NewRequest("GET", fmt.Sprintf("/api/accounts/%d/transactions?cursor=garbage", senderAccount.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+senderToken)
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("beneficiary lookup masks the holder name", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/beneficiaries/lookup?account_number=%d", receiverAccount.AccountNumber), nil)
+		req.Header.Set("Authorization", "Bearer "+senderToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var beneficiary model.Beneficiary
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &beneficiary))
+		assert.Equal(t, receiverAccount.AccountNumber, beneficiary.AccountNumber)
+		assert.Equal(t, "r***", beneficiary.HolderName)
+		assert.Equal(t, "TRY", beneficiary.Currency)
+	})
+
 	t.Run("successful transfer", func(t *testing.T) {
 		amount := 150.75
 
-		// REFACTOR: The request body no longer contains from_account_id.
-		requestBody := fmt.Sprintf(`{"to_account_id": %d, "amount": %.2f}`, receiverAccount.ID, amount)
+		// The receiver is addressed by its public account number, not its internal ID.
+		requestBody := fmt.Sprintf(`{"to_account_number": "%d", "amount": %.2f}`, receiverAccount.AccountNumber, amount)
 
 		// REFACTOR: The endpoint is now resource-oriented, pointing to the source account.
 		url := fmt.Sprintf("/api/accounts/%d/transfers", senderAccount.ID)
@@ -308,6 +483,188 @@ func TestTransfer_Integration(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, 500.00-amount, senderBalance, "Sender's balance should be correctly debited")
 	})
+
+	t.Run("transfer to a saved payee after verification", func(t *testing.T) {
+		send := func(method, url, body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, url, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+senderToken)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			testApp.Router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		created := send("POST", "/api/payees", fmt.Sprintf(`{"nickname": "Receiver", "account_number": "%d"}`, receiverAccount.AccountNumber))
+		assert.Equal(t, http.StatusCreated, created.Code)
+		var payee model.Payee
+		assert.NoError(t, json.Unmarshal(created.Body.Bytes(), &payee))
+		assert.Equal(t, model.PayeeStatusUnverified, payee.Status)
+
+		transferURL := fmt.Sprintf("/api/accounts/%d/transfers", senderAccount.ID)
+		transferBody := fmt.Sprintf(`{"payee_id": %d, "amount": "5.00"}`, payee.ID)
+		assert.Equal(t, http.StatusConflict, send("POST", transferURL, transferBody).Code, "Unverified payees cannot receive transfers")
+
+		assert.Equal(t, http.StatusOK, send("POST", fmt.Sprintf("/api/payees/%d/verify", payee.ID), "").Code)
+		assert.Equal(t, http.StatusCreated, send("POST", transferURL, transferBody).Code)
+
+		assert.Equal(t, http.StatusNoContent, send("DELETE", fmt.Sprintf("/api/payees/%d", payee.ID), "").Code)
+	})
+
+	t.Run("retried transfer with idempotency key is replayed", func(t *testing.T) {
+		url := fmt.Sprintf("/api/accounts/%d/transfers", senderAccount.ID)
+		requestBody := fmt.Sprintf(`{"to_account_id": %d, "amount": "10.00"}`, receiverAccount.ID)
+		idempotencyKey := fmt.Sprintf("transfer-%d", time.Now().UnixNano())
+
+		var balanceBefore float64
+		err := testApp.DB.QueryRow("SELECT balance FROM accounts WHERE id = $1", senderAccount.ID).Scan(&balanceBefore)
+		assert.NoError(t, err)
+
+		send := func(body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+senderToken)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+			rr := httptest.NewRecorder()
+			testApp.Router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		first := send(requestBody)
+		assert.Equal(t, http.StatusCreated, first.Code)
+
+		retry := send(requestBody)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.JSONEq(t, first.Body.String(), retry.Body.String(), "Replay should return the stored response")
+
+		var balanceAfter float64
+		err = testApp.DB.QueryRow("SELECT balance FROM accounts WHERE id = $1", senderAccount.ID).Scan(&balanceAfter)
+		assert.NoError(t, err)
+		assert.Equal(t, balanceBefore-10.00, balanceAfter, "Money should only move once")
+
+		mismatch := send(fmt.Sprintf(`{"to_account_id": %d, "amount": "20.00"}`, receiverAccount.ID))
+		assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+
+		idempotencyKey = fmt.Sprintf("transfer-large-%d", time.Now().UnixNano())
+		padded := fmt.Sprintf(`{"to_account_id": %d, "amount": "10.00", "description": "%s"}`, receiverAccount.ID, strings.Repeat("x", 1<<20))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, send(padded).Code, "Bodies over 1 MB are refused, not truncated")
+	})
+}
+
+func TestConcurrentOpposingTransfers_Integration(t *testing.T) {
+	clearRedis(t)
+	alice := createUserForTest(t, "alice_concurrent", "alice.concurrent@test.com", "password123")
+	bob := createUserForTest(t, "bob_concurrent", "bob.concurrent@test.com", "password123")
+	adminUser := createUserWithRoleForTest(t, "admin_concurrent", "admin.concurrent@test.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, alice.Email)
+	defer cleanupUser(t, bob.Email)
+	defer cleanupUser(t, adminUser.Email)
+
+	aliceAccount := createAccountForTest(t, alice.ID, "TRY")
+	bobAccount := createAccountForTest(t, bob.ID, "TRY")
+	adminToken := loginUserForTest(t, adminUser.Email, "password123")
+	for _, accountID := range []int{aliceAccount.ID, bobAccount.ID} {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", accountID), strings.NewReader(`{"amount": "1000.00"}`))
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	tokens := map[int]string{
+		aliceAccount.ID: loginUserForTest(t, alice.Email, "password123"),
+		bobAccount.ID:   loginUserForTest(t, bob.Email, "password123"),
+	}
+
+	// Opposite transfers between the same two accounts used to deadlock when
+	// each transaction locked its own sender first.
+	const transfersPerDirection = 25
+	var wg sync.WaitGroup
+	codes := make(chan int, 2*transfersPerDirection)
+	transfer := func(fromID, toID int) {
+		defer wg.Done()
+		body := fmt.Sprintf(`{"to_account_id": %d, "amount": "1.00"}`, toID)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/accounts/%d/transfers", fromID), strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tokens[fromID])
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		codes <- rr.Code
+	}
+	for i := 0; i < transfersPerDirection; i++ {
+		wg.Add(2)
+		go transfer(aliceAccount.ID, bobAccount.ID)
+		go transfer(bobAccount.ID, aliceAccount.ID)
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		assert.Equal(t, http.StatusCreated, code, "Every concurrent transfer should succeed")
+	}
+
+	var aliceBalance, bobBalance string
+	assert.NoError(t, testApp.DB.QueryRow("SELECT balance FROM accounts WHERE id = $1", aliceAccount.ID).Scan(&aliceBalance))
+	assert.NoError(t, testApp.DB.QueryRow("SELECT balance FROM accounts WHERE id = $1", bobAccount.ID).Scan(&bobBalance))
+	assert.Equal(t, "1000.00", aliceBalance)
+	assert.Equal(t, "1000.00", bobBalance)
+}
+
+func TestCrossCurrencyTransfer_Integration(t *testing.T) {
+	clearRedis(t)
+	sender := createUserForTest(t, "fx_sender", "fx.sender@test.com", "password123")
+	receiver := createUserForTest(t, "fx_receiver", "fx.receiver@test.com", "password123")
+	adminUser := createUserWithRoleForTest(t, "fx_admin", "fx.admin@bank.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, sender.Email)
+	defer cleanupUser(t, receiver.Email)
+	defer cleanupUser(t, adminUser.Email)
+
+	usdAccount := createAccountForTest(t, sender.ID, "USD")
+	tryAccount := createAccountForTest(t, receiver.ID, "TRY")
+
+	send := func(token, method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	adminToken := loginUserForTest(t, adminUser.Email, "password123")
+	deposit := send(adminToken, "POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", usdAccount.ID), `{"amount": "100.00"}`)
+	assert.Equal(t, http.StatusOK, deposit.Code)
+
+	senderToken := loginUserForTest(t, sender.Email, "password123")
+	quoteResp := send(senderToken, "POST", "/api/fx/quotes", `{"source_currency": "USD", "target_currency": "TRY", "amount": "10.00"}`)
+	assert.Equal(t, http.StatusCreated, quoteResp.Code)
+	var quote model.FXQuote
+	assert.NoError(t, json.Unmarshal(quoteResp.Body.Bytes(), &quote))
+	assert.Equal(t, "325.00", quote.DestinationAmount.Decimal())
+
+	transferURL := fmt.Sprintf("/api/accounts/%d/transfers", usdAccount.ID)
+	body := fmt.Sprintf(`{"to_account_number": "%d", "amount": "10.00", "quote_id": "%s"}`, tryAccount.AccountNumber, quote.ID)
+	transfer := send(senderToken, "POST", transferURL, body)
+	assert.Equal(t, http.StatusCreated, transfer.Code)
+
+	var transaction model.Transaction
+	assert.NoError(t, json.Unmarshal(transfer.Body.Bytes(), &transaction))
+	if assert.NotNil(t, transaction.FX) {
+		assert.Equal(t, "325.00", transaction.FX.DestinationAmount.Decimal())
+		assert.Equal(t, quote.Rate, transaction.FX.Rate)
+	}
+
+	var receiverBalance string
+	assert.NoError(t, testApp.DB.QueryRow("SELECT balance FROM accounts WHERE id = $1", tryAccount.ID).Scan(&receiverBalance))
+	assert.Equal(t, "325.00", receiverBalance)
+
+	assert.Equal(t, http.StatusConflict, send(senderToken, "POST", transferURL, body).Code, "A quote can only be used once")
+
+	verify := send(adminToken, "GET", "/api/admin/ledger/verify", "")
+	var report model.LedgerReport
+	assert.NoError(t, json.Unmarshal(verify.Body.Bytes(), &report))
+	assert.True(t, report.Healthy)
 }
 
 func TestAdminRoutes_Integration(t *testing.T) {
@@ -332,6 +689,41 @@ func TestAdminRoutes_Integration(t *testing.T) {
 		testApp.Router.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusForbidden, rr.Code)
 	})
+	t.Run("admin can search users by email prefix", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", endpoint+"?search=ADMIN@&role=admin&limit=1", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var page model.UserPage
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		assert.Equal(t, 1, page.Total)
+		if assert.Len(t, page.Users, 1) {
+			assert.Equal(t, adminUser.ID, page.Users[0].ID)
+		}
+		assert.Empty(t, page.NextCursor)
+	})
+	t.Run("account listing rejects a balance range without a currency", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/admin/accounts?min_balance=10", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+	t.Run("role change revokes the user's access tokens", func(t *testing.T) {
+		req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/admin/users/%d/role", regularUser.ID), strings.NewReader(`{"role": "user"}`))
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		req, _ = http.NewRequest("GET", "/api/accounts", nil)
+		req.Header.Set("Authorization", "Bearer "+userToken)
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
 }
 
 func TestAuthFlows_Integration(t *testing.T) {
@@ -354,24 +746,577 @@ func TestAuthFlows_Integration(t *testing.T) {
 		rr := httptest.NewRecorder()
 		testApp.Router.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusOK, rr.Code)
-		var refreshResponse struct {
-			AccessToken string `json:"access_token"`
-		}
+		var refreshResponse service.TokenPair
 		err := json.Unmarshal(rr.Body.Bytes(), &refreshResponse)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, refreshResponse.AccessToken)
 		assert.NotEqual(t, initialAccessToken, refreshResponse.AccessToken, "New access token should be different")
+		assert.NotEqual(t, loginResponse.RefreshToken, refreshResponse.RefreshToken, "Refresh token should be rotated")
+
+		// Replaying the rotated token revokes its whole family, including the new token.
+		req, _ = http.NewRequest("POST", "/api/token/refresh", strings.NewReader(refreshBody))
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "Reused refresh token should be rejected")
+
+		rotatedBody := fmt.Sprintf(`{"refresh_token": "%s"}`, refreshResponse.RefreshToken)
+		req, _ = http.NewRequest("POST", "/api/token/refresh", strings.NewReader(rotatedBody))
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "Refresh token family should be revoked after reuse")
+
+		req, _ = http.NewRequest("GET", "/api/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+initialAccessToken)
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "Access tokens of the revoked session should be rejected")
 	})
 	t.Run("successful logout", func(t *testing.T) {
-		req, _ := http.NewRequest("POST", "/api/logout", nil)
-		req.Header.Set("Authorization", "Bearer "+initialAccessToken)
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(loginBody))
 		rr := httptest.NewRecorder()
 		testApp.Router.ServeHTTP(rr, req)
+		var pair service.TokenPair
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pair))
+
+		req, _ = http.NewRequest("POST", "/api/logout", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusNoContent, rr.Code)
-		refreshBody := fmt.Sprintf(`{"refresh_token": "%s"}`, loginResponse.RefreshToken)
+		refreshBody := fmt.Sprintf(`{"refresh_token": "%s"}`, pair.RefreshToken)
 		req, _ = http.NewRequest("POST", "/api/token/refresh", strings.NewReader(refreshBody))
 		rr = httptest.NewRecorder()
 		testApp.Router.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusUnauthorized, rr.Code, "Refresh token should be invalid after logout")
+
+		req, _ = http.NewRequest("GET", "/api/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "Access token should be rejected after logout")
+	})
+}
+
+func TestSessions_Integration(t *testing.T) {
+	email := "sessions@test.com"
+	password := "password123"
+	user := createUserForTest(t, "sessions_user", email, password)
+	defer cleanupUser(t, user.Email)
+
+	login := func(device string) service.TokenPair {
+		body := fmt.Sprintf(`{"email": "%s", "password": "%s", "device_name": "%s"}`, email, password, device)
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(body))
+		req.Header.Set("User-Agent", device+"-agent")
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var pair service.TokenPair
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pair))
+		return pair
+	}
+	refresh := func(refreshToken string) int {
+		req, _ := http.NewRequest("POST", "/api/token/refresh", strings.NewReader(fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken)))
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	laptop := login("laptop")
+	phone := login("phone")
+
+	var sessions []model.Session
+	t.Run("both devices stay logged in", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+laptop.AccessToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &sessions))
+		assert.Len(t, sessions, 2)
+		for _, session := range sessions {
+			assert.Equal(t, session.DeviceName == "laptop", session.Current)
+			assert.Equal(t, session.DeviceName+"-agent", session.UserAgent)
+		}
+	})
+
+	t.Run("revoking the phone session leaves the laptop logged in", func(t *testing.T) {
+		var phoneSession model.Session
+		for _, session := range sessions {
+			if session.DeviceName == "phone" {
+				phoneSession = session
+			}
+		}
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/sessions/%d", phoneSession.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+laptop.AccessToken)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, refresh(phone.RefreshToken))
+		assert.Equal(t, http.StatusOK, refresh(laptop.RefreshToken))
+
+		req, _ = http.NewRequest("GET", "/api/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+phone.AccessToken)
+		rr = httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "Access tokens of the revoked session should be rejected")
+	})
+}
+
+func TestEmailVerificationAndPasswordReset_Integration(t *testing.T) {
+	clearRedis(t)
+	email := "verify@test.com"
+	password := "password123"
+	send := func(method, url, body, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(password string) (int, service.TokenPair) {
+		rr := send("POST", "/login", fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password), "")
+		var pair service.TokenPair
+		json.Unmarshal(rr.Body.Bytes(), &pair)
+		return rr.Code, pair
+	}
+
+	rr := send("POST", "/register", fmt.Sprintf(`{"username":"verify_user","email":"%s","password":"%s"}`, email, password), "")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	defer cleanupUser(t, email)
+	var user model.User
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &user))
+	assert.Nil(t, user.EmailVerifiedAt, "New users start unverified")
+	account := createAccountForTest(t, user.ID, "TRY")
+	transferURL := fmt.Sprintf("/api/accounts/%d/transfers", account.ID)
+	transferBody := `{"to_account_number": "99999999999", "amount": "1.00"}`
+
+	_, pair := login(password)
+	t.Run("unverified users cannot transfer", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, send("POST", transferURL, transferBody, pair.AccessToken).Code)
+	})
+
+	t.Run("verifying the email allows transfers after a refresh", func(t *testing.T) {
+		assert.Equal(t, http.StatusAccepted, send("POST", "/api/email/verification", "", pair.AccessToken).Code)
+
+		token := createUserTokenForTest(t, user.ID, model.TokenPurposeEmailVerification)
+		body := fmt.Sprintf(`{"token": "%s"}`, token)
+		assert.Equal(t, http.StatusNoContent, send("POST", "/email/verify", body, "").Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/email/verify", body, "").Code, "Tokens work only once")
+		assert.Equal(t, http.StatusConflict, send("POST", "/api/email/verification", "", pair.AccessToken).Code)
+
+		rr := send("POST", "/api/token/refresh", fmt.Sprintf(`{"refresh_token": "%s"}`, pair.RefreshToken), "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pair))
+		assert.NotEqual(t, http.StatusForbidden, send("POST", transferURL, transferBody, pair.AccessToken).Code)
+	})
+
+	t.Run("password reset", func(t *testing.T) {
+		assert.Equal(t, http.StatusAccepted, send("POST", "/password/forgot", `{"email": "nobody@test.com"}`, "").Code,
+			"Unknown addresses get the same response")
+		assert.Equal(t, http.StatusAccepted, send("POST", "/password/forgot", fmt.Sprintf(`{"email": "%s"}`, email), "").Code)
+
+		token := createUserTokenForTest(t, user.ID, model.TokenPurposePasswordReset)
+		body := fmt.Sprintf(`{"token": "%s", "password": "new-password456"}`, token)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/password/reset", `{"token": "wrong", "password": "new-password456"}`, "").Code)
+		assert.Equal(t, http.StatusNoContent, send("POST", "/password/reset", body, "").Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/password/reset", body, "").Code, "Tokens work only once")
+
+		assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/sessions", "", pair.AccessToken).Code,
+			"Existing sessions end when the password is reset")
+		code, _ := login(password)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, _ = login("new-password456")
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
+
+func TestPasswordPolicyAndChange_Integration(t *testing.T) {
+	clearRedis(t)
+	email := "policy@test.com"
+	send := func(method, url, body, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("registration refuses passwords breaking the policy", func(t *testing.T) {
+		rr := send("POST", "/register", fmt.Sprintf(`{"username":"policy_user","email":"%s","password":"short1"}`, email), "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "must be at least 10 characters long")
+
+		rr = send("POST", "/register", fmt.Sprintf(`{"username":"policy_user","email":"%s","password":"policy_user-2024"}`, email), "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "must not contain your username")
+	})
+
+	user := createUserForTest(t, "policy_user", email, "password123")
+	defer cleanupUser(t, user.Email)
+	current := loginUserForTest(t, email, "password123")
+	other := loginUserForTest(t, email, "password123")
+
+	t.Run("changing the password", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden,
+			send("PUT", "/api/password", `{"current_password": "wrong-password1", "new_password": "changed-password7"}`, current).Code)
+		rr := send("PUT", "/api/password", `{"current_password": "password123", "new_password": "changedpassword"}`, current)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "must contain a digit")
+
+		assert.Equal(t, http.StatusNoContent,
+			send("PUT", "/api/password", `{"current_password": "password123", "new_password": "changed-password7"}`, current).Code)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/sessions", "", current).Code, "The current session stays logged in")
+		assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/sessions", "", other).Code, "Other sessions end")
+		loginUserForTest(t, email, "changed-password7")
+	})
+}
+
+func TestPermissions_Integration(t *testing.T) {
+	clearRedis(t)
+	adminUser := createUserWithRoleForTest(t, "perm_admin", "perm.admin@test.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, adminUser.Email)
+	supportUser := createUserWithRoleForTest(t, "perm_support", "perm.support@test.com", "password123", "support")
+	defer cleanupUser(t, supportUser.Email)
+	checkerUser := createUserWithRoleForTest(t, "perm_checker", "perm.checker@test.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, checkerUser.Email)
+	adminToken := loginUserForTest(t, adminUser.Email, "password123")
+	checkerToken := loginUserForTest(t, checkerUser.Email, "password123")
+	supportToken := loginUserForTest(t, supportUser.Email, "password123")
+	account := createAccountForTest(t, supportUser.ID, "TRY")
+	send := func(method, url, body, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("support staff can read but not move money or change roles", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("GET", "/api/admin/accounts", "", supportToken).Code)
+		assert.Equal(t, http.StatusOK, send("GET", "/api/admin/users", "", supportToken).Code)
+		assert.Equal(t, http.StatusForbidden,
+			send("POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", account.ID), `{"amount": "10.00"}`, supportToken).Code)
+		assert.Equal(t, http.StatusForbidden,
+			send("PATCH", fmt.Sprintf("/api/admin/users/%d/role", supportUser.ID), `{"role": "admin"}`, supportToken).Code)
+	})
+
+	t.Run("permissions list", func(t *testing.T) {
+		rr := send("GET", "/api/admin/permissions", "", adminToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var permissions []model.PermissionInfo
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &permissions))
+		assert.Len(t, permissions, len(model.Permissions))
+	})
+
+	t.Run("role management", func(t *testing.T) {
+		rr := send("POST", "/api/admin/roles", `{"name": "teller", "description": "Branch teller", "permissions": ["accounts:read", "accounts:deposit"]}`, adminToken)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		defer testApp.DB.Exec("DELETE FROM roles WHERE name = 'teller'")
+		assert.Equal(t, http.StatusConflict, send("POST", "/api/admin/roles", `{"name": "teller", "permissions": []}`, adminToken).Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/api/admin/roles", `{"name": "other", "permissions": ["money:print"]}`, adminToken).Code)
+		assert.Equal(t, http.StatusConflict, send("PUT", "/api/admin/roles/admin", `{"permissions": []}`, adminToken).Code)
+
+		// Role changes granting new permissions take a second admin's approval.
+		changeRole := func(role string) {
+			rr := send("PATCH", fmt.Sprintf("/api/admin/users/%d/role", supportUser.ID), fmt.Sprintf(`{"role": "%s"}`, role), adminToken)
+			assert.Equal(t, http.StatusAccepted, rr.Code)
+			var approval model.ApprovalRequest
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &approval))
+			assert.Equal(t, http.StatusOK,
+				send("POST", fmt.Sprintf("/api/admin/approvals/%d/approve", approval.ID), `{}`, checkerToken).Code)
+		}
+		changeRole("teller")
+		tellerToken := loginUserForTest(t, supportUser.Email, "password123")
+		assert.Equal(t, http.StatusOK,
+			send("POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", account.ID), `{"amount": "10.00"}`, tellerToken).Code)
+		assert.Equal(t, http.StatusConflict, send("DELETE", "/api/admin/roles/teller", "", adminToken).Code, "Roles held by users cannot be deleted")
+
+		rr = send("PUT", "/api/admin/roles/teller", `{"description": "Branch teller", "permissions": ["accounts:read"]}`, adminToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusForbidden,
+			send("POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", account.ID), `{"amount": "10.00"}`, tellerToken).Code,
+			"Permission changes apply to existing tokens at once")
+
+		changeRole("support")
+		assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/admin/roles/teller", "", adminToken).Code)
+	})
+}
+
+func TestLoginLockout_Integration(t *testing.T) {
+	clearRedis(t)
+	email := "lockout@test.com"
+	user := createUserForTest(t, "lockout_user", email, "password123")
+	defer cleanupUser(t, user.Email)
+	adminUser := createUserWithRoleForTest(t, "lockout_admin", "lockout.admin@test.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, adminUser.Email)
+	adminToken := loginUserForTest(t, adminUser.Email, "password123")
+
+	login := func(password string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password)))
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	policy := config.AppConfig.Login
+	for i := 1; i <= policy.DelayAfter+1; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("wrong-password").Code, "Attempt %d", i)
+	}
+	rr := login("password123")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "Attempts after repeated failures have to wait")
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	var failures int
+	err := testApp.DB.QueryRow(`SELECT COUNT(*) FROM login_events WHERE user_id = $1 AND event = $2`, user.ID, model.LoginEventFailed).Scan(&failures)
+	assert.NoError(t, err)
+	assert.Equal(t, policy.DelayAfter+1, failures, "Failed logins are recorded in the audit trail")
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/admin/users/%d/unlock", user.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	rr = httptest.NewRecorder()
+	testApp.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	assert.Equal(t, http.StatusOK, login("password123").Code, "Unlocked accounts can log in at once")
+}
+
+func TestApprovals_Integration(t *testing.T) {
+	clearRedis(t)
+	maker := createUserWithRoleForTest(t, "approval_maker", "approval.maker@test.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, maker.Email)
+	checker := createUserWithRoleForTest(t, "approval_checker", "approval.checker@test.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, checker.Email)
+	customer := createUserForTest(t, "approval_customer", "approval.customer@test.com", "password123")
+	defer cleanupUser(t, customer.Email)
+	makerToken := loginUserForTest(t, maker.Email, "password123")
+	checkerToken := loginUserForTest(t, checker.Email, "password123")
+	account := createAccountForTest(t, customer.ID, "TRY")
+	send := func(method, url, body, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	requestDeposit := func(amount string) model.ApprovalRequest {
+		rr := send("POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", account.ID), fmt.Sprintf(`{"amount": "%s"}`, amount), makerToken)
+		assert.Equal(t, http.StatusAccepted, rr.Code, "Deposits above the threshold await approval")
+		var approval model.ApprovalRequest
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &approval))
+		assert.Equal(t, model.ApprovalStatusPending, approval.Status)
+		return approval
+	}
+	balance := func() string {
+		var balance string
+		assert.NoError(t, testApp.DB.QueryRow(`SELECT balance FROM accounts WHERE id = $1`, account.ID).Scan(&balance))
+		return balance
+	}
+
+	t.Run("large deposits wait for a second admin", func(t *testing.T) {
+		approval := requestDeposit("75000.00")
+		assert.Equal(t, "0.00", balance())
+
+		assert.Equal(t, http.StatusForbidden,
+			send("POST", fmt.Sprintf("/api/admin/approvals/%d/approve", approval.ID), `{}`, makerToken).Code, "Makers cannot approve their own requests")
+
+		rr := send("POST", fmt.Sprintf("/api/admin/approvals/%d/approve", approval.ID), `{"comment": "Checked the slip"}`, checkerToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var decided model.ApprovalRequest
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &decided))
+		assert.Equal(t, model.ApprovalStatusExecuted, decided.Status)
+		if assert.NotNil(t, decided.CheckerID) {
+			assert.Equal(t, checker.ID, *decided.CheckerID)
+		}
+		assert.Equal(t, "75000.00", balance())
+
+		assert.Equal(t, http.StatusConflict,
+			send("POST", fmt.Sprintf("/api/admin/approvals/%d/approve", approval.ID), `{}`, checkerToken).Code, "Requests are carried out once")
+	})
+
+	t.Run("rejected and expired requests are never carried out", func(t *testing.T) {
+		rejected := requestDeposit("60000.00")
+		assert.Equal(t, http.StatusBadRequest,
+			send("POST", fmt.Sprintf("/api/admin/approvals/%d/reject", rejected.ID), `{}`, checkerToken).Code, "Rejections need a reason")
+		assert.Equal(t, http.StatusOK,
+			send("POST", fmt.Sprintf("/api/admin/approvals/%d/reject", rejected.ID), `{"comment": "No documents"}`, checkerToken).Code)
+
+		expired := requestDeposit("60000.00")
+		_, err := testApp.DB.Exec(`UPDATE approval_requests SET expires_at = CURRENT_TIMESTAMP - INTERVAL '1 minute' WHERE id = $1`, expired.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict,
+			send("POST", fmt.Sprintf("/api/admin/approvals/%d/approve", expired.ID), `{}`, checkerToken).Code)
+
+		assert.Equal(t, "75000.00", balance())
+	})
+
+	t.Run("history", func(t *testing.T) {
+		rr := send("GET", fmt.Sprintf("/api/admin/approvals?maker_id=%d&action=deposit", maker.ID), "", checkerToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var page model.ApprovalPage
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		assert.Equal(t, 3, page.Total)
+		if assert.Len(t, page.Requests, 3) {
+			assert.Equal(t, model.ApprovalStatusExpired, page.Requests[0].Status)
+			assert.Equal(t, model.ApprovalStatusRejected, page.Requests[1].Status)
+			assert.Equal(t, "No documents", page.Requests[1].Comment)
+			assert.Equal(t, model.ApprovalStatusExecuted, page.Requests[2].Status)
+		}
+
+		rr = send("GET", "/api/admin/approvals?status=done", "", checkerToken)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("small deposits and demotions take effect at once", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK,
+			send("POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", account.ID), `{"amount": "100.00"}`, makerToken).Code)
+		assert.Equal(t, http.StatusOK,
+			send("PATCH", fmt.Sprintf("/api/admin/users/%d/role", customer.ID), `{"role": "user"}`, makerToken).Code)
+	})
+}
+
+func TestAuditLog_Integration(t *testing.T) {
+	clearRedis(t)
+	admin := createUserWithRoleForTest(t, "audit_admin", "audit.admin@test.com", "password123", model.RoleAdmin)
+	defer cleanupUser(t, admin.Email)
+	customer := createUserForTest(t, "audit_customer", "audit.customer@test.com", "password123")
+	defer cleanupUser(t, customer.Email)
+	adminToken := loginUserForTest(t, admin.Email, "password123")
+	customerToken := loginUserForTest(t, customer.Email, "password123")
+	account := createAccountForTest(t, customer.ID, "TRY")
+	send := func(method, url, body, token, requestID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if requestID != "" {
+			req.Header.Set(handler.RequestIDHeader, requestID)
+		}
+		rr := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	listEvents := func(query string) model.AuditPage {
+		rr := send("GET", "/api/admin/audit?"+query, "", adminToken, "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var page model.AuditPage
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		return page
+	}
+
+	t.Run("deposits are recorded with actor, request and balances", func(t *testing.T) {
+		rr := send("POST", fmt.Sprintf("/api/admin/accounts/%d/deposit", account.ID), `{"amount": "100.00"}`, adminToken, "audit-test-deposit")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "audit-test-deposit", rr.Header().Get(handler.RequestIDHeader))
+
+		page := listEvents("request_id=audit-test-deposit")
+		if assert.Len(t, page.Events, 1) {
+			event := page.Events[0]
+			assert.Equal(t, model.AuditEventDeposit, event.Event)
+			assert.Equal(t, model.AuditTargetAccount, event.TargetType)
+			assert.Equal(t, fmt.Sprint(account.ID), event.TargetID)
+			if assert.NotNil(t, event.ActorID) {
+				assert.Equal(t, admin.ID, *event.ActorID)
+			}
+			assert.Contains(t, string(event.After), `"100.00"`)
+		}
+	})
+
+	t.Run("logins and account creation are recorded", func(t *testing.T) {
+		page := listEvents(fmt.Sprintf("event=login&actor_id=%d", customer.ID))
+		assert.Equal(t, 1, page.Total)
+		page = listEvents(fmt.Sprintf("event=account_created&target_type=account&target_id=%d", account.ID))
+		assert.Equal(t, 1, page.Total)
+	})
+
+	t.Run("the log is append-only and its chain verifies", func(t *testing.T) {
+		_, err := testApp.DB.Exec(`UPDATE audit_events SET ip_address = '0.0.0.0'`)
+		assert.Error(t, err)
+		_, err = testApp.DB.Exec(`DELETE FROM audit_events`)
+		assert.Error(t, err)
+
+		rr := send("GET", "/api/admin/audit/verify", "", adminToken, "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var report model.AuditReport
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		assert.True(t, report.Healthy, report.Problem)
+	})
+
+	t.Run("customers cannot read the log", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, send("GET", "/api/admin/audit", "", customerToken, "").Code)
+	})
+}
+
+func TestRateLimit_Integration(t *testing.T) {
+	clearRedis(t)
+	saved := config.AppConfig.RateLimit
+	defer func() { config.AppConfig.RateLimit = saved }()
+	config.AppConfig.RateLimit.Enabled = true
+	limitedApp := app.NewTestApp(testApp.DB, testRedisClient)
+	// Each attempt uses another email address, so the login guard's delays
+	// after failed logins do not interfere.
+	attempts := 0
+	login := func(ip string) *httptest.ResponseRecorder {
+		attempts++
+		body := fmt.Sprintf(`{"email": "nobody%d@test.com", "password": "password123"}`, attempts)
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":4321"
+		rr := httptest.NewRecorder()
+		limitedApp.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	limit := config.AppConfig.RateLimit.Rules["login"].Limit
+
+	for i := 0; i < limit; i++ {
+		rr := login("198.51.100.7")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, strconv.Itoa(limit), rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(limit-i-1), rr.Header().Get("RateLimit-Remaining"))
+	}
+
+	rr := login("198.51.100.7")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusUnauthorized, login("198.51.100.8").Code, "Other IP addresses are counted separately")
+
+	t.Run("by API key", func(t *testing.T) {
+		clearRedis(t)
+		sum := sha256.Sum256([]byte("partner-key"))
+		config.AppConfig.RateLimit.APIKeys = map[string]string{"partner": hex.EncodeToString(sum[:])}
+		config.AppConfig.RateLimit.Rules = maps.Clone(saved.Rules)
+		rule := config.AppConfig.RateLimit.Rules["login"]
+		rule.Key = service.RateLimitKeyAPIKey
+		config.AppConfig.RateLimit.Rules["login"] = rule
+		keyedApp := app.NewTestApp(testApp.DB, testRedisClient)
+		loginWithKey := func(ip, apiKey string) int {
+			attempts++
+			body := fmt.Sprintf(`{"email": "nobody%d@test.com", "password": "password123"}`, attempts)
+			req, _ := http.NewRequest("POST", "/login", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(handler.APIKeyHeader, apiKey)
+			req.RemoteAddr = ip + ":4321"
+			rr := httptest.NewRecorder()
+			keyedApp.Router.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		for i := 0; i < rule.Limit; i++ {
+			assert.Equal(t, http.StatusUnauthorized, loginWithKey(fmt.Sprintf("198.51.100.%d", 10+i), "partner-key"))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, loginWithKey("198.51.100.99", "partner-key"), "A known key is counted across IP addresses")
+
+		for i := 0; i < rule.Limit; i++ {
+			loginWithKey("198.51.100.200", fmt.Sprintf("made-up-key-%d", i))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, loginWithKey("198.51.100.200", "another-made-up-key"), "Unknown keys are counted by IP address")
 	})
 }
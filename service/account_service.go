@@ -8,33 +8,76 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-bank-api/config"
 	"go-bank-api/model"
 	"go-bank-api/repository"
+	"strings"
 	"time"
 )
 
+// ErrInvalidDepositAmount is returned when a deposit amount is zero or negative.
+var (
+	ErrInvalidDepositAmount = errors.New("deposit amount must be positive")
+	ErrInvalidAccountKind   = errors.New("invalid account kind")
+	ErrBalanceNeedsCurrency = errors.New("a balance filter requires a currency filter")
+)
+
+// IDepositLedger is the part of the ledger that AccountService depends on.
+// LedgerService implements it; tests can substitute a mock.
+type IDepositLedger interface {
+	Deposit(ctx context.Context, accountID int, amount model.Amount) (*model.Account, error)
+	DepositTx(ctx context.Context, tx *sql.Tx, accountID int, amount model.Amount) (*model.Account, error)
+}
+
 // AccountService depends on the ICacheClient interface, not a concrete Redis client.
 type AccountService struct {
 	repo        repository.IAccountRepository
 	cacheClient ICacheClient // DEPENDENCY INVERSION
+	ledger      IDepositLedger
+	audit       IAuditRecorder
 }
 
 // NewAccountService is updated to accept the ICacheClient interface.
-func NewAccountService(repo repository.IAccountRepository, cacheClient ICacheClient) *AccountService {
+func NewAccountService(repo repository.IAccountRepository, cacheClient ICacheClient, ledger IDepositLedger, audit IAuditRecorder) *AccountService {
 	return &AccountService{
 		repo:        repo,
 		cacheClient: cacheClient,
+		ledger:      ledger,
+		audit:       audit,
+	}
+}
+
+// setIBAN fills in the IBAN of each account whose currency has IBANs
+// configured. System accounts, whose numbers are not valid customer account
+// numbers, never get one.
+func setIBAN(accounts ...*model.Account) {
+	cfg := config.AppConfig.IBAN
+	if cfg.BankCode == "" {
+		return
+	}
+	for _, account := range accounts {
+		country, ok := cfg.Countries[strings.ToLower(account.Currency)]
+		if !ok {
+			continue
+		}
+		if iban, err := model.FormatIBAN(country, cfg.BankCode, account.AccountNumber); err == nil {
+			account.IBAN = iban
+		}
 	}
 }
 
-// CreateNewAccount creates a new account and invalidates the user's account cache.
-func (s *AccountService) CreateNewAccount(userID int, currency string) (*model.Account, error) {
-	lastAccountNumber, err := s.repo.GetLastAccountNumber()
+// CreateNewAccount creates a new account, records it in the audit log and
+// invalidates the user's account cache.
+func (s *AccountService) CreateNewAccount(ctx context.Context, userID int, currency string) (*model.Account, error) {
+	base, err := s.repo.NextAccountNumberBase(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	newAccountNumber := lastAccountNumber + 1
+	newAccountNumber, err := model.NewAccountNumber(base)
+	if err != nil {
+		return nil, err
+	}
 
 	account := &model.Account{
 		UserID:        userID,
@@ -42,21 +85,27 @@ func (s *AccountService) CreateNewAccount(userID int, currency string) (*model.A
 		Currency:      currency,
 	}
 
-	if err = s.repo.CreateAccount(account); err != nil {
+	if err = s.repo.CreateAccount(ctx, account); err != nil {
 		return nil, err
 	}
-
-	// Invalidate the cache to ensure data consistency on the next read.
+	setIBAN(account)
+	s.audit.Record(ctx, newAuditEvent(model.AuditEventAccountCreated, model.AuditTargetAccount, account.ID, nil, map[string]interface{}{
+		"user_id":        account.UserID,
+		"account_number": account.AccountNumber,
+		"currency":       account.Currency,
+	}))
+
+	// Invalidate the cache to ensure data consistency on the next read. The
+	// account exists now, so this must happen even if the client has gone away.
 	cacheKey := fmt.Sprintf("accounts:%d", userID)
-	s.cacheClient.Del(context.Background(), cacheKey)
+	s.cacheClient.Del(context.WithoutCancel(ctx), cacheKey)
 
 	return account, nil
 }
 
 // ListAccountsForUser lists accounts for a specific user, utilizing a cache-aside strategy.
-func (s *AccountService) ListAccountsForUser(userID int) ([]*model.Account, error) {
+func (s *AccountService) ListAccountsForUser(ctx context.Context, userID int) ([]*model.Account, error) {
 	cacheKey := fmt.Sprintf("accounts:%d", userID)
-	ctx := context.Background()
 
 	// 1. Attempt to fetch from the cache first (fast path).
 	cachedAccounts, err := s.cacheClient.Get(ctx, cacheKey).Result()
@@ -68,10 +117,11 @@ func (s *AccountService) ListAccountsForUser(userID int) ([]*model.Account, erro
 	}
 
 	// 2. Cache miss. Fetch from the source of truth (database).
-	accounts, err := s.repo.GetAccountsByUserID(userID)
+	accounts, err := s.repo.GetAccountsByUserID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	setIBAN(accounts...)
 
 	// 3. Populate the cache for subsequent requests.
 	data, err := json.Marshal(accounts)
@@ -82,32 +132,80 @@ func (s *AccountService) ListAccountsForUser(userID int) ([]*model.Account, erro
 	return accounts, nil
 }
 
-func (s *AccountService) GetAllAccounts() ([]*model.Account, error) {
-	return s.repo.GetAllAccounts()
-}
+// ListAccounts returns one page of the accounts matching the filter, for the
+// admin listing.
+func (s *AccountService) ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+	if filter.Currency != "" {
+		if _, err := model.CurrencyExponent(filter.Currency); err != nil {
+			return nil, err
+		}
+	}
+	switch filter.Kind {
+	case "", model.AccountKindCustomer, model.AccountKindSettlement, model.AccountKindFeeIncome, model.AccountKindFXPosition:
+	default:
+		return nil, ErrInvalidAccountKind
+	}
+	for _, bound := range []*model.Money{filter.MinBalance, filter.MaxBalance} {
+		if bound != nil && bound.Currency != filter.Currency {
+			return nil, ErrBalanceNeedsCurrency
+		}
+	}
+	if filter.MinBalance != nil && filter.MaxBalance != nil && filter.MinBalance.MinorUnits > filter.MaxBalance.MinorUnits {
+		return nil, ErrInvalidAmountRange
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrInvalidDateRange
+	}
+	filter.Limit = pageLimit(filter.Limit)
 
-// DepositToAccount handles the business logic for depositing funds into a specific account.
-// It ensures the operation is valid and, upon success, invalidates the relevant user's cache.
-func (s *AccountService) DepositToAccount(accountID int, amount float64) (*model.Account, error) {
-	if amount <= 0 {
-		return nil, errors.New("deposit amount must be positive")
+	accounts, total, err := s.repo.ListAccounts(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	// 1. Perform the database operation. The repository returns the updated account,
-	// which critically includes the UserID needed for cache invalidation.
-	updatedAccount, err := s.repo.DepositToAccount(accountID, amount)
+	page := &model.AccountPage{Total: total}
+	page.Accounts, page.NextCursor = paginate(accounts, filter.Limit, func(a *model.Account) model.Cursor {
+		return model.Cursor{CreatedAt: a.CreatedAt, ID: a.ID}
+	})
+	setIBAN(page.Accounts...)
+	return page, nil
+}
+
+// DepositToAccount handles the business logic for depositing funds into a specific account.
+// The deposit is posted to the ledger from the settlement account of the account's
+// currency, so it appears in the account's transaction history. Upon success, the
+// owner's cache is invalidated.
+func (s *AccountService) DepositToAccount(ctx context.Context, accountID int, amount model.Amount) (*model.Account, error) {
+	// 1. Post the deposit. The ledger returns the updated account, which critically
+	// includes the UserID needed for cache invalidation.
+	updatedAccount, err := s.ledger.Deposit(ctx, accountID, amount)
 	if err != nil {
-		// Translate potential DB errors into service-level errors.
-		if err == sql.ErrNoRows {
-			return nil, errors.New("account not found")
-		}
 		return nil, err
 	}
+	setIBAN(updatedAccount)
 
 	// 2. If the DB write is successful, invalidate the cache for the account's owner.
 	// This removes the technical debt and ensures data consistency.
-	cacheKey := fmt.Sprintf("accounts:%d", updatedAccount.UserID)
-	s.cacheClient.Del(context.Background(), cacheKey)
+	s.InvalidateAccountCache(ctx, updatedAccount.UserID)
 
 	return updatedAccount, nil
 }
+
+// DepositToAccountTx is DepositToAccount within tx, for deposits committed
+// together with other changes; see LedgerService.DepositTx. The caller
+// invalidates the owner's cache with InvalidateAccountCache once tx commits.
+func (s *AccountService) DepositToAccountTx(ctx context.Context, tx *sql.Tx, accountID int, amount model.Amount) (*model.Account, error) {
+	updatedAccount, err := s.ledger.DepositTx(ctx, tx, accountID, amount)
+	if err != nil {
+		return nil, err
+	}
+	setIBAN(updatedAccount)
+	return updatedAccount, nil
+}
+
+// InvalidateAccountCache drops the cached account list of the user, after
+// their balances changed. The change has been made, so this happens even if
+// the client has gone away.
+func (s *AccountService) InvalidateAccountCache(ctx context.Context, userID int) {
+	s.cacheClient.Del(context.WithoutCancel(ctx), fmt.Sprintf("accounts:%d", userID))
+}
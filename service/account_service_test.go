@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-bank-api/config"
 	"go-bank-api/model"
 	"testing"
 	"time"
@@ -19,30 +20,63 @@ import (
 // mockAccountRepo provides a mock for IAccountRepository.
 type mockAccountRepo struct{ mock.Mock }
 
-func (m *mockAccountRepo) CreateAccount(a *model.Account) error { return m.Called(a).Error(0) }
-func (m *mockAccountRepo) GetLastAccountNumber() (int64, error) {
+func (m *mockAccountRepo) CreateAccount(_ context.Context, a *model.Account) error {
+	return m.Called(a).Error(0)
+}
+func (m *mockAccountRepo) NextAccountNumberBase(context.Context) (int64, error) {
 	args := m.Called()
 	return args.Get(0).(int64), args.Error(1)
 }
-func (m *mockAccountRepo) DepositToAccount(id int, a float64) (*model.Account, error) {
-	args := m.Called(id, a)
+func (m *mockAccountRepo) GetAccountsByUserID(_ context.Context, id int) ([]*model.Account, error) {
+	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*model.Account), args.Error(1)
+	return args.Get(0).([]*model.Account), args.Error(1)
+}
+func (m *mockAccountRepo) ListAccounts(_ context.Context, filter model.AccountFilter) ([]*model.Account, int, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*model.Account), args.Int(1), args.Error(2)
+}
+func (m *mockAccountRepo) GetAccountForUpdate(context.Context, *sql.Tx, int) (*model.Account, error) {
+	return nil, nil
+}
+func (m *mockAccountRepo) GetAccountsForUpdate(context.Context, *sql.Tx, ...int) (map[int]*model.Account, error) {
+	return nil, nil
+}
+func (m *mockAccountRepo) GetSystemAccountForUpdate(context.Context, *sql.Tx, string, string) (*model.Account, error) {
+	return nil, nil
 }
-func (m *mockAccountRepo) GetAccountsByUserID(id int) ([]*model.Account, error) {
+func (m *mockAccountRepo) GetAccountByNumber(context.Context, int64) (*model.Account, error) {
+	return nil, nil
+}
+func (m *mockAccountRepo) GetHolderName(context.Context, int) (string, error) { return "", nil }
+func (m *mockAccountRepo) GetSystemAccountsForUpdate(context.Context, *sql.Tx, string, ...string) (map[string]*model.Account, error) {
+	return nil, nil
+}
+func (m *mockAccountRepo) GetAccountByID(_ context.Context, id int) (*model.Account, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*model.Account), args.Error(1)
+	return args.Get(0).(*model.Account), args.Error(1)
 }
-func (m *mockAccountRepo) GetAllAccounts() ([]*model.Account, error)                { return nil, nil }
-func (m *mockAccountRepo) GetAccountForUpdate(*sql.Tx, int) (*model.Account, error) { return nil, nil }
-func (m *mockAccountRepo) UpdateAccountBalance(*sql.Tx, int, float64) error         { return nil }
-func (m *mockAccountRepo) GetAccountByID(id int) (*model.Account, error) {
-	args := m.Called(id)
+
+// mockDepositLedger provides a mock for IDepositLedger.
+type mockDepositLedger struct{ mock.Mock }
+
+func (m *mockDepositLedger) Deposit(ctx context.Context, accountID int, amount model.Amount) (*model.Account, error) {
+	args := m.Called(ctx, accountID, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+func (m *mockDepositLedger) DepositTx(ctx context.Context, tx *sql.Tx, accountID int, amount model.Amount) (*model.Account, error) {
+	args := m.Called(ctx, tx, accountID, amount)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -73,17 +107,26 @@ func (m *mockCacheClient) Del(ctx context.Context, keys ...string) *redis.IntCmd
 func TestAccountService_CreateNewAccount(t *testing.T) {
 	mockRepo := new(mockAccountRepo)
 	mockCache := new(mockCacheClient)
-	accountService := NewAccountService(mockRepo, mockCache) // Inject the mock directly.
+	accountService := NewAccountService(mockRepo, mockCache, nil, new(auditSink)) // Inject the mock directly.
 
 	userID := 1
 	cacheKey := fmt.Sprintf("accounts:%d", userID)
-	mockRepo.On("GetLastAccountNumber").Return(int64(1000000025), nil).Once()
+	mockRepo.On("NextAccountNumberBase").Return(int64(1000000025), nil).Once()
 	mockRepo.On("CreateAccount", mock.Anything).Return(nil).Once()
 	mockCache.On("Del", mock.Anything, cacheKey).Return().Once()
 
-	_, err := accountService.CreateNewAccount(userID, "TRY")
+	config.AppConfig.IBAN.BankCode = "00061"
+	config.AppConfig.IBAN.Countries = map[string]string{"try": "TR"}
+	defer func() { config.AppConfig.IBAN.BankCode = "" }()
+
+	account, err := accountService.CreateNewAccount(context.Background(), userID, "TRY")
 
 	assert.NoError(t, err)
+	assert.Equal(t, int64(10000000256), account.AccountNumber, "The allocated base gets a Luhn check digit")
+	assert.NoError(t, model.ValidateAccountNumber(account.AccountNumber))
+	number, err := model.AccountNumberFromIBAN(account.IBAN, "00061")
+	assert.NoError(t, err)
+	assert.Equal(t, account.AccountNumber, number)
 	mockRepo.AssertExpectations(t)
 	mockCache.AssertExpectations(t)
 }
@@ -91,7 +134,7 @@ func TestAccountService_CreateNewAccount(t *testing.T) {
 func TestAccountService_ListAccountsForUser_CacheHit(t *testing.T) {
 	mockRepo := new(mockAccountRepo)
 	mockCache := new(mockCacheClient)
-	accountService := NewAccountService(mockRepo, mockCache)
+	accountService := NewAccountService(mockRepo, mockCache, nil, new(auditSink))
 
 	userID := 2
 	cacheKey := fmt.Sprintf("accounts:%d", userID)
@@ -100,7 +143,7 @@ func TestAccountService_ListAccountsForUser_CacheHit(t *testing.T) {
 
 	mockCache.On("Get", mock.Anything, cacheKey).Return(string(cachedData), nil).Once()
 
-	accounts, err := accountService.ListAccountsForUser(userID)
+	accounts, err := accountService.ListAccountsForUser(context.Background(), userID)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedAccounts, accounts)
@@ -111,7 +154,7 @@ func TestAccountService_ListAccountsForUser_CacheHit(t *testing.T) {
 func TestAccountService_ListAccountsForUser_CacheMiss(t *testing.T) {
 	mockRepo := new(mockAccountRepo)
 	mockCache := new(mockCacheClient)
-	accountService := NewAccountService(mockRepo, mockCache)
+	accountService := NewAccountService(mockRepo, mockCache, nil, new(auditSink))
 
 	userID := 3
 	cacheKey := fmt.Sprintf("accounts:%d", userID)
@@ -122,7 +165,7 @@ func TestAccountService_ListAccountsForUser_CacheMiss(t *testing.T) {
 	mockRepo.On("GetAccountsByUserID", userID).Return(dbAccounts, nil).Once()
 	mockCache.On("Set", mock.Anything, cacheKey, dbData, 10*time.Minute).Return().Once()
 
-	accounts, err := accountService.ListAccountsForUser(userID)
+	accounts, err := accountService.ListAccountsForUser(context.Background(), userID)
 
 	assert.NoError(t, err)
 	assert.Equal(t, dbAccounts, accounts)
@@ -133,50 +176,82 @@ func TestAccountService_ListAccountsForUser_CacheMiss(t *testing.T) {
 func TestAccountService_DepositToAccount(t *testing.T) {
 	mockRepo := new(mockAccountRepo)
 	mockCache := new(mockCacheClient)
-	accountService := NewAccountService(mockRepo, mockCache)
+	mockLedger := new(mockDepositLedger)
+	accountService := NewAccountService(mockRepo, mockCache, mockLedger, new(auditSink))
 
 	t.Run("success", func(t *testing.T) {
 		accountID := 1
 		userID := 5
-		amount := 100.0
 
-		// SETUP: When the repo is called, it returns an account with a UserID.
-		mockRepo.On("DepositToAccount", accountID, amount).Return(&model.Account{ID: accountID, UserID: userID}, nil).Once()
+		// SETUP: When the ledger posts the deposit, it returns an account with a UserID.
+		mockLedger.On("Deposit", mock.Anything, accountID, model.Amount("100")).Return(&model.Account{ID: accountID, UserID: userID}, nil).Once()
 
 		// EXPECTATION: The service MUST call Del on the cache with the correct key for the user.
 		cacheKey := fmt.Sprintf("accounts:%d", userID)
 		mockCache.On("Del", mock.Anything, cacheKey).Return().Once()
 
 		// EXECUTION
-		_, err := accountService.DepositToAccount(accountID, amount)
+		_, err := accountService.DepositToAccount(context.Background(), accountID, "100")
 
 		// ASSERTIONS
 		assert.NoError(t, err)
-		mockRepo.AssertExpectations(t)
+		mockLedger.AssertExpectations(t)
 		mockCache.AssertExpectations(t) // Also assert cache expectations.
 	})
 
-	t.Run("negative amount", func(t *testing.T) {
-		_, err := accountService.DepositToAccount(2, -50.0)
+	t.Run("ledger error", func(t *testing.T) {
+		accountID := 3
+		expectedError := errors.New("db error")
+
+		mockLedger.On("Deposit", mock.Anything, accountID, model.Amount("200.00")).Return(nil, expectedError).Once()
+
+		_, err := accountService.DepositToAccount(context.Background(), accountID, "200.00")
 
 		assert.Error(t, err)
-		assert.Equal(t, "deposit amount must be positive", err.Error())
-		mockRepo.AssertNotCalled(t, "DepositToAccount")
+		assert.Equal(t, expectedError, err)
+		mockLedger.AssertExpectations(t)
 		mockCache.AssertNotCalled(t, "Del")
 	})
+}
 
-	t.Run("repository error", func(t *testing.T) {
-		accountID := 3
-		amount := 200.0
-		expectedError := errors.New("db error")
+func TestAccountService_ListAccounts(t *testing.T) {
+	mockRepo := new(mockAccountRepo)
+	accountService := NewAccountService(mockRepo, nil, nil, new(auditSink))
+	ctx := context.Background()
 
-		mockRepo.On("DepositToAccount", accountID, amount).Return(nil, expectedError).Once()
+	t.Run("balance range in the filtered currency", func(t *testing.T) {
+		min, max := model.NewMoney(1000, "USD"), model.NewMoney(500000, "USD")
+		filter := model.AccountFilter{Currency: "USD", MinBalance: &min, MaxBalance: &max, Limit: model.MaxPageSize}
+		accounts := []*model.Account{{ID: 4, Currency: "USD", Kind: model.AccountKindCustomer, CreatedAt: time.Now()}}
+		mockRepo.On("ListAccounts", filter).Return(accounts, 1, nil).Once()
 
-		_, err := accountService.DepositToAccount(accountID, amount)
+		filter.Limit = 1000
+		page, err := accountService.ListAccounts(ctx, filter)
 
-		assert.Error(t, err)
-		assert.Equal(t, expectedError, err)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Len(t, page.Accounts, 1)
+		assert.Empty(t, page.NextCursor)
 		mockRepo.AssertExpectations(t)
-		mockCache.AssertNotCalled(t, "Del")
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		usd := model.NewMoney(1000, "USD")
+		tests := []struct {
+			name    string
+			filter  model.AccountFilter
+			wantErr error
+		}{
+			{"unknown currency", model.AccountFilter{Currency: "XYZ"}, model.ErrUnsupportedCurrency},
+			{"unknown kind", model.AccountFilter{Kind: "savings"}, ErrInvalidAccountKind},
+			{"balance without currency", model.AccountFilter{MinBalance: &usd}, ErrBalanceNeedsCurrency},
+			{"balance in another currency", model.AccountFilter{Currency: "EUR", MaxBalance: &usd}, ErrBalanceNeedsCurrency},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := accountService.ListAccounts(ctx, tt.filter)
+				assert.Equal(t, tt.wantErr, err)
+			})
+		}
 	})
 }
@@ -0,0 +1,391 @@
+// file: service/approval_service.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrApprovalNotFound         = errors.New("approval request not found")
+	ErrApprovalNotPending       = errors.New("approval request has already been decided")
+	ErrApprovalExpired          = errors.New("approval request has expired")
+	ErrSelfApproval             = errors.New("you cannot decide on your own request")
+	ErrApprovalForbidden        = errors.New("you are not allowed to carry out this request yourself")
+	ErrInvalidApprovalStatus    = errors.New("status must be pending, rejected, expired, executed or failed")
+	ErrInvalidApprovalAction    = errors.New("action must be deposit or role_change")
+	ErrInvalidApprovalTTL       = errors.New("approval requests must expire after a positive duration")
+	ErrInvalidApprovalThreshold = errors.New("invalid deposit approval threshold")
+)
+
+// approvalStatuses lists the statuses approval requests can be filtered by.
+var approvalStatuses = []string{
+	model.ApprovalStatusPending, model.ApprovalStatusRejected, model.ApprovalStatusExpired,
+	model.ApprovalStatusExecuted, model.ApprovalStatusFailed,
+}
+
+// approvalPermissions maps each action to the permission needed to carry it
+// out, which its checker must hold as well.
+var approvalPermissions = map[string]model.Permission{
+	model.ApprovalActionDeposit:    model.PermissionAccountsDeposit,
+	model.ApprovalActionRoleChange: model.PermissionUsersRoleWrite,
+}
+
+// IDepositor is the part of AccountService that ApprovalService depends on to
+// carry out deposits.
+type IDepositor interface {
+	DepositToAccount(ctx context.Context, accountID int, amount model.Amount) (*model.Account, error)
+	DepositToAccountTx(ctx context.Context, tx *sql.Tx, accountID int, amount model.Amount) (*model.Account, error)
+	InvalidateAccountCache(ctx context.Context, userID int)
+}
+
+// IRoleChanger is the part of UserService that ApprovalService depends on to
+// carry out role changes.
+type IRoleChanger interface {
+	CheckRoleChange(ctx context.Context, actorRole string, userID int, newRole model.Role) (bool, error)
+	UpdateUserRole(ctx context.Context, actorRole string, userID int, newRole model.Role) error
+	UpdateUserRoleTx(ctx context.Context, tx *sql.Tx, actorRole string, userID int, newRole model.Role) error
+	RevokeUserTokens(ctx context.Context, userID int) error
+}
+
+// ApprovalPolicy decides which admin actions need a second admin's approval.
+// Deposits above the threshold for their currency need approval; deposits in
+// a currency without a threshold always do. With RoleChanges set, role changes
+// that grant the user new permissions need approval. Requests expire after TTL.
+type ApprovalPolicy struct {
+	DepositThresholds map[string]model.Money
+	RoleChanges       bool
+	TTL               time.Duration
+}
+
+// NewConfiguredApprovalPolicy builds the approval policy from the
+// application configuration.
+func NewConfiguredApprovalPolicy() (ApprovalPolicy, error) {
+	cfg := config.AppConfig.Approvals
+	if cfg.TTL <= 0 {
+		return ApprovalPolicy{}, ErrInvalidApprovalTTL
+	}
+	policy := ApprovalPolicy{
+		DepositThresholds: make(map[string]model.Money, len(cfg.DepositThresholds)),
+		RoleChanges:       cfg.RoleChanges,
+		TTL:               cfg.TTL,
+	}
+	for currency, amount := range cfg.DepositThresholds {
+		currency = strings.ToUpper(currency)
+		threshold, err := model.ParseMoney(amount, currency)
+		if err != nil || threshold.IsNegative() {
+			return ApprovalPolicy{}, fmt.Errorf("%w for %s: %q", ErrInvalidApprovalThreshold, currency, amount)
+		}
+		policy.DepositThresholds[currency] = threshold
+	}
+	return policy, nil
+}
+
+// depositNeedsApproval reports whether a deposit of the amount needs approval.
+func (p ApprovalPolicy) depositNeedsApproval(amount model.Money) bool {
+	threshold, ok := p.DepositThresholds[amount.Currency]
+	return !ok || amount.MinorUnits > threshold.MinorUnits
+}
+
+// ApprovalService applies the four-eyes rule to sensitive admin actions.
+// Actions the policy lets through are carried out at once; the others are
+// stored as pending requests until an admin other than the one who asked
+// approves them, and are carried out on that admin's authority.
+type ApprovalService struct {
+	db           *sql.DB
+	approvalRepo repository.IApprovalRepository
+	accountRepo  repository.IAccountRepository
+	deposits     IDepositor
+	roles        IRoleChanger
+	permissions  IPermissionChecker
+	policy       ApprovalPolicy
+}
+
+// NewApprovalService creates a new ApprovalService.
+func NewApprovalService(db *sql.DB, approvalRepo repository.IApprovalRepository, accountRepo repository.IAccountRepository, deposits IDepositor, roles IRoleChanger, permissions IPermissionChecker, policy ApprovalPolicy) *ApprovalService {
+	return &ApprovalService{
+		db:           db,
+		approvalRepo: approvalRepo,
+		accountRepo:  accountRepo,
+		deposits:     deposits,
+		roles:        roles,
+		permissions:  permissions,
+		policy:       policy,
+	}
+}
+
+// request stores a pending approval request for an action the maker asked for.
+func (s *ApprovalService) request(ctx context.Context, makerID int, action string, payload interface{}) (*model.ApprovalRequest, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	request := &model.ApprovalRequest{
+		Action:    action,
+		Payload:   data,
+		MakerID:   makerID,
+		ExpiresAt: time.Now().Add(s.policy.TTL),
+	}
+	if err := s.approvalRepo.CreateApprovalRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"approval_request_id": request.ID,
+		"action":              action,
+		"maker_id":            makerID,
+	}).Info("Approval request created")
+	return request, nil
+}
+
+// Deposit deposits the amount into the account on behalf of the maker, or, if
+// the deposit needs approval, stores a request for it. Exactly one of the
+// updated account and the approval request is returned.
+func (s *ApprovalService) Deposit(ctx context.Context, makerID, accountID int, amount model.Amount) (*model.Account, *model.ApprovalRequest, error) {
+	account, err := s.accountRepo.GetAccountByID(ctx, accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrAccountNotFound
+		}
+		return nil, nil, err
+	}
+	if account.Kind != model.AccountKindCustomer {
+		return nil, nil, ErrAccountNotFound
+	}
+	money, err := amount.In(account.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !money.IsPositive() {
+		return nil, nil, ErrInvalidDepositAmount
+	}
+
+	if !s.policy.depositNeedsApproval(money) {
+		account, err := s.deposits.DepositToAccount(ctx, accountID, amount)
+		return account, nil, err
+	}
+	request, err := s.request(ctx, makerID, model.ApprovalActionDeposit, model.DepositApproval{AccountID: accountID, Amount: money})
+	return nil, request, err
+}
+
+// ChangeRole changes the user's role on behalf of the maker, who holds
+// makerRole, or, if the change needs approval, stores a request for it. The
+// returned request is nil if the role was changed.
+func (s *ApprovalService) ChangeRole(ctx context.Context, makerID int, makerRole string, userID int, role model.Role) (*model.ApprovalRequest, error) {
+	grants, err := s.roles.CheckRoleChange(ctx, makerRole, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !grants || !s.policy.RoleChanges {
+		return nil, s.roles.UpdateUserRole(ctx, makerRole, userID, role)
+	}
+	return s.request(ctx, makerID, model.ApprovalActionRoleChange, model.RoleChangeApproval{UserID: userID, Role: role})
+}
+
+// GetApprovalRequest returns an approval request by ID.
+func (s *ApprovalService) GetApprovalRequest(ctx context.Context, id int) (*model.ApprovalRequest, error) {
+	request, err := s.approvalRepo.GetApprovalRequest(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrApprovalNotFound
+	}
+	return request, err
+}
+
+// ListApprovalRequests returns one page of the approval requests matching the
+// filter, newest first by default.
+func (s *ApprovalService) ListApprovalRequests(ctx context.Context, filter model.ApprovalFilter) (*model.ApprovalPage, error) {
+	if filter.Status != "" && !contains(approvalStatuses, filter.Status) {
+		return nil, ErrInvalidApprovalStatus
+	}
+	if _, ok := approvalPermissions[filter.Action]; filter.Action != "" && !ok {
+		return nil, ErrInvalidApprovalAction
+	}
+	filter.Limit = pageLimit(filter.Limit)
+
+	requests, total, err := s.approvalRepo.ListApprovalRequests(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.ApprovalPage{Total: total}
+	page.Requests, page.NextCursor = paginate(requests, filter.Limit, func(r *model.ApprovalRequest) model.Cursor {
+		return model.Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+	})
+	return page, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// pending returns the request if the checker may still decide on it.
+func (s *ApprovalService) pending(ctx context.Context, checkerID, id int) (*model.ApprovalRequest, error) {
+	request, err := s.GetApprovalRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case request.Status == model.ApprovalStatusExpired:
+		return nil, ErrApprovalExpired
+	case request.Status != model.ApprovalStatusPending:
+		return nil, ErrApprovalNotPending
+	case request.MakerID == checkerID:
+		return nil, ErrSelfApproval
+	}
+	return request, nil
+}
+
+// decide records the checker's decision within tx. A request decided by
+// someone else or expired since it was loaded is no longer pending.
+func (s *ApprovalService) decide(ctx context.Context, tx *sql.Tx, id int, decision model.ApprovalDecision) (*model.ApprovalRequest, error) {
+	request, err := s.approvalRepo.DecideApprovalRequest(ctx, tx, id, decision)
+	if err == sql.ErrNoRows {
+		return nil, ErrApprovalNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"approval_request_id": id,
+		"action":              request.Action,
+		"status":              decision.Status,
+		"maker_id":            request.MakerID,
+		"checker_id":          decision.CheckerID,
+	}).Info("Approval request decided")
+	return request, nil
+}
+
+// Approve approves a pending request on behalf of a checker holding
+// checkerRole and carries it out. The checker must be someone other than the
+// maker and be allowed to carry out the action directly. The approval and the
+// action are committed in one transaction, so a request is executed if and
+// only if its action took effect. If carrying it out fails, nothing of it is
+// kept and the request ends up failed with the reason, rather than the error
+// being returned.
+func (s *ApprovalService) Approve(ctx context.Context, checkerID int, checkerRole string, id int, comment string) (*model.ApprovalRequest, error) {
+	request, err := s.pending(ctx, checkerID, id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.permissions.HasPermissions(ctx, checkerRole, approvalPermissions[request.Action])
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrApprovalForbidden
+	}
+	if request.Action == model.ApprovalActionRoleChange {
+		var change model.RoleChangeApproval
+		if err := json.Unmarshal(request.Payload, &change); err != nil {
+			return nil, err
+		}
+		// Refuse escalation before approving; other problems, such as the
+		// user having been deleted since, make the approved request fail.
+		if _, err := s.roles.CheckRoleChange(ctx, checkerRole, change.UserID, change.Role); err == ErrPermissionEscalation {
+			return nil, err
+		}
+	}
+
+	var executed func(context.Context)
+	var failure error
+	err = runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		failure = nil
+		approved, err := s.decide(ctx, tx, id, model.ApprovalDecision{Status: model.ApprovalStatusExecuted, CheckerID: checkerID, Comment: comment})
+		if err != nil {
+			return err
+		}
+		if executed, err = s.execute(ctx, tx, checkerRole, approved); err != nil {
+			failure = err
+			return err
+		}
+		request = approved
+		return nil
+	})
+	if failure != nil {
+		logger.Log.WithError(failure).WithField("approval_request_id", id).Error("Approved request could not be carried out")
+		return s.fail(ctx, checkerID, id, comment, failure)
+	}
+	if err != nil {
+		return nil, err
+	}
+	executed(ctx)
+	return request, nil
+}
+
+// fail records that the checker approved a request that could not be carried
+// out, with the reason.
+func (s *ApprovalService) fail(ctx context.Context, checkerID, id int, comment string, failure error) (*model.ApprovalRequest, error) {
+	ctx = context.WithoutCancel(ctx)
+	var request *model.ApprovalRequest
+	err := runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		request, err = s.decide(ctx, tx, id, model.ApprovalDecision{
+			Status: model.ApprovalStatusFailed, CheckerID: checkerID, Comment: comment, Failure: failure.Error(),
+		})
+		return err
+	})
+	return request, err
+}
+
+// execute carries out an approved request on the checker's authority within
+// tx, as its last statements. It returns what is left to do once tx commits.
+func (s *ApprovalService) execute(ctx context.Context, tx *sql.Tx, checkerRole string, request *model.ApprovalRequest) (func(context.Context), error) {
+	switch request.Action {
+	case model.ApprovalActionDeposit:
+		var deposit model.DepositApproval
+		if err := json.Unmarshal(request.Payload, &deposit); err != nil {
+			return nil, err
+		}
+		account, err := s.deposits.DepositToAccountTx(ctx, tx, deposit.AccountID, model.Amount(deposit.Amount.Decimal()))
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) { s.deposits.InvalidateAccountCache(ctx, account.UserID) }, nil
+	case model.ApprovalActionRoleChange:
+		var change model.RoleChangeApproval
+		if err := json.Unmarshal(request.Payload, &change); err != nil {
+			return nil, err
+		}
+		if err := s.roles.UpdateUserRoleTx(ctx, tx, checkerRole, change.UserID, change.Role); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) {
+			if err := s.roles.RevokeUserTokens(ctx, change.UserID); err != nil {
+				logger.Log.WithError(err).WithField("user_id", change.UserID).Error("Could not revoke tokens after an approved role change")
+			}
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown approval action %q", request.Action)
+	}
+}
+
+// Reject rejects a pending request on behalf of a checker other than the
+// maker. The comment gives the reason.
+func (s *ApprovalService) Reject(ctx context.Context, checkerID, id int, comment string) (*model.ApprovalRequest, error) {
+	if _, err := s.pending(ctx, checkerID, id); err != nil {
+		return nil, err
+	}
+	var request *model.ApprovalRequest
+	err := runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		request, err = s.decide(ctx, tx, id, model.ApprovalDecision{Status: model.ApprovalStatusRejected, CheckerID: checkerID, Comment: comment})
+		return err
+	})
+	return request, err
+}
@@ -0,0 +1,360 @@
+// file: service/approval_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-bank-api/config"
+	"go-bank-api/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockApprovalRepo provides a mock for IApprovalRepository.
+type mockApprovalRepo struct{ mock.Mock }
+
+func (m *mockApprovalRepo) CreateApprovalRequest(_ context.Context, request *model.ApprovalRequest) error {
+	args := m.Called(request)
+	request.ID, request.Status = 1, model.ApprovalStatusPending
+	return args.Error(0)
+}
+func (m *mockApprovalRepo) GetApprovalRequest(_ context.Context, id int) (*model.ApprovalRequest, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ApprovalRequest), args.Error(1)
+}
+func (m *mockApprovalRepo) ListApprovalRequests(_ context.Context, filter model.ApprovalFilter) ([]*model.ApprovalRequest, int, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]*model.ApprovalRequest), args.Int(1), args.Error(2)
+}
+func (m *mockApprovalRepo) DecideApprovalRequest(_ context.Context, _ *sql.Tx, id int, decision model.ApprovalDecision) (*model.ApprovalRequest, error) {
+	args := m.Called(id, decision)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ApprovalRequest), args.Error(1)
+}
+
+// mockDepositor provides a mock for IDepositor.
+type mockDepositor struct{ mock.Mock }
+
+func (m *mockDepositor) DepositToAccount(_ context.Context, accountID int, amount model.Amount) (*model.Account, error) {
+	args := m.Called(accountID, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+func (m *mockDepositor) DepositToAccountTx(_ context.Context, _ *sql.Tx, accountID int, amount model.Amount) (*model.Account, error) {
+	args := m.Called(accountID, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+func (m *mockDepositor) InvalidateAccountCache(_ context.Context, userID int) {
+	m.Called(userID)
+}
+
+// mockRoleChanger provides a mock for IRoleChanger.
+type mockRoleChanger struct{ mock.Mock }
+
+func (m *mockRoleChanger) CheckRoleChange(_ context.Context, actorRole string, userID int, newRole model.Role) (bool, error) {
+	args := m.Called(actorRole, userID, newRole)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockRoleChanger) UpdateUserRole(_ context.Context, actorRole string, userID int, newRole model.Role) error {
+	return m.Called(actorRole, userID, newRole).Error(0)
+}
+func (m *mockRoleChanger) UpdateUserRoleTx(_ context.Context, _ *sql.Tx, actorRole string, userID int, newRole model.Role) error {
+	return m.Called(actorRole, userID, newRole).Error(0)
+}
+func (m *mockRoleChanger) RevokeUserTokens(_ context.Context, userID int) error {
+	return m.Called(userID).Error(0)
+}
+
+// mockPermissionChecker provides a mock for IPermissionChecker.
+type mockPermissionChecker struct{ mock.Mock }
+
+func (m *mockPermissionChecker) HasPermissions(_ context.Context, role string, permissions ...model.Permission) (bool, error) {
+	args := m.Called(role, permissions)
+	return args.Bool(0), args.Error(1)
+}
+
+var testApprovalPolicy = ApprovalPolicy{
+	DepositThresholds: map[string]model.Money{"TRY": model.NewMoney(1000000, "TRY")},
+	RoleChanges:       true,
+	TTL:               time.Hour,
+}
+
+func TestApprovalService_Deposit(t *testing.T) {
+	ctx := context.Background()
+	account := &model.Account{ID: 7, UserID: 3, Kind: model.AccountKindCustomer, Currency: "TRY"}
+
+	t.Run("deposits up to the threshold are made at once", func(t *testing.T) {
+		accountRepo, deposits := new(mockAccountRepo), new(mockDepositor)
+		accountRepo.On("GetAccountByID", 7).Return(account, nil).Once()
+		deposits.On("DepositToAccount", 7, model.Amount("10000.00")).Return(account, nil).Once()
+
+		updated, request, err := NewApprovalService(nil, nil, accountRepo, deposits, nil, nil, testApprovalPolicy).Deposit(ctx, 1, 7, "10000.00")
+
+		assert.NoError(t, err)
+		assert.Equal(t, account, updated)
+		assert.Nil(t, request)
+		deposits.AssertExpectations(t)
+	})
+
+	t.Run("deposits above the threshold await approval", func(t *testing.T) {
+		approvalRepo, accountRepo, deposits := new(mockApprovalRepo), new(mockAccountRepo), new(mockDepositor)
+		accountRepo.On("GetAccountByID", 7).Return(account, nil).Once()
+		approvalRepo.On("CreateApprovalRequest", mock.Anything).Return(nil).Once()
+
+		updated, request, err := NewApprovalService(nil, approvalRepo, accountRepo, deposits, nil, nil, testApprovalPolicy).Deposit(ctx, 1, 7, "10000.01")
+
+		assert.NoError(t, err)
+		assert.Nil(t, updated)
+		if assert.NotNil(t, request) {
+			assert.Equal(t, model.ApprovalActionDeposit, request.Action)
+			assert.Equal(t, 1, request.MakerID)
+			assert.JSONEq(t, `{"account_id": 7, "amount": {"amount": "10000.01", "currency": "TRY"}}`, string(request.Payload))
+		}
+		deposits.AssertNotCalled(t, "DepositToAccount", mock.Anything, mock.Anything)
+	})
+
+	t.Run("deposits in a currency without a threshold always await approval", func(t *testing.T) {
+		approvalRepo, accountRepo := new(mockApprovalRepo), new(mockAccountRepo)
+		accountRepo.On("GetAccountByID", 8).Return(&model.Account{ID: 8, Kind: model.AccountKindCustomer, Currency: "USD"}, nil).Once()
+		approvalRepo.On("CreateApprovalRequest", mock.Anything).Return(nil).Once()
+
+		_, request, err := NewApprovalService(nil, approvalRepo, accountRepo, nil, nil, nil, testApprovalPolicy).Deposit(ctx, 1, 8, "1.00")
+
+		assert.NoError(t, err)
+		assert.NotNil(t, request)
+	})
+
+	t.Run("invalid amounts are refused before asking for approval", func(t *testing.T) {
+		accountRepo := new(mockAccountRepo)
+		accountRepo.On("GetAccountByID", 7).Return(account, nil).Once()
+
+		_, _, err := NewApprovalService(nil, nil, accountRepo, nil, nil, nil, testApprovalPolicy).Deposit(ctx, 1, 7, "-5.00")
+
+		assert.Equal(t, ErrInvalidDepositAmount, err)
+	})
+}
+
+func TestApprovalService_ChangeRole(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("changes granting permissions await approval", func(t *testing.T) {
+		approvalRepo, roles := new(mockApprovalRepo), new(mockRoleChanger)
+		roles.On("CheckRoleChange", "admin", 5, model.Role("support")).Return(true, nil).Once()
+		approvalRepo.On("CreateApprovalRequest", mock.Anything).Return(nil).Once()
+
+		request, err := NewApprovalService(nil, approvalRepo, nil, nil, roles, nil, testApprovalPolicy).ChangeRole(ctx, 1, "admin", 5, "support")
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, request) {
+			assert.JSONEq(t, `{"user_id": 5, "role": "support"}`, string(request.Payload))
+		}
+		roles.AssertNotCalled(t, "UpdateUserRole", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("demotions are made at once", func(t *testing.T) {
+		roles := new(mockRoleChanger)
+		roles.On("CheckRoleChange", "admin", 5, model.RoleUser).Return(false, nil).Once()
+		roles.On("UpdateUserRole", "admin", 5, model.RoleUser).Return(nil).Once()
+
+		request, err := NewApprovalService(nil, nil, nil, nil, roles, nil, testApprovalPolicy).ChangeRole(ctx, 1, "admin", 5, model.RoleUser)
+
+		assert.NoError(t, err)
+		assert.Nil(t, request)
+		roles.AssertExpectations(t)
+	})
+}
+
+func TestApprovalService_Approve(t *testing.T) {
+	ctx := context.Background()
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	payload, _ := json.Marshal(model.DepositApproval{AccountID: 7, Amount: model.NewMoney(2000000, "TRY")})
+	pendingDeposit := func() *model.ApprovalRequest {
+		return &model.ApprovalRequest{ID: 9, Action: model.ApprovalActionDeposit, Payload: payload, Status: model.ApprovalStatusPending, MakerID: 1}
+	}
+
+	t.Run("another admin approves and the deposit is made in the same transaction", func(t *testing.T) {
+		approvalRepo, deposits, permissions := new(mockApprovalRepo), new(mockDepositor), new(mockPermissionChecker)
+		approvalRepo.On("GetApprovalRequest", 9).Return(pendingDeposit(), nil).Once()
+		permissions.On("HasPermissions", "admin", []model.Permission{model.PermissionAccountsDeposit}).Return(true, nil).Once()
+		executed := pendingDeposit()
+		executed.Status = model.ApprovalStatusExecuted
+		approvalRepo.On("DecideApprovalRequest", 9, model.ApprovalDecision{Status: model.ApprovalStatusExecuted, CheckerID: 2, Comment: "ok"}).Return(executed, nil).Once()
+		deposits.On("DepositToAccountTx", 7, model.Amount("20000.00")).Return(&model.Account{ID: 7, UserID: 3}, nil).Once()
+		deposits.On("InvalidateAccountCache", 3).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectCommit()
+
+		request, err := NewApprovalService(db, approvalRepo, nil, deposits, nil, permissions, testApprovalPolicy).Approve(ctx, 2, "admin", 9, "ok")
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.ApprovalStatusExecuted, request.Status)
+		approvalRepo.AssertExpectations(t)
+		deposits.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("a failed deposit is rolled back with the approval and the request fails", func(t *testing.T) {
+		approvalRepo, deposits, permissions := new(mockApprovalRepo), new(mockDepositor), new(mockPermissionChecker)
+		approvalRepo.On("GetApprovalRequest", 9).Return(pendingDeposit(), nil).Once()
+		permissions.On("HasPermissions", "admin", mock.Anything).Return(true, nil).Once()
+		approvalRepo.On("DecideApprovalRequest", 9, model.ApprovalDecision{Status: model.ApprovalStatusExecuted, CheckerID: 2}).Return(pendingDeposit(), nil).Once()
+		deposits.On("DepositToAccountTx", 7, model.Amount("20000.00")).Return(nil, ErrAccountNotFound).Once()
+		approvalRepo.On("DecideApprovalRequest", 9, model.ApprovalDecision{Status: model.ApprovalStatusFailed, CheckerID: 2, Failure: ErrAccountNotFound.Error()}).
+			Return(&model.ApprovalRequest{Status: model.ApprovalStatusFailed}, nil).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectRollback()
+		dbMock.ExpectBegin()
+		dbMock.ExpectCommit()
+
+		request, err := NewApprovalService(db, approvalRepo, nil, deposits, nil, permissions, testApprovalPolicy).Approve(ctx, 2, "admin", 9, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.ApprovalStatusFailed, request.Status)
+		approvalRepo.AssertExpectations(t)
+		deposits.AssertNotCalled(t, "InvalidateAccountCache", mock.Anything)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("an approved role change revokes the user's tokens after commit", func(t *testing.T) {
+		approvalRepo, roles, permissions := new(mockApprovalRepo), new(mockRoleChanger), new(mockPermissionChecker)
+		roleChange := &model.ApprovalRequest{
+			ID: 9, Action: model.ApprovalActionRoleChange, Payload: []byte(`{"user_id": 5, "role": "support"}`), Status: model.ApprovalStatusPending, MakerID: 1,
+		}
+		approvalRepo.On("GetApprovalRequest", 9).Return(roleChange, nil).Once()
+		permissions.On("HasPermissions", "admin", []model.Permission{model.PermissionUsersRoleWrite}).Return(true, nil).Once()
+		roles.On("CheckRoleChange", "admin", 5, model.Role("support")).Return(true, nil).Once()
+		approvalRepo.On("DecideApprovalRequest", 9, model.ApprovalDecision{Status: model.ApprovalStatusExecuted, CheckerID: 2}).Return(roleChange, nil).Once()
+		roles.On("UpdateUserRoleTx", "admin", 5, model.Role("support")).Return(nil).Once()
+		roles.On("RevokeUserTokens", 5).Return(nil).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectCommit()
+
+		_, err := NewApprovalService(db, approvalRepo, nil, nil, roles, permissions, testApprovalPolicy).Approve(ctx, 2, "admin", 9, "")
+
+		assert.NoError(t, err)
+		roles.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("makers cannot approve their own requests", func(t *testing.T) {
+		approvalRepo := new(mockApprovalRepo)
+		approvalRepo.On("GetApprovalRequest", 9).Return(pendingDeposit(), nil).Once()
+
+		_, err := NewApprovalService(nil, approvalRepo, nil, nil, nil, nil, testApprovalPolicy).Approve(ctx, 1, "admin", 9, "")
+
+		assert.Equal(t, ErrSelfApproval, err)
+		approvalRepo.AssertNotCalled(t, "DecideApprovalRequest", mock.Anything, mock.Anything)
+	})
+
+	t.Run("checkers must be allowed to carry out the action", func(t *testing.T) {
+		approvalRepo, permissions := new(mockApprovalRepo), new(mockPermissionChecker)
+		approvalRepo.On("GetApprovalRequest", 9).Return(pendingDeposit(), nil).Once()
+		permissions.On("HasPermissions", "auditor", []model.Permission{model.PermissionAccountsDeposit}).Return(false, nil).Once()
+
+		_, err := NewApprovalService(nil, approvalRepo, nil, nil, nil, permissions, testApprovalPolicy).Approve(ctx, 2, "auditor", 9, "")
+
+		assert.Equal(t, ErrApprovalForbidden, err)
+	})
+
+	t.Run("checkers cannot grant roles above their own", func(t *testing.T) {
+		approvalRepo, roles, permissions := new(mockApprovalRepo), new(mockRoleChanger), new(mockPermissionChecker)
+		approvalRepo.On("GetApprovalRequest", 9).Return(&model.ApprovalRequest{
+			ID: 9, Action: model.ApprovalActionRoleChange, Payload: []byte(`{"user_id": 5, "role": "admin"}`), Status: model.ApprovalStatusPending, MakerID: 1,
+		}, nil).Once()
+		permissions.On("HasPermissions", "helpdesk", []model.Permission{model.PermissionUsersRoleWrite}).Return(true, nil).Once()
+		roles.On("CheckRoleChange", "helpdesk", 5, model.RoleAdmin).Return(false, ErrPermissionEscalation).Once()
+
+		_, err := NewApprovalService(nil, approvalRepo, nil, nil, roles, permissions, testApprovalPolicy).Approve(ctx, 2, "helpdesk", 9, "")
+
+		assert.Equal(t, ErrPermissionEscalation, err)
+		approvalRepo.AssertNotCalled(t, "DecideApprovalRequest", mock.Anything, mock.Anything)
+	})
+
+	t.Run("expired and decided requests", func(t *testing.T) {
+		approvalRepo := new(mockApprovalRepo)
+		approvalRepo.On("GetApprovalRequest", 10).Return(&model.ApprovalRequest{ID: 10, Status: model.ApprovalStatusExpired, MakerID: 1}, nil).Once()
+		approvalRepo.On("GetApprovalRequest", 11).Return(&model.ApprovalRequest{ID: 11, Status: model.ApprovalStatusRejected, MakerID: 1}, nil).Once()
+		approvalRepo.On("GetApprovalRequest", 12).Return(nil, sql.ErrNoRows).Once()
+		approvalService := NewApprovalService(nil, approvalRepo, nil, nil, nil, nil, testApprovalPolicy)
+
+		_, err := approvalService.Approve(ctx, 2, "admin", 10, "")
+		assert.Equal(t, ErrApprovalExpired, err)
+		_, err = approvalService.Approve(ctx, 2, "admin", 11, "")
+		assert.Equal(t, ErrApprovalNotPending, err)
+		_, err = approvalService.Approve(ctx, 2, "admin", 12, "")
+		assert.Equal(t, ErrApprovalNotFound, err)
+	})
+}
+
+func TestApprovalService_Reject(t *testing.T) {
+	ctx := context.Background()
+
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	t.Run("a concurrent decision wins", func(t *testing.T) {
+		approvalRepo := new(mockApprovalRepo)
+		approvalRepo.On("GetApprovalRequest", 9).Return(&model.ApprovalRequest{ID: 9, Status: model.ApprovalStatusPending, MakerID: 1}, nil).Once()
+		approvalRepo.On("DecideApprovalRequest", 9, model.ApprovalDecision{Status: model.ApprovalStatusRejected, CheckerID: 2, Comment: "no"}).Return(nil, sql.ErrNoRows).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectRollback()
+
+		_, err := NewApprovalService(db, approvalRepo, nil, nil, nil, nil, testApprovalPolicy).Reject(ctx, 2, 9, "no")
+
+		assert.Equal(t, ErrApprovalNotPending, err)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+}
+
+func TestApprovalService_ListApprovalRequests(t *testing.T) {
+	ctx := context.Background()
+	approvalRepo := new(mockApprovalRepo)
+	approvalRepo.On("ListApprovalRequests", model.ApprovalFilter{Status: model.ApprovalStatusPending, Limit: model.DefaultPageSize}).Return([]*model.ApprovalRequest(nil), 0, nil).Once()
+	approvalService := NewApprovalService(nil, approvalRepo, nil, nil, nil, nil, testApprovalPolicy)
+
+	page, err := approvalService.ListApprovalRequests(ctx, model.ApprovalFilter{Status: model.ApprovalStatusPending})
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.ApprovalRequest{}, page.Requests)
+
+	_, err = approvalService.ListApprovalRequests(ctx, model.ApprovalFilter{Status: "done"})
+	assert.Equal(t, ErrInvalidApprovalStatus, err)
+	_, err = approvalService.ListApprovalRequests(ctx, model.ApprovalFilter{Action: "transfer"})
+	assert.Equal(t, ErrInvalidApprovalAction, err)
+}
+
+func TestNewConfiguredApprovalPolicy(t *testing.T) {
+	saved := config.AppConfig.Approvals
+	defer func() { config.AppConfig.Approvals = saved }()
+	config.AppConfig.Approvals.TTL = time.Hour
+	config.AppConfig.Approvals.DepositThresholds = map[string]string{"try": "50000", "jpy": "1000"}
+
+	policy, err := NewConfiguredApprovalPolicy()
+	assert.NoError(t, err)
+	assert.Equal(t, model.NewMoney(5000000, "TRY"), policy.DepositThresholds["TRY"])
+	assert.False(t, policy.depositNeedsApproval(model.NewMoney(1000, "JPY")))
+	assert.True(t, policy.depositNeedsApproval(model.NewMoney(1001, "JPY")))
+
+	config.AppConfig.Approvals.DepositThresholds = map[string]string{"try": "lots"}
+	_, err = NewConfiguredApprovalPolicy()
+	assert.True(t, errors.Is(err, ErrInvalidApprovalThreshold))
+}
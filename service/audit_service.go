@@ -0,0 +1,183 @@
+// file: service/audit_service.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// auditVerifyBatchSize is how many events VerifyChain reads at a time.
+const auditVerifyBatchSize = 1000
+
+// IAuditRecorder appends events to the audit log. Money events are recorded
+// with RecordTx in the transaction that moves the money, so one is never
+// committed without the other. Other events are recorded with Record once
+// they have happened; failing to record them is logged but does not undo them.
+// AuditService implements it; tests can substitute a fake.
+type IAuditRecorder interface {
+	Record(ctx context.Context, event *model.AuditEvent)
+	RecordTx(ctx context.Context, tx *sql.Tx, event *model.AuditEvent) error
+}
+
+// AuditService writes the hash-chained audit log, searches it and verifies
+// that it has not been tampered with.
+type AuditService struct {
+	db   *sql.DB
+	repo repository.IAuditRepository
+}
+
+// NewAuditService creates a new AuditService with its dependencies.
+func NewAuditService(db *sql.DB, repo repository.IAuditRepository) *AuditService {
+	return &AuditService{db: db, repo: repo}
+}
+
+// newAuditEvent builds an audit event about a target. before and after are
+// the state of the target around the event, encoded as JSON; either may be nil.
+func newAuditEvent(event, targetType string, targetID int, before, after interface{}) *model.AuditEvent {
+	e := &model.AuditEvent{Event: event, TargetType: targetType}
+	if targetID != 0 {
+		e.TargetID = strconv.Itoa(targetID)
+	}
+	e.Before = auditState(before)
+	e.After = auditState(after)
+	return e
+}
+
+// auditState encodes the state of an audit target as JSON, or returns nil if
+// there is no state.
+func auditState(state interface{}) json.RawMessage {
+	if state == nil {
+		return nil
+	}
+	doc, err := json.Marshal(state)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to encode audit event state")
+		return nil
+	}
+	return doc
+}
+
+// Record appends the event to the audit log in a transaction of its own. The
+// event has already happened, so it is recorded even if the client has gone
+// away, and a failure is logged rather than returned.
+func (s *AuditService) Record(ctx context.Context, event *model.AuditEvent) {
+	ctx = context.WithoutCancel(ctx)
+	err := runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		return s.RecordTx(ctx, tx, event)
+	})
+	if err != nil {
+		logger.Log.WithError(err).WithFields(logrus.Fields{
+			"event":       event.Event,
+			"target_type": event.TargetType,
+			"target_id":   event.TargetID,
+		}).Error("Failed to record audit event")
+	}
+}
+
+// RecordTx appends the event to the audit log within tx. The request ID, IP
+// address and user agent come from the request info in ctx, as does the
+// actor unless the event names one. Appends are serialized until tx ends, so
+// the event is chained to the last one committed before it; use
+// runInAuditedTx to make it the last statement of tx.
+func (s *AuditService) RecordTx(ctx context.Context, tx *sql.Tx, event *model.AuditEvent) error {
+	info := RequestInfoFrom(ctx)
+	if event.ActorID == nil && info.ActorID != 0 {
+		actorID := info.ActorID
+		event.ActorID = &actorID
+	}
+	event.RequestID = info.RequestID
+	event.IPAddress = info.IPAddress
+	event.UserAgent = info.UserAgent
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	prevHash, err := s.repo.LockChain(ctx, tx)
+	if err != nil {
+		return err
+	}
+	event.PrevHash = prevHash
+	if event.Hash, err = event.ComputeHash(); err != nil {
+		return err
+	}
+	return s.repo.AppendEvent(ctx, tx, event)
+}
+
+// ListEvents returns one page of the audit events matching the filter.
+func (s *AuditService) ListEvents(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrInvalidDateRange
+	}
+	filter.Limit = pageLimit(filter.Limit)
+
+	events, total, err := s.repo.ListEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.AuditPage{Total: total}
+	page.Events, page.NextCursor = paginate(events, filter.Limit, func(e *model.AuditEvent) model.Cursor {
+		return model.Cursor{CreatedAt: e.CreatedAt, ID: e.ID}
+	})
+	return page, nil
+}
+
+// VerifyChain walks the audit log in order and checks that every event links
+// to the one before it and that its hash matches its contents. It stops at
+// the first event that fails either check.
+func (s *AuditService) VerifyChain(ctx context.Context) (*model.AuditReport, error) {
+	report := &model.AuditReport{Healthy: true, LastHash: model.AuditGenesisHash}
+	afterID := 0
+	for {
+		events, err := s.repo.GetEventsAfter(ctx, afterID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if problem := checkAuditEvent(event, report.LastHash); problem != "" {
+				report.Healthy = false
+				report.FirstBrokenID = event.ID
+				report.Problem = problem
+				break
+			}
+			report.EventsChecked++
+			report.LastHash = event.Hash
+			afterID = event.ID
+		}
+		if !report.Healthy || len(events) < auditVerifyBatchSize {
+			break
+		}
+	}
+	report.CheckedAt = time.Now()
+
+	if !report.Healthy {
+		logger.Log.WithFields(logrus.Fields{
+			"first_broken_id": report.FirstBrokenID,
+			"problem":         report.Problem,
+		}).Error("Audit log verification found a broken hash chain")
+	}
+	return report, nil
+}
+
+// checkAuditEvent describes what is wrong with an event that should follow
+// the event with prevHash, or returns "" if nothing is.
+func checkAuditEvent(event *model.AuditEvent, prevHash string) string {
+	if event.PrevHash != prevHash {
+		return "event does not link to the event before it"
+	}
+	hash, err := event.ComputeHash()
+	if err != nil {
+		return "event state is not valid JSON"
+	}
+	if hash != event.Hash {
+		return "event hash does not match its contents"
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+// file: service/audit_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go-bank-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// auditSink is a fake IAuditRecorder that keeps the events it is given.
+type auditSink struct {
+	events []*model.AuditEvent
+}
+
+func (a *auditSink) Record(_ context.Context, event *model.AuditEvent) {
+	a.events = append(a.events, event)
+}
+func (a *auditSink) RecordTx(_ context.Context, _ *sql.Tx, event *model.AuditEvent) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+// mockAuditRepo provides a mock for IAuditRepository.
+type mockAuditRepo struct{ mock.Mock }
+
+func (m *mockAuditRepo) LockChain(_ context.Context, tx *sql.Tx) (string, error) {
+	args := m.Called(tx)
+	return args.String(0), args.Error(1)
+}
+func (m *mockAuditRepo) AppendEvent(_ context.Context, tx *sql.Tx, event *model.AuditEvent) error {
+	return m.Called(tx, event).Error(0)
+}
+func (m *mockAuditRepo) ListEvents(_ context.Context, filter model.AuditFilter) ([]*model.AuditEvent, int, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]*model.AuditEvent), args.Int(1), args.Error(2)
+}
+func (m *mockAuditRepo) GetEventsAfter(_ context.Context, afterID, limit int) ([]*model.AuditEvent, error) {
+	args := m.Called(afterID, limit)
+	return args.Get(0).([]*model.AuditEvent), args.Error(1)
+}
+
+// auditChain builds a valid chain of n events through RecordTx.
+func auditChain(t *testing.T, n int) []*model.AuditEvent {
+	var events []*model.AuditEvent
+	repo := new(mockAuditRepo)
+	repo.On("AppendEvent", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		event := args.Get(1).(*model.AuditEvent)
+		event.ID = len(events) + 1
+		events = append(events, event)
+	})
+	auditService := NewAuditService(nil, repo)
+	for i := 0; i < n; i++ {
+		prevHash := model.AuditGenesisHash
+		if i > 0 {
+			prevHash = events[i-1].Hash
+		}
+		repo.On("LockChain", mock.Anything).Return(prevHash, nil).Once()
+		event := newAuditEvent(model.AuditEventDeposit, model.AuditTargetAccount, 7, map[string]int{"balance": i}, map[string]int{"balance": i + 1})
+		assert.NoError(t, auditService.RecordTx(context.Background(), nil, event))
+	}
+	return events
+}
+
+func TestAuditService_RecordTx(t *testing.T) {
+	repo := new(mockAuditRepo)
+	repo.On("LockChain", mock.Anything).Return(model.AuditGenesisHash, nil).Once()
+	repo.On("AppendEvent", mock.Anything, mock.Anything).Return(nil).Once()
+	ctx := WithRequestInfo(context.Background(), RequestInfo{RequestID: "req-1", IPAddress: "10.0.0.1", UserAgent: "curl", ActorID: 3})
+
+	event := newAuditEvent(model.AuditEventRoleChanged, model.AuditTargetUser, 9, map[string]string{"role": "user"}, map[string]string{"role": "admin"})
+	err := NewAuditService(nil, repo).RecordTx(ctx, nil, event)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *event.ActorID, "The actor comes from the request")
+	assert.Equal(t, "req-1", event.RequestID)
+	assert.Equal(t, "10.0.0.1", event.IPAddress)
+	assert.Equal(t, "9", event.TargetID)
+	assert.Equal(t, model.AuditGenesisHash, event.PrevHash)
+	hash, err := event.ComputeHash()
+	assert.NoError(t, err)
+	assert.Equal(t, hash, event.Hash)
+	repo.AssertExpectations(t)
+}
+
+func TestAuditService_VerifyChain(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("intact chain", func(t *testing.T) {
+		events := auditChain(t, 3)
+		repo := new(mockAuditRepo)
+		repo.On("GetEventsAfter", 0, auditVerifyBatchSize).Return(events, nil).Once()
+
+		report, err := NewAuditService(nil, repo).VerifyChain(ctx)
+
+		assert.NoError(t, err)
+		assert.True(t, report.Healthy)
+		assert.Equal(t, 3, report.EventsChecked)
+		assert.Equal(t, events[2].Hash, report.LastHash)
+	})
+
+	t.Run("changed event", func(t *testing.T) {
+		events := auditChain(t, 3)
+		events[1].After = json.RawMessage(`{"balance": 1000000}`)
+		repo := new(mockAuditRepo)
+		repo.On("GetEventsAfter", 0, auditVerifyBatchSize).Return(events, nil).Once()
+
+		report, err := NewAuditService(nil, repo).VerifyChain(ctx)
+
+		assert.NoError(t, err)
+		assert.False(t, report.Healthy)
+		assert.Equal(t, 2, report.FirstBrokenID)
+		assert.Equal(t, 1, report.EventsChecked)
+	})
+
+	t.Run("removed event", func(t *testing.T) {
+		events := auditChain(t, 3)
+		repo := new(mockAuditRepo)
+		repo.On("GetEventsAfter", 0, auditVerifyBatchSize).Return([]*model.AuditEvent{events[0], events[2]}, nil).Once()
+
+		report, err := NewAuditService(nil, repo).VerifyChain(ctx)
+
+		assert.NoError(t, err)
+		assert.False(t, report.Healthy)
+		assert.Equal(t, 3, report.FirstBrokenID)
+		assert.Equal(t, "event does not link to the event before it", report.Problem)
+	})
+}
+
+func TestAuditService_ListEvents(t *testing.T) {
+	events := auditChain(t, 3)
+	repo := new(mockAuditRepo)
+	repo.On("ListEvents", model.AuditFilter{Event: model.AuditEventDeposit, Limit: 2}).Return(events, 3, nil).Once()
+
+	page, err := NewAuditService(nil, repo).ListEvents(context.Background(), model.AuditFilter{Event: model.AuditEventDeposit, Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 2)
+	assert.Equal(t, 3, page.Total)
+	assert.NotEmpty(t, page.NextCursor)
+}
@@ -3,75 +3,168 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"go-bank-api/config"
 	"go-bank-api/logger"
 	"go-bank-api/model"
 	"go-bank-api/repository"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sirupsen/logrus"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrExpiredRefreshToken = errors.New("expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used; please log in again")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrPasswordUnchanged   = errors.New("new password must differ from the current password")
+)
+
+// IMFAVerifier is the second login step for users with two-factor
+// authentication. MFAService implements it; tests can substitute a mock.
+type IMFAVerifier interface {
+	IsEnabled(ctx context.Context, userID int) (bool, error)
+	CreateChallenge(ctx context.Context, userID int, device model.DeviceInfo) (string, error)
+	ChallengeUser(ctx context.Context, challengeToken string) (int, error)
+	VerifyChallenge(ctx context.Context, challengeToken, code string) (int, model.DeviceInfo, error)
+}
+
+// accessTokenTTL is how long an access token stays valid. Revocations in the
+// token denylist only need to be kept for as long.
+const accessTokenTTL = 15 * time.Minute
+
 // AuthService handles the business logic for authentication, including token generation and validation.
-// It depends on user and token repositories to interact with the database.
+// It depends on user, token and session repositories to interact with the database,
+// on the token denylist to revoke access tokens of ended sessions, on the
+// keyring to sign access tokens, on the MFA verifier for logins with
+// two-factor authentication, on the login guard against brute force, on the
+// password hasher, on the policy new passwords must follow and on the audit
+// log, which records logins and logouts.
 type AuthService struct {
-	userRepo  repository.IUserRepository
-	tokenRepo repository.ITokenRepository
+	userRepo    repository.IUserRepository
+	tokenRepo   repository.ITokenRepository
+	sessionRepo repository.ISessionRepository
+	denylist    ITokenDenylist
+	keys        *KeyRing
+	mfa         IMFAVerifier
+	guard       ILoginGuard
+	hasher      *PasswordHasher
+	policy      IPasswordPolicy
+	audit       IAuditRecorder
 }
 
 // NewAuthService creates a new AuthService with its dependencies.
-func NewAuthService(userRepo repository.IUserRepository, tokenRepo repository.ITokenRepository) *AuthService {
+func NewAuthService(userRepo repository.IUserRepository, tokenRepo repository.ITokenRepository, sessionRepo repository.ISessionRepository, denylist ITokenDenylist, keys *KeyRing, mfa IMFAVerifier, guard ILoginGuard, hasher *PasswordHasher, policy IPasswordPolicy, audit IAuditRecorder) *AuthService {
 	return &AuthService{
-		userRepo:  userRepo,
-		tokenRepo: tokenRepo,
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		sessionRepo: sessionRepo,
+		denylist:    denylist,
+		keys:        keys,
+		mfa:         mfa,
+		guard:       guard,
+		hasher:      hasher,
+		policy:      policy,
+		audit:       audit,
 	}
 }
 
+// NewAccessTokenDenylist creates the denylist for the access tokens issued by AuthService.
+func NewAccessTokenDenylist(client IDenylistClient) *TokenDenylist {
+	return NewTokenDenylist(client, accessTokenTTL)
+}
+
 // TokenPair represents a pair of access and refresh tokens.
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
 
-// HashPassword generates a bcrypt hash of the password.
+// LoginResult is the outcome of a password login: the token pair, or for
+// users with two-factor authentication a short-lived MFA token to present
+// together with a code to complete the login.
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// HashPassword hashes the password with the configured algorithm.
 func (s *AuthService) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	hash, err := s.hasher.HashPassword(password)
 	if err != nil {
 		logger.Log.WithError(err).Error("Failed to hash password")
 		return "", err
 	}
-	return string(bytes), nil
+	return hash, nil
 }
 
-// CheckPasswordHash compares a password with its bcrypt hash.
+// CheckPasswordHash compares a password with its hash, of any supported algorithm.
 func (s *AuthService) CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	match, _ := s.hasher.VerifyPassword(password, hash)
+	return match
 }
 
-// generateAccessToken creates a new short-lived JWT access token.
-func (s *AuthService) generateAccessToken(user *model.User) (string, error) {
-	jwtKey := []byte(config.AppConfig.JWT.SecretKey)
-	expirationTime := time.Now().Add(15 * time.Minute) // Short-lived
+// CheckNewPassword checks a password the user is about to set against the
+// password policy. It returns a *PasswordPolicyError listing the rules the
+// password breaks.
+func (s *AuthService) CheckNewPassword(ctx context.Context, password string, user *model.User) error {
+	return s.policy.Check(ctx, password, user)
+}
+
+// rehashPassword replaces a user's password hash made with an outdated
+// algorithm or outdated parameters, while the password is at hand after a
+// successful login. Failures are logged; the old hash keeps working.
+func (s *AuthService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	log := logger.Log.WithField("user_id", user.ID)
+	newHash, err := s.hasher.HashPassword(password)
+	if err != nil {
+		log.WithError(err).Warn("Failed to rehash password")
+		return
+	}
+	if err := s.userRepo.ReplacePasswordHash(ctx, user.ID, user.Password, newHash); err != nil {
+		if err != sql.ErrNoRows {
+			log.WithError(err).Warn("Failed to store rehashed password")
+		}
+		return
+	}
+	user.Password = newHash
+	log.Info("Password hash upgraded")
+}
+
+// generateAccessToken creates a new short-lived JWT access token for a session.
+// Each token gets a random ID so that it can be revoked on its own, and is
+// signed with the active key of the keyring.
+func (s *AuthService) generateAccessToken(user *model.User, sessionID int) (string, error) {
+	expirationTime := time.Now().Add(accessTokenTTL) // Short-lived
+
+	tokenID := make([]byte, 16)
+	if _, err := rand.Read(tokenID); err != nil {
+		return "", fmt.Errorf("failed to generate access token ID: %w", err)
+	}
 
 	claims := &model.AppClaims{
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:        user.ID,
+		Role:          user.Role,
+		SessionID:     sessionID,
+		EmailVerified: user.EmailVerifiedAt != nil,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        base64.RawURLEncoding.EncodeToString(tokenID),
 			Subject:   user.Email,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
 		logger.Log.WithError(err).WithField("email", user.Email).Error("Failed to sign access token")
 		return "", fmt.Errorf("failed to sign access token: %w", err)
@@ -80,19 +173,35 @@ func (s *AuthService) generateAccessToken(user *model.User) (string, error) {
 	return tokenString, nil
 }
 
-// generateRefreshToken creates a new long-lived, cryptographically secure refresh token.
-func (s *AuthService) generateRefreshToken(userID int) (string, *model.RefreshToken, error) {
+// generateOpaqueToken creates a cryptographically secure random token, such as
+// a refresh token, and the hash it is stored under. Only the hash is kept, so
+// a leaked database does not reveal usable tokens.
+func generateOpaqueToken() (string, string, error) {
 	randomBytes := make([]byte, 32)
 	if _, err := rand.Read(randomBytes); err != nil {
-		return "", nil, fmt.Errorf("failed to generate random bytes for refresh token: %w", err)
+		return "", "", err
 	}
 	tokenString := base64.URLEncoding.EncodeToString(randomBytes)
+	return tokenString, hashOpaqueToken(tokenString), nil
+}
 
+// hashOpaqueToken returns the hash a token created by generateOpaqueToken is
+// stored and looked up under.
+func hashOpaqueToken(tokenString string) string {
 	hash := sha256.Sum256([]byte(tokenString))
-	tokenHash := base64.URLEncoding.EncodeToString(hash[:])
+	return base64.URLEncoding.EncodeToString(hash[:])
+}
+
+// generateRefreshToken creates a new long-lived, cryptographically secure refresh token for a session.
+func (s *AuthService) generateRefreshToken(userID, sessionID int) (string, *model.RefreshToken, error) {
+	tokenString, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate random bytes for refresh token: %w", err)
+	}
 
 	refreshToken := &model.RefreshToken{
 		UserID:    userID,
+		SessionID: sessionID,
 		TokenHash: tokenHash,
 		ExpiresAt: time.Now().Add(7 * 24 * time.Hour), // Long-lived (7 days)
 	}
@@ -100,72 +209,402 @@ func (s *AuthService) generateRefreshToken(userID int) (string, *model.RefreshTo
 	return tokenString, refreshToken, nil
 }
 
-// AuthenticateUser validates user credentials and generates a new token pair.
-func (s *AuthService) AuthenticateUser(email, password string) (*TokenPair, error) {
-	user, err := s.userRepo.GetUserByEmail(email)
+// AuthenticateUser validates user credentials. Users without two-factor
+// authentication get a new session for the device and its token pair at once;
+// sessions on other devices are left untouched. Users with two-factor
+// authentication get an MFA challenge token instead, which CompleteMFALogin
+// exchanges for the token pair once a valid code is presented.
+// Attempts for an email address or from an IP address that failed too often
+// are refused with a *LoginThrottledError before the password is checked.
+// Failed logins are only forgotten once the whole login succeeded, so the
+// password alone does not reset the count for a second factor being guessed.
+func (s *AuthService) AuthenticateUser(ctx context.Context, email, password string, device model.DeviceInfo) (*LoginResult, error) {
+	if err := s.guard.Check(ctx, email, device.IPAddress); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		// Check the password anyway, so unknown email addresses cannot be
+		// told apart from known ones by how long the login takes.
+		s.hasher.VerifyPassword(password, s.hasher.dummyHash)
+		return nil, s.loginFailed(ctx, email, 0, device, ErrInvalidCredentials)
+	}
+
+	match, needsRehash := s.hasher.VerifyPassword(password, user.Password)
+	if !match {
+		return nil, s.loginFailed(ctx, email, user.ID, device, ErrInvalidCredentials)
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, password)
+	}
+
+	mfaEnabled, err := s.mfa.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not check two-factor authentication: %w", err)
+	}
+	if mfaEnabled {
+		challenge, err := s.mfa.CreateChallenge(ctx, user.ID, device)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFARequired: true, MFAToken: challenge}, nil
+	}
+
+	s.loginSucceeded(ctx, user)
+	tokenPair, err := s.startSession(ctx, user, device)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: tokenPair}, nil
+}
+
+// loginFailed records a failed login with the login guard and in the audit
+// log and returns the error for the attempt: failure, or a
+// *LoginThrottledError if the failure locked the account.
+func (s *AuthService) loginFailed(ctx context.Context, email string, userID int, device model.DeviceInfo, failure error) error {
+	s.audit.Record(ctx, newAuditEvent(model.AuditEventLoginFailed, model.AuditTargetUser, userID, nil, map[string]string{"email": email}))
+
+	err := s.guard.RecordFailure(ctx, email, userID, device)
+	var throttled *LoginThrottledError
+	if errors.As(err, &throttled) {
+		return throttled
+	}
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		logger.Log.WithError(err).WithField("email", email).Warn("Failed to record failed login")
 	}
+	return failure
+}
 
-	if !s.CheckPasswordHash(password, user.Password) {
-		return nil, errors.New("invalid credentials")
+// loginSucceeded resets the user's failed login count.
+func (s *AuthService) loginSucceeded(ctx context.Context, user *model.User) {
+	if err := s.guard.RecordSuccess(ctx, user.Email); err != nil {
+		logger.Log.WithError(err).WithField("user_id", user.ID).Warn("Failed to reset failed login count")
 	}
+}
 
-	accessToken, err := s.generateAccessToken(user)
+// CompleteMFALogin finishes a login with two-factor authentication: it checks
+// the code presented for the MFA challenge and starts the session on the
+// device the password was entered on. Codes count as login attempts of the
+// device presenting them: they are refused while the account or the IP
+// address is throttled, and invalid ones count towards the lockout, so
+// starting over with the password does not buy more guesses.
+func (s *AuthService) CompleteMFALogin(ctx context.Context, challengeToken, code string, device model.DeviceInfo) (*TokenPair, error) {
+	userID, err := s.mfa.ChallengeUser(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+	if err := s.guard.Check(ctx, user.Email, device.IPAddress); err != nil {
+		return nil, err
+	}
+
+	_, loginDevice, err := s.mfa.VerifyChallenge(ctx, challengeToken, code)
+	if err == ErrInvalidMFACode || err == ErrTooManyMFAAttempts {
+		return nil, s.loginFailed(ctx, user.Email, user.ID, device, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.loginSucceeded(ctx, user)
+	return s.startSession(ctx, user, loginDevice)
+}
+
+// startSession starts a new session for the device and generates its token pair.
+func (s *AuthService) startSession(ctx context.Context, user *model.User, device model.DeviceInfo) (*TokenPair, error) {
+	session := &model.Session{
+		UserID:     user.ID,
+		DeviceName: device.DeviceName,
+		UserAgent:  device.UserAgent,
+		IPAddress:  device.IPAddress,
+	}
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("could not start session: %w", err)
+	}
+
+	accessToken, err := s.generateAccessToken(user, session.ID)
 	if err != nil {
 		return nil, fmt.Errorf("could not generate access token: %w", err)
 	}
 
-	refreshTokenString, refreshToken, err := s.generateRefreshToken(user.ID)
+	refreshTokenString, refreshToken, err := s.generateRefreshToken(user.ID, session.ID)
 	if err != nil {
 		return nil, fmt.Errorf("could not generate refresh token: %w", err)
 	}
 
-	if err := s.tokenRepo.DeleteByUserID(user.ID); err != nil {
-		logger.Log.WithError(err).WithField("user_id", user.ID).Warn("Failed to delete old refresh tokens for user")
-	}
-	if err := s.tokenRepo.Create(refreshToken); err != nil {
+	if err := s.tokenRepo.Create(ctx, refreshToken); err != nil {
 		return nil, fmt.Errorf("could not store refresh token: %w", err)
 	}
 
+	event := newAuditEvent(model.AuditEventLogin, model.AuditTargetUser, user.ID, nil, map[string]interface{}{
+		"session_id":  session.ID,
+		"device_name": session.DeviceName,
+	})
+	event.ActorID = &user.ID
+	s.audit.Record(ctx, event)
+
 	return &TokenPair{
 		AccessToken:  accessToken,
 		RefreshToken: refreshTokenString,
 	}, nil
 }
 
-// RefreshAccessToken validates a refresh token and issues a new access token if valid.
-func (s *AuthService) RefreshAccessToken(refreshTokenString string) (string, error) {
-	hash := sha256.Sum256([]byte(refreshTokenString))
-	tokenHash := base64.URLEncoding.EncodeToString(hash[:])
-
-	refreshToken, err := s.tokenRepo.GetByTokenHash(tokenHash)
+// RefreshAccessToken exchanges a valid refresh token for a new token pair. The
+// refresh token is rotated: the presented token is marked as used and its
+// successor joins the same family. A used token presented again has been
+// replayed, most likely by someone who stole it, so the whole family is revoked
+// and both the thief and the legitimate client have to log in again.
+func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshTokenString string) (*TokenPair, error) {
+	refreshToken, err := s.tokenRepo.GetByTokenHash(ctx, hashOpaqueToken(refreshTokenString))
 	if err != nil {
-		return "", errors.New("invalid refresh token")
+		return nil, ErrInvalidRefreshToken
 	}
 
+	if refreshToken.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if refreshToken.UsedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, refreshToken)
+	}
 	if time.Now().After(refreshToken.ExpiresAt) {
-		return "", errors.New("expired refresh token")
+		return nil, ErrExpiredRefreshToken
 	}
 
-	user, err := s.userRepo.GetUserByID(refreshToken.UserID)
+	if err := s.tokenRepo.MarkUsed(ctx, refreshToken.ID); err != nil {
+		if err == sql.ErrNoRows {
+			// Another request used or revoked the token since it was read.
+			return nil, s.revokeReusedFamily(ctx, refreshToken)
+		}
+		return nil, fmt.Errorf("could not rotate refresh token: %w", err)
+	}
+	if refreshToken.SessionID != 0 {
+		if err := s.sessionRepo.TouchSession(ctx, refreshToken.SessionID); err != nil {
+			logger.Log.WithError(err).WithField("session_id", refreshToken.SessionID).Warn("Failed to update session last used time")
+		}
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, refreshToken.UserID)
 	if err != nil {
-		return "", errors.New("user not found for token")
+		return nil, ErrInvalidRefreshToken
 	}
 
-	newAccessToken, err := s.generateAccessToken(user)
+	newAccessToken, err := s.generateAccessToken(user, refreshToken.SessionID)
 	if err != nil {
-		return "", fmt.Errorf("could not generate new access token: %w", err)
+		return nil, fmt.Errorf("could not generate new access token: %w", err)
 	}
 
-	return newAccessToken, nil
+	newRefreshTokenString, newRefreshToken, err := s.generateRefreshToken(user.ID, refreshToken.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate refresh token: %w", err)
+	}
+	newRefreshToken.FamilyID = refreshToken.FamilyID
+	if err := s.tokenRepo.Create(ctx, newRefreshToken); err != nil {
+		return nil, fmt.Errorf("could not store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  newAccessToken,
+		RefreshToken: newRefreshTokenString,
+	}, nil
 }
 
-// LogoutUser invalidates a user's session by deleting their refresh tokens.
-func (s *AuthService) LogoutUser(userID int) error {
-	if err := s.tokenRepo.DeleteByUserID(userID); err != nil {
-		logger.Log.WithError(err).WithField("user_id", userID).Error("Failed to delete refresh tokens during logout")
+// revokeReusedFamily handles the replay of an already rotated refresh token by
+// revoking every token in its family and the session they belong to, including
+// the session's access tokens.
+func (s *AuthService) revokeReusedFamily(ctx context.Context, token *model.RefreshToken) error {
+	log := logger.Log.WithFields(logrus.Fields{
+		"security_event": "refresh_token_reuse",
+		"user_id":        token.UserID,
+		"token_id":       token.ID,
+		"family_id":      token.FamilyID,
+		"session_id":     token.SessionID,
+	})
+	log.Warn("Security event: reuse of a rotated refresh token detected, revoking the token family")
+
+	if err := s.tokenRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		log.WithError(err).Error("Failed to revoke refresh token family after reuse")
+		return fmt.Errorf("could not revoke refresh token family: %w", err)
+	}
+	if token.SessionID != 0 {
+		if err := s.sessionRepo.RevokeSession(ctx, token.SessionID); err != nil && err != sql.ErrNoRows {
+			log.WithError(err).Error("Failed to revoke session after refresh token reuse")
+			return fmt.Errorf("could not revoke session: %w", err)
+		}
+		if err := s.denylist.RevokeSession(ctx, token.SessionID); err != nil {
+			log.WithError(err).Error("Failed to revoke session access tokens after refresh token reuse")
+			return err
+		}
+	}
+	return ErrRefreshTokenReused
+}
+
+// LogoutUser ends the session the access token was issued to and revokes the
+// token itself. Access tokens issued before sessions existed carry no session,
+// in which case every refresh token of the user is deleted and all of the
+// user's access tokens are revoked instead. The logout is recorded in the
+// audit log.
+func (s *AuthService) LogoutUser(ctx context.Context, claims *model.AppClaims) error {
+	if err := s.logout(ctx, claims); err != nil {
+		return err
+	}
+	event := newAuditEvent(model.AuditEventLogout, model.AuditTargetUser, claims.UserID, map[string]int{"session_id": claims.SessionID}, nil)
+	event.ActorID = &claims.UserID
+	s.audit.Record(ctx, event)
+	return nil
+}
+
+// logout ends the session or, without one, every session of the user.
+func (s *AuthService) logout(ctx context.Context, claims *model.AppClaims) error {
+	if claims.SessionID == 0 {
+		if err := s.tokenRepo.DeleteByUserID(ctx, claims.UserID); err != nil {
+			logger.Log.WithError(err).WithField("user_id", claims.UserID).Error("Failed to delete refresh tokens during logout")
+			return fmt.Errorf("could not log out: %w", err)
+		}
+		if err := s.denylist.RevokeUser(ctx, claims.UserID); err != nil {
+			return fmt.Errorf("could not log out: %w", err)
+		}
+		return nil
+	}
+	if err := s.RevokeSession(ctx, claims.UserID, claims.SessionID); err != nil && err != ErrSessionNotFound {
 		return fmt.Errorf("could not log out: %w", err)
 	}
+	if claims.ExpiresAt != nil {
+		if err := s.denylist.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return fmt.Errorf("could not log out: %w", err)
+		}
+	}
 	return nil
 }
+
+// ListSessions returns the user's active sessions, marking the current one.
+func (s *AuthService) ListSessions(ctx context.Context, userID, currentSessionID int) ([]*model.Session, error) {
+	sessions, err := s.sessionRepo.GetActiveSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions, so neither its refresh tokens
+// nor its outstanding access tokens can be used any longer. Sessions of other
+// users are reported as not found.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID int) error {
+	session, err := s.sessionRepo.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.sessionRepo.RevokeSession(ctx, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if err := s.denylist.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	logger.Log.WithFields(logrus.Fields{"user_id": userID, "session_id": sessionID}).Info("Session revoked")
+	return nil
+}
+
+// RevokeAllSessions ends every session of the user and revokes all of their
+// outstanding tokens, logging them out on every device.
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID int) error {
+	if err := s.sessionRepo.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.denylist.RevokeUser(ctx, userID); err != nil {
+		return err
+	}
+	logger.Log.WithField("user_id", userID).Info("All sessions revoked")
+	return nil
+}
+
+// ChangePassword replaces the password of the user the claims belong to,
+// after checking the current one. Wrong current passwords count as failed
+// logins, so guessing through this endpoint is throttled like logging in.
+// The new password must follow the password policy. The user's other
+// sessions are ended, in case the old password was known to someone else.
+func (s *AuthService) ChangePassword(ctx context.Context, claims *model.AppClaims, currentPassword, newPassword string, device model.DeviceInfo) error {
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.guard.Check(ctx, user.Email, device.IPAddress); err != nil {
+		return err
+	}
+	if match, _ := s.hasher.VerifyPassword(currentPassword, user.Password); !match {
+		return s.loginFailed(ctx, user.Email, user.ID, device, ErrInvalidCredentials)
+	}
+	if newPassword == currentPassword {
+		return ErrPasswordUnchanged
+	}
+	if err := s.policy.Check(ctx, newPassword, user); err != nil {
+		return err
+	}
+
+	newHash, err := s.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.ReplacePasswordHash(ctx, user.ID, user.Password, newHash); err != nil {
+		if err == sql.ErrNoRows {
+			// The password was changed or reset since it was checked.
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	logger.Log.WithField("user_id", user.ID).Info("Password changed")
+
+	if err := s.revokeOtherSessions(ctx, claims); err != nil {
+		return fmt.Errorf("password was changed but other sessions could not be revoked: %w", err)
+	}
+	return nil
+}
+
+// revokeOtherSessions ends every session of the user but the one the claims
+// were issued to. Tokens from before sessions existed have no session to keep,
+// so for them every session is ended.
+func (s *AuthService) revokeOtherSessions(ctx context.Context, claims *model.AppClaims) error {
+	if claims.SessionID == 0 {
+		return s.RevokeAllSessions(ctx, claims.UserID)
+	}
+	sessions, err := s.sessionRepo.GetActiveSessionsByUserID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == claims.SessionID {
+			continue
+		}
+		if err := s.RevokeSession(ctx, claims.UserID, session.ID); err != nil && err != ErrSessionNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnlockUser lifts a lockout of the user's account after too many failed
+// logins. It returns sql.ErrNoRows if the user does not exist.
+func (s *AuthService) UnlockUser(ctx context.Context, adminID, userID int) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.guard.Unlock(ctx, adminID, user)
+}
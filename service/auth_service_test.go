@@ -3,14 +3,29 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"go-bank-api/model"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// testHasher hashes with bcrypt at the minimum cost, so tests run fast and
+// the MinCost hashes they store do not need rehashing.
+var testHasher, _ = NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
 // TestAuthService_HashAndCheckPassword ensures that password hashing and verification methods work correctly.
 func TestAuthService_HashAndCheckPassword(t *testing.T) {
 	// Since HashPassword and CheckPasswordHash don't use any repository dependencies,
 	// we can instantiate AuthService with nil repositories for this specific test.
-	authService := NewAuthService(nil, nil)
+	authService := NewAuthService(nil, nil, nil, nil, nil, nil, nil, testHasher, nil, new(auditSink))
 	password := "mySecretPassword123"
 
 	// 1. Test Hashing
@@ -36,3 +51,508 @@ func TestAuthService_HashAndCheckPassword(t *testing.T) {
 		t.Errorf("authService.CheckPasswordHash() should have returned false for a non-matching password, but got true.")
 	}
 }
+
+// mockTokenRepo provides a mock for ITokenRepository.
+type mockTokenRepo struct{ mock.Mock }
+
+func (m *mockTokenRepo) Create(_ context.Context, token *model.RefreshToken) error {
+	return m.Called(token).Error(0)
+}
+func (m *mockTokenRepo) GetByTokenHash(_ context.Context, tokenHash string) (*model.RefreshToken, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+func (m *mockTokenRepo) MarkUsed(_ context.Context, tokenID int) error {
+	return m.Called(tokenID).Error(0)
+}
+func (m *mockTokenRepo) RevokeFamily(_ context.Context, familyID string) error {
+	return m.Called(familyID).Error(0)
+}
+func (m *mockTokenRepo) DeleteByUserID(_ context.Context, userID int) error {
+	return m.Called(userID).Error(0)
+}
+
+// mockSessionRepo provides a mock for ISessionRepository.
+type mockSessionRepo struct{ mock.Mock }
+
+func (m *mockSessionRepo) CreateSession(_ context.Context, session *model.Session) error {
+	return m.Called(session).Error(0)
+}
+func (m *mockSessionRepo) GetSessionByID(_ context.Context, sessionID int) (*model.Session, error) {
+	args := m.Called(sessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Session), args.Error(1)
+}
+func (m *mockSessionRepo) GetActiveSessionsByUserID(_ context.Context, userID int) ([]*model.Session, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]*model.Session), args.Error(1)
+}
+func (m *mockSessionRepo) TouchSession(_ context.Context, sessionID int) error {
+	return m.Called(sessionID).Error(0)
+}
+func (m *mockSessionRepo) RevokeSession(_ context.Context, sessionID int) error {
+	return m.Called(sessionID).Error(0)
+}
+func (m *mockSessionRepo) RevokeUserSessions(_ context.Context, userID int) error {
+	return m.Called(userID).Error(0)
+}
+
+// mockDenylist provides a mock for ITokenDenylist.
+type mockDenylist struct{ mock.Mock }
+
+func (m *mockDenylist) RevokeToken(_ context.Context, tokenID string, expiresAt time.Time) error {
+	return m.Called(tokenID, expiresAt).Error(0)
+}
+func (m *mockDenylist) RevokeSession(_ context.Context, sessionID int) error {
+	return m.Called(sessionID).Error(0)
+}
+func (m *mockDenylist) RevokeUser(_ context.Context, userID int) error {
+	return m.Called(userID).Error(0)
+}
+func (m *mockDenylist) IsRevoked(_ context.Context, claims *model.AppClaims) (bool, error) {
+	args := m.Called(claims)
+	return args.Bool(0), args.Error(1)
+}
+
+// mockMFAVerifier provides a mock for IMFAVerifier.
+type mockMFAVerifier struct{ mock.Mock }
+
+func (m *mockMFAVerifier) IsEnabled(_ context.Context, userID int) (bool, error) {
+	args := m.Called(userID)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockMFAVerifier) CreateChallenge(_ context.Context, userID int, device model.DeviceInfo) (string, error) {
+	args := m.Called(userID, device)
+	return args.String(0), args.Error(1)
+}
+func (m *mockMFAVerifier) ChallengeUser(_ context.Context, challengeToken string) (int, error) {
+	args := m.Called(challengeToken)
+	return args.Int(0), args.Error(1)
+}
+func (m *mockMFAVerifier) VerifyChallenge(_ context.Context, challengeToken, code string) (int, model.DeviceInfo, error) {
+	args := m.Called(challengeToken, code)
+	return args.Int(0), args.Get(1).(model.DeviceInfo), args.Error(2)
+}
+
+// mockLoginGuard provides a mock for ILoginGuard.
+type mockLoginGuard struct{ mock.Mock }
+
+func (m *mockLoginGuard) Check(_ context.Context, email, ipAddress string) error {
+	return m.Called(email, ipAddress).Error(0)
+}
+func (m *mockLoginGuard) RecordFailure(_ context.Context, email string, userID int, device model.DeviceInfo) error {
+	return m.Called(email, userID, device).Error(0)
+}
+func (m *mockLoginGuard) RecordSuccess(_ context.Context, email string) error {
+	return m.Called(email).Error(0)
+}
+func (m *mockLoginGuard) Unlock(_ context.Context, actorID int, user *model.User) error {
+	return m.Called(actorID, user).Error(0)
+}
+
+func TestAuthService_TwoFactorLogin(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewHMACKeyRing("test-secret")
+	assert.NoError(t, err)
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	user := &model.User{ID: 7, Email: "mfa@test.com", Password: string(hash), Role: "user"}
+	device := model.DeviceInfo{DeviceName: "phone"}
+
+	t.Run("password alone yields an MFA challenge", func(t *testing.T) {
+		userRepo, sessionRepo, mfa, guard := new(mockUserRepo), new(mockSessionRepo), new(mockMFAVerifier), new(mockLoginGuard)
+		authService := NewAuthService(userRepo, nil, sessionRepo, nil, keys, mfa, guard, testHasher, nil, new(auditSink))
+		guard.On("Check", user.Email, "").Return(nil).Once()
+		userRepo.On("GetUserByEmail", user.Email).Return(user, nil).Once()
+		mfa.On("IsEnabled", 7).Return(true, nil).Once()
+		mfa.On("CreateChallenge", 7, device).Return("challenge", nil).Once()
+
+		result, err := authService.AuthenticateUser(ctx, user.Email, "password123", device)
+
+		assert.NoError(t, err)
+		assert.True(t, result.MFARequired)
+		assert.Equal(t, "challenge", result.MFAToken)
+		assert.Nil(t, result.TokenPair)
+		sessionRepo.AssertNotCalled(t, "CreateSession", mock.Anything)
+		guard.AssertNotCalled(t, "RecordSuccess", mock.Anything)
+	})
+
+	mfaDevice := model.DeviceInfo{IPAddress: "10.0.0.2"}
+
+	t.Run("valid code starts the session", func(t *testing.T) {
+		userRepo, tokenRepo, sessionRepo, mfa, guard := new(mockUserRepo), new(mockTokenRepo), new(mockSessionRepo), new(mockMFAVerifier), new(mockLoginGuard)
+		authService := NewAuthService(userRepo, tokenRepo, sessionRepo, nil, keys, mfa, guard, testHasher, nil, new(auditSink))
+		mfa.On("ChallengeUser", "challenge").Return(7, nil).Once()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, "10.0.0.2").Return(nil).Once()
+		mfa.On("VerifyChallenge", "challenge", "123456").Return(7, device, nil).Once()
+		guard.On("RecordSuccess", user.Email).Return(nil).Once()
+		sessionRepo.On("CreateSession", mock.MatchedBy(func(s *model.Session) bool {
+			return s.UserID == 7 && s.DeviceName == "phone"
+		})).Return(nil).Once()
+		tokenRepo.On("Create", mock.Anything).Return(nil).Once()
+
+		pair, err := authService.CompleteMFALogin(ctx, "challenge", "123456", mfaDevice)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, pair.AccessToken)
+		assert.NotEmpty(t, pair.RefreshToken)
+		sessionRepo.AssertExpectations(t)
+		guard.AssertExpectations(t)
+	})
+
+	t.Run("invalid code counts as a failed login", func(t *testing.T) {
+		userRepo, mfa, guard, audit := new(mockUserRepo), new(mockMFAVerifier), new(mockLoginGuard), new(auditSink)
+		authService := NewAuthService(userRepo, nil, nil, nil, keys, mfa, guard, testHasher, nil, audit)
+		mfa.On("ChallengeUser", "challenge").Return(7, nil).Once()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, "10.0.0.2").Return(nil).Once()
+		mfa.On("VerifyChallenge", "challenge", "000000").Return(0, model.DeviceInfo{}, ErrInvalidMFACode).Once()
+		guard.On("RecordFailure", user.Email, 7, mfaDevice).Return(nil).Once()
+
+		_, err := authService.CompleteMFALogin(ctx, "challenge", "000000", mfaDevice)
+
+		assert.Equal(t, ErrInvalidMFACode, err)
+		guard.AssertExpectations(t)
+		if assert.Len(t, audit.events, 1) {
+			assert.Equal(t, model.AuditEventLoginFailed, audit.events[0].Event)
+		}
+	})
+
+	t.Run("invalid code locks the account", func(t *testing.T) {
+		userRepo, mfa, guard := new(mockUserRepo), new(mockMFAVerifier), new(mockLoginGuard)
+		authService := NewAuthService(userRepo, nil, nil, nil, keys, mfa, guard, testHasher, nil, new(auditSink))
+		mfa.On("ChallengeUser", "challenge").Return(7, nil).Once()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, "10.0.0.2").Return(nil).Once()
+		mfa.On("VerifyChallenge", "challenge", "000000").Return(0, model.DeviceInfo{}, ErrInvalidMFACode).Once()
+		guard.On("RecordFailure", user.Email, 7, mfaDevice).Return(&LoginThrottledError{Locked: true, RetryAfter: time.Minute}).Once()
+
+		_, err := authService.CompleteMFALogin(ctx, "challenge", "000000", mfaDevice)
+
+		var throttled *LoginThrottledError
+		assert.ErrorAs(t, err, &throttled)
+		assert.True(t, throttled.Locked)
+	})
+
+	t.Run("locked account cannot present codes", func(t *testing.T) {
+		userRepo, mfa, guard := new(mockUserRepo), new(mockMFAVerifier), new(mockLoginGuard)
+		authService := NewAuthService(userRepo, nil, nil, nil, keys, mfa, guard, testHasher, nil, new(auditSink))
+		mfa.On("ChallengeUser", "challenge").Return(7, nil).Once()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, "10.0.0.2").Return(&LoginThrottledError{Locked: true, RetryAfter: time.Minute}).Once()
+
+		_, err := authService.CompleteMFALogin(ctx, "challenge", "123456", mfaDevice)
+
+		var throttled *LoginThrottledError
+		assert.ErrorAs(t, err, &throttled)
+		mfa.AssertNotCalled(t, "VerifyChallenge", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthService_LoginProtection(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	user := &model.User{ID: 7, Email: "user@test.com", Password: string(hash), Role: "user"}
+	device := model.DeviceInfo{IPAddress: "10.0.0.1"}
+
+	t.Run("throttled attempts are refused before the password is checked", func(t *testing.T) {
+		userRepo, guard := new(mockUserRepo), new(mockLoginGuard)
+		throttled := &LoginThrottledError{Locked: true, RetryAfter: time.Minute}
+		guard.On("Check", user.Email, "10.0.0.1").Return(throttled).Once()
+
+		_, err := NewAuthService(userRepo, nil, nil, nil, nil, nil, guard, testHasher, nil, new(auditSink)).AuthenticateUser(ctx, user.Email, "password123", device)
+
+		assert.Equal(t, throttled, err)
+		userRepo.AssertNotCalled(t, "GetUserByEmail", mock.Anything)
+	})
+
+	t.Run("wrong password is recorded as a failure", func(t *testing.T) {
+		userRepo, guard := new(mockUserRepo), new(mockLoginGuard)
+		guard.On("Check", user.Email, "10.0.0.1").Return(nil).Once()
+		userRepo.On("GetUserByEmail", user.Email).Return(user, nil).Once()
+		guard.On("RecordFailure", user.Email, 7, device).Return(nil).Once()
+
+		_, err := NewAuthService(userRepo, nil, nil, nil, nil, nil, guard, testHasher, nil, new(auditSink)).AuthenticateUser(ctx, user.Email, "wrong-password", device)
+
+		assert.Equal(t, ErrInvalidCredentials, err)
+		guard.AssertExpectations(t)
+	})
+
+	t.Run("unknown email is recorded as a failure", func(t *testing.T) {
+		userRepo, guard := new(mockUserRepo), new(mockLoginGuard)
+		guard.On("Check", "nobody@test.com", "10.0.0.1").Return(nil).Once()
+		userRepo.On("GetUserByEmail", "nobody@test.com").Return(nil, sql.ErrNoRows).Once()
+		guard.On("RecordFailure", "nobody@test.com", 0, device).Return(nil).Once()
+
+		_, err := NewAuthService(userRepo, nil, nil, nil, nil, nil, guard, testHasher, nil, new(auditSink)).AuthenticateUser(ctx, "nobody@test.com", "password123", device)
+
+		assert.Equal(t, ErrInvalidCredentials, err)
+		guard.AssertExpectations(t)
+	})
+
+	t.Run("the failure that locks the account reports the lockout", func(t *testing.T) {
+		userRepo, guard := new(mockUserRepo), new(mockLoginGuard)
+		throttled := &LoginThrottledError{Locked: true, RetryAfter: 15 * time.Minute}
+		guard.On("Check", user.Email, "10.0.0.1").Return(nil).Once()
+		userRepo.On("GetUserByEmail", user.Email).Return(user, nil).Once()
+		guard.On("RecordFailure", user.Email, 7, device).Return(throttled).Once()
+
+		_, err := NewAuthService(userRepo, nil, nil, nil, nil, nil, guard, testHasher, nil, new(auditSink)).AuthenticateUser(ctx, user.Email, "wrong-password", device)
+
+		assert.Equal(t, throttled, err)
+	})
+}
+
+func TestAuthService_RehashOnLogin(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	user := &model.User{ID: 7, Email: "user@test.com", Password: string(hash), Role: "user"}
+	hasher, err := NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmArgon2id, Argon2: testArgon2Params})
+	assert.NoError(t, err)
+	userRepo, guard, mfa := new(mockUserRepo), new(mockLoginGuard), new(mockMFAVerifier)
+	guard.On("Check", user.Email, "").Return(nil).Once()
+	userRepo.On("GetUserByEmail", user.Email).Return(user, nil).Once()
+	guard.On("RecordSuccess", user.Email).Return(nil).Once()
+	var newHash string
+	userRepo.On("ReplacePasswordHash", 7, string(hash), mock.Anything).Run(func(args mock.Arguments) {
+		newHash = args.String(2)
+	}).Return(nil).Once()
+	mfa.On("IsEnabled", 7).Return(true, nil).Once()
+	mfa.On("CreateChallenge", 7, model.DeviceInfo{}).Return("challenge", nil).Once()
+
+	_, err = NewAuthService(userRepo, nil, nil, nil, nil, mfa, guard, hasher, nil, new(auditSink)).AuthenticateUser(ctx, user.Email, "password123", model.DeviceInfo{})
+
+	assert.NoError(t, err)
+	userRepo.AssertExpectations(t)
+	match, needsRehash := hasher.VerifyPassword("password123", newHash)
+	assert.True(t, match, "The legacy bcrypt hash is replaced with an argon2id hash of the same password")
+	assert.False(t, needsRehash)
+}
+
+func TestAuthService_RefreshAccessToken(t *testing.T) {
+	keys, err := NewHMACKeyRing("test-secret")
+	assert.NoError(t, err)
+	ctx := context.Background()
+	const presented = "presented-refresh-token"
+	hash := sha256.Sum256([]byte(presented))
+	tokenHash := base64.URLEncoding.EncodeToString(hash[:])
+	user := &model.User{ID: 7, Email: "rotate@test.com", Role: "user"}
+
+	t.Run("rotates the token within its family", func(t *testing.T) {
+		userRepo, tokenRepo, sessionRepo := new(mockUserRepo), new(mockTokenRepo), new(mockSessionRepo)
+		authService := NewAuthService(userRepo, tokenRepo, sessionRepo, nil, keys, nil, nil, testHasher, nil, new(auditSink))
+		stored := &model.RefreshToken{ID: 1, UserID: 7, SessionID: 3, FamilyID: "family-1", TokenHash: tokenHash, ExpiresAt: time.Now().Add(time.Hour)}
+		tokenRepo.On("GetByTokenHash", tokenHash).Return(stored, nil).Once()
+		tokenRepo.On("MarkUsed", 1).Return(nil).Once()
+		sessionRepo.On("TouchSession", 3).Return(nil).Once()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		tokenRepo.On("Create", mock.MatchedBy(func(token *model.RefreshToken) bool {
+			return token.FamilyID == "family-1" && token.SessionID == 3 && token.UserID == 7 && token.TokenHash != tokenHash
+		})).Return(nil).Once()
+
+		pair, err := authService.RefreshAccessToken(ctx, presented)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, pair.AccessToken)
+		assert.NotEqual(t, presented, pair.RefreshToken)
+		tokenRepo.AssertExpectations(t)
+		sessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("reuse of a used token revokes the family and its session", func(t *testing.T) {
+		tokenRepo, sessionRepo, denylist := new(mockTokenRepo), new(mockSessionRepo), new(mockDenylist)
+		authService := NewAuthService(new(mockUserRepo), tokenRepo, sessionRepo, denylist, keys, nil, nil, testHasher, nil, new(auditSink))
+		usedAt := time.Now().Add(-time.Minute)
+		stored := &model.RefreshToken{ID: 1, UserID: 7, SessionID: 3, FamilyID: "family-1", ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}
+		tokenRepo.On("GetByTokenHash", tokenHash).Return(stored, nil).Once()
+		tokenRepo.On("RevokeFamily", "family-1").Return(nil).Once()
+		sessionRepo.On("RevokeSession", 3).Return(nil).Once()
+		denylist.On("RevokeSession", 3).Return(nil).Once()
+
+		_, err := authService.RefreshAccessToken(ctx, presented)
+
+		assert.Equal(t, ErrRefreshTokenReused, err)
+		tokenRepo.AssertExpectations(t)
+		sessionRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+		tokenRepo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("losing a concurrent rotation counts as reuse", func(t *testing.T) {
+		tokenRepo := new(mockTokenRepo)
+		authService := NewAuthService(new(mockUserRepo), tokenRepo, new(mockSessionRepo), nil, keys, nil, nil, testHasher, nil, new(auditSink))
+		stored := &model.RefreshToken{ID: 1, UserID: 7, FamilyID: "family-1", ExpiresAt: time.Now().Add(time.Hour)}
+		tokenRepo.On("GetByTokenHash", tokenHash).Return(stored, nil).Once()
+		tokenRepo.On("MarkUsed", 1).Return(sql.ErrNoRows).Once()
+		tokenRepo.On("RevokeFamily", "family-1").Return(nil).Once()
+
+		_, err := authService.RefreshAccessToken(ctx, presented)
+
+		assert.Equal(t, ErrRefreshTokenReused, err)
+		tokenRepo.AssertExpectations(t)
+	})
+
+	t.Run("revoked and expired tokens are rejected", func(t *testing.T) {
+		tokenRepo := new(mockTokenRepo)
+		authService := NewAuthService(new(mockUserRepo), tokenRepo, new(mockSessionRepo), nil, keys, nil, nil, testHasher, nil, new(auditSink))
+		revokedAt := time.Now()
+		tokenRepo.On("GetByTokenHash", tokenHash).Return(&model.RefreshToken{ID: 1, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, nil).Once()
+		tokenRepo.On("GetByTokenHash", tokenHash).Return(&model.RefreshToken{ID: 2, ExpiresAt: time.Now().Add(-time.Hour)}, nil).Once()
+
+		_, err := authService.RefreshAccessToken(ctx, presented)
+		assert.Equal(t, ErrInvalidRefreshToken, err)
+		_, err = authService.RefreshAccessToken(ctx, presented)
+		assert.Equal(t, ErrExpiredRefreshToken, err)
+		tokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything)
+	})
+}
+
+func TestAuthService_Sessions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("lists sessions marking the current one", func(t *testing.T) {
+		sessionRepo := new(mockSessionRepo)
+		authService := NewAuthService(nil, nil, sessionRepo, nil, nil, nil, nil, testHasher, nil, new(auditSink))
+		sessionRepo.On("GetActiveSessionsByUserID", 7).Return([]*model.Session{{ID: 3, UserID: 7}, {ID: 4, UserID: 7}}, nil).Once()
+
+		sessions, err := authService.ListSessions(ctx, 7, 4)
+
+		assert.NoError(t, err)
+		assert.False(t, sessions[0].Current)
+		assert.True(t, sessions[1].Current)
+	})
+
+	t.Run("revokes one of the user's sessions and its access tokens", func(t *testing.T) {
+		sessionRepo, denylist := new(mockSessionRepo), new(mockDenylist)
+		authService := NewAuthService(nil, nil, sessionRepo, denylist, nil, nil, nil, testHasher, nil, new(auditSink))
+		sessionRepo.On("GetSessionByID", 3).Return(&model.Session{ID: 3, UserID: 7}, nil).Once()
+		sessionRepo.On("RevokeSession", 3).Return(nil).Once()
+		denylist.On("RevokeSession", 3).Return(nil).Once()
+
+		assert.NoError(t, authService.RevokeSession(ctx, 7, 3))
+		sessionRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+	})
+
+	t.Run("another user's session is not found", func(t *testing.T) {
+		sessionRepo := new(mockSessionRepo)
+		authService := NewAuthService(nil, nil, sessionRepo, nil, nil, nil, nil, testHasher, nil, new(auditSink))
+		sessionRepo.On("GetSessionByID", 3).Return(&model.Session{ID: 3, UserID: 8}, nil).Once()
+
+		assert.Equal(t, ErrSessionNotFound, authService.RevokeSession(ctx, 7, 3))
+		sessionRepo.AssertNotCalled(t, "RevokeSession", mock.Anything)
+	})
+
+	t.Run("logout ends only the current session and revokes the token", func(t *testing.T) {
+		tokenRepo, sessionRepo, denylist := new(mockTokenRepo), new(mockSessionRepo), new(mockDenylist)
+		authService := NewAuthService(nil, tokenRepo, sessionRepo, denylist, nil, nil, nil, testHasher, nil, new(auditSink))
+		expiresAt := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+		claims := &model.AppClaims{UserID: 7, SessionID: 3, RegisteredClaims: jwt.RegisteredClaims{ID: "jti-1", ExpiresAt: jwt.NewNumericDate(expiresAt)}}
+		sessionRepo.On("GetSessionByID", 3).Return(&model.Session{ID: 3, UserID: 7}, nil).Once()
+		sessionRepo.On("RevokeSession", 3).Return(nil).Once()
+		denylist.On("RevokeSession", 3).Return(nil).Once()
+		denylist.On("RevokeToken", "jti-1", expiresAt).Return(nil).Once()
+
+		assert.NoError(t, authService.LogoutUser(ctx, claims))
+		sessionRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+		tokenRepo.AssertNotCalled(t, "DeleteByUserID", mock.Anything)
+	})
+
+	t.Run("logout without a session revokes all of the user's tokens", func(t *testing.T) {
+		tokenRepo, denylist := new(mockTokenRepo), new(mockDenylist)
+		authService := NewAuthService(nil, tokenRepo, nil, denylist, nil, nil, nil, testHasher, nil, new(auditSink))
+		tokenRepo.On("DeleteByUserID", 7).Return(nil).Once()
+		denylist.On("RevokeUser", 7).Return(nil).Once()
+
+		assert.NoError(t, authService.LogoutUser(ctx, &model.AppClaims{UserID: 7}))
+		tokenRepo.AssertExpectations(t)
+		denylist.AssertExpectations(t)
+	})
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+	hash, err := testHasher.HashPassword("old-password1")
+	assert.NoError(t, err)
+	device := model.DeviceInfo{IPAddress: "10.0.0.1"}
+	claims := &model.AppClaims{UserID: 7, SessionID: 3}
+	newUser := func() *model.User {
+		return &model.User{ID: 7, Username: "user", Email: "user@test.com", Password: hash}
+	}
+
+	t.Run("replaces the password and ends the other sessions", func(t *testing.T) {
+		userRepo, sessionRepo, denylist := new(mockUserRepo), new(mockSessionRepo), new(mockDenylist)
+		guard, policy := new(mockLoginGuard), new(mockPasswordPolicy)
+		authService := NewAuthService(userRepo, nil, sessionRepo, denylist, nil, nil, guard, testHasher, policy, new(auditSink))
+		user := newUser()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, device.IPAddress).Return(nil).Once()
+		policy.On("Check", "new-password2", user).Return(nil).Once()
+		userRepo.On("ReplacePasswordHash", 7, hash, mock.Anything).Return(nil).Once()
+		sessionRepo.On("GetActiveSessionsByUserID", 7).Return([]*model.Session{{ID: 3, UserID: 7}, {ID: 4, UserID: 7}}, nil).Once()
+		sessionRepo.On("GetSessionByID", 4).Return(&model.Session{ID: 4, UserID: 7}, nil).Once()
+		sessionRepo.On("RevokeSession", 4).Return(nil).Once()
+		denylist.On("RevokeSession", 4).Return(nil).Once()
+
+		err := authService.ChangePassword(ctx, claims, "old-password1", "new-password2", device)
+
+		assert.NoError(t, err)
+		userRepo.AssertExpectations(t)
+		sessionRepo.AssertExpectations(t)
+		sessionRepo.AssertNotCalled(t, "RevokeSession", 3)
+		newHash := userRepo.Calls[1].Arguments.String(2)
+		assert.True(t, authService.CheckPasswordHash("new-password2", newHash))
+	})
+
+	t.Run("wrong current password counts as a failed login", func(t *testing.T) {
+		userRepo, guard, policy := new(mockUserRepo), new(mockLoginGuard), new(mockPasswordPolicy)
+		authService := NewAuthService(userRepo, nil, nil, nil, nil, nil, guard, testHasher, policy, new(auditSink))
+		user := newUser()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, device.IPAddress).Return(nil).Once()
+		guard.On("RecordFailure", user.Email, 7, device).Return(nil).Once()
+
+		err := authService.ChangePassword(ctx, claims, "wrong-password", "new-password2", device)
+
+		assert.Equal(t, ErrInvalidCredentials, err)
+		guard.AssertExpectations(t)
+		policy.AssertNotCalled(t, "Check", mock.Anything, mock.Anything)
+		userRepo.AssertNotCalled(t, "ReplacePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("new password refused by the policy", func(t *testing.T) {
+		userRepo, guard, policy := new(mockUserRepo), new(mockLoginGuard), new(mockPasswordPolicy)
+		authService := NewAuthService(userRepo, nil, nil, nil, nil, nil, guard, testHasher, policy, new(auditSink))
+		user := newUser()
+		policyErr := &PasswordPolicyError{Violations: []string{"must not contain your username"}}
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, device.IPAddress).Return(nil).Once()
+		policy.On("Check", "user-password3", user).Return(policyErr).Once()
+
+		err := authService.ChangePassword(ctx, claims, "old-password1", "user-password3", device)
+
+		assert.Equal(t, policyErr, err)
+		userRepo.AssertNotCalled(t, "ReplacePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("new password same as the current one", func(t *testing.T) {
+		userRepo, guard := new(mockUserRepo), new(mockLoginGuard)
+		authService := NewAuthService(userRepo, nil, nil, nil, nil, nil, guard, testHasher, nil, new(auditSink))
+		user := newUser()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		guard.On("Check", user.Email, device.IPAddress).Return(nil).Once()
+
+		err := authService.ChangePassword(ctx, claims, "old-password1", "old-password1", device)
+
+		assert.Equal(t, ErrPasswordUnchanged, err)
+	})
+}
@@ -0,0 +1,114 @@
+// file: service/fx_rates.go
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/model"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrRateUnavailable = errors.New("no exchange rate available for this currency pair")
+
+// RateProvider supplies mid-market exchange rates. The rate is the number of
+// units of the target currency one unit of the source currency buys.
+type RateProvider interface {
+	Rate(ctx context.Context, source, target string) (model.Rate, error)
+}
+
+// NewConfiguredRateProvider builds the rate provider selected in the FX
+// configuration: the static rate table by default, or the HTTP provider.
+func NewConfiguredRateProvider() (RateProvider, error) {
+	cfg := config.AppConfig.FX
+	switch cfg.Provider {
+	case "", "static":
+		return NewStaticRateProvider(cfg.Rates)
+	case "http":
+		return NewHTTPRateProvider(cfg.HTTP.URL, cfg.HTTP.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown FX rate provider %q", cfg.Provider)
+	}
+}
+
+// StaticRateProvider serves rates from a fixed table, keyed by currency pair.
+// A pair missing from the table is served as the inverse of the opposite pair.
+type StaticRateProvider struct {
+	rates map[string]model.Rate
+}
+
+// NewStaticRateProvider parses a rate table such as {"USD/TRY": "32.45"}.
+// Pairs are case-insensitive, since Viper lower-cases map keys.
+func NewStaticRateProvider(table map[string]string) (*StaticRateProvider, error) {
+	rates := make(map[string]model.Rate, len(table))
+	for pair, value := range table {
+		source, target, ok := strings.Cut(strings.ToUpper(pair), "/")
+		if !ok || len(source) != 3 || len(target) != 3 {
+			return nil, fmt.Errorf("invalid currency pair %q in FX rate table", pair)
+		}
+		rate, err := model.ParseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate for %s: %w", pair, err)
+		}
+		rates[source+"/"+target] = rate
+	}
+	return &StaticRateProvider{rates: rates}, nil
+}
+
+// Rate returns the rate for the pair from the table.
+func (p *StaticRateProvider) Rate(_ context.Context, source, target string) (model.Rate, error) {
+	if rate, ok := p.rates[source+"/"+target]; ok {
+		return rate, nil
+	}
+	if rate, ok := p.rates[target+"/"+source]; ok {
+		return rate.Inverse()
+	}
+	return 0, ErrRateUnavailable
+}
+
+// HTTPRateProvider fetches rates from an HTTP endpoint that answers
+// GET <url>?base=USD&symbols=TRY with {"rates": {"TRY": 32.45}}.
+type HTTPRateProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPRateProvider creates an HTTPRateProvider for the given endpoint.
+func NewHTTPRateProvider(endpoint string, timeout time.Duration) *HTTPRateProvider {
+	return &HTTPRateProvider{url: endpoint, client: &http.Client{Timeout: timeout}}
+}
+
+// Rate fetches the rate for the pair from the endpoint.
+func (p *HTTPRateProvider) Rate(ctx context.Context, source, target string) (model.Rate, error) {
+	query := url.Values{"base": {source}, "symbols": {target}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("could not fetch exchange rate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("could not fetch exchange rate: provider returned %s", resp.Status)
+	}
+
+	var body struct {
+		Rates map[string]json.Number `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("could not decode exchange rate response: %w", err)
+	}
+	value, ok := body.Rates[target]
+	if !ok {
+		return 0, ErrRateUnavailable
+	}
+	return model.ParseRate(value.String())
+}
@@ -0,0 +1,107 @@
+// file: service/fx_service.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrSameCurrencyQuote = errors.New("source and target currencies must differ")
+	ErrAmountTooSmall    = errors.New("amount is too small to convert")
+	ErrQuoteNotFound     = errors.New("quote not found")
+	ErrQuoteExpired      = errors.New("quote has expired")
+	ErrQuoteUsed         = errors.New("quote has already been used")
+	ErrQuoteMismatch     = errors.New("quote does not match the transfer amount or currencies")
+)
+
+// FXService prices cross-currency transfers and issues quotes that lock a
+// price for a short time.
+type FXService struct {
+	rates     RateProvider
+	quoteRepo repository.IFXQuoteRepository
+	spreadBps int
+	quoteTTL  time.Duration
+}
+
+// NewFXService creates a new FXService. spreadBps is the bank's margin on the
+// mid-market rate in basis points; quoteTTL is how long a quote stays valid.
+func NewFXService(rates RateProvider, quoteRepo repository.IFXQuoteRepository, spreadBps int, quoteTTL time.Duration) *FXService {
+	return &FXService{rates: rates, quoteRepo: quoteRepo, spreadBps: spreadBps, quoteTTL: quoteTTL}
+}
+
+// Price converts a positive source amount into the target currency at the
+// current mid-market rate less the spread.
+func (s *FXService) Price(ctx context.Context, source model.Money, targetCurrency string) (*model.FXDetails, error) {
+	midRate, err := s.rates.Rate(ctx, source.Currency, targetCurrency)
+	if err != nil {
+		return nil, err
+	}
+	rate := midRate.WithSpread(s.spreadBps)
+	destination, err := rate.Convert(source, targetCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if !destination.IsPositive() {
+		return nil, ErrAmountTooSmall
+	}
+	return &model.FXDetails{
+		DestinationAmount: destination,
+		MidRate:           midRate,
+		Rate:              rate,
+		SpreadBps:         s.spreadBps,
+	}, nil
+}
+
+// CreateQuote prices a conversion and stores it as a quote the user can
+// transfer with until it expires.
+func (s *FXService) CreateQuote(ctx context.Context, userID int, req model.FXQuoteRequest) (*model.FXQuote, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"source_currency": req.SourceCurrency,
+		"target_currency": req.TargetCurrency,
+		"amount":          req.Amount,
+	})
+
+	if req.SourceCurrency == req.TargetCurrency {
+		return nil, ErrSameCurrencyQuote
+	}
+	if _, err := model.CurrencyExponent(req.TargetCurrency); err != nil {
+		return nil, err
+	}
+	source, err := req.Amount.In(req.SourceCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if !source.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+
+	details, err := s.Price(ctx, source, req.TargetCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &model.FXQuote{
+		UserID:            userID,
+		SourceAmount:      source,
+		DestinationAmount: details.DestinationAmount,
+		MidRate:           details.MidRate,
+		Rate:              details.Rate,
+		SpreadBps:         details.SpreadBps,
+		ExpiresAt:         time.Now().Add(s.quoteTTL),
+	}
+	if err := s.quoteRepo.CreateQuote(ctx, quote); err != nil {
+		return nil, err
+	}
+
+	log.WithField("quote_id", quote.ID).Info("FX quote created")
+	return quote, nil
+}
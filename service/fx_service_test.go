@@ -0,0 +1,120 @@
+// file: service/fx_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockFXQuoteRepository is a mock for IFXQuoteRepository.
+type MockFXQuoteRepository struct{ mock.Mock }
+
+func (m *MockFXQuoteRepository) CreateQuote(_ context.Context, quote *model.FXQuote) error {
+	return m.Called(quote).Error(0)
+}
+func (m *MockFXQuoteRepository) GetQuoteForUpdate(_ context.Context, tx *sql.Tx, id string) (*model.FXQuote, error) {
+	args := m.Called(tx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXQuote), args.Error(1)
+}
+func (m *MockFXQuoteRepository) MarkQuoteUsed(_ context.Context, tx *sql.Tx, id string) error {
+	return m.Called(tx, id).Error(0)
+}
+
+func mustRate(t *testing.T, s string) model.Rate {
+	t.Helper()
+	rate, err := model.ParseRate(s)
+	assert.NoError(t, err)
+	return rate
+}
+
+func TestStaticRateProvider(t *testing.T) {
+	provider, err := NewStaticRateProvider(map[string]string{"usd/try": "32.50"})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	rate, err := provider.Rate(ctx, "USD", "TRY")
+	assert.NoError(t, err)
+	assert.Equal(t, mustRate(t, "32.50"), rate)
+
+	inverse, err := provider.Rate(ctx, "TRY", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, mustRate(t, "0.03076923"), inverse)
+
+	_, err = provider.Rate(ctx, "EUR", "TRY")
+	assert.Equal(t, ErrRateUnavailable, err)
+
+	_, err = NewStaticRateProvider(map[string]string{"usdtry": "32.50"})
+	assert.Error(t, err)
+}
+
+func TestHTTPRateProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "EUR", r.URL.Query().Get("base"))
+		if r.URL.Query().Get("symbols") != "USD" {
+			w.Write([]byte(`{"base": "EUR", "rates": {}}`))
+			return
+		}
+		w.Write([]byte(`{"base": "EUR", "rates": {"USD": 1.0825}}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTPRateProvider(server.URL, time.Second)
+
+	rate, err := provider.Rate(context.Background(), "EUR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, mustRate(t, "1.0825"), rate)
+
+	_, err = provider.Rate(context.Background(), "EUR", "GBP")
+	assert.Equal(t, ErrRateUnavailable, err)
+}
+
+func TestFXService_CreateQuote(t *testing.T) {
+	provider, err := NewStaticRateProvider(map[string]string{"usd/try": "32.50"})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		quoteRepo := new(MockFXQuoteRepository)
+		fxService := NewFXService(provider, quoteRepo, 100, 30*time.Second)
+		quoteRepo.On("CreateQuote", mock.MatchedBy(func(q *model.FXQuote) bool {
+			return q.UserID == 1 && time.Until(q.ExpiresAt) > 25*time.Second
+		})).Return(nil).Once()
+
+		quote, err := fxService.CreateQuote(ctx, 1, model.FXQuoteRequest{SourceCurrency: "USD", TargetCurrency: "TRY", Amount: "100"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.NewMoney(10000, "USD"), quote.SourceAmount)
+		// 32.50 less a 1% spread is 32.175.
+		assert.Equal(t, mustRate(t, "32.175"), quote.Rate)
+		assert.Equal(t, model.NewMoney(321750, "TRY"), quote.DestinationAmount)
+		quoteRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown pair", func(t *testing.T) {
+		fxService := NewFXService(provider, new(MockFXQuoteRepository), 100, 30*time.Second)
+
+		_, err := fxService.CreateQuote(ctx, 1, model.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "TRY", Amount: "100"})
+
+		assert.Equal(t, ErrRateUnavailable, err)
+	})
+
+	t.Run("amount too small to convert", func(t *testing.T) {
+		fxService := NewFXService(provider, new(MockFXQuoteRepository), 100, 30*time.Second)
+
+		_, err := fxService.CreateQuote(ctx, 1, model.FXQuoteRequest{SourceCurrency: "TRY", TargetCurrency: "USD", Amount: "0.10"})
+
+		assert.Equal(t, ErrAmountTooSmall, err)
+	})
+}
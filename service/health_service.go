@@ -0,0 +1,179 @@
+// file: service/health_service.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultHealthTimeout bounds each dependency check if no timeout is configured.
+const defaultHealthTimeout = 2 * time.Second
+
+// migrationFilePattern matches the up migrations in the migrations directory
+// and captures their version.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_.+\.up\.sql$`)
+
+// IRedisPinger is the part of the Redis client the readiness probe needs.
+type IRedisPinger interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+}
+
+// HealthService checks whether the API can serve requests: whether Postgres
+// and Redis answer and the database schema is migrated far enough.
+type HealthService struct {
+	repo            repository.IHealthRepository
+	redis           IRedisPinger
+	expectedVersion uint
+	timeout         time.Duration
+	shuttingDown    atomic.Bool
+}
+
+// NewHealthService creates a new HealthService. The schema has to be at
+// expectedVersion or newer; each check gives up after timeout.
+func NewHealthService(repo repository.IHealthRepository, redis IRedisPinger, expectedVersion uint, timeout time.Duration) *HealthService {
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+	return &HealthService{repo: repo, redis: redis, expectedVersion: expectedVersion, timeout: timeout}
+}
+
+// NewConfiguredHealthService creates a HealthService from the health
+// configuration, expecting the newest migration in the migrations directory.
+func NewConfiguredHealthService(repo repository.IHealthRepository, redis IRedisPinger) (*HealthService, error) {
+	cfg := config.AppConfig.Health
+	version, err := LatestMigrationVersion(cfg.MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewHealthService(repo, redis, version, cfg.Timeout), nil
+}
+
+// LatestMigrationVersion returns the version of the newest up migration in dir.
+func LatestMigrationVersion(dir string) (uint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("could not read migrations: %w", err)
+	}
+	var latest uint64
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("no migrations found in %q", dir)
+	}
+	return uint(latest), nil
+}
+
+// SetShuttingDown makes the API report not ready from now on, so load
+// balancers stop sending it requests before it stops accepting them.
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Ready checks all dependencies at once. The API is ready if every one of
+// them is up and it is not shutting down.
+func (s *HealthService) Ready(ctx context.Context) *model.ReadinessReport {
+	if s.shuttingDown.Load() {
+		return &model.ReadinessReport{Status: model.HealthShuttingDown}
+	}
+
+	report := &model.ReadinessReport{Status: model.HealthReady}
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		report.Checks.Database = s.check(ctx, "database", s.repo.Ping)
+	}()
+	go func() {
+		defer wg.Done()
+		report.Checks.Redis = s.check(ctx, "redis", func(ctx context.Context) error {
+			return s.redis.Ping(ctx).Err()
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		report.Checks.Migrations = s.checkMigrations(ctx)
+	}()
+	wg.Wait()
+
+	if report.Checks.Database.Status != model.HealthUp ||
+		report.Checks.Redis.Status != model.HealthUp ||
+		report.Checks.Migrations.Status != model.HealthUp {
+		report.Status = model.HealthNotReady
+	}
+	return report
+}
+
+// check runs fn with the check timeout and times it. The probe is public, so
+// the report only says whether the dependency failed or timed out; the cause
+// is logged.
+func (s *HealthService) check(ctx context.Context, dependency string, fn func(context.Context) error) *model.DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	result := &model.DependencyHealth{Status: model.HealthUp, LatencyMS: latencyMS(time.Since(start))}
+	if err != nil {
+		logger.Log.WithError(err).WithField("dependency", dependency).Warn("Readiness check failed")
+		result.Status = model.HealthDown
+		result.Error = "unavailable"
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out"
+		}
+	}
+	return result
+}
+
+// checkMigrations checks that the schema is not dirty and at least at the
+// expected version. A newer schema is fine: migrations run before a new
+// version of the API is rolled out.
+func (s *HealthService) checkMigrations(ctx context.Context) *model.MigrationHealth {
+	result := &model.MigrationHealth{Expected: s.expectedVersion}
+	result.DependencyHealth = *s.check(ctx, "migrations", func(ctx context.Context) error {
+		var err error
+		result.Version, result.Dirty, err = s.repo.GetSchemaVersion(ctx)
+		return err
+	})
+	if result.Status != model.HealthUp {
+		return result
+	}
+	switch {
+	case result.Dirty:
+		result.Status = model.HealthDown
+		result.Error = fmt.Sprintf("migration %d failed and left the schema dirty", result.Version)
+	case result.Version < result.Expected:
+		result.Status = model.HealthDown
+		result.Error = fmt.Sprintf("schema is at version %d, expected %d", result.Version, result.Expected)
+	}
+	return result
+}
+
+// latencyMS converts d to fractional milliseconds.
+func latencyMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,138 @@
+// file: service/health_service_test.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"go-bank-api/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHealthRepo is a fake IHealthRepository. With hang set, pings wait until
+// they are cancelled.
+type fakeHealthRepo struct {
+	hang    bool
+	version uint
+	dirty   bool
+	pinged  bool
+}
+
+func (f *fakeHealthRepo) Ping(ctx context.Context) error {
+	f.pinged = true
+	if f.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+func (f *fakeHealthRepo) GetSchemaVersion(_ context.Context) (uint, bool, error) {
+	return f.version, f.dirty, nil
+}
+
+// fakeRedisPinger answers pings with err.
+type fakeRedisPinger struct{ err error }
+
+func (f fakeRedisPinger) Ping(ctx context.Context) *redis.StatusCmd {
+	if f.err != nil {
+		return redis.NewStatusResult("", f.err)
+	}
+	return redis.NewStatusResult("PONG", nil)
+}
+
+func TestHealthService_Ready(t *testing.T) {
+	s := NewHealthService(&fakeHealthRepo{version: 20}, fakeRedisPinger{}, 20, time.Second)
+
+	report := s.Ready(context.Background())
+
+	assert.Equal(t, model.HealthReady, report.Status)
+	assert.Equal(t, model.HealthUp, report.Checks.Database.Status)
+	assert.Equal(t, model.HealthUp, report.Checks.Redis.Status)
+	assert.Equal(t, model.HealthUp, report.Checks.Migrations.Status)
+	assert.Equal(t, uint(20), report.Checks.Migrations.Version)
+}
+
+func TestHealthService_Ready_NewerSchema(t *testing.T) {
+	s := NewHealthService(&fakeHealthRepo{version: 21}, fakeRedisPinger{}, 20, time.Second)
+
+	report := s.Ready(context.Background())
+
+	assert.Equal(t, model.HealthReady, report.Status, "Migrations run before the new version is rolled out")
+}
+
+func TestHealthService_Ready_RedisDown(t *testing.T) {
+	s := NewHealthService(&fakeHealthRepo{version: 20}, fakeRedisPinger{err: errors.New("dial tcp 10.0.0.5:6379: connection refused")}, 20, time.Second)
+
+	report := s.Ready(context.Background())
+
+	assert.Equal(t, model.HealthNotReady, report.Status)
+	assert.Equal(t, model.HealthDown, report.Checks.Redis.Status)
+	assert.Equal(t, "unavailable", report.Checks.Redis.Error, "The cause is logged, not reported")
+	assert.Equal(t, model.HealthUp, report.Checks.Database.Status)
+}
+
+func TestHealthService_Ready_DatabaseTimeout(t *testing.T) {
+	s := NewHealthService(&fakeHealthRepo{hang: true, version: 20}, fakeRedisPinger{}, 20, 20*time.Millisecond)
+
+	report := s.Ready(context.Background())
+
+	assert.Equal(t, model.HealthNotReady, report.Status)
+	assert.Equal(t, model.HealthDown, report.Checks.Database.Status)
+	assert.Equal(t, "timed out", report.Checks.Database.Error)
+	assert.GreaterOrEqual(t, report.Checks.Database.LatencyMS, float64(20))
+}
+
+func TestHealthService_Ready_Migrations(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version uint
+		dirty   bool
+	}{
+		{name: "Behind", version: 19},
+		{name: "Dirty", version: 20, dirty: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewHealthService(&fakeHealthRepo{version: tc.version, dirty: tc.dirty}, fakeRedisPinger{}, 20, time.Second)
+
+			report := s.Ready(context.Background())
+
+			assert.Equal(t, model.HealthNotReady, report.Status)
+			assert.Equal(t, model.HealthDown, report.Checks.Migrations.Status)
+			assert.NotEmpty(t, report.Checks.Migrations.Error)
+		})
+	}
+}
+
+func TestHealthService_Ready_ShuttingDown(t *testing.T) {
+	repo := &fakeHealthRepo{version: 20}
+	s := NewHealthService(repo, fakeRedisPinger{}, 20, time.Second)
+
+	s.SetShuttingDown()
+	report := s.Ready(context.Background())
+
+	assert.Equal(t, model.HealthShuttingDown, report.Status)
+	assert.Nil(t, report.Checks.Database)
+	assert.False(t, repo.pinged, "Dependencies are not checked while shutting down")
+}
+
+func TestLatestMigrationVersion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"001_init.up.sql", "001_init.down.sql", "012_add.up.sql", "013_next.down.sql", "README.md"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	version, err := LatestMigrationVersion(dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(12), version)
+
+	_, err = LatestMigrationVersion(t.TempDir())
+	assert.Error(t, err, "A directory without migrations is a configuration error")
+}
@@ -0,0 +1,167 @@
+// file: service/idempotency_service.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyService stores and replays responses for requests carrying an
+// Idempotency-Key. Postgres is the source of truth; completed responses are
+// additionally cached so that replays do not hit the database.
+type IdempotencyService struct {
+	repo        repository.IIdempotencyRepository
+	cacheClient ICacheClient // Optional; may be nil.
+	ttl         time.Duration
+	claimTTL    time.Duration
+}
+
+// NewIdempotencyService creates a new IdempotencyService. Stored responses are
+// replayed for ttl after they are stored. A claimed key whose response is
+// never stored, e.g. because the API stopped mid-request, can be used again
+// after claimTTL.
+func NewIdempotencyService(repo repository.IIdempotencyRepository, cacheClient ICacheClient, ttl, claimTTL time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		repo:        repo,
+		cacheClient: cacheClient,
+		ttl:         ttl,
+		claimTTL:    claimTTL,
+	}
+}
+
+// maxIdempotencyClaimAttempts is how often Begin tries to claim a key that is
+// released again while it looks at the request holding it.
+const maxIdempotencyClaimAttempts = 3
+
+func idempotencyCacheKey(userID int, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userID, key)
+}
+
+// Begin starts processing of a request identified by the user, key and request
+// fingerprint. It returns either:
+//   - a completed record, whose stored response must be replayed as-is, or
+//   - a freshly claimed record, which the caller must later Complete or Release.
+//
+// ErrIdempotencyKeyReused is returned when the key was used for a different
+// request, and ErrIdempotencyKeyInFlight while the first request is still running.
+func (s *IdempotencyService) Begin(ctx context.Context, userID int, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"idempotency_key": key,
+	})
+
+	if cached := s.getCached(ctx, userID, key); cached != nil {
+		if cached.Fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		log.Info("Replaying cached idempotent response")
+		return cached, nil
+	}
+
+	record := &model.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(s.claimTTL),
+	}
+	// If the key is released between a failed claim and loading the record,
+	// the claim is tried again; past the last attempt the request counts as in
+	// flight, which the client can retry.
+	for attempt := 0; attempt < maxIdempotencyClaimAttempts; attempt++ {
+		claimed, err := s.repo.Claim(ctx, record)
+		if err != nil {
+			return nil, fmt.Errorf("could not claim idempotency key: %w", err)
+		}
+		if claimed {
+			return record, nil
+		}
+
+		existing, err := s.repo.GetByKey(ctx, userID, key)
+		if err == sql.ErrNoRows {
+			log.Info("Idempotency key was released before it could be loaded, claiming again")
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not load idempotency record: %w", err)
+		}
+		if existing.Fingerprint != fingerprint {
+			log.Warn("Idempotency key reused with a different request body")
+			return nil, ErrIdempotencyKeyReused
+		}
+		if !existing.Completed() {
+			return nil, ErrIdempotencyKeyInFlight
+		}
+
+		log.Info("Replaying stored idempotent response")
+		s.setCached(ctx, existing)
+		return existing, nil
+	}
+	return nil, ErrIdempotencyKeyInFlight
+}
+
+// Complete stores the response produced for a claimed record, to be replayed
+// for the full TTL from now on.
+func (s *IdempotencyService) Complete(ctx context.Context, record *model.IdempotencyRecord, statusCode int, body []byte) error {
+	expiresAt := time.Now().Add(s.ttl)
+	if err := s.repo.Complete(ctx, record.ID, statusCode, body, expiresAt); err != nil {
+		return fmt.Errorf("could not store idempotent response: %w", err)
+	}
+	record.StatusCode = statusCode
+	record.ResponseBody = body
+	record.ExpiresAt = expiresAt
+	s.setCached(ctx, record)
+	return nil
+}
+
+// Release forgets a claimed record so that the same key can be retried, e.g.
+// after the request failed with a server error.
+func (s *IdempotencyService) Release(ctx context.Context, record *model.IdempotencyRecord) error {
+	if err := s.repo.Delete(ctx, record.ID); err != nil {
+		return fmt.Errorf("could not release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *IdempotencyService) getCached(ctx context.Context, userID int, key string) *model.IdempotencyRecord {
+	if s.cacheClient == nil {
+		return nil
+	}
+	data, err := s.cacheClient.Get(ctx, idempotencyCacheKey(userID, key)).Result()
+	if err != nil {
+		return nil
+	}
+	var record model.IdempotencyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil
+	}
+	return &record
+}
+
+func (s *IdempotencyService) setCached(ctx context.Context, record *model.IdempotencyRecord) {
+	if s.cacheClient == nil {
+		return
+	}
+	ttl := time.Until(record.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(record)
+	if err == nil {
+		s.cacheClient.Set(ctx, idempotencyCacheKey(record.UserID, record.Key), data, ttl)
+	}
+}
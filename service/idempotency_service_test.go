@@ -0,0 +1,178 @@
+// file: service/idempotency_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go-bank-api/model"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockIdempotencyRepo struct{ mock.Mock }
+
+func (m *mockIdempotencyRepo) Claim(ctx context.Context, record *model.IdempotencyRecord) (bool, error) {
+	args := m.Called(ctx, record)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockIdempotencyRepo) GetByKey(ctx context.Context, userID int, key string) (*model.IdempotencyRecord, error) {
+	args := m.Called(ctx, userID, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IdempotencyRecord), args.Error(1)
+}
+func (m *mockIdempotencyRepo) Complete(ctx context.Context, id int, statusCode int, body []byte, expiresAt time.Time) error {
+	return m.Called(ctx, id, statusCode, body, expiresAt).Error(0)
+}
+func (m *mockIdempotencyRepo) Delete(ctx context.Context, id int) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	ctx := context.Background()
+	userID := 7
+	key := "retry-key-1"
+	cacheKey := "idempotency:7:retry-key-1"
+
+	t.Run("first request claims the key", func(t *testing.T) {
+		mockRepo := new(mockIdempotencyRepo)
+		mockCache := new(mockCacheClient)
+		idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+
+		mockCache.On("Get", mock.Anything, cacheKey).Return("", redis.Nil).Once()
+		mockRepo.On("Claim", mock.Anything, mock.AnythingOfType("*model.IdempotencyRecord")).Return(true, nil).Once()
+
+		record, err := idempotencyService.Begin(ctx, userID, key, "fp-1")
+
+		assert.NoError(t, err)
+		assert.False(t, record.Completed())
+		assert.Equal(t, "fp-1", record.Fingerprint)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), record.ExpiresAt, time.Second, "Claims only last for the claim TTL")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("completed request is replayed from the database", func(t *testing.T) {
+		mockRepo := new(mockIdempotencyRepo)
+		mockCache := new(mockCacheClient)
+		idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+
+		stored := &model.IdempotencyRecord{ID: 3, UserID: userID, Key: key, Fingerprint: "fp-1", StatusCode: 201, ResponseBody: []byte(`{"id":1}`), ExpiresAt: time.Now().Add(time.Hour)}
+		mockCache.On("Get", mock.Anything, cacheKey).Return("", redis.Nil).Once()
+		mockRepo.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockRepo.On("GetByKey", mock.Anything, userID, key).Return(stored, nil).Once()
+		mockCache.On("Set", mock.Anything, cacheKey, mock.Anything, mock.Anything).Return().Once()
+
+		record, err := idempotencyService.Begin(ctx, userID, key, "fp-1")
+
+		assert.NoError(t, err)
+		assert.True(t, record.Completed())
+		assert.Equal(t, 201, record.StatusCode)
+		mockRepo.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+	})
+
+	t.Run("completed request is replayed from the cache", func(t *testing.T) {
+		mockRepo := new(mockIdempotencyRepo)
+		mockCache := new(mockCacheClient)
+		idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+
+		cached, _ := json.Marshal(&model.IdempotencyRecord{ID: 3, UserID: userID, Key: key, Fingerprint: "fp-1", StatusCode: 200, ResponseBody: []byte(`{}`)})
+		mockCache.On("Get", mock.Anything, cacheKey).Return(string(cached), nil).Once()
+
+		record, err := idempotencyService.Begin(ctx, userID, key, "fp-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, record.StatusCode)
+		mockRepo.AssertNotCalled(t, "Claim")
+	})
+
+	t.Run("key reused with a different request", func(t *testing.T) {
+		mockRepo := new(mockIdempotencyRepo)
+		mockCache := new(mockCacheClient)
+		idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+
+		stored := &model.IdempotencyRecord{ID: 3, UserID: userID, Key: key, Fingerprint: "fp-1", StatusCode: 201}
+		mockCache.On("Get", mock.Anything, cacheKey).Return("", redis.Nil).Once()
+		mockRepo.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockRepo.On("GetByKey", mock.Anything, userID, key).Return(stored, nil).Once()
+
+		_, err := idempotencyService.Begin(ctx, userID, key, "fp-2")
+
+		assert.Equal(t, ErrIdempotencyKeyReused, err)
+	})
+
+	t.Run("first request still in flight", func(t *testing.T) {
+		mockRepo := new(mockIdempotencyRepo)
+		mockCache := new(mockCacheClient)
+		idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+
+		stored := &model.IdempotencyRecord{ID: 3, UserID: userID, Key: key, Fingerprint: "fp-1"}
+		mockCache.On("Get", mock.Anything, cacheKey).Return("", redis.Nil).Once()
+		mockRepo.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockRepo.On("GetByKey", mock.Anything, userID, key).Return(stored, nil).Once()
+
+		_, err := idempotencyService.Begin(ctx, userID, key, "fp-1")
+
+		assert.Equal(t, ErrIdempotencyKeyInFlight, err)
+	})
+
+	t.Run("key released while it is loaded is claimed again", func(t *testing.T) {
+		mockRepo := new(mockIdempotencyRepo)
+		mockCache := new(mockCacheClient)
+		idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+
+		mockCache.On("Get", mock.Anything, cacheKey).Return("", redis.Nil).Once()
+		mockRepo.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Once()
+		mockRepo.On("GetByKey", mock.Anything, userID, key).Return(nil, sql.ErrNoRows).Once()
+		mockRepo.On("Claim", mock.Anything, mock.Anything).Return(true, nil).Once()
+
+		record, err := idempotencyService.Begin(ctx, userID, key, "fp-1")
+
+		assert.NoError(t, err)
+		assert.False(t, record.Completed())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("key keeps being released", func(t *testing.T) {
+		mockRepo := new(mockIdempotencyRepo)
+		mockCache := new(mockCacheClient)
+		idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+
+		mockCache.On("Get", mock.Anything, cacheKey).Return("", redis.Nil).Once()
+		mockRepo.On("Claim", mock.Anything, mock.Anything).Return(false, nil).Times(maxIdempotencyClaimAttempts)
+		mockRepo.On("GetByKey", mock.Anything, userID, key).Return(nil, sql.ErrNoRows).Times(maxIdempotencyClaimAttempts)
+
+		_, err := idempotencyService.Begin(ctx, userID, key, "fp-1")
+
+		assert.Equal(t, ErrIdempotencyKeyInFlight, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestIdempotencyService_Complete(t *testing.T) {
+	mockRepo := new(mockIdempotencyRepo)
+	mockCache := new(mockCacheClient)
+	idempotencyService := NewIdempotencyService(mockRepo, mockCache, time.Hour, time.Minute)
+	record := &model.IdempotencyRecord{ID: 3, UserID: 7, Key: "retry-key-1", Fingerprint: "fp-1", ExpiresAt: time.Now().Add(time.Minute)}
+
+	keptForTTL := mock.MatchedBy(func(expiresAt time.Time) bool {
+		return expiresAt.Sub(time.Now().Add(time.Hour)).Abs() < time.Second
+	})
+	mockRepo.On("Complete", mock.Anything, 3, 201, []byte(`{"id":1}`), keptForTTL).Return(nil).Once()
+	mockCache.On("Set", mock.Anything, "idempotency:7:retry-key-1", mock.Anything, mock.Anything).Return().Once()
+
+	err := idempotencyService.Complete(context.Background(), record, 201, []byte(`{"id":1}`))
+
+	assert.NoError(t, err)
+	assert.True(t, record.Completed())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), record.ExpiresAt, time.Second, "Stored responses are kept for the full TTL")
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
@@ -0,0 +1,255 @@
+// file: service/jwt_keys.go
+
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/model"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoSigningKey = errors.New("no JWT signing key is active")
+	ErrUnknownKeyID = errors.New("token signed with an unknown or retired key")
+)
+
+// SigningKey is an asymmetric key that signs access tokens from ActiveFrom on.
+type SigningKey struct {
+	ID         string
+	ActiveFrom time.Time
+	method     jwt.SigningMethod
+	private    crypto.Signer
+}
+
+// ParseSigningKey parses a PEM encoded RSA or Ed25519 private key. RSA keys
+// sign with RS256 and Ed25519 keys with EdDSA.
+func ParseSigningKey(id string, pemBytes []byte, activeFrom time.Time) (*SigningKey, error) {
+	if id == "" {
+		return nil, errors.New("signing key has no kid")
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s: no PEM data found", id)
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", id, err)
+	}
+
+	key := &SigningKey{ID: id, ActiveFrom: activeFrom}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("signing key %s: RSA keys must be at least 2048 bits", id)
+		}
+		key.method, key.private = jwt.SigningMethodRS256, private
+	case ed25519.PrivateKey:
+		key.method, key.private = jwt.SigningMethodEdDSA, private
+	default:
+		return nil, fmt.Errorf("signing key %s: unsupported key type %T", id, parsed)
+	}
+	return key, nil
+}
+
+// LoadSigningKey reads a signing key from a PEM file.
+func LoadSigningKey(id, path string, activeFrom time.Time) (*SigningKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", id, err)
+	}
+	return ParseSigningKey(id, pemBytes, activeFrom)
+}
+
+// JWK returns the public half of the key.
+func (k *SigningKey) JWK() model.JWK {
+	jwk := model.JWK{Use: "sig", Alg: k.method.Alg(), Kid: k.ID}
+	switch public := k.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// KeyRing holds the keys access tokens are signed and verified with. The key
+// whose ActiveFrom passed most recently signs; it replaced its predecessors,
+// which keep verifying tokens for the overlap window so that tokens signed
+// just before a rotation stay valid until they expire. Keys scheduled for the
+// future verify and are published too, so verifiers that cache the JWKS know
+// them before they are used.
+//
+// A KeyRing may also hold a legacy HS256 secret. It signs with the secret until
+// the first asymmetric key becomes active, and while it is set tokens signed
+// with it, which carry no kid, are accepted.
+type KeyRing struct {
+	keys    []*SigningKey // ordered by ActiveFrom
+	overlap time.Duration
+	secret  []byte
+}
+
+// NewKeyRing creates a KeyRing. overlap must be at least the access token
+// lifetime, or tokens signed shortly before a rotation would be rejected early.
+func NewKeyRing(keys []*SigningKey, overlap time.Duration, secret string) (*KeyRing, error) {
+	if len(keys) == 0 && secret == "" {
+		return nil, errors.New("neither JWT signing keys nor a JWT secret are configured")
+	}
+	if len(keys) > 0 && overlap < accessTokenTTL {
+		return nil, fmt.Errorf("JWT key rotation overlap %s is shorter than the access token lifetime %s", overlap, accessTokenTTL)
+	}
+
+	sorted := make([]*SigningKey, len(keys))
+	copy(sorted, keys)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom) })
+	seen := make(map[string]bool, len(sorted))
+	for _, key := range sorted {
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate JWT signing key kid %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	ring := &KeyRing{keys: sorted, overlap: overlap}
+	if secret != "" {
+		ring.secret = []byte(secret)
+	}
+	return ring, nil
+}
+
+// NewHMACKeyRing creates a KeyRing that signs with an HS256 secret only.
+func NewHMACKeyRing(secret string) (*KeyRing, error) {
+	return NewKeyRing(nil, 0, secret)
+}
+
+// NewConfiguredKeyRing loads the keys from the JWT configuration.
+func NewConfiguredKeyRing() (*KeyRing, error) {
+	cfg := config.AppConfig.JWT
+	keys := make([]*SigningKey, 0, len(cfg.Keys))
+	for _, keyCfg := range cfg.Keys {
+		var activeFrom time.Time
+		if keyCfg.ActiveFrom != "" {
+			var err error
+			activeFrom, err = time.Parse(time.RFC3339, keyCfg.ActiveFrom)
+			if err != nil {
+				return nil, fmt.Errorf("signing key %s: invalid active_from: %w", keyCfg.ID, err)
+			}
+		}
+		key, err := LoadSigningKey(keyCfg.ID, keyCfg.PrivateKeyFile, activeFrom)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyRing(keys, cfg.RotationOverlap, cfg.SecretKey)
+}
+
+// activeIndex returns the index of the key that signs at now, or -1 if no key
+// is active yet.
+func (r *KeyRing) activeIndex(now time.Time) int {
+	active := -1
+	for i, key := range r.keys {
+		if !key.ActiveFrom.After(now) {
+			active = i
+		}
+	}
+	return active
+}
+
+// verificationKeysAt returns the keys that verify tokens at now: the active
+// key, the keys scheduled after it and the keys it replaced less than the
+// overlap window ago.
+func (r *KeyRing) verificationKeysAt(now time.Time) []*SigningKey {
+	active := r.activeIndex(now)
+	keys := make([]*SigningKey, 0, len(r.keys))
+	for i, key := range r.keys {
+		if i < active && !r.keys[i+1].ActiveFrom.After(now.Add(-r.overlap)) {
+			continue // Retired: its successor took over before the overlap window.
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// signAt signs the claims with the key active at now.
+func (r *KeyRing) signAt(claims jwt.Claims, now time.Time) (string, error) {
+	if active := r.activeIndex(now); active >= 0 {
+		key := r.keys[active]
+		token := jwt.NewWithClaims(key.method, claims)
+		token.Header["kid"] = key.ID
+		return token.SignedString(key.private)
+	}
+	if r.secret != nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(r.secret)
+	}
+	return "", ErrNoSigningKey
+}
+
+// Sign signs the claims with the currently active key.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	return r.signAt(claims, time.Now())
+}
+
+// keyfuncAt selects the key to verify a token with at now by its kid. The
+// signing method must match the key, so that a public key can never be used
+// as an HMAC secret.
+func (r *KeyRing) keyfuncAt(now time.Time) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && r.secret != nil {
+				return r.secret, nil
+			}
+			return nil, ErrUnknownKeyID
+		}
+		for _, key := range r.verificationKeysAt(now) {
+			if key.ID == kid {
+				if token.Method.Alg() != key.method.Alg() {
+					return nil, fmt.Errorf("token algorithm %s does not match key %s", token.Method.Alg(), kid)
+				}
+				return key.private.Public(), nil
+			}
+		}
+		return nil, ErrUnknownKeyID
+	}
+}
+
+// Parse verifies a token and decodes its claims.
+func (r *KeyRing) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, r.keyfuncAt(time.Now()),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodHS256.Alg()}))
+}
+
+// JWKS returns the public keys currently accepted for verification. The HS256
+// secret is never published.
+func (r *KeyRing) JWKS() model.JWKSet {
+	keys := r.verificationKeysAt(time.Now())
+	set := model.JWKSet{Keys: make([]model.JWK, 0, len(keys))}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
@@ -0,0 +1,170 @@
+// file: service/jwt_keys_test.go
+
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pemKey(t *testing.T, private interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestParseSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("RSA signs with RS256", func(t *testing.T) {
+		key, err := ParseSigningKey("rsa-1", pemKey(t, rsaKey), time.Time{})
+		require.NoError(t, err)
+		jwk := key.JWK()
+		assert.Equal(t, "RSA", jwk.Kty)
+		assert.Equal(t, "RS256", jwk.Alg)
+		assert.Equal(t, "rsa-1", jwk.Kid)
+		assert.Equal(t, "AQAB", jwk.E)
+		assert.NotEmpty(t, jwk.N)
+	})
+
+	t.Run("PKCS#1 RSA keys are accepted", func(t *testing.T) {
+		block := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+		_, err := ParseSigningKey("rsa-1", block, time.Time{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Ed25519 signs with EdDSA", func(t *testing.T) {
+		key, err := ParseSigningKey("ed-1", pemKey(t, edKey), time.Time{})
+		require.NoError(t, err)
+		jwk := key.JWK()
+		assert.Equal(t, "OKP", jwk.Kty)
+		assert.Equal(t, "Ed25519", jwk.Crv)
+		assert.Equal(t, "EdDSA", jwk.Alg)
+		assert.NotEmpty(t, jwk.X)
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		_, err := ParseSigningKey("", pemKey(t, edKey), time.Time{})
+		assert.Error(t, err)
+		_, err = ParseSigningKey("bad", []byte("not a key"), time.Time{})
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	_, oldPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	_, newPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	rotation := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	oldKey, err := ParseSigningKey("2025-01", pemKey(t, oldPrivate), rotation.Add(-30*24*time.Hour))
+	require.NoError(t, err)
+	newKey, err := ParseSigningKey("2025-06", pemKey(t, newPrivate), rotation)
+	require.NoError(t, err)
+	ring, err := NewKeyRing([]*SigningKey{newKey, oldKey}, 30*time.Minute, "")
+	require.NoError(t, err)
+
+	kids := func(now time.Time) []string {
+		var ids []string
+		for _, key := range ring.verificationKeysAt(now) {
+			ids = append(ids, key.ID)
+		}
+		return ids
+	}
+	verify := func(token string, now time.Time) error {
+		_, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, ring.keyfuncAt(now))
+		return err
+	}
+
+	beforeRotation := rotation.Add(-time.Hour)
+	oldToken, err := ring.signAt(&jwt.RegisteredClaims{Subject: "old"}, beforeRotation)
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(oldToken, &jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01", parsed.Header["kid"])
+	assert.Equal(t, []string{"2025-01", "2025-06"}, kids(beforeRotation), "The next key is published before it signs")
+
+	afterRotation := rotation.Add(10 * time.Minute)
+	newToken, err := ring.signAt(&jwt.RegisteredClaims{Subject: "new"}, afterRotation)
+	require.NoError(t, err)
+	parsed, _, err = jwt.NewParser().ParseUnverified(newToken, &jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "2025-06", parsed.Header["kid"])
+	assert.NoError(t, verify(oldToken, afterRotation), "The replaced key verifies during the overlap window")
+	assert.NoError(t, verify(newToken, afterRotation))
+
+	afterOverlap := rotation.Add(time.Hour)
+	assert.Equal(t, []string{"2025-06"}, kids(afterOverlap))
+	assert.ErrorIs(t, verify(oldToken, afterOverlap), ErrUnknownKeyID)
+	assert.NoError(t, verify(newToken, afterOverlap))
+}
+
+func TestKeyRing_Verification(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := ParseSigningKey("rsa-1", pemKey(t, rsaKey), time.Time{})
+	require.NoError(t, err)
+	claims := &jwt.RegisteredClaims{Subject: "user@test.com", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+
+	t.Run("asymmetric token round trip", func(t *testing.T) {
+		ring, err := NewKeyRing([]*SigningKey{key}, time.Hour, "")
+		require.NoError(t, err)
+		token, err := ring.Sign(claims)
+		require.NoError(t, err)
+
+		parsed := &jwt.RegisteredClaims{}
+		_, err = ring.Parse(token, parsed)
+		assert.NoError(t, err)
+		assert.Equal(t, "user@test.com", parsed.Subject)
+		assert.Len(t, ring.JWKS().Keys, 1)
+	})
+
+	t.Run("HMAC token claiming an asymmetric kid is rejected", func(t *testing.T) {
+		ring, err := NewKeyRing([]*SigningKey{key}, time.Hour, "legacy-secret")
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		forged.Header["kid"] = "rsa-1"
+		token, err := forged.SignedString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+		require.NoError(t, err)
+
+		_, err = ring.Parse(token, &jwt.RegisteredClaims{})
+		assert.Error(t, err)
+	})
+
+	t.Run("legacy HS256 tokens are accepted only while the secret is set", func(t *testing.T) {
+		legacy, err := NewHMACKeyRing("legacy-secret")
+		require.NoError(t, err)
+		token, err := legacy.Sign(claims)
+		require.NoError(t, err)
+
+		migrating, err := NewKeyRing([]*SigningKey{key}, time.Hour, "legacy-secret")
+		require.NoError(t, err)
+		_, err = migrating.Parse(token, &jwt.RegisteredClaims{})
+		assert.NoError(t, err)
+		assert.Len(t, migrating.JWKS().Keys, 1, "The secret is never published")
+
+		migrated, err := NewKeyRing([]*SigningKey{key}, time.Hour, "")
+		require.NoError(t, err)
+		_, err = migrated.Parse(token, &jwt.RegisteredClaims{})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid keyrings", func(t *testing.T) {
+		_, err := NewKeyRing(nil, 0, "")
+		assert.Error(t, err)
+		_, err = NewKeyRing([]*SigningKey{key}, time.Minute, "")
+		assert.Error(t, err, "The overlap must cover the access token lifetime")
+		_, err = NewKeyRing([]*SigningKey{key, key}, time.Hour, "")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,425 @@
+// file: service/ledger_service.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrTransactionNotFound        = errors.New("transaction not found")
+	ErrTransactionAlreadyReversed = errors.New("transaction has already been reversed")
+	ErrTransactionNotReversible   = errors.New("only transfers, deposits and fees can be reversed")
+	ErrInvalidFeeAmount           = errors.New("fee amount must be positive")
+)
+
+// LedgerService moves money between customer accounts and the bank's system
+// accounts. Every movement is stored as a transaction row plus a balanced journal
+// entry, and account balances are only changed by posting such entries.
+type LedgerService struct {
+	db              *sql.DB
+	accountRepo     repository.IAccountRepository
+	transactionRepo repository.ITransactionRepository
+	ledgerRepo      repository.ILedgerRepository
+	cacheClient     ICacheClient
+	audit           IAuditRecorder
+}
+
+// NewLedgerService creates a new LedgerService with its dependencies.
+func NewLedgerService(db *sql.DB, accountRepo repository.IAccountRepository, transactionRepo repository.ITransactionRepository, ledgerRepo repository.ILedgerRepository, cacheClient ICacheClient, audit IAuditRecorder) *LedgerService {
+	return &LedgerService{
+		db:              db,
+		accountRepo:     accountRepo,
+		transactionRepo: transactionRepo,
+		ledgerRepo:      ledgerRepo,
+		cacheClient:     cacheClient,
+		audit:           audit,
+	}
+}
+
+// recordMovement creates the transaction row for a movement from one account to
+// another and posts its balanced journal entry within tx.
+func recordMovement(ctx context.Context, tx *sql.Tx, transactionRepo repository.ITransactionRepository, ledgerRepo repository.ILedgerRepository, kind string, from, to *model.Account, amount model.Money, description string) (*model.Transaction, error) {
+	transaction := &model.Transaction{
+		Kind:          kind,
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        amount,
+	}
+	if err := transactionRepo.CreateTransaction(ctx, tx, transaction); err != nil {
+		return nil, fmt.Errorf("could not create transaction record: %w", err)
+	}
+
+	entry := &model.JournalEntry{
+		Kind:          kind,
+		TransactionID: transaction.ID,
+		Description:   description,
+		Postings:      []model.Posting{model.Debit(from.ID, amount), model.Credit(to.ID, amount)},
+	}
+	if err := ledgerRepo.PostEntry(ctx, tx, entry); err != nil {
+		return nil, fmt.Errorf("could not post journal entry: %w", err)
+	}
+	return transaction, nil
+}
+
+// recordFXMovement writes a cross-currency transfer: the sender pays the
+// source amount into the bank's position in the source currency, and the
+// receiver is paid the destination amount out of the bank's position in the
+// target currency. Each currency balances on its own.
+func recordFXMovement(ctx context.Context, tx *sql.Tx, transactionRepo repository.ITransactionRepository, ledgerRepo repository.ILedgerRepository, from, to, fromPosition, toPosition *model.Account, amount model.Money, fx *model.FXDetails, description string) (*model.Transaction, error) {
+	transaction := &model.Transaction{
+		Kind:          model.EntryKindTransfer,
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        amount,
+		FX:            fx,
+	}
+	if err := transactionRepo.CreateTransaction(ctx, tx, transaction); err != nil {
+		return nil, fmt.Errorf("could not create transaction record: %w", err)
+	}
+
+	entry := &model.JournalEntry{
+		Kind:          model.EntryKindTransfer,
+		TransactionID: transaction.ID,
+		Description:   description,
+		Postings: []model.Posting{
+			model.Debit(from.ID, amount),
+			model.Credit(fromPosition.ID, amount),
+			model.Debit(toPosition.ID, fx.DestinationAmount),
+			model.Credit(to.ID, fx.DestinationAmount),
+		},
+	}
+	if err := ledgerRepo.PostEntry(ctx, tx, entry); err != nil {
+		return nil, fmt.Errorf("could not post journal entry: %w", err)
+	}
+	return transaction, nil
+}
+
+// Deposit credits a customer account from the settlement account of its currency.
+// The amount is interpreted in the account's currency. The deposit is recorded
+// in the audit log in the same transaction. It returns the updated account.
+func (s *LedgerService) Deposit(ctx context.Context, accountID int, amount model.Amount) (*model.Account, error) {
+	var account *model.Account
+	err := runInAuditedTx(ctx, s.db, s.audit, func(tx *sql.Tx) (*model.AuditEvent, error) {
+		var event *model.AuditEvent
+		var err error
+		account, event, err = s.deposit(ctx, tx, accountID, amount)
+		return event, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithFields(logrus.Fields{"account_id": accountID, "amount": amount}).Info("Deposit posted to the ledger")
+	return account, nil
+}
+
+// DepositTx is Deposit within tx, for deposits committed together with other
+// changes. It records the deposit in the audit log as its last statement, so
+// tx must be committed right after it.
+func (s *LedgerService) DepositTx(ctx context.Context, tx *sql.Tx, accountID int, amount model.Amount) (*model.Account, error) {
+	account, event, err := s.deposit(ctx, tx, accountID, amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.RecordTx(ctx, tx, event); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// deposit posts a deposit within tx and returns the updated account and the
+// audit event to record.
+func (s *LedgerService) deposit(ctx context.Context, tx *sql.Tx, accountID int, amount model.Amount) (*model.Account, *model.AuditEvent, error) {
+	account, err := s.accountRepo.GetAccountForUpdate(ctx, tx, accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrAccountNotFound
+		}
+		return nil, nil, err
+	}
+	if account.Kind != model.AccountKindCustomer {
+		return nil, nil, ErrAccountNotFound
+	}
+
+	money, err := amount.In(account.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !money.IsPositive() {
+		return nil, nil, ErrInvalidDepositAmount
+	}
+
+	settlement, err := s.accountRepo.GetSystemAccountForUpdate(ctx, tx, model.AccountKindSettlement, account.Currency)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load settlement account: %w", err)
+	}
+
+	transaction, err := recordMovement(ctx, tx, s.transactionRepo, s.ledgerRepo, model.EntryKindDeposit, settlement, account, money, "Deposit")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before := account.Balance
+	account.Balance, err = account.Balance.Add(money)
+	if err != nil {
+		return nil, nil, err
+	}
+	event := newAuditEvent(model.AuditEventDeposit, model.AuditTargetAccount, account.ID,
+		map[string]model.Money{"balance": before},
+		map[string]interface{}{"balance": account.Balance, "amount": money, "transaction_id": transaction.ID})
+	return account, event, nil
+}
+
+// ChargeFee debits a fee from a customer account into the fee income account
+// of its currency. The amount is interpreted in the account's currency. The
+// fee is recorded in the audit log in the same transaction.
+func (s *LedgerService) ChargeFee(ctx context.Context, accountID int, amount model.Amount, description string) (*model.Transaction, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"account_id": accountID,
+		"amount":     amount,
+	})
+
+	var account *model.Account
+	var transaction *model.Transaction
+	err := runInAuditedTx(ctx, s.db, s.audit, func(tx *sql.Tx) (*model.AuditEvent, error) {
+		var err error
+		account, err = s.accountRepo.GetAccountForUpdate(ctx, tx, accountID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrAccountNotFound
+			}
+			return nil, err
+		}
+		if account.Kind != model.AccountKindCustomer {
+			return nil, ErrAccountNotFound
+		}
+
+		money, err := amount.In(account.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if !money.IsPositive() {
+			return nil, ErrInvalidFeeAmount
+		}
+		remaining, err := account.Balance.Sub(money)
+		if err != nil {
+			return nil, err
+		}
+		if remaining.IsNegative() {
+			return nil, ErrInsufficientFunds
+		}
+
+		feeIncome, err := s.accountRepo.GetSystemAccountForUpdate(ctx, tx, model.AccountKindFeeIncome, account.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("could not load fee income account: %w", err)
+		}
+
+		transaction, err = recordMovement(ctx, tx, s.transactionRepo, s.ledgerRepo, model.EntryKindFee, account, feeIncome, money, description)
+		if err != nil {
+			return nil, err
+		}
+		return newAuditEvent(model.AuditEventFee, model.AuditTargetAccount, account.ID,
+			map[string]model.Money{"balance": account.Balance},
+			map[string]interface{}{"balance": remaining, "amount": money, "transaction_id": transaction.ID}), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateAccountCaches(ctx, account)
+	log.Info("Fee posted to the ledger")
+	return transaction, nil
+}
+
+// ReverseTransaction undoes a transfer, deposit or fee by posting the opposite
+// of its journal entry. A transaction can be reversed at most once, and a
+// reversal is refused if it would leave a customer account negative. The
+// reversal is recorded in the audit log in the same transaction.
+func (s *LedgerService) ReverseTransaction(ctx context.Context, transactionID int) (*model.Transaction, error) {
+	log := logger.Log.WithField("transaction_id", transactionID)
+
+	original, err := s.transactionRepo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, err
+	}
+	switch original.Kind {
+	case model.EntryKindTransfer, model.EntryKindDeposit, model.EntryKindFee:
+	default:
+		return nil, ErrTransactionNotReversible
+	}
+
+	var reversal *model.Transaction
+	var from, to *model.Account
+	err = runInAuditedTx(ctx, s.db, s.audit, func(tx *sql.Tx) (*model.AuditEvent, error) {
+		accounts, err := s.accountRepo.GetAccountsForUpdate(ctx, tx, original.ToAccountID, original.FromAccountID)
+		if err != nil {
+			return nil, err
+		}
+		// Money flows back from the original receiver to the original sender.
+		from, to = accounts[original.ToAccountID], accounts[original.FromAccountID]
+		if from == nil || to == nil {
+			return nil, ErrAccountNotFound
+		}
+
+		entry, err := s.ledgerRepo.GetEntryByTransactionID(ctx, tx, original.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not load journal entry: %w", err)
+		}
+		reversed, err := s.ledgerRepo.IsEntryReversed(ctx, tx, entry.ID)
+		if err != nil {
+			return nil, err
+		}
+		if reversed {
+			return nil, ErrTransactionAlreadyReversed
+		}
+
+		// The original receiver gives back what it received, which for a
+		// cross-currency transfer is the destination amount. The FX legs are
+		// unwound at the original rate, recorded in the reversal's direction.
+		returned := original.Amount
+		var fx *model.FXDetails
+		if original.FX != nil {
+			returned = original.FX.DestinationAmount
+			midRate, err := original.FX.MidRate.Inverse()
+			if err != nil {
+				return nil, err
+			}
+			rate, err := original.FX.Rate.Inverse()
+			if err != nil {
+				return nil, err
+			}
+			fx = &model.FXDetails{
+				DestinationAmount: original.Amount,
+				MidRate:           midRate,
+				Rate:              rate,
+				SpreadBps:         original.FX.SpreadBps,
+			}
+			// Lock the position accounts after the customer accounts, as transfers do.
+			if _, err := s.accountRepo.GetSystemAccountsForUpdate(ctx, tx, model.AccountKindFXPosition, original.Amount.Currency, returned.Currency); err != nil {
+				return nil, err
+			}
+		}
+
+		if from.Kind == model.AccountKindCustomer {
+			remaining, err := from.Balance.Sub(returned)
+			if err != nil {
+				return nil, err
+			}
+			if remaining.IsNegative() {
+				return nil, ErrInsufficientFunds
+			}
+		}
+
+		reversal = &model.Transaction{
+			Kind:          model.EntryKindReversal,
+			FromAccountID: from.ID,
+			ToAccountID:   to.ID,
+			Amount:        returned,
+			FX:            fx,
+		}
+		if err := s.transactionRepo.CreateTransaction(ctx, tx, reversal); err != nil {
+			return nil, fmt.Errorf("could not create transaction record: %w", err)
+		}
+
+		reversalEntry := &model.JournalEntry{
+			Kind:            model.EntryKindReversal,
+			TransactionID:   reversal.ID,
+			ReversesEntryID: &entry.ID,
+			Description:     fmt.Sprintf("Reversal of transaction %d", original.ID),
+		}
+		for _, posting := range entry.Postings {
+			reversalEntry.Postings = append(reversalEntry.Postings, model.Posting{
+				AccountID: posting.AccountID,
+				Amount:    posting.Amount.Negate(),
+			})
+		}
+		if err := s.ledgerRepo.PostEntry(ctx, tx, reversalEntry); err != nil {
+			return nil, fmt.Errorf("could not post journal entry: %w", err)
+		}
+		return reversalEvent(original, reversal, from, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateAccountCaches(ctx, from, to)
+	log.WithField("reversal_transaction_id", reversal.ID).Info("Transaction reversed")
+	return reversal, nil
+}
+
+// invalidateAccountCaches drops the cached account lists of the owners of the
+// customer accounts among accounts, after their balances changed. The change
+// has been committed, so this happens even if the client has gone away.
+func (s *LedgerService) invalidateAccountCaches(ctx context.Context, accounts ...*model.Account) {
+	for _, account := range accounts {
+		if account.Kind == model.AccountKindCustomer {
+			s.cacheClient.Del(context.WithoutCancel(ctx), fmt.Sprintf("accounts:%d", account.UserID))
+		}
+	}
+}
+
+// reversalEvent builds the audit event of a reversal, with the balances of
+// both accounts before and after it. from gives back what it received in the
+// original transaction, and to gets back what it paid.
+func reversalEvent(original, reversal *model.Transaction, from, to *model.Account) (*model.AuditEvent, error) {
+	fromBalance, err := from.Balance.Sub(reversal.Amount)
+	if err != nil {
+		return nil, err
+	}
+	toBalance, err := to.Balance.Add(original.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	before := map[string]interface{}{
+		"from_account": map[string]interface{}{"id": from.ID, "balance": from.Balance},
+		"to_account":   map[string]interface{}{"id": to.ID, "balance": to.Balance},
+	}
+	after := map[string]interface{}{
+		"from_account":            map[string]interface{}{"id": from.ID, "balance": fromBalance},
+		"to_account":              map[string]interface{}{"id": to.ID, "balance": toBalance},
+		"amount":                  reversal.Amount,
+		"reverses_transaction_id": original.ID,
+	}
+	return newAuditEvent(model.AuditEventReversal, model.AuditTargetTransaction, reversal.ID, before, after), nil
+}
+
+// VerifyLedger checks that every account balance equals the sum of its postings
+// and that every journal entry is balanced.
+func (s *LedgerService) VerifyLedger(ctx context.Context) (*model.LedgerReport, error) {
+	mismatches, err := s.ledgerRepo.GetBalanceMismatches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unbalanced, err := s.ledgerRepo.GetUnbalancedEntryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.LedgerReport{
+		CheckedAt:         time.Now(),
+		Healthy:           len(mismatches) == 0 && len(unbalanced) == 0,
+		BalanceMismatches: mismatches,
+		UnbalancedEntries: unbalanced,
+	}
+	if !report.Healthy {
+		logger.Log.WithFields(logrus.Fields{
+			"balance_mismatches": len(mismatches),
+			"unbalanced_entries": len(unbalanced),
+		}).Error("Ledger verification found inconsistencies")
+	}
+	return report, nil
+}
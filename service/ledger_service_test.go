@@ -0,0 +1,210 @@
+// file: service/ledger_service_test.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"go-bank-api/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLedgerService_Deposit(t *testing.T) {
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockAccountRepo := new(MockAccountRepository)
+	mockTxnRepo := new(MockTransactionRepository)
+	mockLedgerRepo := new(MockLedgerRepository)
+	audit := new(auditSink)
+	ledgerService := NewLedgerService(db, mockAccountRepo, mockTxnRepo, mockLedgerRepo, nil, audit)
+
+	ctx := context.Background()
+	accountID := 1
+	settlement := &model.Account{ID: 100, Balance: model.NewMoney(0, "TRY"), Currency: "TRY", Kind: model.AccountKindSettlement}
+	newAccount := func() *model.Account {
+		return &model.Account{ID: accountID, UserID: 5, Balance: model.NewMoney(2500, "TRY"), Currency: "TRY", Kind: model.AccountKindCustomer}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		amount := model.NewMoney(10000, "TRY")
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, accountID).Return(newAccount(), nil).Once()
+		mockAccountRepo.On("GetSystemAccountForUpdate", mock.Anything, model.AccountKindSettlement, "TRY").Return(settlement, nil).Once()
+		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(tr *model.Transaction) bool {
+			return tr.Kind == model.EntryKindDeposit && tr.FromAccountID == settlement.ID && tr.ToAccountID == accountID
+		})).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, isBalancedEntry(model.EntryKindDeposit, settlement.ID, accountID, amount)).Return(nil).Once()
+		dbMock.ExpectCommit()
+
+		account, err := ledgerService.Deposit(ctx, accountID, "100")
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.NewMoney(12500, "TRY"), account.Balance)
+		if assert.Len(t, audit.events, 1, "The deposit is audited in its transaction") {
+			assert.Equal(t, model.AuditEventDeposit, audit.events[0].Event)
+			assert.Equal(t, "1", audit.events[0].TargetID)
+		}
+		mockAccountRepo.AssertExpectations(t)
+		mockTxnRepo.AssertExpectations(t)
+		mockLedgerRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("negative amount", func(t *testing.T) {
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, accountID).Return(newAccount(), nil).Once()
+		dbMock.ExpectRollback()
+
+		_, err := ledgerService.Deposit(ctx, accountID, "-50.0")
+
+		assert.Error(t, err)
+		assert.Equal(t, "deposit amount must be positive", err.Error())
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("excess precision", func(t *testing.T) {
+		yenAccount := &model.Account{ID: 4, UserID: 7, Balance: model.NewMoney(0, "JPY"), Currency: "JPY", Kind: model.AccountKindCustomer}
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, yenAccount.ID).Return(yenAccount, nil).Once()
+		dbMock.ExpectRollback()
+
+		_, err := ledgerService.Deposit(ctx, yenAccount.ID, "10.5")
+
+		assert.Equal(t, model.ErrExcessPrecision, err)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("posting error", func(t *testing.T) {
+		expectedError := errors.New("db error")
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, accountID).Return(newAccount(), nil).Once()
+		mockAccountRepo.On("GetSystemAccountForUpdate", mock.Anything, model.AccountKindSettlement, "TRY").Return(settlement, nil).Once()
+		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, mock.Anything).Return(expectedError).Once()
+		dbMock.ExpectRollback()
+
+		_, err := ledgerService.Deposit(ctx, accountID, "200.00")
+
+		assert.ErrorIs(t, err, expectedError)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+}
+
+func TestLedgerService_ChargeFee(t *testing.T) {
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockAccountRepo := new(MockAccountRepository)
+	mockTxnRepo := new(MockTransactionRepository)
+	mockLedgerRepo := new(MockLedgerRepository)
+	audit := new(auditSink)
+	mockCache := new(mockCacheClient)
+	ledgerService := NewLedgerService(db, mockAccountRepo, mockTxnRepo, mockLedgerRepo, mockCache, audit)
+
+	account := &model.Account{ID: 1, UserID: 5, Balance: model.NewMoney(2500, "TRY"), Currency: "TRY", Kind: model.AccountKindCustomer}
+	feeIncome := &model.Account{ID: 101, Balance: model.NewMoney(0, "TRY"), Currency: "TRY", Kind: model.AccountKindFeeIncome}
+	fee := model.NewMoney(500, "TRY")
+
+	dbMock.ExpectBegin()
+	mockAccountRepo.On("GetAccountForUpdate", mock.Anything, account.ID).Return(account, nil).Once()
+	mockAccountRepo.On("GetSystemAccountForUpdate", mock.Anything, model.AccountKindFeeIncome, "TRY").Return(feeIncome, nil).Once()
+	mockTxnRepo.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil).Once()
+	mockLedgerRepo.On("PostEntry", mock.Anything, isBalancedEntry(model.EntryKindFee, account.ID, feeIncome.ID, fee)).Return(nil).Once()
+	dbMock.ExpectCommit()
+	mockCache.On("Del", mock.Anything, "accounts:5").Return().Once()
+
+	_, err = ledgerService.ChargeFee(context.Background(), account.ID, "5", "Monthly fee")
+
+	assert.NoError(t, err)
+	if assert.Len(t, audit.events, 1, "The fee is audited in its transaction") {
+		assert.Equal(t, model.AuditEventFee, audit.events[0].Event)
+		assert.Equal(t, "1", audit.events[0].TargetID)
+	}
+	mockLedgerRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestLedgerService_ReverseTransaction(t *testing.T) {
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockAccountRepo := new(MockAccountRepository)
+	mockTxnRepo := new(MockTransactionRepository)
+	mockLedgerRepo := new(MockLedgerRepository)
+	audit := new(auditSink)
+	mockCache := new(mockCacheClient)
+	ledgerService := NewLedgerService(db, mockAccountRepo, mockTxnRepo, mockLedgerRepo, mockCache, audit)
+
+	ctx := context.Background()
+	amount := model.NewMoney(3000, "EUR")
+	original := &model.Transaction{ID: 10, Kind: model.EntryKindTransfer, FromAccountID: 1, ToAccountID: 2, Amount: amount}
+	sender := &model.Account{ID: 1, UserID: 1, Balance: model.NewMoney(0, "EUR"), Currency: "EUR", Kind: model.AccountKindCustomer}
+	receiver := &model.Account{ID: 2, UserID: 2, Balance: model.NewMoney(3000, "EUR"), Currency: "EUR", Kind: model.AccountKindCustomer}
+	entry := &model.JournalEntry{ID: 20, Kind: model.EntryKindTransfer, TransactionID: original.ID, Postings: []model.Posting{
+		model.Debit(sender.ID, amount),
+		model.Credit(receiver.ID, amount),
+	}}
+
+	t.Run("success", func(t *testing.T) {
+		mockTxnRepo.On("GetTransactionByID", original.ID).Return(original, nil).Once()
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{receiver.ID, sender.ID}).Return(accountsByID(receiver, sender), nil).Once()
+		mockLedgerRepo.On("GetEntryByTransactionID", mock.Anything, original.ID).Return(entry, nil).Once()
+		mockLedgerRepo.On("IsEntryReversed", mock.Anything, entry.ID).Return(false, nil).Once()
+		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.Kind == model.EntryKindReversal && *e.ReversesEntryID == entry.ID &&
+				e.Postings[0] == model.Credit(sender.ID, amount) && e.Postings[1] == model.Debit(receiver.ID, amount)
+		})).Return(nil).Once()
+		dbMock.ExpectCommit()
+		mockCache.On("Del", mock.Anything, "accounts:2").Return().Once()
+		mockCache.On("Del", mock.Anything, "accounts:1").Return().Once()
+
+		reversal, err := ledgerService.ReverseTransaction(ctx, original.ID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, receiver.ID, reversal.FromAccountID)
+		assert.Equal(t, sender.ID, reversal.ToAccountID)
+		if assert.Len(t, audit.events, 1, "The reversal is audited in its transaction") {
+			assert.Equal(t, model.AuditEventReversal, audit.events[0].Event)
+			assert.Equal(t, model.AuditTargetTransaction, audit.events[0].TargetType)
+		}
+		mockLedgerRepo.AssertExpectations(t)
+		mockCache.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("already reversed", func(t *testing.T) {
+		mockTxnRepo.On("GetTransactionByID", original.ID).Return(original, nil).Once()
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{receiver.ID, sender.ID}).Return(accountsByID(receiver, sender), nil).Once()
+		mockLedgerRepo.On("GetEntryByTransactionID", mock.Anything, original.ID).Return(entry, nil).Once()
+		mockLedgerRepo.On("IsEntryReversed", mock.Anything, entry.ID).Return(true, nil).Once()
+		dbMock.ExpectRollback()
+
+		_, err := ledgerService.ReverseTransaction(ctx, original.ID)
+
+		assert.Equal(t, ErrTransactionAlreadyReversed, err)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("reversal of a reversal", func(t *testing.T) {
+		mockTxnRepo.On("GetTransactionByID", 11).Return(&model.Transaction{ID: 11, Kind: model.EntryKindReversal}, nil).Once()
+
+		_, err := ledgerService.ReverseTransaction(ctx, 11)
+
+		assert.Equal(t, ErrTransactionNotReversible, err)
+	})
+}
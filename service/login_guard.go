@@ -0,0 +1,231 @@
+// file: service/login_guard.go
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// LoginThrottledError is returned for a login attempt refused without checking
+// the password, because the email address or the IP address failed too often.
+type LoginThrottledError struct {
+	// Locked is set when the email address is locked out, rather than waiting
+	// out the delay after its last failed attempt.
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "account temporarily locked after too many failed login attempts"
+	}
+	return "too many failed login attempts; please wait before trying again"
+}
+
+// IThrottleClient is the subset of the Redis client the login guard uses.
+type IThrottleClient interface {
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// ILoginGuard protects password logins against brute force. AuthService
+// consults it before checking a password, so refused attempts cost no hashing.
+type ILoginGuard interface {
+	Check(ctx context.Context, email, ipAddress string) error
+	RecordFailure(ctx context.Context, email string, userID int, device model.DeviceInfo) error
+	RecordSuccess(ctx context.Context, email string) error
+	Unlock(ctx context.Context, actorID int, user *model.User) error
+}
+
+// LoginPolicy sets the limits the login guard enforces; see config.Login.
+type LoginPolicy struct {
+	MaxFailures      int
+	DelayAfter       int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutDuration  time.Duration
+	FailureWindow    time.Duration
+	MaxFailuresPerIP int
+}
+
+// delay returns how long to wait after the given number of consecutive
+// failures: nothing up to DelayAfter, then BaseDelay doubling up to MaxDelay.
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if failures <= p.DelayAfter {
+		return 0
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(failures-p.DelayAfter-1))
+	if delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// LoginGuard counts failed logins in Redis, per email address and per IP
+// address, and refuses attempts while an address has to wait or is locked
+// out. Counting by email address rather than by user also throttles guesses
+// at addresses without an account, and does not reveal which have one.
+// Failures, lockouts and unlocks are recorded in the login audit trail.
+type LoginGuard struct {
+	client IThrottleClient
+	events repository.ILoginEventRepository
+	policy LoginPolicy
+}
+
+// NewLoginGuard creates a new LoginGuard.
+func NewLoginGuard(client IThrottleClient, events repository.ILoginEventRepository, policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{client: client, events: events, policy: policy}
+}
+
+// NewConfiguredLoginGuard creates a LoginGuard with the policy from the login configuration.
+func NewConfiguredLoginGuard(client IThrottleClient, events repository.ILoginEventRepository) *LoginGuard {
+	cfg := config.AppConfig.Login
+	return NewLoginGuard(client, events, LoginPolicy{
+		MaxFailures:      cfg.MaxFailures,
+		DelayAfter:       cfg.DelayAfter,
+		BaseDelay:        cfg.BaseDelay,
+		MaxDelay:         cfg.MaxDelay,
+		LockoutDuration:  cfg.LockoutDuration,
+		FailureWindow:    cfg.FailureWindow,
+		MaxFailuresPerIP: cfg.MaxFailuresPerIP,
+	})
+}
+
+func normalizeEmail(email string) string { return strings.ToLower(strings.TrimSpace(email)) }
+
+func loginFailuresKey(email string) string { return "login:failures:" + normalizeEmail(email) }
+func loginDelayKey(email string) string    { return "login:delay:" + normalizeEmail(email) }
+func loginLockKey(email string) string     { return "login:lock:" + normalizeEmail(email) }
+
+// loginIPFailuresKey counts an IP address's failures in fixed windows, so the
+// end of the current window is known without asking Redis.
+func (g *LoginGuard) loginIPFailuresKey(ipAddress string, now time.Time) (string, time.Time) {
+	window := now.UnixNano() / int64(g.policy.FailureWindow)
+	end := time.Unix(0, (window+1)*int64(g.policy.FailureWindow))
+	return "login:failures:ip:" + ipAddress + ":" + strconv.FormatInt(window, 10), end
+}
+
+// untilValue parses a deadline stored as Unix milliseconds.
+func untilValue(value interface{}) time.Time {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// Check refuses the attempt with a *LoginThrottledError if the email address
+// is locked out or waiting out a delay, or the IP address failed too often.
+func (g *LoginGuard) Check(ctx context.Context, email, ipAddress string) error {
+	now := time.Now()
+	ipKey, windowEnd := g.loginIPFailuresKey(ipAddress, now)
+	values, err := g.client.MGet(ctx, loginLockKey(email), loginDelayKey(email), ipKey).Result()
+	if err != nil {
+		return fmt.Errorf("could not check failed logins: %w", err)
+	}
+
+	if until := untilValue(values[0]); until.After(now) {
+		return &LoginThrottledError{Locked: true, RetryAfter: until.Sub(now)}
+	}
+	if until := untilValue(values[1]); until.After(now) {
+		return &LoginThrottledError{RetryAfter: until.Sub(now)}
+	}
+	if ipAddress != "" && g.policy.MaxFailuresPerIP > 0 {
+		if s, ok := values[2].(string); ok {
+			if failures, _ := strconv.Atoi(s); failures >= g.policy.MaxFailuresPerIP {
+				return &LoginThrottledError{RetryAfter: windowEnd.Sub(now)}
+			}
+		}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login. It returns a *LoginThrottledError if
+// the failure locked the email address out.
+func (g *LoginGuard) RecordFailure(ctx context.Context, email string, userID int, device model.DeviceInfo) error {
+	now := time.Now()
+	log := logger.Log.WithFields(logrus.Fields{"email": email, "ip_address": device.IPAddress})
+	g.recordEvent(ctx, &model.LoginEvent{UserID: userID, Email: email, Event: model.LoginEventFailed,
+		IPAddress: device.IPAddress, UserAgent: device.UserAgent})
+
+	if device.IPAddress != "" {
+		ipKey, windowEnd := g.loginIPFailuresKey(device.IPAddress, now)
+		if err := g.client.Incr(ctx, ipKey).Err(); err != nil {
+			return fmt.Errorf("could not count failed login: %w", err)
+		}
+		g.client.Expire(ctx, ipKey, windowEnd.Sub(now))
+	}
+
+	key := loginFailuresKey(email)
+	failures, err := g.client.Incr(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("could not count failed login: %w", err)
+	}
+	g.client.Expire(ctx, key, g.policy.FailureWindow)
+
+	if g.policy.MaxFailures > 0 && int(failures) >= g.policy.MaxFailures {
+		until := now.Add(g.policy.LockoutDuration)
+		if err := g.client.Set(ctx, loginLockKey(email), until.UnixMilli(), g.policy.LockoutDuration).Err(); err != nil {
+			return fmt.Errorf("could not lock account: %w", err)
+		}
+		g.client.Del(ctx, key, loginDelayKey(email))
+		log.WithField("failures", failures).Warn("Too many failed logins, account locked")
+		g.recordEvent(ctx, &model.LoginEvent{UserID: userID, Email: email, Event: model.LoginEventLocked,
+			IPAddress: device.IPAddress, UserAgent: device.UserAgent})
+		return &LoginThrottledError{Locked: true, RetryAfter: g.policy.LockoutDuration}
+	}
+
+	if delay := g.policy.delay(int(failures)); delay > 0 {
+		if err := g.client.Set(ctx, loginDelayKey(email), now.Add(delay).UnixMilli(), delay).Err(); err != nil {
+			return fmt.Errorf("could not delay next login: %w", err)
+		}
+		log.WithFields(logrus.Fields{"failures": failures, "delay": delay}).Info("Failed login, delaying next attempt")
+	}
+	return nil
+}
+
+// RecordSuccess forgets the failed logins of the email address.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	if err := g.client.Del(ctx, loginFailuresKey(email), loginDelayKey(email)).Err(); err != nil {
+		return fmt.Errorf("could not reset failed logins: %w", err)
+	}
+	return nil
+}
+
+// Unlock lifts a lockout of the user's email address and forgets its failed
+// logins.
+func (g *LoginGuard) Unlock(ctx context.Context, actorID int, user *model.User) error {
+	if err := g.client.Del(ctx, loginLockKey(user.Email), loginFailuresKey(user.Email), loginDelayKey(user.Email)).Err(); err != nil {
+		return fmt.Errorf("could not unlock account: %w", err)
+	}
+	logger.Log.WithFields(logrus.Fields{"user_id": user.ID, "admin_id": actorID}).Info("Account unlocked")
+	g.recordEvent(ctx, &model.LoginEvent{UserID: user.ID, Email: user.Email, Event: model.LoginEventUnlocked, ActorID: actorID})
+	return nil
+}
+
+// recordEvent appends to the login audit trail. A failure to record is logged
+// but does not fail the login.
+func (g *LoginGuard) recordEvent(ctx context.Context, event *model.LoginEvent) {
+	if err := g.events.Create(ctx, event); err != nil {
+		logger.Log.WithError(err).WithField("event", event.Event).Error("Failed to record login event")
+	}
+}
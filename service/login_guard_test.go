@@ -0,0 +1,174 @@
+// file: service/login_guard_test.go
+
+package service
+
+import (
+	"context"
+	"go-bank-api/model"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockThrottleClient provides a mock for IThrottleClient.
+type mockThrottleClient struct{ mock.Mock }
+
+func (m *mockThrottleClient) MGet(_ context.Context, keys ...string) *redis.SliceCmd {
+	args := m.Called(keys)
+	return redis.NewSliceResult(args.Get(0).([]interface{}), args.Error(1))
+}
+func (m *mockThrottleClient) Incr(_ context.Context, key string) *redis.IntCmd {
+	args := m.Called(key)
+	return redis.NewIntResult(int64(args.Int(0)), args.Error(1))
+}
+func (m *mockThrottleClient) Expire(_ context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	m.Called(key, expiration)
+	return redis.NewBoolResult(true, nil)
+}
+func (m *mockThrottleClient) Set(_ context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	args := m.Called(key, value, expiration)
+	return redis.NewStatusResult("OK", args.Error(0))
+}
+func (m *mockThrottleClient) Del(_ context.Context, keys ...string) *redis.IntCmd {
+	args := m.Called(keys)
+	return redis.NewIntResult(int64(len(keys)), args.Error(0))
+}
+
+// mockLoginEventRepo provides a mock for ILoginEventRepository.
+type mockLoginEventRepo struct{ mock.Mock }
+
+func (m *mockLoginEventRepo) Create(_ context.Context, event *model.LoginEvent) error {
+	return m.Called(event).Error(0)
+}
+
+var testLoginPolicy = LoginPolicy{
+	MaxFailures:      5,
+	DelayAfter:       2,
+	BaseDelay:        time.Second,
+	MaxDelay:         4 * time.Second,
+	LockoutDuration:  15 * time.Minute,
+	FailureWindow:    15 * time.Minute,
+	MaxFailuresPerIP: 20,
+}
+
+func eventOfKind(kind string) interface{} {
+	return mock.MatchedBy(func(e *model.LoginEvent) bool { return e.Event == kind })
+}
+
+func TestLoginPolicy_Delay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), testLoginPolicy.delay(2))
+	assert.Equal(t, time.Second, testLoginPolicy.delay(3))
+	assert.Equal(t, 2*time.Second, testLoginPolicy.delay(4))
+	assert.Equal(t, 4*time.Second, testLoginPolicy.delay(5))
+	assert.Equal(t, 4*time.Second, testLoginPolicy.delay(9), "Delays are capped")
+}
+
+func TestLoginGuard_Check(t *testing.T) {
+	ctx := context.Background()
+	future := strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10)
+
+	t.Run("locked account", func(t *testing.T) {
+		client := new(mockThrottleClient)
+		client.On("MGet", mock.Anything).Return([]interface{}{future, nil, nil}, nil).Once()
+
+		err := NewLoginGuard(client, nil, testLoginPolicy).Check(ctx, "User@Test.com", "10.0.0.1")
+
+		throttled, ok := err.(*LoginThrottledError)
+		if assert.True(t, ok) {
+			assert.True(t, throttled.Locked)
+			assert.InDelta(t, time.Minute.Seconds(), throttled.RetryAfter.Seconds(), 1)
+		}
+		keys := client.Calls[0].Arguments.Get(0).([]string)
+		assert.Equal(t, "login:lock:user@test.com", keys[0], "Email addresses are compared ignoring case")
+	})
+
+	t.Run("waiting out a delay", func(t *testing.T) {
+		client := new(mockThrottleClient)
+		client.On("MGet", mock.Anything).Return([]interface{}{nil, future, nil}, nil).Once()
+
+		err := NewLoginGuard(client, nil, testLoginPolicy).Check(ctx, "user@test.com", "10.0.0.1")
+
+		throttled, ok := err.(*LoginThrottledError)
+		if assert.True(t, ok) {
+			assert.False(t, throttled.Locked)
+		}
+	})
+
+	t.Run("IP address over its limit", func(t *testing.T) {
+		client := new(mockThrottleClient)
+		client.On("MGet", mock.Anything).Return([]interface{}{nil, nil, "20"}, nil).Once()
+
+		err := NewLoginGuard(client, nil, testLoginPolicy).Check(ctx, "other@test.com", "10.0.0.1")
+
+		throttled, ok := err.(*LoginThrottledError)
+		if assert.True(t, ok) {
+			assert.LessOrEqual(t, throttled.RetryAfter, testLoginPolicy.FailureWindow)
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		client := new(mockThrottleClient)
+		client.On("MGet", mock.Anything).Return([]interface{}{nil, nil, "19"}, nil).Once()
+
+		assert.NoError(t, NewLoginGuard(client, nil, testLoginPolicy).Check(ctx, "user@test.com", "10.0.0.1"))
+	})
+}
+
+func TestLoginGuard_RecordFailure(t *testing.T) {
+	ctx := context.Background()
+	device := model.DeviceInfo{IPAddress: "10.0.0.1", UserAgent: "test-agent"}
+
+	t.Run("failures beyond the free attempts delay the next one", func(t *testing.T) {
+		client, events := new(mockThrottleClient), new(mockLoginEventRepo)
+		events.On("Create", eventOfKind(model.LoginEventFailed)).Return(nil).Once()
+		client.On("Incr", mock.MatchedBy(func(key string) bool { return key != "login:failures:user@test.com" })).Return(1, nil).Once()
+		client.On("Incr", "login:failures:user@test.com").Return(4, nil).Once()
+		client.On("Expire", mock.Anything, mock.Anything).Return()
+		client.On("Set", "login:delay:user@test.com", mock.Anything, 2*time.Second).Return(nil).Once()
+
+		err := NewLoginGuard(client, events, testLoginPolicy).RecordFailure(ctx, "user@test.com", 7, device)
+
+		assert.NoError(t, err)
+		client.AssertExpectations(t)
+		events.AssertExpectations(t)
+	})
+
+	t.Run("too many failures lock the account", func(t *testing.T) {
+		client, events := new(mockThrottleClient), new(mockLoginEventRepo)
+		events.On("Create", eventOfKind(model.LoginEventFailed)).Return(nil).Once()
+		events.On("Create", eventOfKind(model.LoginEventLocked)).Return(nil).Once()
+		client.On("Incr", mock.MatchedBy(func(key string) bool { return key != "login:failures:user@test.com" })).Return(1, nil).Once()
+		client.On("Incr", "login:failures:user@test.com").Return(5, nil).Once()
+		client.On("Expire", mock.Anything, mock.Anything).Return()
+		client.On("Set", "login:lock:user@test.com", mock.Anything, testLoginPolicy.LockoutDuration).Return(nil).Once()
+		client.On("Del", []string{"login:failures:user@test.com", "login:delay:user@test.com"}).Return(nil).Once()
+
+		err := NewLoginGuard(client, events, testLoginPolicy).RecordFailure(ctx, "user@test.com", 7, device)
+
+		throttled, ok := err.(*LoginThrottledError)
+		if assert.True(t, ok) {
+			assert.True(t, throttled.Locked)
+			assert.Equal(t, testLoginPolicy.LockoutDuration, throttled.RetryAfter)
+		}
+		client.AssertExpectations(t)
+		events.AssertExpectations(t)
+	})
+}
+
+func TestLoginGuard_Unlock(t *testing.T) {
+	client, events := new(mockThrottleClient), new(mockLoginEventRepo)
+	client.On("Del", []string{"login:lock:user@test.com", "login:failures:user@test.com", "login:delay:user@test.com"}).Return(nil).Once()
+	events.On("Create", mock.MatchedBy(func(e *model.LoginEvent) bool {
+		return e.Event == model.LoginEventUnlocked && e.UserID == 7 && e.ActorID == 1
+	})).Return(nil).Once()
+
+	err := NewLoginGuard(client, events, testLoginPolicy).Unlock(context.Background(), 1, &model.User{ID: 7, Email: "user@test.com"})
+
+	assert.NoError(t, err)
+	client.AssertExpectations(t)
+	events.AssertExpectations(t)
+}
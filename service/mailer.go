@@ -0,0 +1,185 @@
+// file: service/mailer.go
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MailMessage is a plain-text email to a single recipient.
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email. SMTPMailer delivers it; LogMailer keeps it locally for
+// development.
+type Mailer interface {
+	Send(ctx context.Context, msg MailMessage) error
+}
+
+// NewConfiguredMailer builds the mailer selected in the mail configuration:
+// the log mailer by default, or the SMTP mailer.
+func NewConfiguredMailer() (Mailer, error) {
+	cfg := config.AppConfig.Mail
+	switch cfg.Driver {
+	case "", "log":
+		return NewLogMailer(cfg.From, cfg.Dir), nil
+	case "smtp":
+		if cfg.SMTP.Host == "" {
+			return nil, fmt.Errorf("mail.smtp.host is required for the smtp mail driver")
+		}
+		return NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// headerValue strips line breaks, so that a value cannot add headers of its own.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// compose renders the message in RFC 5322 format.
+func (msg MailMessage) compose(from string, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(msg.Subject)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+// SMTPMailer sends email through an SMTP server, upgrading the connection with
+// STARTTLS when the server offers it. Credentials are only sent over TLS.
+type SMTPMailer struct {
+	host     string
+	addr     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates a new SMTPMailer. Without a username no
+// authentication is attempted.
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		addr:     net.JoinHostPort(host, port),
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send delivers the message. The context bounds connecting and, through the
+// connection deadline, the whole SMTP conversation.
+func (m *SMTPMailer) Send(ctx context.Context, msg MailMessage) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("could not connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("could not start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("could not start TLS with SMTP server: %w", err)
+		}
+	}
+	if m.username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection
+		// to anything but localhost.
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("could not authenticate with SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.from); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("SMTP server rejected recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("could not send message: %w", err)
+	}
+	if _, err := w.Write(msg.compose(m.from, time.Now())); err != nil {
+		w.Close()
+		return fmt.Errorf("could not send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("could not send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// LogMailer is a mailer for local development. It writes each message to a
+// .eml file in its directory, or to the log when it has none, instead of
+// delivering it. Messages contain live links, so it must not be used in
+// production.
+type LogMailer struct {
+	from string
+	dir  string
+}
+
+// NewLogMailer creates a new LogMailer.
+func NewLogMailer(from, dir string) *LogMailer {
+	return &LogMailer{from: from, dir: dir}
+}
+
+// Send writes the message to the mailer's directory or to the log.
+func (m *LogMailer) Send(_ context.Context, msg MailMessage) error {
+	now := time.Now()
+	data := msg.compose(m.from, now)
+	log := logger.Log.WithFields(logrus.Fields{"to": msg.To, "subject": msg.Subject})
+
+	if m.dir == "" {
+		log.WithField("body", msg.Body).Info("Email not sent, logged instead")
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("could not create mail directory: %w", err)
+	}
+	random, _, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), random[:8])
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("could not write email: %w", err)
+	}
+	log.WithField("path", path).Info("Email not sent, written to file instead")
+	return nil
+}
@@ -0,0 +1,50 @@
+// file: service/mailer_test.go
+
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailMessage_Compose(t *testing.T) {
+	msg := MailMessage{
+		To:      "user@test.com\r\nBcc: attacker@test.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	}
+
+	data := string(msg.compose("no-reply@test.com", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	headers, body, ok := strings.Cut(data, "\r\n\r\n")
+	assert.True(t, ok)
+	assert.Contains(t, headers, "From: no-reply@test.com\r\n")
+	assert.Contains(t, headers, "To: user@test.comBcc: attacker@test.com\r\n", "Line breaks cannot add headers")
+	assert.NotContains(t, headers, "\r\nBcc:")
+	assert.Contains(t, headers, "Subject: Reset your password\r\n")
+	assert.Contains(t, headers, "Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n")
+	assert.Equal(t, "line one\r\nline two", body)
+}
+
+func TestLogMailer_WritesMessageToFile(t *testing.T) {
+	dir := t.TempDir()
+	mailer := NewLogMailer("no-reply@test.com", dir)
+
+	err := mailer.Send(context.Background(), MailMessage{To: "user@test.com", Subject: "Hello", Body: "Hi there"})
+
+	assert.NoError(t, err)
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		data, err := os.ReadFile(files[0])
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "To: user@test.com\r\n")
+		assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nHi there"))
+	}
+}
@@ -0,0 +1,370 @@
+// file: service/mfa_service.go
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled      = errors.New("no authenticator enrollment in progress")
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired MFA token; please log in again")
+	ErrTooManyMFAAttempts  = errors.New("too many invalid codes; please log in again")
+)
+
+const (
+	// mfaChallengeTTL is how long a user has to enter a code after the password.
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts is how many invalid codes a challenge tolerates before it
+	// is discarded and the password has to be entered again.
+	maxMFAAttempts = 5
+	// recoveryCodeCount is how many recovery codes are issued on enrollment.
+	recoveryCodeCount = 10
+)
+
+// mfaChallenge is the pending second step of a login, kept in the cache under
+// the hash of the challenge token handed to the client. Invalid codes are
+// counted under the same key with an ":attempts" suffix.
+type mfaChallenge struct {
+	UserID int              `json:"user_id"`
+	Device model.DeviceInfo `json:"device"`
+}
+
+// IChallengeClient is the part of the Redis client MFA challenges are kept
+// with. Counting attempts and consuming a challenge are single commands, so
+// concurrent requests cannot share an attempt or complete a challenge twice.
+type IChallengeClient interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	GetDel(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// MFAService manages TOTP two-factor authentication: enrollment, recovery codes
+// and the challenges of logins waiting for a code. TOTP secrets are stored
+// encrypted with secrets.
+type MFAService struct {
+	db          *sql.DB
+	mfaRepo     repository.IMFARepository
+	userRepo    repository.IUserRepository
+	cacheClient IChallengeClient
+	secrets     *SecretBox
+}
+
+// NewMFAService creates a new MFAService.
+func NewMFAService(db *sql.DB, mfaRepo repository.IMFARepository, userRepo repository.IUserRepository, cacheClient IChallengeClient, secrets *SecretBox) *MFAService {
+	return &MFAService{
+		db:          db,
+		mfaRepo:     mfaRepo,
+		userRepo:    userRepo,
+		cacheClient: cacheClient,
+		secrets:     secrets,
+	}
+}
+
+// totpSecretLabel binds a sealed TOTP secret to the user it belongs to.
+func totpSecretLabel(userID int) string {
+	return fmt.Sprintf("mfa_totp:%d", userID)
+}
+
+// getTOTP retrieves the user's authenticator with its secret decrypted. A
+// secret stored before secrets were encrypted is encrypted now.
+func (s *MFAService) getTOTP(ctx context.Context, userID int) (*model.TOTPCredential, error) {
+	stored, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	credential := *stored
+	credential.Secret, err = s.secrets.Open(stored.Secret, totpSecretLabel(userID))
+	if err == ErrSecretNotSealed {
+		s.sealStoredSecret(ctx, userID, stored.Secret)
+	} else if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// sealStoredSecret replaces a TOTP secret stored in plaintext with the
+// encrypted secret. Failures are logged; the secret is sealed on a later read.
+func (s *MFAService) sealStoredSecret(ctx context.Context, userID int, secret string) {
+	log := logger.Log.WithField("user_id", userID)
+	sealed, err := s.secrets.Seal(secret, totpSecretLabel(userID))
+	if err == nil {
+		err = s.mfaRepo.ReplaceTOTPSecret(ctx, userID, secret, sealed)
+	}
+	switch err {
+	case nil:
+		log.Info("Stored TOTP secret encrypted")
+	case sql.ErrNoRows:
+		// The secret was replaced meanwhile, by a new enrollment.
+	default:
+		log.WithError(err).Error("Failed to encrypt stored TOTP secret")
+	}
+}
+
+func mfaChallengeKey(challenge string) string {
+	hash := sha256.Sum256([]byte(challenge))
+	return "mfa_challenge:" + base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// hashRecoveryCode hashes a recovery code as entered, ignoring case, spaces
+// and dashes.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return base64.URLEncoding.EncodeToString(hash[:])
+}
+
+// generateRecoveryCodes returns new recovery codes such as "k7f2q-9xw4m".
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, 7)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(random))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// EnrollTOTP starts the enrollment of an authenticator app. Two-factor
+// authentication is not enforced until the enrollment is confirmed with a code.
+func (s *MFAService) EnrollTOTP(ctx context.Context, userID int) (*model.TOTPEnrollment, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.secrets.Seal(secret, totpSecretLabel(userID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SavePendingTOTP(ctx, userID, sealed); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	logger.Log.WithField("user_id", userID).Info("TOTP enrollment started")
+	return &model.TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: totpURI(config.AppConfig.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP turns on two-factor authentication once the user proves the
+// authenticator works by entering a code from it, and issues recovery codes.
+// The codes are returned only this once; just their hashes are stored.
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID int, code string) (*model.RecoveryCodes, error) {
+	credential, err := s.getTOTP(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	if credential.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	step, ok := matchTOTP(credential.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	err = runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.mfaRepo.ConfirmTOTP(ctx, tx, userID, step); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrMFAAlreadyEnabled
+			}
+			return err
+		}
+		return s.mfaRepo.ReplaceRecoveryCodes(ctx, tx, userID, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithField("user_id", userID).Info("Two-factor authentication enabled")
+	return &model.RecoveryCodes{Codes: codes}, nil
+}
+
+// IsEnabled reports whether the user has a confirmed authenticator.
+func (s *MFAService) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	credential, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return credential.ConfirmedAt != nil, nil
+}
+
+// CreateChallenge records a login that passed the password check and waits
+// for a code, and returns the token the client presents with the code.
+func (s *MFAService) CreateChallenge(ctx context.Context, userID int, device model.DeviceInfo) (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate MFA token: %w", err)
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(random)
+
+	data, err := json.Marshal(mfaChallenge{UserID: userID, Device: device})
+	if err != nil {
+		return "", err
+	}
+	if err := s.cacheClient.Set(ctx, mfaChallengeKey(challenge), data, mfaChallengeTTL).Err(); err != nil {
+		return "", fmt.Errorf("could not store MFA challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// ChallengeUser returns the user a challenge was created for, without counting
+// an attempt, so the login can be checked against the login guard before a
+// code is.
+func (s *MFAService) ChallengeUser(ctx context.Context, challengeToken string) (int, error) {
+	challenge, err := s.loadChallenge(ctx, mfaChallengeKey(challengeToken))
+	if err != nil {
+		return 0, err
+	}
+	return challenge.UserID, nil
+}
+
+// loadChallenge reads the challenge stored under key.
+func (s *MFAService) loadChallenge(ctx context.Context, key string) (*mfaChallenge, error) {
+	data, err := s.cacheClient.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, fmt.Errorf("could not load MFA challenge: %w", err)
+	}
+	var challenge mfaChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return &challenge, nil
+}
+
+// VerifyChallenge checks the code presented for a challenge and returns the
+// user and device of the login it completes. A challenge can be completed
+// once; it is discarded after maxMFAAttempts codes that were not valid.
+func (s *MFAService) VerifyChallenge(ctx context.Context, challengeToken, code string) (int, model.DeviceInfo, error) {
+	key := mfaChallengeKey(challengeToken)
+	challenge, err := s.loadChallenge(ctx, key)
+	if err != nil {
+		return 0, model.DeviceInfo{}, err
+	}
+
+	// The attempt is counted before the code is checked, so concurrent
+	// requests each use up an attempt of their own.
+	attemptsKey := key + ":attempts"
+	attempts, err := s.cacheClient.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		return 0, model.DeviceInfo{}, fmt.Errorf("could not count MFA attempt: %w", err)
+	}
+	if attempts == 1 {
+		s.cacheClient.Expire(ctx, attemptsKey, mfaChallengeTTL)
+	}
+	log := logger.Log.WithFields(logrus.Fields{"user_id": challenge.UserID, "attempts": attempts})
+	if attempts > maxMFAAttempts {
+		s.cacheClient.Del(ctx, key, attemptsKey)
+		return 0, model.DeviceInfo{}, ErrTooManyMFAAttempts
+	}
+
+	if err := s.verifyCode(ctx, challenge.UserID, code); err != nil {
+		if err != ErrInvalidMFACode {
+			return 0, model.DeviceInfo{}, err
+		}
+		if attempts == maxMFAAttempts {
+			log.Warn("Too many invalid two-factor codes, discarding login challenge")
+			s.cacheClient.Del(ctx, key, attemptsKey)
+			return 0, model.DeviceInfo{}, ErrTooManyMFAAttempts
+		}
+		log.Info("Invalid two-factor code presented")
+		return 0, model.DeviceInfo{}, ErrInvalidMFACode
+	}
+
+	// Taking the challenge out of the cache succeeds for one request only, so
+	// it cannot complete two logins.
+	if err := s.cacheClient.GetDel(ctx, key).Err(); err != nil {
+		if err == redis.Nil {
+			return 0, model.DeviceInfo{}, ErrInvalidMFAChallenge
+		}
+		return 0, model.DeviceInfo{}, fmt.Errorf("could not consume MFA challenge: %w", err)
+	}
+	s.cacheClient.Del(ctx, attemptsKey)
+	return challenge.UserID, challenge.Device, nil
+}
+
+// verifyCode accepts either a current code from the user's authenticator or
+// one of their unused recovery codes.
+func (s *MFAService) verifyCode(ctx context.Context, userID int, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		if err := s.mfaRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code)); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+		logger.Log.WithField("user_id", userID).Warn("Recovery code used to log in")
+		return nil
+	}
+
+	credential, err := s.getTOTP(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	step, ok := matchTOTP(credential.Secret, code, time.Now())
+	if !ok || credential.ConfirmedAt == nil || step <= credential.LastUsedStep {
+		return ErrInvalidMFACode
+	}
+	// Recording the step only succeeds once per code, even for concurrent logins.
+	if err := s.mfaRepo.UseTOTPStep(ctx, userID, step); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+// file: service/mfa_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go-bank-api/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockMFARepo provides a mock for IMFARepository.
+type mockMFARepo struct{ mock.Mock }
+
+func (m *mockMFARepo) SavePendingTOTP(_ context.Context, userID int, secret string) error {
+	return m.Called(userID, secret).Error(0)
+}
+func (m *mockMFARepo) GetTOTP(_ context.Context, userID int) (*model.TOTPCredential, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TOTPCredential), args.Error(1)
+}
+func (m *mockMFARepo) ConfirmTOTP(_ context.Context, tx *sql.Tx, userID int, step int64) error {
+	return m.Called(tx, userID, step).Error(0)
+}
+func (m *mockMFARepo) UseTOTPStep(_ context.Context, userID int, step int64) error {
+	return m.Called(userID, step).Error(0)
+}
+func (m *mockMFARepo) ReplaceTOTPSecret(_ context.Context, userID int, oldSecret, newSecret string) error {
+	return m.Called(userID, oldSecret, newSecret).Error(0)
+}
+func (m *mockMFARepo) ReplaceRecoveryCodes(_ context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	return m.Called(tx, userID, codeHashes).Error(0)
+}
+func (m *mockMFARepo) UseRecoveryCode(_ context.Context, userID int, codeHash string) error {
+	return m.Called(userID, codeHash).Error(0)
+}
+
+// mockChallengeClient provides a mock for IChallengeClient.
+type mockChallengeClient struct{ mock.Mock }
+
+func (m *mockChallengeClient) Get(_ context.Context, key string) *redis.StringCmd {
+	args := m.Called(key)
+	return redis.NewStringResult(args.String(0), args.Error(1))
+}
+func (m *mockChallengeClient) GetDel(_ context.Context, key string) *redis.StringCmd {
+	args := m.Called(key)
+	return redis.NewStringResult(args.String(0), args.Error(1))
+}
+func (m *mockChallengeClient) Set(_ context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	m.Called(key, value, expiration)
+	return redis.NewStatusResult("OK", nil)
+}
+func (m *mockChallengeClient) Incr(_ context.Context, key string) *redis.IntCmd {
+	args := m.Called(key)
+	return redis.NewIntResult(int64(args.Int(0)), args.Error(1))
+}
+func (m *mockChallengeClient) Expire(_ context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	m.Called(key, expiration)
+	return redis.NewBoolResult(true, nil)
+}
+func (m *mockChallengeClient) Del(_ context.Context, keys ...string) *redis.IntCmd {
+	m.Called(keys)
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+// testSecretBox returns a SecretBox with a fixed key.
+func testSecretBox(t *testing.T) *SecretBox {
+	box, err := NewSecretBox([]byte("0123456789abcdef0123456789abcdef"))
+	assert.NoError(t, err)
+	return box
+}
+
+// sealedTOTPSecret returns the secret as it is stored for the user.
+func sealedTOTPSecret(t *testing.T, secret string, userID int) string {
+	sealed, err := testSecretBox(t).Seal(secret, totpSecretLabel(userID))
+	assert.NoError(t, err)
+	return sealed
+}
+
+// currentTOTP returns the code an authenticator app shows for the secret now.
+func currentTOTP(t *testing.T, secret string) (string, int64) {
+	key, err := totpEncoding.DecodeString(secret)
+	assert.NoError(t, err)
+	step := totpStep(time.Now())
+	return totpCode(key, step), step
+}
+
+func TestTOTP(t *testing.T) {
+	// Test vectors from RFC 6238, truncated to six digits.
+	secret := []byte("12345678901234567890")
+	assert.Equal(t, "287082", totpCode(secret, totpStep(time.Unix(59, 0))))
+	assert.Equal(t, "081804", totpCode(secret, totpStep(time.Unix(1111111109, 0))))
+	assert.Equal(t, "005924", totpCode(secret, totpStep(time.Unix(1234567890, 0))))
+
+	encoded := totpEncoding.EncodeToString(secret)
+	now := time.Unix(1111111109, 0)
+	step, ok := matchTOTP(encoded, "081804", now.Add(totpPeriod*time.Second))
+	assert.True(t, ok, "A code from the previous step is accepted")
+	assert.Equal(t, totpStep(now), step)
+	_, ok = matchTOTP(encoded, "081804", now.Add(2*totpPeriod*time.Second))
+	assert.False(t, ok)
+
+	uri := totpURI("Go Bank", "user@test.com", encoded)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Go%20Bank:user@test.com?"))
+	assert.Contains(t, uri, "secret="+encoded)
+	assert.Contains(t, uri, "issuer=Go%20Bank")
+}
+
+func TestMFAService_ConfirmTOTP(t *testing.T) {
+	ctx := context.Background()
+	secret, err := generateTOTPSecret()
+	assert.NoError(t, err)
+
+	t.Run("valid code enables MFA and issues recovery codes", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mfaRepo := new(mockMFARepo)
+		mfaService := NewMFAService(db, mfaRepo, nil, nil, testSecretBox(t))
+		code, step := currentTOTP(t, secret)
+
+		mfaRepo.On("GetTOTP", 7).Return(&model.TOTPCredential{UserID: 7, Secret: sealedTOTPSecret(t, secret, 7)}, nil).Once()
+		dbMock.ExpectBegin()
+		mfaRepo.On("ConfirmTOTP", mock.Anything, 7, step).Return(nil).Once()
+		var storedHashes []string
+		mfaRepo.On("ReplaceRecoveryCodes", mock.Anything, 7, mock.Anything).Run(func(args mock.Arguments) {
+			storedHashes = args.Get(2).([]string)
+		}).Return(nil).Once()
+		dbMock.ExpectCommit()
+
+		codes, err := mfaService.ConfirmTOTP(ctx, 7, code)
+
+		assert.NoError(t, err)
+		assert.Len(t, codes.Codes, recoveryCodeCount)
+		assert.Len(t, storedHashes, recoveryCodeCount)
+		assert.Equal(t, hashRecoveryCode(strings.ToUpper(codes.Codes[0])), storedHashes[0], "Only hashes are stored")
+		assert.NotContains(t, storedHashes, codes.Codes[0])
+		mfaRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mfaRepo := new(mockMFARepo)
+		mfaRepo.On("GetTOTP", 7).Return(&model.TOTPCredential{UserID: 7, Secret: sealedTOTPSecret(t, secret, 7)}, nil).Once()
+
+		_, err := NewMFAService(nil, mfaRepo, nil, nil, testSecretBox(t)).ConfirmTOTP(ctx, 7, "abcdef")
+
+		assert.Equal(t, ErrInvalidMFACode, err)
+	})
+
+	t.Run("already enabled", func(t *testing.T) {
+		mfaRepo := new(mockMFARepo)
+		confirmedAt := time.Now()
+		mfaRepo.On("GetTOTP", 7).Return(&model.TOTPCredential{UserID: 7, Secret: sealedTOTPSecret(t, secret, 7), ConfirmedAt: &confirmedAt}, nil).Once()
+
+		_, err := NewMFAService(nil, mfaRepo, nil, nil, testSecretBox(t)).ConfirmTOTP(ctx, 7, "123456")
+
+		assert.Equal(t, ErrMFAAlreadyEnabled, err)
+	})
+}
+
+func TestMFAService_TOTPSecretEncryption(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("enrollment stores the secret encrypted", func(t *testing.T) {
+		mfaRepo, userRepo := new(mockMFARepo), new(mockUserRepo)
+		userRepo.On("GetUserByID", 7).Return(&model.User{ID: 7, Email: "user@test.com"}, nil).Once()
+		var stored string
+		mfaRepo.On("SavePendingTOTP", 7, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.String(1)
+		}).Return(nil).Once()
+
+		enrollment, err := NewMFAService(nil, mfaRepo, userRepo, nil, testSecretBox(t)).EnrollTOTP(ctx, 7)
+
+		assert.NoError(t, err)
+		assert.NotContains(t, stored, enrollment.Secret)
+		opened, err := testSecretBox(t).Open(stored, totpSecretLabel(7))
+		assert.NoError(t, err)
+		assert.Equal(t, enrollment.Secret, opened)
+		_, err = testSecretBox(t).Open(stored, totpSecretLabel(8))
+		assert.Error(t, err, "A secret copied to another user does not decrypt")
+	})
+
+	t.Run("plaintext secret is encrypted when read", func(t *testing.T) {
+		secret, err := generateTOTPSecret()
+		assert.NoError(t, err)
+		mfaRepo := new(mockMFARepo)
+		mfaRepo.On("GetTOTP", 7).Return(&model.TOTPCredential{UserID: 7, Secret: secret}, nil).Once()
+		mfaRepo.On("ReplaceTOTPSecret", 7, secret, mock.MatchedBy(func(sealed string) bool {
+			opened, err := testSecretBox(t).Open(sealed, totpSecretLabel(7))
+			return err == nil && opened == secret
+		})).Return(nil).Once()
+
+		_, err = NewMFAService(nil, mfaRepo, nil, nil, testSecretBox(t)).ConfirmTOTP(ctx, 7, "abcdef")
+
+		assert.Equal(t, ErrInvalidMFACode, err)
+		mfaRepo.AssertExpectations(t)
+	})
+}
+
+func TestMFAService_VerifyChallenge(t *testing.T) {
+	ctx := context.Background()
+	secret, err := generateTOTPSecret()
+	assert.NoError(t, err)
+	confirmedAt := time.Now()
+	credential := &model.TOTPCredential{UserID: 7, Secret: sealedTOTPSecret(t, secret, 7), ConfirmedAt: &confirmedAt}
+	device := model.DeviceInfo{DeviceName: "phone"}
+	data, _ := json.Marshal(mfaChallenge{UserID: 7, Device: device})
+	challengeData := string(data)
+	key := mfaChallengeKey("challenge")
+	attemptsKey := key + ":attempts"
+
+	t.Run("valid authenticator code completes the challenge once", func(t *testing.T) {
+		mfaRepo, cache := new(mockMFARepo), new(mockChallengeClient)
+		mfaService := NewMFAService(nil, mfaRepo, nil, cache, testSecretBox(t))
+		code, step := currentTOTP(t, secret)
+		cache.On("Get", key).Return(challengeData, nil).Once()
+		cache.On("Incr", attemptsKey).Return(1, nil).Once()
+		cache.On("Expire", attemptsKey, mfaChallengeTTL).Once()
+		mfaRepo.On("GetTOTP", 7).Return(credential, nil).Once()
+		mfaRepo.On("UseTOTPStep", 7, step).Return(nil).Once()
+		cache.On("GetDel", key).Return(challengeData, nil).Once()
+		cache.On("Del", []string{attemptsKey}).Once()
+
+		userID, gotDevice, err := mfaService.VerifyChallenge(ctx, "challenge", code)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, userID)
+		assert.Equal(t, device, gotDevice)
+		mfaRepo.AssertExpectations(t)
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("challenge consumed by a concurrent request", func(t *testing.T) {
+		mfaRepo, cache := new(mockMFARepo), new(mockChallengeClient)
+		mfaService := NewMFAService(nil, mfaRepo, nil, cache, testSecretBox(t))
+		cache.On("Get", key).Return(challengeData, nil).Once()
+		cache.On("Incr", attemptsKey).Return(2, nil).Once()
+		mfaRepo.On("UseRecoveryCode", 7, hashRecoveryCode("abcde-fghij")).Return(nil).Once()
+		cache.On("GetDel", key).Return("", redis.Nil).Once()
+
+		_, _, err := mfaService.VerifyChallenge(ctx, "challenge", "abcde-fghij")
+
+		assert.Equal(t, ErrInvalidMFAChallenge, err)
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("replayed authenticator code is rejected", func(t *testing.T) {
+		mfaRepo, cache := new(mockMFARepo), new(mockChallengeClient)
+		mfaService := NewMFAService(nil, mfaRepo, nil, cache, testSecretBox(t))
+		code, step := currentTOTP(t, secret)
+		cache.On("Get", key).Return(challengeData, nil).Once()
+		cache.On("Incr", attemptsKey).Return(2, nil).Once()
+		mfaRepo.On("GetTOTP", 7).Return(credential, nil).Once()
+		mfaRepo.On("UseTOTPStep", 7, step).Return(sql.ErrNoRows).Once()
+
+		_, _, err := mfaService.VerifyChallenge(ctx, "challenge", code)
+
+		assert.Equal(t, ErrInvalidMFACode, err)
+		cache.AssertExpectations(t)
+		cache.AssertNotCalled(t, "GetDel", key)
+	})
+
+	t.Run("recovery code", func(t *testing.T) {
+		mfaRepo, cache := new(mockMFARepo), new(mockChallengeClient)
+		mfaService := NewMFAService(nil, mfaRepo, nil, cache, testSecretBox(t))
+		cache.On("Get", key).Return(challengeData, nil).Once()
+		cache.On("Incr", attemptsKey).Return(1, nil).Once()
+		cache.On("Expire", attemptsKey, mfaChallengeTTL).Once()
+		mfaRepo.On("UseRecoveryCode", 7, hashRecoveryCode("abcde-fghij")).Return(nil).Once()
+		cache.On("GetDel", key).Return(challengeData, nil).Once()
+		cache.On("Del", []string{attemptsKey}).Once()
+
+		userID, _, err := mfaService.VerifyChallenge(ctx, "challenge", "ABCDE FGHIJ")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, userID)
+		mfaRepo.AssertExpectations(t)
+	})
+
+	t.Run("challenge is discarded after too many invalid codes", func(t *testing.T) {
+		mfaRepo, cache := new(mockMFARepo), new(mockChallengeClient)
+		mfaService := NewMFAService(nil, mfaRepo, nil, cache, testSecretBox(t))
+		cache.On("Get", key).Return(challengeData, nil).Once()
+		cache.On("Incr", attemptsKey).Return(maxMFAAttempts, nil).Once()
+		mfaRepo.On("UseRecoveryCode", 7, mock.Anything).Return(sql.ErrNoRows).Once()
+		cache.On("Del", []string{key, attemptsKey}).Once()
+
+		_, _, err := mfaService.VerifyChallenge(ctx, "challenge", "wrong-code")
+
+		assert.Equal(t, ErrTooManyMFAAttempts, err)
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("attempts past the limit are not checked", func(t *testing.T) {
+		mfaRepo, cache := new(mockMFARepo), new(mockChallengeClient)
+		mfaService := NewMFAService(nil, mfaRepo, nil, cache, testSecretBox(t))
+		cache.On("Get", key).Return(challengeData, nil).Once()
+		cache.On("Incr", attemptsKey).Return(maxMFAAttempts+1, nil).Once()
+		cache.On("Del", []string{key, attemptsKey}).Once()
+
+		_, _, err := mfaService.VerifyChallenge(ctx, "challenge", "abcde-fghij")
+
+		assert.Equal(t, ErrTooManyMFAAttempts, err)
+		mfaRepo.AssertNotCalled(t, "UseRecoveryCode", mock.Anything, mock.Anything)
+	})
+
+	t.Run("challenge user is looked up without counting an attempt", func(t *testing.T) {
+		cache := new(mockChallengeClient)
+		cache.On("Get", key).Return(challengeData, nil).Once()
+
+		userID, err := NewMFAService(nil, nil, nil, cache, testSecretBox(t)).ChallengeUser(ctx, "challenge")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, userID)
+		cache.AssertNotCalled(t, "Incr", mock.Anything)
+	})
+
+	t.Run("unknown or expired challenge", func(t *testing.T) {
+		cache := new(mockChallengeClient)
+		cache.On("Get", key).Return("", redis.Nil).Once()
+
+		_, _, err := NewMFAService(nil, nil, nil, cache, testSecretBox(t)).VerifyChallenge(ctx, "challenge", "123456")
+
+		assert.Equal(t, ErrInvalidMFAChallenge, err)
+	})
+}
@@ -0,0 +1,31 @@
+// file: service/pagination.go
+
+package service
+
+import "go-bank-api/model"
+
+// pageLimit applies the default and maximum page sizes to a requested limit.
+func pageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return model.DefaultPageSize
+	case limit > model.MaxPageSize:
+		return model.MaxPageSize
+	default:
+		return limit
+	}
+}
+
+// paginate trims the extra row a listing query fetches beyond the limit and
+// returns the cursor of the next page, which is empty on the last page. It
+// never returns a nil slice, so empty pages encode as [] rather than null.
+func paginate[T any](items []T, limit int, cursor func(T) model.Cursor) ([]T, string) {
+	if len(items) <= limit {
+		if items == nil {
+			items = []T{}
+		}
+		return items, ""
+	}
+	items = items[:limit]
+	return items, cursor(items[limit-1]).Encode()
+}
@@ -0,0 +1,191 @@
+// file: service/password_hasher.go
+
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-bank-api/config"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms.
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+)
+
+var errMalformedPasswordHash = errors.New("malformed password hash")
+
+// Upper bounds on argon2id cost, far above any sensible setting. Parameters
+// are read from stored hashes, and a corrupt hash must not make a login use
+// gigabytes of memory or run for minutes.
+const (
+	maxArgon2Memory     = 1 << 20 // KiB, so 1 GiB
+	maxArgon2Iterations = 64
+)
+
+// validArgon2Cost reports whether argon2id can run with the parameters:
+// argon2.IDKey panics if parallelism is 0.
+func validArgon2Cost(memory, iterations uint32, parallelism uint8) bool {
+	return iterations >= 1 && iterations <= maxArgon2Iterations &&
+		parallelism >= 1 &&
+		memory >= 8*uint32(parallelism) && memory <= maxArgon2Memory
+}
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PasswordParams select the algorithm new password hashes are made with and
+// its cost.
+type PasswordParams struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// PasswordHasher hashes passwords with argon2id, in the PHC string format
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash), or with bcrypt, in its own
+// $2a$ format. It verifies hashes of either kind whatever it is configured to
+// create, and reports hashes made with another algorithm or other parameters
+// as needing a rehash, so stored hashes can be upgraded as users log in.
+type PasswordHasher struct {
+	params PasswordParams
+	// dummyHash is a hash of no user's password, made with the configured
+	// parameters. Checking a password against it takes as long as checking a
+	// real one, so a failed login for an unknown email address is not faster
+	// than one for a known address.
+	dummyHash string
+}
+
+// NewPasswordHasher creates a PasswordHasher that makes new hashes with the
+// given parameters.
+func NewPasswordHasher(params PasswordParams) (*PasswordHasher, error) {
+	switch params.Algorithm {
+	case PasswordAlgorithmArgon2id:
+		a := params.Argon2
+		if !validArgon2Cost(a.Memory, a.Iterations, a.Parallelism) {
+			return nil, fmt.Errorf("invalid argon2id parameters: m=%d, t=%d, p=%d", a.Memory, a.Iterations, a.Parallelism)
+		}
+		if a.SaltLength < 8 || a.KeyLength < 16 {
+			return nil, fmt.Errorf("argon2id salt must be at least 8 bytes and key at least 16 bytes")
+		}
+	case PasswordAlgorithmBcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", params.Algorithm)
+	}
+	h := &PasswordHasher{params: params}
+	dummyHash, err := h.HashPassword("not a password of any user")
+	if err != nil {
+		return nil, err
+	}
+	h.dummyHash = dummyHash
+	return h, nil
+}
+
+// NewConfiguredPasswordHasher creates a PasswordHasher with the parameters from
+// the password configuration.
+func NewConfiguredPasswordHasher() (*PasswordHasher, error) {
+	cfg := config.AppConfig.Password
+	return NewPasswordHasher(PasswordParams{
+		Algorithm: cfg.Algorithm,
+		Argon2: Argon2Params{
+			Memory:      cfg.Argon2.Memory,
+			Iterations:  cfg.Argon2.Iterations,
+			Parallelism: cfg.Argon2.Parallelism,
+			SaltLength:  cfg.Argon2.SaltLength,
+			KeyLength:   cfg.Argon2.KeyLength,
+		},
+		BcryptCost: cfg.BcryptCost,
+	})
+}
+
+// HashPassword hashes the password with the configured algorithm.
+func (h *PasswordHasher) HashPassword(password string) (string, error) {
+	if h.params.Algorithm == PasswordAlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.params.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	a := h.params.Argon2
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether the password matches the hash and, if it
+// does, whether the hash should be replaced because it was made with another
+// algorithm or other parameters than the configured ones.
+func (h *PasswordHasher) VerifyPassword(password, encodedHash string) (match, needsRehash bool) {
+	if strings.HasPrefix(encodedHash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2Hash(encodedHash)
+		if err != nil {
+			return false, false
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false
+		}
+		want := h.params.Argon2
+		return true, h.params.Algorithm != PasswordAlgorithmArgon2id ||
+			params.Memory != want.Memory || params.Iterations != want.Iterations ||
+			params.Parallelism != want.Parallelism || params.KeyLength != want.KeyLength ||
+			uint32(len(salt)) != want.SaltLength
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return true, h.params.Algorithm != PasswordAlgorithmBcrypt || err != nil || cost != h.params.BcryptCost
+}
+
+// decodeArgon2Hash parses an argon2id hash in the PHC string format.
+func decodeArgon2Hash(encodedHash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != PasswordAlgorithmArgon2id {
+		return params, nil, nil, errMalformedPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errMalformedPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil ||
+		!validArgon2Cost(params.Memory, params.Iterations, params.Parallelism) {
+		return params, nil, nil, errMalformedPasswordHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errMalformedPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errMalformedPasswordHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,96 @@
+// file: service/password_hasher_test.go
+
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params are cheap argon2id parameters for tests.
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasher_Argon2id(t *testing.T) {
+	hasher, err := NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmArgon2id, Argon2: testArgon2Params})
+	require.NoError(t, err)
+
+	hash, err := hasher.HashPassword("mySecretPassword123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), "Hashes use the PHC string format")
+
+	match, needsRehash := hasher.VerifyPassword("mySecretPassword123", hash)
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+	match, _ = hasher.VerifyPassword("wrongPassword", hash)
+	assert.False(t, match)
+
+	assert.True(t, strings.HasPrefix(hasher.dummyHash, "$argon2id$v=19$m=64,t=1,p=1$"), "Unknown users are checked at the configured cost")
+
+	other, err := hasher.HashPassword("mySecretPassword123")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "Every hash has its own salt")
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	upgraded, err := NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmArgon2id, Argon2: stronger})
+	require.NoError(t, err)
+	match, needsRehash = upgraded.VerifyPassword("mySecretPassword123", hash)
+	assert.True(t, match, "Hashes made with older parameters still verify")
+	assert.True(t, needsRehash)
+
+	for _, malformed := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$salt",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=100000,p=1$c2FsdHNhbHQ$a2V5",
+		"plain",
+	} {
+		assert.NotPanics(t, func() {
+			match, _ := hasher.VerifyPassword("mySecretPassword123", malformed)
+			assert.False(t, match, malformed)
+		}, malformed)
+	}
+}
+
+func TestPasswordHasher_Bcrypt(t *testing.T) {
+	bcryptHasher, err := NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	require.NoError(t, err)
+	hash, err := bcryptHasher.HashPassword("mySecretPassword123")
+	require.NoError(t, err)
+
+	match, needsRehash := bcryptHasher.VerifyPassword("mySecretPassword123", hash)
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+	cost, err := bcrypt.Cost([]byte(bcryptHasher.dummyHash))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost, cost, "Unknown users are checked at the configured cost")
+
+	costlier, err := NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1})
+	require.NoError(t, err)
+	_, needsRehash = costlier.VerifyPassword("mySecretPassword123", hash)
+	assert.True(t, needsRehash, "Hashes with another cost need a rehash")
+
+	argonHasher, err := NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmArgon2id, Argon2: testArgon2Params})
+	require.NoError(t, err)
+	match, needsRehash = argonHasher.VerifyPassword("mySecretPassword123", hash)
+	assert.True(t, match, "bcrypt hashes verify after switching to argon2id")
+	assert.True(t, needsRehash)
+}
+
+func TestNewPasswordHasher_RejectsInvalidParameters(t *testing.T) {
+	_, err := NewPasswordHasher(PasswordParams{Algorithm: "md5"})
+	assert.Error(t, err)
+	_, err = NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: 40})
+	assert.Error(t, err)
+	weak := testArgon2Params
+	weak.Iterations = 0
+	_, err = NewPasswordHasher(PasswordParams{Algorithm: PasswordAlgorithmArgon2id, Argon2: weak})
+	assert.Error(t, err)
+}
@@ -0,0 +1,251 @@
+// file: service/password_policy.go
+
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicyError is returned for a new password that breaks the password
+// policy. Violations lists every rule it breaks, each phrased to follow the
+// word "password", e.g. "must contain a digit".
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password " + strings.Join(e.Violations, "; ")
+}
+
+// IPasswordPolicy checks a new password of the user. PasswordPolicy
+// implements it; tests can substitute a mock.
+type IPasswordPolicy interface {
+	Check(ctx context.Context, password string, user *model.User) error
+}
+
+// IBreachedPasswords looks up breached passwords by range, k-anonymity style
+// as the Pwned Passwords API does: given the first five hex characters of a
+// password's SHA-1 hash, it returns the other 35 characters of every breached
+// password hash with that prefix, in upper case. The caller compares them
+// with its own hash, so a lookup never reveals the password or its full hash.
+type IBreachedPasswords interface {
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// breachedHashPrefixLength is the length of the hash prefixes looked up.
+const breachedHashPrefixLength = 5
+
+// BreachedPasswordFile is a breached password list loaded from a local file.
+type BreachedPasswordFile struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedPasswordFile reads a breached password list. The file has one
+// SHA-1 hash per line in hex, optionally followed by ":" and a count, as in
+// the Pwned Passwords downloads; blank lines and lines starting with "#" are
+// skipped.
+func LoadBreachedPasswordFile(path string) (*BreachedPasswordFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	list := &BreachedPasswordFile{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("breached password list %s, line %d: not a SHA-1 hash", path, lineNumber)
+		}
+		hash = strings.ToUpper(hash)
+		prefix := hash[:breachedHashPrefixLength]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[breachedHashPrefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read breached password list: %w", err)
+	}
+	return list, nil
+}
+
+// Range returns the suffixes of the listed hashes with the prefix.
+func (l *BreachedPasswordFile) Range(_ context.Context, prefix string) ([]string, error) {
+	return l.ranges[strings.ToUpper(prefix)], nil
+}
+
+// PasswordRules are the rules of a password policy; see config.Password.Policy.
+// Lengths count characters, not bytes. MaxBytes, if set, additionally limits
+// the length in bytes, for hashing algorithms that cannot take longer input.
+type PasswordRules struct {
+	MinLength          int
+	MaxLength          int
+	MaxBytes           int
+	RequireUppercase   bool
+	RequireLowercase   bool
+	RequireDigit       bool
+	RequireSymbol      bool
+	ForbidPersonalInfo bool
+}
+
+// bcryptMaxPasswordBytes is the longest password bcrypt hashes; it returns
+// bcrypt.ErrPasswordTooLong for longer ones.
+const bcryptMaxPasswordBytes = 72
+
+// minPasswordLength is the least MinLength a policy may set, because logins
+// refuse shorter passwords before looking at them.
+const minPasswordLength = 8
+
+// PasswordPolicy checks new passwords, at registration, password change and
+// password reset, against its rules and, when it has one, a breached
+// password list. Passwords set before a rule was introduced keep working.
+type PasswordPolicy struct {
+	rules    PasswordRules
+	breached IBreachedPasswords
+}
+
+// NewPasswordPolicy creates a PasswordPolicy. breached may be nil to skip the
+// breached password check.
+func NewPasswordPolicy(rules PasswordRules, breached IBreachedPasswords) (*PasswordPolicy, error) {
+	if rules.MinLength < minPasswordLength {
+		return nil, fmt.Errorf("minimum password length must be at least %d", minPasswordLength)
+	}
+	if rules.MaxLength < rules.MinLength {
+		return nil, fmt.Errorf("maximum password length must not be less than the minimum length %d", rules.MinLength)
+	}
+	return &PasswordPolicy{rules: rules, breached: breached}, nil
+}
+
+// NewConfiguredPasswordPolicy creates a PasswordPolicy with the rules from the
+// password configuration, loading the breached password list if one is set.
+func NewConfiguredPasswordPolicy() (*PasswordPolicy, error) {
+	cfg := config.AppConfig.Password.Policy
+	var breached IBreachedPasswords
+	if cfg.BreachedListFile != "" {
+		list, err := LoadBreachedPasswordFile(cfg.BreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Log.WithField("ranges", len(list.ranges)).Info("Breached password list loaded")
+		breached = list
+	}
+	var maxBytes int
+	if config.AppConfig.Password.Algorithm == PasswordAlgorithmBcrypt {
+		maxBytes = bcryptMaxPasswordBytes
+	}
+	return NewPasswordPolicy(PasswordRules{
+		MinLength:          cfg.MinLength,
+		MaxLength:          cfg.MaxLength,
+		MaxBytes:           maxBytes,
+		RequireUppercase:   cfg.RequireUppercase,
+		RequireLowercase:   cfg.RequireLowercase,
+		RequireDigit:       cfg.RequireDigit,
+		RequireSymbol:      cfg.RequireSymbol,
+		ForbidPersonalInfo: cfg.ForbidPersonalInfo,
+	}, breached)
+}
+
+// Check returns a *PasswordPolicyError listing every rule the password breaks,
+// or nil if it follows them all. The user's username and email address are
+// the personal information a password must not contain.
+func (p *PasswordPolicy) Check(ctx context.Context, password string, user *model.User) error {
+	var violations []string
+	if length := utf8.RuneCountInString(password); length < p.rules.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.rules.MinLength))
+	} else if length > p.rules.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters long", p.rules.MaxLength))
+	} else if p.rules.MaxBytes > 0 && len(password) > p.rules.MaxBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long; accented letters and symbols take up to 4 bytes each", p.rules.MaxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.rules.RequireUppercase && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.rules.RequireLowercase && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.rules.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.rules.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if p.rules.ForbidPersonalInfo && user != nil {
+		lowered := strings.ToLower(password)
+		if containsPersonalInfo(lowered, user.Username) {
+			violations = append(violations, "must not contain your username")
+		}
+		localPart, _, _ := strings.Cut(user.Email, "@")
+		if containsPersonalInfo(lowered, localPart) {
+			violations = append(violations, "must not contain your email address")
+		}
+	}
+
+	if p.breached != nil {
+		breached, err := p.isBreached(ctx, password)
+		if err != nil {
+			return fmt.Errorf("could not check breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, "has appeared in a data breach; choose a different one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether the lower-cased password contains the
+// piece of personal information. Pieces shorter than three characters would
+// rule out too many passwords and are ignored.
+func containsPersonalInfo(loweredPassword, info string) bool {
+	info = strings.ToLower(strings.TrimSpace(info))
+	return utf8.RuneCountInString(info) >= 3 && strings.Contains(loweredPassword, info)
+}
+
+// isBreached looks the password up in the breached password list by the
+// prefix of its SHA-1 hash.
+func (p *PasswordPolicy) isBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := p.breached.Range(ctx, hash[:breachedHashPrefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[breachedHashPrefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
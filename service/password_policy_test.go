@@ -0,0 +1,130 @@
+// file: service/password_policy_test.go
+
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"go-bank-api/config"
+	"go-bank-api/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testPasswordRules = PasswordRules{
+	MinLength:          10,
+	MaxLength:          64,
+	RequireUppercase:   true,
+	RequireLowercase:   true,
+	RequireDigit:       true,
+	RequireSymbol:      true,
+	ForbidPersonalInfo: true,
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	ctx := context.Background()
+	policy, err := NewPasswordPolicy(testPasswordRules, nil)
+	require.NoError(t, err)
+	user := &model.User{Username: "alice", Email: "alice.smith@test.com"}
+
+	assert.NoError(t, policy.Check(ctx, "Correct-Horse-42", user))
+
+	err = policy.Check(ctx, "short", user)
+	policyErr, ok := err.(*PasswordPolicyError)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{
+			"must be at least 10 characters long",
+			"must contain an uppercase letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}, policyErr.Violations, "Every broken rule is listed")
+		assert.Equal(t, "password must be at least 10 characters long; must contain an uppercase letter; must contain a digit; must contain a symbol", err.Error())
+	}
+
+	err = policy.Check(ctx, strings.Repeat("Aa1!", 17), user)
+	if assert.IsType(t, &PasswordPolicyError{}, err) {
+		assert.Equal(t, []string{"must be at most 64 characters long"}, err.(*PasswordPolicyError).Violations)
+	}
+
+	err = policy.Check(ctx, "My-ALICE-pass-1", user)
+	if assert.IsType(t, &PasswordPolicyError{}, err) {
+		assert.Equal(t, []string{"must not contain your username"}, err.(*PasswordPolicyError).Violations, "Personal information is matched ignoring case")
+	}
+
+	err = policy.Check(ctx, "Alice.Smith-99", &model.User{Username: "al", Email: "alice.smith@test.com"})
+	if assert.IsType(t, &PasswordPolicyError{}, err) {
+		assert.Equal(t, []string{"must not contain your email address"}, err.(*PasswordPolicyError).Violations)
+	}
+
+	assert.NoError(t, policy.Check(ctx, "Correct-Horse-42", &model.User{Username: "or", Email: "se@test.com"}),
+		"Very short usernames and addresses are not looked for")
+}
+
+func TestPasswordPolicy_BcryptLengthLimit(t *testing.T) {
+	ctx := context.Background()
+	saved := config.AppConfig.Password
+	defer func() { config.AppConfig.Password = saved }()
+	config.AppConfig.Password.Algorithm = PasswordAlgorithmBcrypt
+	config.AppConfig.Password.Policy.MinLength = 10
+	config.AppConfig.Password.Policy.MaxLength = 128
+	policy, err := NewConfiguredPasswordPolicy()
+	require.NoError(t, err)
+
+	// 40 characters, but 80 bytes: too long for bcrypt to hash.
+	long := strings.Repeat("é1", 40)
+	err = policy.Check(ctx, long, nil)
+	if assert.IsType(t, &PasswordPolicyError{}, err) {
+		assert.Equal(t, []string{"must be at most 72 bytes long; accented letters and symbols take up to 4 bytes each"}, err.(*PasswordPolicyError).Violations)
+	}
+	_, err = bcrypt.GenerateFromPassword([]byte(long), bcrypt.MinCost)
+	assert.ErrorIs(t, err, bcrypt.ErrPasswordTooLong, "The policy refuses what bcrypt cannot hash")
+
+	assert.NoError(t, policy.Check(ctx, strings.Repeat("a1", 36), nil), "72 bytes are fine")
+
+	config.AppConfig.Password.Algorithm = PasswordAlgorithmArgon2id
+	policy, err = NewConfiguredPasswordPolicy()
+	require.NoError(t, err)
+	assert.NoError(t, policy.Check(ctx, long, nil), "argon2id takes passwords of any length")
+}
+
+func TestPasswordPolicy_BreachedPasswords(t *testing.T) {
+	ctx := context.Background()
+	sum := sha1.Sum([]byte("Password-123"))
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# breached passwords\n\n" +
+		"0000000000000000000000000000000000000000:12\n" +
+		strings.ToLower(hex.EncodeToString(sum[:])) + ":3861493\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	list, err := LoadBreachedPasswordFile(path)
+	require.NoError(t, err)
+	suffixes, err := list.Range(ctx, strings.ToUpper(hex.EncodeToString(sum[:]))[:5])
+	require.NoError(t, err)
+	assert.Equal(t, []string{strings.ToUpper(hex.EncodeToString(sum[:]))[5:]}, suffixes)
+
+	policy, err := NewPasswordPolicy(testPasswordRules, list)
+	require.NoError(t, err)
+	err = policy.Check(ctx, "Password-123", nil)
+	if assert.IsType(t, &PasswordPolicyError{}, err) {
+		assert.Equal(t, []string{"has appeared in a data breach; choose a different one"}, err.(*PasswordPolicyError).Violations)
+	}
+	assert.NoError(t, policy.Check(ctx, "Password-124", nil))
+
+	require.NoError(t, os.WriteFile(path, []byte("not-a-hash\n"), 0o600))
+	_, err = LoadBreachedPasswordFile(path)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestNewPasswordPolicy_RejectsInvalidRules(t *testing.T) {
+	_, err := NewPasswordPolicy(PasswordRules{MinLength: 6, MaxLength: 64}, nil)
+	assert.Error(t, err, "Logins refuse passwords shorter than 8 characters")
+	_, err = NewPasswordPolicy(PasswordRules{MinLength: 12, MaxLength: 10}, nil)
+	assert.Error(t, err)
+}
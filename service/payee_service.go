@@ -0,0 +1,145 @@
+// file: service/payee_service.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrPayeeNotFound    = errors.New("payee not found")
+	ErrPayeeExists      = errors.New("a payee with this account number already exists")
+	ErrPayeeNotVerified = errors.New("payee must be verified before transferring to it")
+)
+
+// pqUniqueViolation is the PostgreSQL SQLSTATE for a unique constraint violation.
+const pqUniqueViolation = "23505"
+
+// IBeneficiaryResolver resolves an account number or IBAN to a beneficiary.
+// TransactionService implements it; tests can substitute a mock.
+type IBeneficiaryResolver interface {
+	LookupBeneficiary(ctx context.Context, accountNumber, iban string) (*model.Beneficiary, error)
+}
+
+// PayeeService manages the payees saved by users.
+type PayeeService struct {
+	payeeRepo repository.IPayeeRepository
+	resolver  IBeneficiaryResolver
+}
+
+// NewPayeeService creates a new PayeeService.
+func NewPayeeService(payeeRepo repository.IPayeeRepository, resolver IBeneficiaryResolver) *PayeeService {
+	return &PayeeService{payeeRepo: payeeRepo, resolver: resolver}
+}
+
+// CreatePayee looks up the beneficiary and saves it as an unverified payee,
+// together with the masked holder name the user has to confirm.
+func (s *PayeeService) CreatePayee(ctx context.Context, userID int, req model.CreatePayeeRequest) (*model.Payee, error) {
+	log := logger.Log.WithFields(logrus.Fields{
+		"user_id":        userID,
+		"account_number": req.AccountNumber,
+	})
+	log.Info("Creating new payee")
+
+	beneficiary, err := s.resolver.LookupBeneficiary(ctx, req.AccountNumber, req.IBAN)
+	if err != nil {
+		return nil, err
+	}
+	if req.Currency != "" && req.Currency != beneficiary.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	payee := &model.Payee{
+		UserID:        userID,
+		Nickname:      req.Nickname,
+		AccountNumber: beneficiary.AccountNumber,
+		Currency:      beneficiary.Currency,
+		HolderName:    beneficiary.HolderName,
+	}
+	if err := s.payeeRepo.CreatePayee(ctx, payee); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return nil, ErrPayeeExists
+		}
+		return nil, err
+	}
+
+	log.WithField("payee_id", payee.ID).Info("Payee created successfully")
+	return payee, nil
+}
+
+// ListPayees returns the payees saved by a user.
+func (s *PayeeService) ListPayees(ctx context.Context, userID int) ([]*model.Payee, error) {
+	return s.payeeRepo.GetPayeesByUserID(ctx, userID)
+}
+
+// GetPayee returns one of the user's payees. Payees of other users are
+// reported as not found.
+func (s *PayeeService) GetPayee(ctx context.Context, userID, payeeID int) (*model.Payee, error) {
+	payee, err := s.payeeRepo.GetPayeeByID(ctx, payeeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPayeeNotFound
+		}
+		return nil, err
+	}
+	if payee.UserID != userID {
+		logger.Log.WithFields(logrus.Fields{"user_id": userID, "payee_id": payeeID}).Warn("Attempt to access another user's payee")
+		return nil, ErrPayeeNotFound
+	}
+	return payee, nil
+}
+
+// RenamePayee changes the nickname of one of the user's payees.
+func (s *PayeeService) RenamePayee(ctx context.Context, userID, payeeID int, nickname string) (*model.Payee, error) {
+	payee, err := s.GetPayee(ctx, userID, payeeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.payeeRepo.UpdateNickname(ctx, payee.ID, nickname); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPayeeNotFound
+		}
+		return nil, err
+	}
+	payee.Nickname = nickname
+	return payee, nil
+}
+
+// VerifyPayee records that the user has confirmed the payee's holder name,
+// which allows transfers to it.
+func (s *PayeeService) VerifyPayee(ctx context.Context, userID, payeeID int) (*model.Payee, error) {
+	payee, err := s.GetPayee(ctx, userID, payeeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.payeeRepo.MarkVerified(ctx, payee.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPayeeNotFound
+		}
+		return nil, err
+	}
+	return s.GetPayee(ctx, userID, payeeID)
+}
+
+// DeletePayee removes one of the user's payees.
+func (s *PayeeService) DeletePayee(ctx context.Context, userID, payeeID int) error {
+	if _, err := s.GetPayee(ctx, userID, payeeID); err != nil {
+		return err
+	}
+	if err := s.payeeRepo.DeletePayee(ctx, payeeID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPayeeNotFound
+		}
+		return err
+	}
+	return nil
+}
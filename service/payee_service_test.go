@@ -0,0 +1,142 @@
+// file: service/payee_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/model"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPayeeRepository is a mock for IPayeeRepository.
+type MockPayeeRepository struct{ mock.Mock }
+
+func (m *MockPayeeRepository) CreatePayee(_ context.Context, payee *model.Payee) error {
+	return m.Called(payee).Error(0)
+}
+func (m *MockPayeeRepository) GetPayeeByID(_ context.Context, id int) (*model.Payee, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payee), args.Error(1)
+}
+func (m *MockPayeeRepository) GetPayeesByUserID(_ context.Context, userID int) ([]*model.Payee, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Payee), args.Error(1)
+}
+func (m *MockPayeeRepository) UpdateNickname(_ context.Context, id int, nickname string) error {
+	return m.Called(id, nickname).Error(0)
+}
+func (m *MockPayeeRepository) MarkVerified(_ context.Context, id int) error {
+	return m.Called(id).Error(0)
+}
+func (m *MockPayeeRepository) DeletePayee(_ context.Context, id int) error {
+	return m.Called(id).Error(0)
+}
+
+// mockBeneficiaryResolver provides a mock for IBeneficiaryResolver.
+type mockBeneficiaryResolver struct{ mock.Mock }
+
+func (m *mockBeneficiaryResolver) LookupBeneficiary(_ context.Context, accountNumber, iban string) (*model.Beneficiary, error) {
+	args := m.Called(accountNumber, iban)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Beneficiary), args.Error(1)
+}
+
+func TestPayeeService_CreatePayee(t *testing.T) {
+	ctx := context.Background()
+	beneficiary := &model.Beneficiary{AccountNumber: 10000000256, Currency: "TRY", HolderName: "j*** d***"}
+
+	t.Run("success", func(t *testing.T) {
+		payeeRepo := new(MockPayeeRepository)
+		resolver := new(mockBeneficiaryResolver)
+		payeeService := NewPayeeService(payeeRepo, resolver)
+
+		resolver.On("LookupBeneficiary", "10000000256", "").Return(beneficiary, nil).Once()
+		payeeRepo.On("CreatePayee", mock.MatchedBy(func(p *model.Payee) bool {
+			return p.UserID == 1 && p.AccountNumber == beneficiary.AccountNumber && p.HolderName == beneficiary.HolderName && p.Currency == "TRY"
+		})).Return(nil).Once()
+
+		payee, err := payeeService.CreatePayee(ctx, 1, model.CreatePayeeRequest{Nickname: "Jane", AccountNumber: "10000000256"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Jane", payee.Nickname)
+		payeeRepo.AssertExpectations(t)
+		resolver.AssertExpectations(t)
+	})
+
+	t.Run("currency mismatch", func(t *testing.T) {
+		resolver := new(mockBeneficiaryResolver)
+		payeeService := NewPayeeService(new(MockPayeeRepository), resolver)
+		resolver.On("LookupBeneficiary", "10000000256", "").Return(beneficiary, nil).Once()
+
+		_, err := payeeService.CreatePayee(ctx, 1, model.CreatePayeeRequest{Nickname: "Jane", AccountNumber: "10000000256", Currency: "EUR"})
+
+		assert.Equal(t, ErrCurrencyMismatch, err)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		payeeRepo := new(MockPayeeRepository)
+		resolver := new(mockBeneficiaryResolver)
+		payeeService := NewPayeeService(payeeRepo, resolver)
+		resolver.On("LookupBeneficiary", "10000000256", "").Return(beneficiary, nil).Once()
+		payeeRepo.On("CreatePayee", mock.Anything).Return(&pq.Error{Code: pqUniqueViolation}).Once()
+
+		_, err := payeeService.CreatePayee(ctx, 1, model.CreatePayeeRequest{Nickname: "Jane", AccountNumber: "10000000256"})
+
+		assert.Equal(t, ErrPayeeExists, err)
+	})
+}
+
+func TestPayeeService_VerifyPayee(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		payeeRepo := new(MockPayeeRepository)
+		payeeService := NewPayeeService(payeeRepo, nil)
+		unverified := &model.Payee{ID: 3, UserID: 1, Status: model.PayeeStatusUnverified}
+		verified := &model.Payee{ID: 3, UserID: 1, Status: model.PayeeStatusVerified}
+
+		payeeRepo.On("GetPayeeByID", 3).Return(unverified, nil).Once()
+		payeeRepo.On("MarkVerified", 3).Return(nil).Once()
+		payeeRepo.On("GetPayeeByID", 3).Return(verified, nil).Once()
+
+		payee, err := payeeService.VerifyPayee(ctx, 1, 3)
+
+		assert.NoError(t, err)
+		assert.True(t, payee.Verified())
+		payeeRepo.AssertExpectations(t)
+	})
+
+	t.Run("another user's payee", func(t *testing.T) {
+		payeeRepo := new(MockPayeeRepository)
+		payeeService := NewPayeeService(payeeRepo, nil)
+		payeeRepo.On("GetPayeeByID", 3).Return(&model.Payee{ID: 3, UserID: 2}, nil).Once()
+
+		_, err := payeeService.VerifyPayee(ctx, 1, 3)
+
+		assert.Equal(t, ErrPayeeNotFound, err)
+		payeeRepo.AssertNotCalled(t, "MarkVerified", 3)
+	})
+
+	t.Run("missing payee", func(t *testing.T) {
+		payeeRepo := new(MockPayeeRepository)
+		payeeService := NewPayeeService(payeeRepo, nil)
+		payeeRepo.On("GetPayeeByID", 4).Return(nil, sql.ErrNoRows).Once()
+
+		_, err := payeeService.VerifyPayee(ctx, 1, 4)
+
+		assert.Equal(t, ErrPayeeNotFound, err)
+	})
+}
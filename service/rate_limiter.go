@@ -0,0 +1,370 @@
+// file: service/rate_limiter.go
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate limiting algorithms.
+const (
+	RateLimitSlidingWindow = "sliding_window"
+	RateLimitTokenBucket   = "token_bucket"
+)
+
+// What rate limits are counted per. Requests are only counted by API key if
+// the key is one of the configured ones; any other key could be changed with
+// every request to dodge the limit.
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyAPIKey = "api_key"
+)
+
+// rateLimitFallbackPeriod is how long the limiter counts in memory after
+// Redis failed, before it tries Redis again.
+const rateLimitFallbackPeriod = 10 * time.Second
+
+// RateLimitRule allows Limit requests per Window for each key. Name
+// identifies the rule, so rules do not share counters.
+type RateLimitRule struct {
+	Name      string
+	Limit     int
+	Window    time.Duration
+	Key       string
+	Algorithm string
+}
+
+// Validate checks that the rule can be enforced.
+func (r RateLimitRule) Validate() error {
+	if r.Limit <= 0 || r.Window <= 0 {
+		return fmt.Errorf("rate limit rule %q needs a positive limit and window", r.Name)
+	}
+	switch r.Key {
+	case RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyAPIKey:
+	default:
+		return fmt.Errorf("rate limit rule %q: unknown key %q", r.Name, r.Key)
+	}
+	switch r.Algorithm {
+	case RateLimitSlidingWindow, RateLimitTokenBucket:
+	default:
+		return fmt.Errorf("rate limit rule %q: unknown algorithm %q", r.Name, r.Algorithm)
+	}
+	return nil
+}
+
+// RateLimitResult is the outcome of counting a request against a rule.
+// Remaining is how many more requests are allowed right now, Reset how long
+// until the full limit is available again and RetryAfter, for refused
+// requests, how long until the next one is allowed.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// IRateLimitStore counts requests against a rule for a key.
+type IRateLimitStore interface {
+	Take(ctx context.Context, rule RateLimitRule, key string, now time.Time) (*RateLimitResult, error)
+}
+
+// RateLimiter enforces the configured rate limit rules. Requests are counted
+// in the primary store, normally Redis, so every instance of the API shares
+// the counts. If the primary store fails, requests are counted in memory for
+// a while, per instance, rather than let through unlimited or refused.
+type RateLimiter struct {
+	rules    map[string]RateLimitRule
+	apiKeys  map[string]string
+	primary  IRateLimitStore
+	fallback IRateLimitStore
+	now      func() time.Time
+
+	mu            sync.Mutex
+	fallbackUntil time.Time
+}
+
+// NewRateLimiter creates a RateLimiter enforcing the rules. apiKeys maps the
+// name of each client allowed to use an API key to the hex SHA-256 hash of
+// the key.
+func NewRateLimiter(rules []RateLimitRule, apiKeys map[string]string, primary, fallback IRateLimitStore) (*RateLimiter, error) {
+	l := &RateLimiter{
+		rules:    make(map[string]RateLimitRule, len(rules)),
+		apiKeys:  make(map[string]string, len(apiKeys)),
+		primary:  primary,
+		fallback: fallback,
+		now:      time.Now,
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		l.rules[rule.Name] = rule
+	}
+	for client, hash := range apiKeys {
+		if sum, err := hex.DecodeString(hash); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("API key of %q must be the hex SHA-256 hash of the key", client)
+		}
+		l.apiKeys[strings.ToLower(hash)] = client
+	}
+	return l, nil
+}
+
+// NewConfiguredRateLimiter creates a RateLimiter with the rules from the rate
+// limit configuration, counting in Redis with an in-memory fallback. With rate
+// limiting disabled it has no rules.
+func NewConfiguredRateLimiter(client redis.Scripter) (*RateLimiter, error) {
+	cfg := config.AppConfig.RateLimit
+	var rules []RateLimitRule
+	if cfg.Enabled {
+		// Sorted, so an invalid configuration is reported the same way on
+		// every start.
+		names := make([]string, 0, len(cfg.Rules))
+		for name := range cfg.Rules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rule := cfg.Rules[name]
+			rules = append(rules, RateLimitRule{
+				Name:      name,
+				Limit:     rule.Limit,
+				Window:    rule.Window,
+				Key:       rule.Key,
+				Algorithm: rule.Algorithm,
+			})
+		}
+	}
+	return NewRateLimiter(rules, cfg.APIKeys, NewRedisRateLimitStore(client), NewMemoryRateLimitStore())
+}
+
+// Rule returns the rule with the given name, if it is configured.
+func (l *RateLimiter) Rule(name string) (RateLimitRule, bool) {
+	rule, ok := l.rules[name]
+	return rule, ok
+}
+
+// APIKeyClient returns the name of the client the API key belongs to, if it
+// is one of the configured keys.
+func (l *RateLimiter) APIKeyClient(apiKey string) (string, bool) {
+	if apiKey == "" {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	client, ok := l.apiKeys[hex.EncodeToString(sum[:])]
+	return client, ok
+}
+
+// Allow counts a request by key against the rule.
+func (l *RateLimiter) Allow(ctx context.Context, rule RateLimitRule, key string) *RateLimitResult {
+	now := l.now()
+	if !l.usingFallback(now) {
+		result, err := l.primary.Take(ctx, rule, key, now)
+		if err == nil {
+			return result
+		}
+		logger.Log.WithError(err).WithField("rule", rule.Name).Warn("Rate limit store unavailable, counting requests in memory")
+		l.mu.Lock()
+		l.fallbackUntil = now.Add(rateLimitFallbackPeriod)
+		l.mu.Unlock()
+	}
+	// The in-memory store never fails.
+	result, _ := l.fallback.Take(ctx, rule, key, now)
+	return result
+}
+
+func (l *RateLimiter) usingFallback(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return now.Before(l.fallbackUntil)
+}
+
+// tokenRefill returns how long it takes a bucket of the rule to gain the given
+// number of tokens.
+func tokenRefill(rule RateLimitRule, tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens * float64(rule.Window) / float64(rule.Limit)))
+}
+
+// RedisRateLimitStore counts requests in Redis. Each check runs as one Lua
+// script, so concurrent requests on different instances are counted exactly.
+type RedisRateLimitStore struct {
+	client redis.Scripter
+}
+
+// NewRedisRateLimitStore creates a new RedisRateLimitStore.
+func NewRedisRateLimitStore(client redis.Scripter) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client}
+}
+
+// slidingWindowScript keeps the times of the requests within the window in a
+// sorted set. It returns whether the request is allowed, how many requests
+// remain and the milliseconds until the oldest counted request leaves the window.
+var slidingWindowScript = redis.NewScript(`
+local key, now, window, limit, member = KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), ARGV[4]
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {allowed, limit - count, tonumber(oldest[2]) + window - now}
+`)
+
+// tokenBucketScript keeps the tokens left in the bucket and the time they
+// were counted in a hash. It returns whether the request is allowed and the
+// tokens left, in thousandths.
+var tokenBucketScript = redis.NewScript(`
+local key, now, window, limit = KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens, ts = tonumber(state[1]) or limit, tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - ts) * limit / window)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', key, window)
+return {allowed, math.floor(tokens * 1000)}
+`)
+
+// Take counts the request in Redis.
+func (s *RedisRateLimitStore) Take(ctx context.Context, rule RateLimitRule, key string, now time.Time) (*RateLimitResult, error) {
+	redisKey := "ratelimit:" + rule.Name + ":" + rule.Algorithm + ":" + key
+	nowMs, windowMs := now.UnixMilli(), rule.Window.Milliseconds()
+
+	if rule.Algorithm == RateLimitTokenBucket {
+		values, err := tokenBucketScript.Run(ctx, s.client, []string{redisKey}, nowMs, windowMs, rule.Limit).Int64Slice()
+		if err != nil {
+			return nil, fmt.Errorf("could not check rate limit: %w", err)
+		}
+		return bucketResult(rule, values[0] == 1, float64(values[1])/1000), nil
+	}
+
+	member := make([]byte, 8)
+	if _, err := rand.Read(member); err != nil {
+		return nil, fmt.Errorf("failed to generate rate limit entry: %w", err)
+	}
+	values, err := slidingWindowScript.Run(ctx, s.client, []string{redisKey}, nowMs, windowMs, rule.Limit, hex.EncodeToString(member)).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("could not check rate limit: %w", err)
+	}
+	return windowResult(rule, values[0] == 1, int(values[1]), time.Duration(values[2])*time.Millisecond), nil
+}
+
+// windowResult describes a sliding window check. untilOldestExpires is how
+// long until the oldest request counted leaves the window.
+func windowResult(rule RateLimitRule, allowed bool, remaining int, untilOldestExpires time.Duration) *RateLimitResult {
+	result := &RateLimitResult{Allowed: allowed, Limit: rule.Limit, Remaining: remaining, Reset: untilOldestExpires}
+	if !allowed {
+		result.RetryAfter = untilOldestExpires
+	}
+	return result
+}
+
+// bucketResult describes a token bucket check that left the given tokens.
+func bucketResult(rule RateLimitRule, allowed bool, tokens float64) *RateLimitResult {
+	result := &RateLimitResult{
+		Allowed:   allowed,
+		Limit:     rule.Limit,
+		Remaining: int(tokens),
+		Reset:     tokenRefill(rule, float64(rule.Limit)-tokens),
+	}
+	if !allowed {
+		result.RetryAfter = tokenRefill(rule, 1-tokens)
+	}
+	return result
+}
+
+// MemoryRateLimitStore counts requests in memory, for one instance only.
+// Idle counters are dropped once their window has passed.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+}
+
+type rateLimitEntry struct {
+	requests []time.Time // sliding window: times of the requests in the window
+	tokens   float64     // token bucket: tokens left at updated
+	updated  time.Time
+	expires  time.Time
+}
+
+// NewMemoryRateLimitStore creates a new, empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: make(map[string]*rateLimitEntry)}
+}
+
+// Take counts the request in memory. It never fails.
+func (s *MemoryRateLimitStore) Take(_ context.Context, rule RateLimitRule, key string, now time.Time) (*RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	entryKey := rule.Name + ":" + rule.Algorithm + ":" + key
+	entry, ok := s.entries[entryKey]
+	if !ok || !now.Before(entry.expires) {
+		entry = &rateLimitEntry{tokens: float64(rule.Limit), updated: now}
+		s.entries[entryKey] = entry
+	}
+	entry.expires = now.Add(rule.Window)
+
+	if rule.Algorithm == RateLimitTokenBucket {
+		elapsed := now.Sub(entry.updated)
+		if elapsed > 0 {
+			entry.tokens = math.Min(float64(rule.Limit), entry.tokens+float64(elapsed)*float64(rule.Limit)/float64(rule.Window))
+		}
+		entry.updated = now
+		allowed := entry.tokens >= 1
+		if allowed {
+			entry.tokens--
+		}
+		return bucketResult(rule, allowed, entry.tokens), nil
+	}
+
+	start := now.Add(-rule.Window)
+	kept := entry.requests[:0]
+	for _, t := range entry.requests {
+		if t.After(start) {
+			kept = append(kept, t)
+		}
+	}
+	entry.requests = kept
+	allowed := len(entry.requests) < rule.Limit
+	if allowed {
+		entry.requests = append(entry.requests, now)
+	}
+	return windowResult(rule, allowed, rule.Limit-len(entry.requests), entry.requests[0].Add(rule.Window).Sub(now)), nil
+}
+
+// sweep drops expired counters, at most once a minute.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// file: service/rate_limiter_test.go
+
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockRateLimitStore provides a mock for IRateLimitStore.
+type mockRateLimitStore struct{ mock.Mock }
+
+func (m *mockRateLimitStore) Take(_ context.Context, rule RateLimitRule, key string, now time.Time) (*RateLimitResult, error) {
+	args := m.Called(rule.Name, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*RateLimitResult), args.Error(1)
+}
+
+func TestMemoryRateLimitStore_SlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	rule := RateLimitRule{Name: "login", Limit: 2, Window: time.Minute, Key: RateLimitKeyIP, Algorithm: RateLimitSlidingWindow}
+	store := NewMemoryRateLimitStore()
+	start := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+
+	first, _ := store.Take(ctx, rule, "ip:10.0.0.1", start)
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+	second, _ := store.Take(ctx, rule, "ip:10.0.0.1", start.Add(20*time.Second))
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 0, second.Remaining)
+
+	refused, _ := store.Take(ctx, rule, "ip:10.0.0.1", start.Add(30*time.Second))
+	assert.False(t, refused.Allowed)
+	assert.Equal(t, 30*time.Second, refused.RetryAfter, "The first request leaves the window a minute after it was made")
+
+	other, _ := store.Take(ctx, rule, "ip:10.0.0.2", start.Add(30*time.Second))
+	assert.True(t, other.Allowed, "Keys are counted separately")
+
+	again, _ := store.Take(ctx, rule, "ip:10.0.0.1", start.Add(61*time.Second))
+	assert.True(t, again.Allowed)
+	assert.Equal(t, 0, again.Remaining, "The second request is still in the window")
+}
+
+func TestMemoryRateLimitStore_TokenBucket(t *testing.T) {
+	ctx := context.Background()
+	rule := RateLimitRule{Name: "transfers", Limit: 3, Window: 3 * time.Second, Key: RateLimitKeyUser, Algorithm: RateLimitTokenBucket}
+	store := NewMemoryRateLimitStore()
+	start := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		result, _ := store.Take(ctx, rule, "user:1", start)
+		assert.True(t, result.Allowed, "A full bucket allows a burst")
+	}
+	refused, _ := store.Take(ctx, rule, "user:1", start)
+	assert.False(t, refused.Allowed)
+	assert.Equal(t, time.Second, refused.RetryAfter)
+	assert.Equal(t, 3*time.Second, refused.Reset)
+
+	refilled, _ := store.Take(ctx, rule, "user:1", start.Add(time.Second))
+	assert.True(t, refilled.Allowed, "One token is back after a third of the window")
+	assert.Equal(t, 0, refilled.Remaining)
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	ctx := context.Background()
+	rule := RateLimitRule{Name: "login", Limit: 5, Window: time.Minute, Key: RateLimitKeyIP, Algorithm: RateLimitSlidingWindow}
+
+	t.Run("counts in the primary store", func(t *testing.T) {
+		primary := new(mockRateLimitStore)
+		primary.On("Take", "login", "ip:10.0.0.1").Return(&RateLimitResult{Allowed: false, Limit: 5}, nil).Once()
+		limiter, err := NewRateLimiter([]RateLimitRule{rule}, nil, primary, NewMemoryRateLimitStore())
+		assert.NoError(t, err)
+
+		assert.False(t, limiter.Allow(ctx, rule, "ip:10.0.0.1").Allowed)
+		primary.AssertExpectations(t)
+	})
+
+	t.Run("falls back to memory while the primary store fails", func(t *testing.T) {
+		primary := new(mockRateLimitStore)
+		primary.On("Take", "login", "ip:10.0.0.1").Return(nil, errors.New("connection refused")).Once()
+		limiter, err := NewRateLimiter([]RateLimitRule{rule}, nil, primary, NewMemoryRateLimitStore())
+		assert.NoError(t, err)
+		now := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+		limiter.now = func() time.Time { return now }
+
+		first := limiter.Allow(ctx, rule, "ip:10.0.0.1")
+		assert.True(t, first.Allowed)
+		assert.Equal(t, 4, first.Remaining)
+		second := limiter.Allow(ctx, rule, "ip:10.0.0.1")
+		assert.Equal(t, 3, second.Remaining, "The primary store is not retried at once")
+		primary.AssertNumberOfCalls(t, "Take", 1)
+
+		now = now.Add(rateLimitFallbackPeriod)
+		primary.On("Take", "login", "ip:10.0.0.1").Return(&RateLimitResult{Allowed: true, Limit: 5, Remaining: 4}, nil).Once()
+		limiter.Allow(ctx, rule, "ip:10.0.0.1")
+		primary.AssertNumberOfCalls(t, "Take", 2)
+	})
+}
+
+func TestNewRateLimiter_InvalidRule(t *testing.T) {
+	for _, rule := range []RateLimitRule{
+		{Name: "zero", Limit: 0, Window: time.Minute, Key: RateLimitKeyIP, Algorithm: RateLimitSlidingWindow},
+		{Name: "key", Limit: 1, Window: time.Minute, Key: "session", Algorithm: RateLimitSlidingWindow},
+		{Name: "algorithm", Limit: 1, Window: time.Minute, Key: RateLimitKeyIP, Algorithm: "fixed_window"},
+	} {
+		_, err := NewRateLimiter([]RateLimitRule{rule}, nil, nil, nil)
+		assert.Error(t, err, rule.Name)
+	}
+}
+
+func TestRateLimiter_APIKeyClient(t *testing.T) {
+	sum := sha256.Sum256([]byte("partner-key"))
+	limiter, err := NewRateLimiter(nil, map[string]string{"partner": hex.EncodeToString(sum[:])}, nil, nil)
+	assert.NoError(t, err)
+
+	client, ok := limiter.APIKeyClient("partner-key")
+	assert.True(t, ok)
+	assert.Equal(t, "partner", client)
+	_, ok = limiter.APIKeyClient("made-up-key")
+	assert.False(t, ok, "Unknown keys are not counted by key")
+	_, ok = limiter.APIKeyClient("")
+	assert.False(t, ok)
+
+	_, err = NewRateLimiter(nil, map[string]string{"partner": "partner-key"}, nil, nil)
+	assert.Error(t, err, "Keys are configured by their hash")
+}
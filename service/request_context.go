@@ -0,0 +1,28 @@
+// file: service/request_context.go
+
+package service
+
+import "context"
+
+// RequestInfo describes the HTTP request a service call is made for. The
+// handlers put it in the request context; the audit log reads it from there.
+type RequestInfo struct {
+	RequestID string
+	IPAddress string
+	UserAgent string
+	ActorID   int
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying info.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns the RequestInfo stored in ctx, or the zero value if
+// there is none, e.g. outside of HTTP requests.
+func RequestInfoFrom(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
@@ -0,0 +1,238 @@
+// file: service/role_service.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrRoleNotFound         = errors.New("role not found")
+	ErrRoleExists           = errors.New("a role with this name already exists")
+	ErrBuiltInRole          = errors.New("built-in roles cannot be changed or deleted")
+	ErrRoleInUse            = errors.New("role is still held by users")
+	ErrInvalidRoleName      = errors.New("role names must be 2 to 20 lowercase letters, digits, '-' or '_', starting with a letter")
+	ErrUnknownPermission    = errors.New("unknown permission")
+	ErrPermissionEscalation = errors.New("cannot grant permissions you do not have yourself")
+)
+
+// pqForeignKeyViolation is the PostgreSQL SQLSTATE for a foreign key violation.
+const pqForeignKeyViolation = "23503"
+
+// rolePermissionsCacheTTL bounds how long another instance may keep serving
+// permissions from the cache if invalidating it after a change failed.
+const rolePermissionsCacheTTL = 5 * time.Minute
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+// IPermissionChecker decides whether a role grants permissions. RoleService
+// implements it; handler.RequirePermission consults it on every request.
+type IPermissionChecker interface {
+	HasPermissions(ctx context.Context, role string, permissions ...model.Permission) (bool, error)
+}
+
+// IRoleGranter is the part of RoleService that UserService depends on to
+// assign roles.
+type IRoleGranter interface {
+	GetRole(ctx context.Context, name string) (*model.RoleDefinition, error)
+	CheckGrantable(ctx context.Context, actorRole string, permissions []model.Permission) error
+}
+
+// RoleService manages roles, the named permission sets users hold, and
+// answers permission checks from a cache of each role's permissions.
+// Nobody can grant permissions they do not hold themselves, so a role
+// manager cannot raise their own privileges through a role they define.
+type RoleService struct {
+	db       *sql.DB
+	roleRepo repository.IRoleRepository
+	cache    ICacheClient
+}
+
+// NewRoleService creates a new RoleService.
+func NewRoleService(db *sql.DB, roleRepo repository.IRoleRepository, cache ICacheClient) *RoleService {
+	return &RoleService{db: db, roleRepo: roleRepo, cache: cache}
+}
+
+func rolePermissionsKey(role string) string { return "role:permissions:" + role }
+
+// permissions returns the permissions of a role, cache-aside. Unknown roles
+// have none.
+func (s *RoleService) permissions(ctx context.Context, name string) ([]model.Permission, error) {
+	key := rolePermissionsKey(name)
+	if cached, err := s.cache.Get(ctx, key).Result(); err == nil {
+		var permissions []model.Permission
+		if err := json.Unmarshal([]byte(cached), &permissions); err == nil {
+			return permissions, nil
+		}
+	}
+
+	var permissions []model.Permission
+	role, err := s.roleRepo.GetRole(ctx, name)
+	switch {
+	case err == nil:
+		permissions = role.Permissions
+	case err != sql.ErrNoRows:
+		return nil, err
+	}
+	if data, err := json.Marshal(permissions); err == nil {
+		s.cache.Set(ctx, key, data, rolePermissionsCacheTTL)
+	}
+	return permissions, nil
+}
+
+// HasPermissions reports whether the role grants every one of the permissions.
+func (s *RoleService) HasPermissions(ctx context.Context, role string, permissions ...model.Permission) (bool, error) {
+	granted, err := s.permissions(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	return (&model.RoleDefinition{Permissions: granted}).HasPermissions(permissions...), nil
+}
+
+// CheckGrantable returns ErrUnknownPermission if any of the permissions does
+// not exist, or ErrPermissionEscalation if the actor's role does not grant
+// them all.
+func (s *RoleService) CheckGrantable(ctx context.Context, actorRole string, permissions []model.Permission) error {
+	for _, p := range permissions {
+		if !p.Valid() {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, p)
+		}
+	}
+	ok, err := s.HasPermissions(ctx, actorRole, permissions...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPermissionEscalation
+	}
+	return nil
+}
+
+// ListRoles returns every role with its permissions.
+func (s *RoleService) ListRoles(ctx context.Context) ([]*model.RoleDefinition, error) {
+	return s.roleRepo.ListRoles(ctx)
+}
+
+// GetRole returns a role with its permissions.
+func (s *RoleService) GetRole(ctx context.Context, name string) (*model.RoleDefinition, error) {
+	role, err := s.roleRepo.GetRole(ctx, name)
+	if err == sql.ErrNoRows {
+		return nil, ErrRoleNotFound
+	}
+	return role, err
+}
+
+// normalizePermissions sorts the permissions and drops duplicates.
+func normalizePermissions(permissions []model.Permission) []model.Permission {
+	seen := make(map[model.Permission]bool, len(permissions))
+	unique := make([]model.Permission, 0, len(permissions))
+	for _, p := range permissions {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i] < unique[j] })
+	return unique
+}
+
+// CreateRole defines a new role on behalf of an actor holding actorRole.
+func (s *RoleService) CreateRole(ctx context.Context, actorRole string, role *model.RoleDefinition) error {
+	if !roleNamePattern.MatchString(string(role.Name)) {
+		return ErrInvalidRoleName
+	}
+	role.Permissions = normalizePermissions(role.Permissions)
+	if err := s.CheckGrantable(ctx, actorRole, role.Permissions); err != nil {
+		return err
+	}
+
+	err := runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.roleRepo.CreateRole(ctx, tx, role); err != nil {
+			return err
+		}
+		return s.roleRepo.SetPermissions(ctx, tx, string(role.Name), role.Permissions)
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return ErrRoleExists
+		}
+		return err
+	}
+	// An earlier lookup may have cached the name as a role without permissions.
+	s.cache.Del(context.WithoutCancel(ctx), rolePermissionsKey(string(role.Name)))
+	logger.Log.WithFields(logrus.Fields{"role": role.Name, "permissions": role.Permissions}).Info("Role created")
+	return nil
+}
+
+// UpdateRole replaces the description and permissions of a role that is not
+// built in, on behalf of an actor holding actorRole. The actor must hold the
+// permissions the role has now as well as those it is given, so they cannot
+// take away permissions they could not grant. Users holding the role get the
+// new permissions at once.
+func (s *RoleService) UpdateRole(ctx context.Context, actorRole string, role *model.RoleDefinition) error {
+	current, err := s.GetRole(ctx, string(role.Name))
+	if err != nil {
+		return err
+	}
+	if current.BuiltIn {
+		return ErrBuiltInRole
+	}
+	role.Permissions = normalizePermissions(role.Permissions)
+	if err := s.CheckGrantable(ctx, actorRole, append(current.Permissions, role.Permissions...)); err != nil {
+		return err
+	}
+
+	err = runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.roleRepo.UpdateRole(ctx, tx, role); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		return s.roleRepo.SetPermissions(ctx, tx, string(role.Name), role.Permissions)
+	})
+	if err != nil {
+		return err
+	}
+	s.cache.Del(context.WithoutCancel(ctx), rolePermissionsKey(string(role.Name)))
+	logger.Log.WithFields(logrus.Fields{"role": role.Name, "permissions": role.Permissions}).Info("Role updated")
+	return nil
+}
+
+// DeleteRole deletes a role that is not built in and no user holds.
+func (s *RoleService) DeleteRole(ctx context.Context, name string) error {
+	current, err := s.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if current.BuiltIn {
+		return ErrBuiltInRole
+	}
+	if err := s.roleRepo.DeleteRole(ctx, name); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation {
+			return ErrRoleInUse
+		}
+		if err == sql.ErrNoRows {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+	s.cache.Del(context.WithoutCancel(ctx), rolePermissionsKey(name))
+	logger.Log.WithField("role", name).Info("Role deleted")
+	return nil
+}
@@ -0,0 +1,174 @@
+// file: service/role_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockRoleRepo provides a mock for IRoleRepository.
+type mockRoleRepo struct{ mock.Mock }
+
+func (m *mockRoleRepo) ListRoles(_ context.Context) ([]*model.RoleDefinition, error) {
+	args := m.Called()
+	return args.Get(0).([]*model.RoleDefinition), args.Error(1)
+}
+func (m *mockRoleRepo) GetRole(_ context.Context, name string) (*model.RoleDefinition, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleDefinition), args.Error(1)
+}
+func (m *mockRoleRepo) CreateRole(_ context.Context, tx *sql.Tx, role *model.RoleDefinition) error {
+	return m.Called(tx, role).Error(0)
+}
+func (m *mockRoleRepo) UpdateRole(_ context.Context, tx *sql.Tx, role *model.RoleDefinition) error {
+	return m.Called(tx, role).Error(0)
+}
+func (m *mockRoleRepo) SetPermissions(_ context.Context, tx *sql.Tx, name string, permissions []model.Permission) error {
+	return m.Called(tx, name, permissions).Error(0)
+}
+func (m *mockRoleRepo) DeleteRole(_ context.Context, name string) error {
+	return m.Called(name).Error(0)
+}
+
+func TestRoleService_HasPermissions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cache hit", func(t *testing.T) {
+		roleRepo, cache := new(mockRoleRepo), new(mockCacheClient)
+		cache.On("Get", mock.Anything, "role:permissions:support").Return(`["accounts:read","users:read"]`, nil).Once()
+
+		ok, err := NewRoleService(nil, roleRepo, cache).HasPermissions(ctx, "support", model.PermissionUsersRead)
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		roleRepo.AssertNotCalled(t, "GetRole", mock.Anything)
+	})
+
+	t.Run("cache miss loads the role and caches its permissions", func(t *testing.T) {
+		roleRepo, cache := new(mockRoleRepo), new(mockCacheClient)
+		cache.On("Get", mock.Anything, "role:permissions:support").Return("", redis.Nil).Once()
+		roleRepo.On("GetRole", "support").Return(testSupportRole, nil).Once()
+		cache.On("Set", mock.Anything, "role:permissions:support", mock.Anything, rolePermissionsCacheTTL).Return().Once()
+
+		ok, err := NewRoleService(nil, roleRepo, cache).HasPermissions(ctx, "support", model.PermissionUsersRead, model.PermissionAccountsDeposit)
+
+		assert.NoError(t, err)
+		assert.False(t, ok, "Every permission is required")
+		cache.AssertExpectations(t)
+	})
+
+	t.Run("unknown roles have no permissions", func(t *testing.T) {
+		roleRepo, cache := new(mockRoleRepo), new(mockCacheClient)
+		cache.On("Get", mock.Anything, "role:permissions:ghost").Return("", redis.Nil).Once()
+		roleRepo.On("GetRole", "ghost").Return(nil, sql.ErrNoRows).Once()
+		cache.On("Set", mock.Anything, "role:permissions:ghost", mock.Anything, rolePermissionsCacheTTL).Return().Once()
+
+		ok, err := NewRoleService(nil, roleRepo, cache).HasPermissions(ctx, "ghost", model.PermissionUsersRead)
+
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestRoleService_CheckGrantable(t *testing.T) {
+	ctx := context.Background()
+	cache := new(mockCacheClient)
+	cache.On("Get", mock.Anything, "role:permissions:support").Return(`["accounts:read","users:read"]`, nil)
+	roleService := NewRoleService(nil, nil, cache)
+
+	assert.NoError(t, roleService.CheckGrantable(ctx, "support", []model.Permission{model.PermissionUsersRead}))
+	assert.ErrorIs(t, roleService.CheckGrantable(ctx, "support", []model.Permission{"users:delete"}), ErrUnknownPermission)
+	assert.Equal(t, ErrPermissionEscalation, roleService.CheckGrantable(ctx, "support", []model.Permission{model.PermissionAccountsDeposit}))
+}
+
+func TestRoleService_CreateRole(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		roleRepo, cache := new(mockRoleRepo), new(mockCacheClient)
+		cache.On("Get", mock.Anything, "role:permissions:admin").Return(`["accounts:read","users:read"]`, nil).Once()
+		dbMock.ExpectBegin()
+		roleRepo.On("CreateRole", mock.Anything, mock.Anything).Return(nil).Once()
+		roleRepo.On("SetPermissions", mock.Anything, "auditor", []model.Permission{model.PermissionAccountsRead, model.PermissionUsersRead}).Return(nil).Once()
+		dbMock.ExpectCommit()
+		cache.On("Del", mock.Anything, "role:permissions:auditor").Return().Once()
+
+		role := &model.RoleDefinition{Name: "auditor", Permissions: []model.Permission{model.PermissionUsersRead, model.PermissionAccountsRead, model.PermissionUsersRead}}
+		err = NewRoleService(db, roleRepo, cache).CreateRole(ctx, "admin", role)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Permission{model.PermissionAccountsRead, model.PermissionUsersRead}, role.Permissions, "Permissions are sorted and deduplicated")
+		roleRepo.AssertExpectations(t)
+		cache.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		roleRepo, cache := new(mockRoleRepo), new(mockCacheClient)
+		cache.On("Get", mock.Anything, "role:permissions:admin").Return(`[]`, nil).Once()
+		dbMock.ExpectBegin()
+		roleRepo.On("CreateRole", mock.Anything, mock.Anything).Return(&pq.Error{Code: pqUniqueViolation}).Once()
+		dbMock.ExpectRollback()
+
+		err = NewRoleService(db, roleRepo, cache).CreateRole(ctx, "admin", &model.RoleDefinition{Name: "support", Permissions: []model.Permission{}})
+
+		assert.Equal(t, ErrRoleExists, err)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		err := NewRoleService(nil, nil, nil).CreateRole(ctx, "admin", &model.RoleDefinition{Name: "Support Staff"})
+
+		assert.Equal(t, ErrInvalidRoleName, err)
+	})
+}
+
+func TestRoleService_UpdateAndDeleteRole(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("built-in roles cannot be changed", func(t *testing.T) {
+		roleRepo := new(mockRoleRepo)
+		roleRepo.On("GetRole", "admin").Return(&model.RoleDefinition{Name: model.RoleAdmin, BuiltIn: true}, nil)
+		roleService := NewRoleService(nil, roleRepo, nil)
+
+		assert.Equal(t, ErrBuiltInRole, roleService.UpdateRole(ctx, "admin", &model.RoleDefinition{Name: model.RoleAdmin}))
+		assert.Equal(t, ErrBuiltInRole, roleService.DeleteRole(ctx, "admin"))
+		roleRepo.AssertNotCalled(t, "DeleteRole", mock.Anything)
+	})
+
+	t.Run("removing permissions the actor lacks is refused", func(t *testing.T) {
+		roleRepo, cache := new(mockRoleRepo), new(mockCacheClient)
+		roleRepo.On("GetRole", "support").Return(testSupportRole, nil).Once()
+		cache.On("Get", mock.Anything, "role:permissions:helpdesk").Return(`["users:read","roles:write"]`, nil).Once()
+
+		err := NewRoleService(nil, roleRepo, cache).UpdateRole(ctx, "helpdesk", &model.RoleDefinition{Name: "support", Permissions: []model.Permission{model.PermissionUsersRead}})
+
+		assert.Equal(t, ErrPermissionEscalation, err)
+		roleRepo.AssertNotCalled(t, "UpdateRole", mock.Anything, mock.Anything)
+	})
+
+	t.Run("roles held by users cannot be deleted", func(t *testing.T) {
+		roleRepo := new(mockRoleRepo)
+		roleRepo.On("GetRole", "support").Return(testSupportRole, nil).Once()
+		roleRepo.On("DeleteRole", "support").Return(&pq.Error{Code: pqForeignKeyViolation}).Once()
+
+		assert.Equal(t, ErrRoleInUse, NewRoleService(nil, roleRepo, nil).DeleteRole(ctx, "support"))
+	})
+}
@@ -0,0 +1,58 @@
+// file: service/secret_box_test.go
+
+package service
+
+import (
+	"encoding/base64"
+	"go-bank-api/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretBox(t *testing.T) {
+	box := testSecretBox(t)
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP", "mfa_totp:7")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, sealedSecretPrefix))
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	again, err := box.Seal("JBSWY3DPEHPK3PXP", "mfa_totp:7")
+	assert.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "Every seal uses a fresh nonce")
+
+	opened, err := box.Open(sealed, "mfa_totp:7")
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", opened)
+
+	_, err = box.Open(sealed, "mfa_totp:8")
+	assert.Error(t, err, "The label is authenticated")
+
+	other, err := NewSecretBox([]byte("fedcba9876543210fedcba9876543210"))
+	assert.NoError(t, err)
+	_, err = other.Open(sealed, "mfa_totp:7")
+	assert.Error(t, err, "Another key does not decrypt")
+
+	plain, err := box.Open("JBSWY3DPEHPK3PXP", "mfa_totp:7")
+	assert.Equal(t, ErrSecretNotSealed, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plain)
+}
+
+func TestNewConfiguredSecretBox(t *testing.T) {
+	saved := config.AppConfig.MFA.EncryptionKey
+	defer func() { config.AppConfig.MFA.EncryptionKey = saved }()
+
+	config.AppConfig.MFA.EncryptionKey = ""
+	_, err := NewConfiguredSecretBox()
+	assert.Error(t, err, "The key is required")
+
+	config.AppConfig.MFA.EncryptionKey = base64.StdEncoding.EncodeToString([]byte("too short"))
+	_, err = NewConfiguredSecretBox()
+	assert.Error(t, err)
+
+	config.AppConfig.MFA.EncryptionKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	_, err = NewConfiguredSecretBox()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,113 @@
+// file: service/token_denylist_test.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"go-bank-api/model"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockDenylistClient provides a mock for IDenylistClient.
+type mockDenylistClient struct{ mock.Mock }
+
+func (m *mockDenylistClient) Set(ctx context.Context, key string, val interface{}, exp time.Duration) *redis.StatusCmd {
+	args := m.Called(key, val, exp)
+	cmd := redis.NewStatusCmd(ctx)
+	cmd.SetErr(args.Error(0))
+	return cmd
+}
+func (m *mockDenylistClient) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	args := m.Called(keys)
+	cmd := redis.NewSliceCmd(ctx)
+	if args.Get(0) != nil {
+		cmd.SetVal(args.Get(0).([]interface{}))
+	}
+	cmd.SetErr(args.Error(1))
+	return cmd
+}
+
+func TestTokenDenylist_IsRevoked(t *testing.T) {
+	ctx := context.Background()
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	claims := &model.AppClaims{UserID: 7, SessionID: 3, RegisteredClaims: jwt.RegisteredClaims{ID: "jti-1", IssuedAt: jwt.NewNumericDate(issuedAt)}}
+	keys := []string{"denylist:token:jti-1", "denylist:session:3", "denylist:user:7"}
+	unix := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   bool
+	}{
+		{"not revoked", []interface{}{nil, nil, nil}, false},
+		{"token revoked", []interface{}{"1", nil, nil}, true},
+		{"session revoked", []interface{}{nil, "1", nil}, true},
+		{"user revoked after the token was issued", []interface{}{nil, nil, unix(issuedAt.Add(time.Second))}, true},
+		{"user revoked in the second the token was issued", []interface{}{nil, nil, unix(issuedAt)}, true},
+		{"token issued after the user was revoked", []interface{}{nil, nil, unix(issuedAt.Add(-time.Second))}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new(mockDenylistClient)
+			client.On("MGet", keys).Return(tt.values, nil).Once()
+
+			revoked, err := NewTokenDenylist(client, time.Minute).IsRevoked(ctx, claims)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, revoked)
+		})
+	}
+
+	t.Run("redis error", func(t *testing.T) {
+		client := new(mockDenylistClient)
+		client.On("MGet", keys).Return(nil, errors.New("connection refused")).Once()
+
+		_, err := NewTokenDenylist(client, time.Minute).IsRevoked(ctx, claims)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestTokenDenylist_Revoke(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("token is kept until it expires", func(t *testing.T) {
+		client := new(mockDenylistClient)
+		client.On("Set", "denylist:token:jti-1", 1, mock.MatchedBy(func(ttl time.Duration) bool {
+			return ttl > 4*time.Minute && ttl <= 5*time.Minute
+		})).Return(nil).Once()
+
+		err := NewTokenDenylist(client, 15*time.Minute).RevokeToken(ctx, "jti-1", time.Now().Add(5*time.Minute))
+
+		assert.NoError(t, err)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("expired token is not stored", func(t *testing.T) {
+		client := new(mockDenylistClient)
+
+		err := NewTokenDenylist(client, 15*time.Minute).RevokeToken(ctx, "jti-1", time.Now().Add(-time.Minute))
+
+		assert.NoError(t, err)
+		client.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("session and user are kept for the token lifetime", func(t *testing.T) {
+		client := new(mockDenylistClient)
+		client.On("Set", "denylist:session:3", 1, 15*time.Minute).Return(nil).Once()
+		client.On("Set", "denylist:user:7", mock.AnythingOfType("int64"), 15*time.Minute).Return(nil).Once()
+		denylist := NewTokenDenylist(client, 15*time.Minute)
+
+		assert.NoError(t, denylist.RevokeSession(ctx, 3))
+		assert.NoError(t, denylist.RevokeUser(ctx, 7))
+		client.AssertExpectations(t)
+	})
+}
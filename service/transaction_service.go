@@ -5,9 +5,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"go-bank-api/config"
 	"go-bank-api/logger"
 	"go-bank-api/model"
 	"go-bank-api/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 )
@@ -21,114 +25,355 @@ var (
 	ErrCurrencyMismatch        = errors.New("currency mismatch between accounts")
 	ErrInvalidAmount           = errors.New("transfer amount must be greater than zero")
 	ErrAccountNotFound         = errors.New("account not found")
+	ErrBeneficiaryRequired     = errors.New("an account number or IBAN is required")
+	ErrInvalidDirection        = errors.New("direction must be incoming or outgoing")
+	ErrInvalidDateRange        = errors.New("from must be before to")
+	ErrInvalidAmountRange      = errors.New("min_amount must not exceed max_amount")
 )
 
 type TransactionService struct {
 	db              *sql.DB
 	accountRepo     repository.IAccountRepository
 	transactionRepo repository.ITransactionRepository
+	ledgerRepo      repository.ILedgerRepository
+	payeeRepo       repository.IPayeeRepository
+	quoteRepo       repository.IFXQuoteRepository
+	fx              IFXPricer
+	audit           IAuditRecorder
 }
 
-func NewTransactionService(db *sql.DB, accountRepo repository.IAccountRepository, transactionRepo repository.ITransactionRepository) *TransactionService {
+// IFXPricer prices cross-currency transfers. FXService implements it; tests
+// can substitute a mock.
+type IFXPricer interface {
+	Price(ctx context.Context, source model.Money, targetCurrency string) (*model.FXDetails, error)
+}
+
+func NewTransactionService(db *sql.DB, accountRepo repository.IAccountRepository, transactionRepo repository.ITransactionRepository, ledgerRepo repository.ILedgerRepository, payeeRepo repository.IPayeeRepository, quoteRepo repository.IFXQuoteRepository, fx IFXPricer, audit IAuditRecorder) *TransactionService {
 	return &TransactionService{
 		db:              db,
 		accountRepo:     accountRepo,
 		transactionRepo: transactionRepo,
+		ledgerRepo:      ledgerRepo,
+		payeeRepo:       payeeRepo,
+		quoteRepo:       quoteRepo,
+		fx:              fx,
+		audit:           audit,
 	}
 }
 
 // TransferRequest defines the structure for a money transfer. from_account_id is now sourced from the URL.
+// The receiver is addressed by exactly one of to_account_number, to_iban or payee_id (a verified
+// payee of the sender); to_account_id is the internal ID and is only kept for existing clients.
+// The amount is interpreted in the sender account's currency. Transfers to an account in another
+// currency are converted at the rate locked by quote_id, or at the current rate without one.
 type TransferRequest struct {
-	ToAccountID int     `json:"to_account_id" validate:"required"`
-	Amount      float64 `json:"amount" validate:"required,gt=0"`
+	ToAccountNumber string       `json:"to_account_number,omitempty" validate:"omitempty,account_number" example:"10000000256"`
+	ToIBAN          string       `json:"to_iban,omitempty" validate:"omitempty,iban,excluded_with=ToAccountNumber"`
+	PayeeID         int          `json:"payee_id,omitempty" validate:"excluded_with=ToAccountNumber ToIBAN"`
+	ToAccountID     int          `json:"to_account_id,omitempty" validate:"required_without_all=ToAccountNumber ToIBAN PayeeID,excluded_with=ToAccountNumber ToIBAN PayeeID"`
+	QuoteID         string       `json:"quote_id,omitempty" validate:"omitempty,uuid"`
+	Amount          model.Amount `json:"amount" validate:"required" swaggertype:"string" example:"150.75"`
 }
 
 // TransferMoney now accepts fromAccountID directly, making the function signature more explicit and aligned with the new endpoint design.
 func (s *TransactionService) TransferMoney(ctx context.Context, userID, fromAccountID int, req TransferRequest) (*model.Transaction, error) {
 	log := logger.Log.WithFields(logrus.Fields{
-		"from_account_id": fromAccountID,
-		"to_account_id":   req.ToAccountID,
-		"amount":          req.Amount,
-		"user_id":         userID,
+		"from_account_id":   fromAccountID,
+		"to_account_number": req.ToAccountNumber,
+		"payee_id":          req.PayeeID,
+		"to_account_id":     req.ToAccountID,
+		"amount":            req.Amount,
+		"user_id":           userID,
 	})
 
 	log.Info("Starting money transfer process")
 
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	toAccountID := req.ToAccountID
+	if toAccountID == 0 {
+		var toAccount *model.Account
+		var err error
+		if req.PayeeID != 0 {
+			toAccount, err = s.findPayeeAccount(ctx, userID, req.PayeeID)
+		} else {
+			toAccount, err = s.findBeneficiaryAccount(ctx, req.ToAccountNumber, req.ToIBAN)
+		}
+		if err != nil {
+			return nil, err
+		}
+		toAccountID = toAccount.ID
 	}
-	defer tx.Rollback()
 
-	if fromAccountID == req.ToAccountID {
+	if fromAccountID == toAccountID {
 		return nil, ErrSameAccountTransfer
 	}
-	if req.Amount <= 0 {
-		return nil, ErrInvalidAmount
-	}
 
-	fromAccount, err := s.accountRepo.GetAccountForUpdate(tx, fromAccountID)
+	transaction, err := s.transfer(ctx, userID, fromAccountID, toAccountID, req, nil)
+	var pricing *fxPricingRequiredError
+	if errors.As(err, &pricing) {
+		// The rate is fetched with no rows locked, so a slow rate provider
+		// never holds up other transfers, and the transfer is then retried.
+		log.Info("Pricing cross-currency transfer")
+		fx, priceErr := s.fx.Price(ctx, pricing.source, pricing.targetCurrency)
+		if priceErr != nil {
+			return nil, priceErr
+		}
+		transaction, err = s.transfer(ctx, userID, fromAccountID, toAccountID, req, fx)
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
+		return nil, err
+	}
+
+	log.Info("Transaction completed successfully")
+	return transaction, nil
+}
+
+// fxPricingRequiredError is returned by transfer when the accounts are in
+// different currencies and the transfer was neither quoted nor priced yet.
+type fxPricingRequiredError struct {
+	source         model.Money
+	targetCurrency string
+}
+
+func (e *fxPricingRequiredError) Error() string {
+	return "cross-currency transfer of " + e.source.String() + " to " + e.targetCurrency + " needs pricing"
+}
+
+// transfer moves money between two customer accounts in one database
+// transaction, which also records the transfer in the audit log.
+// Cross-currency transfers use the quote named in the request or, failing
+// that, the given pricing.
+func (s *TransactionService) transfer(ctx context.Context, userID, fromAccountID, toAccountID int, req TransferRequest, pricing *model.FXDetails) (*model.Transaction, error) {
+	var transaction *model.Transaction
+	err := runInAuditedTx(ctx, s.db, s.audit, func(tx *sql.Tx) (*model.AuditEvent, error) {
+		// Both rows are locked in one statement in a fixed order, so opposite
+		// transfers between the same accounts cannot deadlock each other.
+		accounts, err := s.accountRepo.GetAccountsForUpdate(ctx, tx, fromAccountID, toAccountID)
+		if err != nil {
+			return nil, err
+		}
+		fromAccount, ok := accounts[fromAccountID]
+		if !ok {
 			return nil, ErrSenderAccountNotFound
 		}
+		toAccount, ok := accounts[toAccountID]
+		// System accounts can only be reached through deposits, fees and reversals.
+		if !ok || toAccount.Kind != model.AccountKindCustomer {
+			return nil, ErrReceiverAccountNotFound
+		}
+		if fromAccount.UserID != userID {
+			return nil, ErrPermissionDenied
+		}
+
+		amount, err := req.Amount.In(fromAccount.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if !amount.IsPositive() {
+			return nil, ErrInvalidAmount
+		}
+
+		remaining, err := fromAccount.Balance.Sub(amount)
+		if err != nil {
+			return nil, err
+		}
+		if remaining.IsNegative() {
+			return nil, ErrInsufficientFunds
+		}
+
+		if fromAccount.Currency == toAccount.Currency {
+			if req.QuoteID != "" {
+				return nil, ErrQuoteMismatch
+			}
+			transaction, err = recordMovement(ctx, tx, s.transactionRepo, s.ledgerRepo, model.EntryKindTransfer, fromAccount, toAccount, amount, "Transfer")
+			if err != nil {
+				return nil, err
+			}
+			return transferEvent(transaction, fromAccount, toAccount, amount)
+		}
+
+		fx := pricing
+		if req.QuoteID != "" {
+			if fx, err = s.useQuote(ctx, tx, userID, req.QuoteID, amount, toAccount.Currency); err != nil {
+				return nil, err
+			}
+		} else if fx == nil {
+			return nil, &fxPricingRequiredError{source: amount, targetCurrency: toAccount.Currency}
+		}
+		if fx.DestinationAmount.Currency != toAccount.Currency {
+			return nil, ErrCurrencyMismatch
+		}
+
+		// Position accounts are system accounts, locked after the customer
+		// accounts and in ID order, matching GetAccountsForUpdate.
+		positions, err := s.accountRepo.GetSystemAccountsForUpdate(ctx, tx, model.AccountKindFXPosition, fromAccount.Currency, toAccount.Currency)
+		if err != nil {
+			return nil, err
+		}
+		fromPosition, toPosition := positions[fromAccount.Currency], positions[toAccount.Currency]
+		if fromPosition == nil || toPosition == nil {
+			return nil, fmt.Errorf("could not load FX position accounts: %w", sql.ErrNoRows)
+		}
+
+		transaction, err = recordFXMovement(ctx, tx, s.transactionRepo, s.ledgerRepo, fromAccount, toAccount, fromPosition, toPosition, amount, fx, "Transfer")
+		if err != nil {
+			return nil, err
+		}
+		return transferEvent(transaction, fromAccount, toAccount, fx.DestinationAmount)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return transaction, nil
+}
 
-	toAccount, err := s.accountRepo.GetAccountForUpdate(tx, req.ToAccountID)
+// transferEvent builds the audit event of a transfer, with the balances of
+// both accounts before and after it. credited is the amount the receiver is
+// paid, in the receiver's currency.
+func transferEvent(transaction *model.Transaction, from, to *model.Account, credited model.Money) (*model.AuditEvent, error) {
+	fromBalance, err := from.Balance.Sub(transaction.Amount)
+	if err != nil {
+		return nil, err
+	}
+	toBalance, err := to.Balance.Add(credited)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, ErrReceiverAccountNotFound
-		}
 		return nil, err
 	}
 
-	if fromAccount.UserID != userID {
-		return nil, ErrPermissionDenied
+	before := map[string]interface{}{
+		"from_account": map[string]interface{}{"id": from.ID, "balance": from.Balance},
+		"to_account":   map[string]interface{}{"id": to.ID, "balance": to.Balance},
+	}
+	after := map[string]interface{}{
+		"from_account": map[string]interface{}{"id": from.ID, "balance": fromBalance},
+		"to_account":   map[string]interface{}{"id": to.ID, "balance": toBalance},
+		"amount":       transaction.Amount,
+	}
+	if transaction.FX != nil {
+		after["fx"] = transaction.FX
+	}
+	return newAuditEvent(model.AuditEventTransfer, model.AuditTargetTransaction, transaction.ID, before, after), nil
+}
+
+// useQuote consumes one of the user's quotes for a transfer of source into
+// the target currency and returns its pricing.
+func (s *TransactionService) useQuote(ctx context.Context, tx *sql.Tx, userID int, quoteID string, source model.Money, targetCurrency string) (*model.FXDetails, error) {
+	quote, err := s.quoteRepo.GetQuoteForUpdate(ctx, tx, quoteID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrQuoteNotFound
+		}
+		return nil, err
 	}
-	if fromAccount.Balance < req.Amount {
-		return nil, ErrInsufficientFunds
+	switch {
+	case quote.UserID != userID:
+		return nil, ErrQuoteNotFound
+	case quote.UsedAt != nil:
+		return nil, ErrQuoteUsed
+	case time.Now().After(quote.ExpiresAt):
+		return nil, ErrQuoteExpired
+	case quote.SourceAmount != source || quote.DestinationAmount.Currency != targetCurrency:
+		return nil, ErrQuoteMismatch
 	}
-	if fromAccount.Currency != toAccount.Currency {
-		return nil, ErrCurrencyMismatch
+	if err := s.quoteRepo.MarkQuoteUsed(ctx, tx, quote.ID); err != nil {
+		return nil, err
 	}
+	return quote.Details(), nil
+}
 
-	err = s.accountRepo.UpdateAccountBalance(tx, fromAccount.ID, fromAccount.Balance-req.Amount)
+// LookupBeneficiary resolves an account number or IBAN to the receiving account
+// so the sender can confirm the masked holder name before transferring.
+func (s *TransactionService) LookupBeneficiary(ctx context.Context, accountNumber, iban string) (*model.Beneficiary, error) {
+	account, err := s.findBeneficiaryAccount(ctx, accountNumber, iban)
 	if err != nil {
-		return nil, fmt.Errorf("could not update sender balance: %w", err)
+		return nil, err
 	}
 
-	err = s.accountRepo.UpdateAccountBalance(tx, toAccount.ID, toAccount.Balance+req.Amount)
+	holder, err := s.accountRepo.GetHolderName(ctx, account.ID)
 	if err != nil {
-		return nil, fmt.Errorf("could not update receiver balance: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, ErrReceiverAccountNotFound
+		}
+		return nil, err
 	}
 
-	transaction := &model.Transaction{
-		FromAccountID: fromAccountID,
-		ToAccountID:   req.ToAccountID,
-		Amount:        req.Amount,
+	beneficiary := &model.Beneficiary{
+		AccountNumber: account.AccountNumber,
+		Currency:      account.Currency,
+		HolderName:    maskName(holder),
 	}
+	setIBAN(account)
+	beneficiary.IBAN = account.IBAN
+	return beneficiary, nil
+}
 
-	err = s.transactionRepo.CreateTransaction(tx, transaction)
+// findBeneficiaryAccount loads the customer account addressed by a public
+// account number or by an IBAN issued by this bank.
+func (s *TransactionService) findBeneficiaryAccount(ctx context.Context, accountNumber, iban string) (*model.Account, error) {
+	var number int64
+	var err error
+	switch {
+	case iban != "":
+		number, err = model.AccountNumberFromIBAN(iban, config.AppConfig.IBAN.BankCode)
+	case accountNumber != "":
+		number, err = model.ParseAccountNumber(accountNumber)
+	default:
+		return nil, ErrBeneficiaryRequired
+	}
 	if err != nil {
-		return nil, fmt.Errorf("could not create transaction record: %w", err)
+		return nil, err
 	}
+	return s.findAccountByNumber(ctx, number)
+}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("could not commit transaction: %w", err)
+// findPayeeAccount loads the account of one of the user's verified payees.
+func (s *TransactionService) findPayeeAccount(ctx context.Context, userID, payeeID int) (*model.Account, error) {
+	payee, err := s.payeeRepo.GetPayeeByID(ctx, payeeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPayeeNotFound
+		}
+		return nil, err
+	}
+	if payee.UserID != userID {
+		return nil, ErrPayeeNotFound
+	}
+	if !payee.Verified() {
+		return nil, ErrPayeeNotVerified
 	}
+	return s.findAccountByNumber(ctx, payee.AccountNumber)
+}
 
-	log.Info("Transaction completed successfully")
-	return transaction, nil
+func (s *TransactionService) findAccountByNumber(ctx context.Context, number int64) (*model.Account, error) {
+	account, err := s.accountRepo.GetAccountByNumber(ctx, number)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReceiverAccountNotFound
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+// maskName keeps the first letter of each word of a name and hides the rest,
+// e.g. "jane doe" becomes "j*** d***".
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		first, _ := utf8.DecodeRuneInString(word)
+		words[i] = string(first) + "***"
+	}
+	return strings.Join(words, " ")
 }
 
-func (s *TransactionService) ListTransactionsForAccount(ctx context.Context, userID, accountID int) ([]*model.Transaction, error) {
+// ListTransactionsForAccount returns one page of the history of an account
+// owned by the user, filtered and ordered as the query asks.
+func (s *TransactionService) ListTransactionsForAccount(ctx context.Context, userID, accountID int, query model.TransactionQuery) (*model.TransactionPage, error) {
 	log := logger.Log.WithFields(logrus.Fields{
 		"requesting_user_id": userID,
 		"target_account_id":  accountID,
 	})
 
-	account, err := s.accountRepo.GetAccountByID(accountID)
+	account, err := s.accountRepo.GetAccountByID(ctx, accountID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrAccountNotFound
@@ -141,5 +386,76 @@ func (s *TransactionService) ListTransactionsForAccount(ctx context.Context, use
 		return nil, ErrPermissionDenied
 	}
 
-	return s.transactionRepo.GetTransactionsByAccountID(accountID)
+	filter, err := historyFilter(account, query)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.transactionRepo.ListTransactions(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.TransactionPage{}
+	page.Transactions, page.NextCursor = paginate(transactions, filter.Limit, func(t *model.Transaction) model.Cursor {
+		return model.Cursor{CreatedAt: t.CreatedAt, ID: t.ID}
+	})
+	return page, nil
+}
+
+// historyFilter validates a history query against the account and turns it
+// into a repository filter. Amounts are read in the account's currency.
+func historyFilter(account *model.Account, query model.TransactionQuery) (model.TransactionFilter, error) {
+	filter := model.TransactionFilter{
+		AccountID: account.ID,
+		Limit:     pageLimit(query.Limit),
+		From:      query.From,
+		To:        query.To,
+		Ascending: query.Ascending,
+	}
+
+	if query.Cursor != "" {
+		cursor, err := model.DecodeCursor(query.Cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &cursor
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return filter, ErrInvalidDateRange
+	}
+
+	switch query.Direction {
+	case "", model.DirectionIncoming, model.DirectionOutgoing:
+		filter.Direction = query.Direction
+	default:
+		return filter, ErrInvalidDirection
+	}
+
+	if query.MinAmount != "" {
+		min, err := query.MinAmount.In(account.Currency)
+		if err != nil {
+			return filter, err
+		}
+		filter.MinAmount = &min
+	}
+	if query.MaxAmount != "" {
+		max, err := query.MaxAmount.In(account.Currency)
+		if err != nil {
+			return filter, err
+		}
+		filter.MaxAmount = &max
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && filter.MinAmount.MinorUnits > filter.MaxAmount.MinorUnits {
+		return filter, ErrInvalidAmountRange
+	}
+
+	if query.Counterparty != "" {
+		number, err := model.ParseAccountNumber(query.Counterparty)
+		if err != nil {
+			return filter, err
+		}
+		filter.CounterpartyAccountNumber = number
+	}
+	return filter, nil
 }
@@ -6,10 +6,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"go-bank-api/config"
 	"go-bank-api/logger"
 	"go-bank-api/model"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -25,47 +27,129 @@ func TestMain(m *testing.M) {
 // MockAccountRepository is a mock for IAccountRepository.
 type MockAccountRepository struct{ mock.Mock }
 
-func (m *MockAccountRepository) CreateAccount(*model.Account) error                { return nil }
-func (m *MockAccountRepository) GetAccountsByUserID(int) ([]*model.Account, error) { return nil, nil }
-func (m *MockAccountRepository) GetAllAccounts() ([]*model.Account, error)         { return nil, nil }
-func (m *MockAccountRepository) DepositToAccount(int, float64) (*model.Account, error) {
+func (m *MockAccountRepository) CreateAccount(context.Context, *model.Account) error { return nil }
+func (m *MockAccountRepository) GetAccountsByUserID(context.Context, int) ([]*model.Account, error) {
 	return nil, nil
 }
-func (m *MockAccountRepository) GetLastAccountNumber() (int64, error) { return 0, nil }
-func (m *MockAccountRepository) GetAccountByID(id int) (*model.Account, error) {
+func (m *MockAccountRepository) ListAccounts(context.Context, model.AccountFilter) ([]*model.Account, int, error) {
+	return nil, 0, nil
+}
+func (m *MockAccountRepository) NextAccountNumberBase(context.Context) (int64, error) { return 0, nil }
+func (m *MockAccountRepository) GetAccountByID(_ context.Context, id int) (*model.Account, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Account), args.Error(1)
 }
-func (m *MockAccountRepository) GetAccountForUpdate(tx *sql.Tx, id int) (*model.Account, error) {
+func (m *MockAccountRepository) GetAccountByNumber(_ context.Context, number int64) (*model.Account, error) {
+	args := m.Called(number)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+func (m *MockAccountRepository) GetHolderName(_ context.Context, id int) (string, error) {
+	args := m.Called(id)
+	return args.String(0), args.Error(1)
+}
+func (m *MockAccountRepository) GetAccountForUpdate(_ context.Context, tx *sql.Tx, id int) (*model.Account, error) {
 	args := m.Called(tx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Account), args.Error(1)
 }
-func (m *MockAccountRepository) UpdateAccountBalance(tx *sql.Tx, id int, bal float64) error {
-	return m.Called(tx, id, bal).Error(0)
+func (m *MockAccountRepository) GetAccountsForUpdate(_ context.Context, tx *sql.Tx, ids ...int) (map[int]*model.Account, error) {
+	args := m.Called(tx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*model.Account), args.Error(1)
+}
+func (m *MockAccountRepository) GetSystemAccountForUpdate(_ context.Context, tx *sql.Tx, kind, currency string) (*model.Account, error) {
+	args := m.Called(tx, kind, currency)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+func (m *MockAccountRepository) GetSystemAccountsForUpdate(_ context.Context, tx *sql.Tx, kind string, currencies ...string) (map[string]*model.Account, error) {
+	args := m.Called(tx, kind, currencies)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*model.Account), args.Error(1)
 }
 
 // MockTransactionRepository is a mock for ITransactionRepository.
 type MockTransactionRepository struct{ mock.Mock }
 
-func (m *MockTransactionRepository) CreateTransaction(tx *sql.Tx, tr *model.Transaction) error {
+func (m *MockTransactionRepository) CreateTransaction(_ context.Context, tx *sql.Tx, tr *model.Transaction) error {
 	return m.Called(tx, tr).Error(0)
 }
 
-// Correctly implements the new method for the interface.
-func (m *MockTransactionRepository) GetTransactionsByAccountID(id int) ([]*model.Transaction, error) {
+func (m *MockTransactionRepository) GetTransactionByID(_ context.Context, id int) (*model.Transaction, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
+	return args.Get(0).(*model.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) ListTransactions(_ context.Context, filter model.TransactionFilter) ([]*model.Transaction, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).([]*model.Transaction), args.Error(1)
 }
 
+// MockLedgerRepository is a mock for ILedgerRepository.
+type MockLedgerRepository struct{ mock.Mock }
+
+func (m *MockLedgerRepository) PostEntry(_ context.Context, tx *sql.Tx, entry *model.JournalEntry) error {
+	return m.Called(tx, entry).Error(0)
+}
+func (m *MockLedgerRepository) GetEntryByTransactionID(_ context.Context, tx *sql.Tx, id int) (*model.JournalEntry, error) {
+	args := m.Called(tx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.JournalEntry), args.Error(1)
+}
+func (m *MockLedgerRepository) IsEntryReversed(_ context.Context, tx *sql.Tx, id int) (bool, error) {
+	args := m.Called(tx, id)
+	return args.Bool(0), args.Error(1)
+}
+func (m *MockLedgerRepository) GetBalanceMismatches(ctx context.Context) ([]model.BalanceMismatch, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.BalanceMismatch), args.Error(1)
+}
+func (m *MockLedgerRepository) GetUnbalancedEntryIDs(ctx context.Context) ([]int, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+// accountsByID builds the result of a GetAccountsForUpdate call.
+func accountsByID(accounts ...*model.Account) map[int]*model.Account {
+	byID := make(map[int]*model.Account, len(accounts))
+	for _, acc := range accounts {
+		byID[acc.ID] = acc
+	}
+	return byID
+}
+
+// isBalancedEntry matches a journal entry of the given kind that debits and
+// credits the given accounts by amount.
+func isBalancedEntry(kind string, debitAccountID, creditAccountID int, amount model.Money) interface{} {
+	return mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Kind == kind && e.Validate() == nil && len(e.Postings) == 2 &&
+			e.Postings[0] == model.Debit(debitAccountID, amount) &&
+			e.Postings[1] == model.Credit(creditAccountID, amount)
+	})
+}
+
 func TestTransactionService_TransferMoney(t *testing.T) {
 	db, dbMock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -73,7 +157,9 @@ func TestTransactionService_TransferMoney(t *testing.T) {
 
 	mockAccountRepo := new(MockAccountRepository)
 	mockTxnRepo := new(MockTransactionRepository)
-	transactionService := NewTransactionService(db, mockAccountRepo, mockTxnRepo)
+	mockLedgerRepo := new(MockLedgerRepository)
+	mockPayeeRepo := new(MockPayeeRepository)
+	transactionService := NewTransactionService(db, mockAccountRepo, mockTxnRepo, mockLedgerRepo, mockPayeeRepo, nil, nil, new(auditSink))
 
 	ctx := context.Background()
 	userID := 1
@@ -82,19 +168,18 @@ func TestTransactionService_TransferMoney(t *testing.T) {
 
 	req := TransferRequest{
 		ToAccountID: toAccountID,
-		Amount:      100.0,
+		Amount:      "100.00",
 	}
 
-	fromAccount := &model.Account{ID: fromAccountID, UserID: userID, Balance: 500.0, Currency: "TRY"}
-	toAccount := &model.Account{ID: toAccountID, UserID: 2, Balance: 200.0, Currency: "TRY"}
+	fromAccount := &model.Account{ID: fromAccountID, UserID: userID, Balance: model.NewMoney(50000, "TRY"), Currency: "TRY", Kind: model.AccountKindCustomer}
+	toAccount := &model.Account{ID: toAccountID, UserID: 2, Balance: model.NewMoney(20000, "TRY"), Currency: "TRY", Kind: model.AccountKindCustomer}
+	amount := model.NewMoney(10000, "TRY")
 
 	t.Run("success", func(t *testing.T) {
 		dbMock.ExpectBegin()
-		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, fromAccountID).Return(fromAccount, nil).Once()
-		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, req.ToAccountID).Return(toAccount, nil).Once()
-		mockAccountRepo.On("UpdateAccountBalance", mock.Anything, fromAccount.ID, fromAccount.Balance-req.Amount).Return(nil).Once()
-		mockAccountRepo.On("UpdateAccountBalance", mock.Anything, toAccount.ID, toAccount.Balance+req.Amount).Return(nil).Once()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{fromAccountID, req.ToAccountID}).Return(accountsByID(fromAccount, toAccount), nil).Once()
 		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.AnythingOfType("*model.Transaction")).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, isBalancedEntry(model.EntryKindTransfer, fromAccountID, toAccountID, amount)).Return(nil).Once()
 		dbMock.ExpectCommit()
 
 		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, req)
@@ -102,18 +187,18 @@ func TestTransactionService_TransferMoney(t *testing.T) {
 		assert.NoError(t, err)
 		mockAccountRepo.AssertExpectations(t)
 		mockTxnRepo.AssertExpectations(t)
+		mockLedgerRepo.AssertExpectations(t)
 		assert.NoError(t, dbMock.ExpectationsWereMet())
 	})
 
 	// --- Test Case 2: Insufficient Funds ---
 	t.Run("insufficient funds", func(t *testing.T) {
 		// Setup
-		fromAccountPoor := &model.Account{ID: fromAccountID, UserID: userID, Balance: 50.0, Currency: "TRY"} // Not enough balance
+		fromAccountPoor := &model.Account{ID: fromAccountID, UserID: userID, Balance: model.NewMoney(5000, "TRY"), Currency: "TRY", Kind: model.AccountKindCustomer} // Not enough balance
 
 		// Expectations
 		dbMock.ExpectBegin()
-		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, fromAccountID).Return(fromAccountPoor, nil).Once()
-		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, req.ToAccountID).Return(toAccount, nil).Once()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{fromAccountID, req.ToAccountID}).Return(accountsByID(fromAccountPoor, toAccount), nil).Once()
 		dbMock.ExpectRollback()
 
 		// Execution
@@ -131,11 +216,9 @@ func TestTransactionService_TransferMoney(t *testing.T) {
 	t.Run("commit error", func(t *testing.T) {
 		// Expectations
 		dbMock.ExpectBegin()
-		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, fromAccountID).Return(fromAccount, nil).Once()
-		mockAccountRepo.On("GetAccountForUpdate", mock.Anything, req.ToAccountID).Return(toAccount, nil).Once()
-		mockAccountRepo.On("UpdateAccountBalance", mock.Anything, fromAccount.ID, fromAccount.Balance-req.Amount).Return(nil).Once()
-		mockAccountRepo.On("UpdateAccountBalance", mock.Anything, toAccount.ID, toAccount.Balance+req.Amount).Return(nil).Once()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{fromAccountID, req.ToAccountID}).Return(accountsByID(fromAccount, toAccount), nil).Once()
 		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.AnythingOfType("*model.Transaction")).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, isBalancedEntry(model.EntryKindTransfer, fromAccountID, toAccountID, amount)).Return(nil).Once()
 		dbMock.ExpectCommit().WillReturnError(errors.New("commit failed"))
 
 		// Execution
@@ -146,6 +229,362 @@ func TestTransactionService_TransferMoney(t *testing.T) {
 		assert.Error(t, err)
 		mockAccountRepo.AssertExpectations(t)
 		mockTxnRepo.AssertExpectations(t)
+		mockLedgerRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	// --- Test Case 4: Amount more precise than the currency allows ---
+	t.Run("excess precision", func(t *testing.T) {
+		preciseReq := TransferRequest{ToAccountID: toAccountID, Amount: "10.005"}
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{fromAccountID, req.ToAccountID}).Return(accountsByID(fromAccount, toAccount), nil).Once()
+		dbMock.ExpectRollback()
+
+		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, preciseReq)
+
+		assert.Equal(t, model.ErrExcessPrecision, err)
+		mockAccountRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	// --- Test Case 5: Receiver is a system account ---
+	t.Run("system account receiver", func(t *testing.T) {
+		settlement := &model.Account{ID: 99, Balance: model.NewMoney(0, "TRY"), Currency: "TRY", Kind: model.AccountKindSettlement}
+		systemReq := TransferRequest{ToAccountID: settlement.ID, Amount: "10.00"}
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{fromAccountID, settlement.ID}).Return(accountsByID(fromAccount, settlement), nil).Once()
+		dbMock.ExpectRollback()
+
+		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, systemReq)
+
+		assert.Equal(t, ErrReceiverAccountNotFound, err)
+		mockAccountRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	// --- Test Case 6: Receiver addressed by account number ---
+	t.Run("by account number", func(t *testing.T) {
+		numberReq := TransferRequest{ToAccountNumber: "1000-0000-256", Amount: "100.00"}
+
+		mockAccountRepo.On("GetAccountByNumber", int64(10000000256)).Return(toAccount, nil).Once()
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{fromAccountID, toAccountID}).Return(accountsByID(fromAccount, toAccount), nil).Once()
+		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.AnythingOfType("*model.Transaction")).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, isBalancedEntry(model.EntryKindTransfer, fromAccountID, toAccountID, amount)).Return(nil).Once()
+		dbMock.ExpectCommit()
+
+		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, numberReq)
+
+		assert.NoError(t, err)
+		mockAccountRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	// --- Test Case 7: Unknown account number ---
+	t.Run("unknown account number", func(t *testing.T) {
+		mockAccountRepo.On("GetAccountByNumber", int64(10000000264)).Return(nil, sql.ErrNoRows).Once()
+
+		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, TransferRequest{ToAccountNumber: "10000000264", Amount: "1"})
+
+		assert.Equal(t, ErrReceiverAccountNotFound, err)
+		mockAccountRepo.AssertExpectations(t)
+	})
+
+	// --- Test Case 8: Receiver addressed by a verified payee ---
+	t.Run("by verified payee", func(t *testing.T) {
+		payee := &model.Payee{ID: 7, UserID: userID, AccountNumber: 10000000256, Status: model.PayeeStatusVerified}
+
+		mockPayeeRepo.On("GetPayeeByID", payee.ID).Return(payee, nil).Once()
+		mockAccountRepo.On("GetAccountByNumber", payee.AccountNumber).Return(toAccount, nil).Once()
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{fromAccountID, toAccountID}).Return(accountsByID(fromAccount, toAccount), nil).Once()
+		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.AnythingOfType("*model.Transaction")).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, isBalancedEntry(model.EntryKindTransfer, fromAccountID, toAccountID, amount)).Return(nil).Once()
+		dbMock.ExpectCommit()
+
+		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, TransferRequest{PayeeID: payee.ID, Amount: "100.00"})
+
+		assert.NoError(t, err)
+		mockPayeeRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	// --- Test Case 9: Payee not yet verified ---
+	t.Run("unverified payee", func(t *testing.T) {
+		payee := &model.Payee{ID: 8, UserID: userID, AccountNumber: 10000000256, Status: model.PayeeStatusUnverified}
+		mockPayeeRepo.On("GetPayeeByID", payee.ID).Return(payee, nil).Once()
+
+		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, TransferRequest{PayeeID: payee.ID, Amount: "100.00"})
+
+		assert.Equal(t, ErrPayeeNotVerified, err)
+	})
+
+	// --- Test Case 10: Payee of another user ---
+	t.Run("another user's payee", func(t *testing.T) {
+		payee := &model.Payee{ID: 9, UserID: 42, AccountNumber: 10000000256, Status: model.PayeeStatusVerified}
+		mockPayeeRepo.On("GetPayeeByID", payee.ID).Return(payee, nil).Once()
+
+		_, err := transactionService.TransferMoney(ctx, userID, fromAccountID, TransferRequest{PayeeID: payee.ID, Amount: "100.00"})
+
+		assert.Equal(t, ErrPayeeNotFound, err)
+	})
+}
+
+func TestTransactionService_LookupBeneficiary(t *testing.T) {
+	config.AppConfig.IBAN.BankCode = "00061"
+	config.AppConfig.IBAN.Countries = map[string]string{"try": "TR"}
+	defer func() { config.AppConfig.IBAN.BankCode = "" }()
+
+	mockAccountRepo := new(MockAccountRepository)
+	transactionService := NewTransactionService(nil, mockAccountRepo, nil, nil, nil, nil, nil, new(auditSink))
+
+	ctx := context.Background()
+	account := &model.Account{ID: 2, UserID: 2, AccountNumber: 10000000256, Currency: "TRY", Kind: model.AccountKindCustomer}
+	iban, err := model.FormatIBAN("TR", "00061", account.AccountNumber)
+	assert.NoError(t, err)
+
+	t.Run("by IBAN", func(t *testing.T) {
+		mockAccountRepo.On("GetAccountByNumber", account.AccountNumber).Return(account, nil).Once()
+		mockAccountRepo.On("GetHolderName", account.ID).Return("jane doe", nil).Once()
+
+		beneficiary, err := transactionService.LookupBeneficiary(ctx, "", iban)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "j*** d***", beneficiary.HolderName)
+		assert.Equal(t, iban, beneficiary.IBAN)
+		assert.Equal(t, "TRY", beneficiary.Currency)
+		mockAccountRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid check digit", func(t *testing.T) {
+		_, err := transactionService.LookupBeneficiary(ctx, "10000000250", "")
+
+		assert.Equal(t, model.ErrInvalidAccountNumber, err)
+	})
+
+	t.Run("IBAN of another bank", func(t *testing.T) {
+		_, err := transactionService.LookupBeneficiary(ctx, "", "GB82WEST12345698765432")
+
+		assert.Equal(t, model.ErrInvalidIBAN, err)
+	})
+
+	t.Run("nothing given", func(t *testing.T) {
+		_, err := transactionService.LookupBeneficiary(ctx, "", "")
+
+		assert.Equal(t, ErrBeneficiaryRequired, err)
+	})
+}
+
+func TestTransactionService_ListTransactionsForAccount(t *testing.T) {
+	mockAccountRepo := new(MockAccountRepository)
+	mockTransactionRepo := new(MockTransactionRepository)
+	transactionService := NewTransactionService(nil, mockAccountRepo, mockTransactionRepo, nil, nil, nil, nil, new(auditSink))
+
+	ctx := context.Background()
+	account := &model.Account{ID: 1, UserID: 1, AccountNumber: 10000000017, Currency: "USD", Kind: model.AccountKindCustomer}
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	history := []*model.Transaction{
+		{ID: 9, Kind: model.EntryKindTransfer, FromAccountID: 1, ToAccountID: 2, CreatedAt: createdAt.Add(2 * time.Minute)},
+		{ID: 8, Kind: model.EntryKindTransfer, FromAccountID: 2, ToAccountID: 1, CreatedAt: createdAt.Add(time.Minute)},
+		{ID: 7, Kind: model.EntryKindDeposit, ToAccountID: 1, CreatedAt: createdAt},
+	}
+
+	t.Run("first page of a longer history has a cursor", func(t *testing.T) {
+		mockAccountRepo.On("GetAccountByID", account.ID).Return(account, nil).Once()
+		mockTransactionRepo.On("ListTransactions", model.TransactionFilter{AccountID: 1, Limit: 2}).Return(history, nil).Once()
+
+		page, err := transactionService.ListTransactionsForAccount(ctx, 1, account.ID, model.TransactionQuery{Limit: 2})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Transactions, 2)
+		cursor, err := model.DecodeCursor(page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, 8, cursor.ID)
+		assert.True(t, history[1].CreatedAt.Equal(cursor.CreatedAt))
+		mockTransactionRepo.AssertExpectations(t)
+	})
+
+	t.Run("filters are converted for the repository", func(t *testing.T) {
+		cursor := model.Cursor{CreatedAt: createdAt, ID: 8}
+		min := model.NewMoney(1000, "USD")
+		filter := model.TransactionFilter{
+			AccountID:                 1,
+			Limit:                     model.MaxPageSize,
+			After:                     &cursor,
+			MinAmount:                 &min,
+			Direction:                 model.DirectionIncoming,
+			CounterpartyAccountNumber: 10000000256,
+			Ascending:                 true,
+		}
+		mockAccountRepo.On("GetAccountByID", account.ID).Return(account, nil).Once()
+		mockTransactionRepo.On("ListTransactions", filter).Return(history[2:], nil).Once()
+
+		page, err := transactionService.ListTransactionsForAccount(ctx, 1, account.ID, model.TransactionQuery{
+			Limit:        500,
+			Cursor:       cursor.Encode(),
+			MinAmount:    "10",
+			Direction:    model.DirectionIncoming,
+			Counterparty: "10000000256",
+			Ascending:    true,
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Transactions, 1)
+		assert.Empty(t, page.NextCursor)
+		mockTransactionRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid queries are rejected", func(t *testing.T) {
+		to := createdAt
+		from := createdAt.Add(time.Hour)
+		tests := []struct {
+			name    string
+			query   model.TransactionQuery
+			wantErr error
+		}{
+			{"bad cursor", model.TransactionQuery{Cursor: "garbage"}, model.ErrInvalidCursor},
+			{"bad direction", model.TransactionQuery{Direction: "sideways"}, ErrInvalidDirection},
+			{"empty date range", model.TransactionQuery{From: &from, To: &to}, ErrInvalidDateRange},
+			{"inverted amount range", model.TransactionQuery{MinAmount: "5", MaxAmount: "1"}, ErrInvalidAmountRange},
+			{"too precise amount", model.TransactionQuery{MinAmount: "1.005"}, model.ErrExcessPrecision},
+			{"bad counterparty", model.TransactionQuery{Counterparty: "10000000250"}, model.ErrInvalidAccountNumber},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mockAccountRepo.On("GetAccountByID", account.ID).Return(account, nil).Once()
+
+				_, err := transactionService.ListTransactionsForAccount(ctx, 1, account.ID, tt.query)
+
+				assert.Equal(t, tt.wantErr, err)
+			})
+		}
+	})
+
+	t.Run("another user's account", func(t *testing.T) {
+		mockAccountRepo.On("GetAccountByID", account.ID).Return(account, nil).Once()
+
+		_, err := transactionService.ListTransactionsForAccount(ctx, 2, account.ID, model.TransactionQuery{})
+
+		assert.Equal(t, ErrPermissionDenied, err)
+	})
+}
+
+// mockFXPricer provides a mock for IFXPricer.
+type mockFXPricer struct{ mock.Mock }
+
+func (m *mockFXPricer) Price(_ context.Context, source model.Money, targetCurrency string) (*model.FXDetails, error) {
+	args := m.Called(source, targetCurrency)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FXDetails), args.Error(1)
+}
+
+func TestTransactionService_TransferMoney_FX(t *testing.T) {
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockAccountRepo := new(MockAccountRepository)
+	mockTxnRepo := new(MockTransactionRepository)
+	mockLedgerRepo := new(MockLedgerRepository)
+	mockQuoteRepo := new(MockFXQuoteRepository)
+	pricer := new(mockFXPricer)
+	transactionService := NewTransactionService(db, mockAccountRepo, mockTxnRepo, mockLedgerRepo, nil, mockQuoteRepo, pricer, new(auditSink))
+
+	ctx := context.Background()
+	sender := &model.Account{ID: 1, UserID: 1, Balance: model.NewMoney(50000, "USD"), Currency: "USD", Kind: model.AccountKindCustomer}
+	receiver := &model.Account{ID: 2, UserID: 2, Balance: model.NewMoney(0, "TRY"), Currency: "TRY", Kind: model.AccountKindCustomer}
+	usdPosition := &model.Account{ID: 301, Currency: "USD", Kind: model.AccountKindFXPosition}
+	tryPosition := &model.Account{ID: 300, Currency: "TRY", Kind: model.AccountKindFXPosition}
+	positions := map[string]*model.Account{"USD": usdPosition, "TRY": tryPosition}
+
+	source := model.NewMoney(10000, "USD")
+	details := &model.FXDetails{DestinationAmount: model.NewMoney(321750, "TRY"), MidRate: mustRate(t, "32.50"), Rate: mustRate(t, "32.175"), SpreadBps: 100}
+	isFXEntry := mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Validate() == nil && len(e.Postings) == 4 &&
+			e.Postings[0] == model.Debit(sender.ID, source) && e.Postings[1] == model.Credit(usdPosition.ID, source) &&
+			e.Postings[2] == model.Debit(tryPosition.ID, details.DestinationAmount) && e.Postings[3] == model.Credit(receiver.ID, details.DestinationAmount)
+	})
+
+	t.Run("priced at the current rate", func(t *testing.T) {
+		// The first attempt finds the currencies differ and rolls back to fetch a rate.
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{sender.ID, receiver.ID}).Return(accountsByID(sender, receiver), nil).Once()
+		dbMock.ExpectRollback()
+		pricer.On("Price", source, "TRY").Return(details, nil).Once()
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{sender.ID, receiver.ID}).Return(accountsByID(sender, receiver), nil).Once()
+		mockAccountRepo.On("GetSystemAccountsForUpdate", mock.Anything, model.AccountKindFXPosition, []string{"USD", "TRY"}).Return(positions, nil).Once()
+		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(tr *model.Transaction) bool {
+			return tr.Amount == source && tr.FX == details
+		})).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, isFXEntry).Return(nil).Once()
+		dbMock.ExpectCommit()
+
+		transaction, err := transactionService.TransferMoney(ctx, sender.UserID, sender.ID, TransferRequest{ToAccountID: receiver.ID, Amount: "100.00"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, details.DestinationAmount, transaction.FX.DestinationAmount)
+		pricer.AssertExpectations(t)
+		mockAccountRepo.AssertExpectations(t)
+		mockLedgerRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("priced by a quote", func(t *testing.T) {
+		quote := &model.FXQuote{ID: "5b0f7c1e-9d1a-4c8e-8f0e-2d6f1b7a9c31", UserID: sender.UserID, SourceAmount: source,
+			DestinationAmount: details.DestinationAmount, MidRate: details.MidRate, Rate: details.Rate, SpreadBps: 100,
+			ExpiresAt: time.Now().Add(time.Minute)}
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{sender.ID, receiver.ID}).Return(accountsByID(sender, receiver), nil).Once()
+		mockQuoteRepo.On("GetQuoteForUpdate", mock.Anything, quote.ID).Return(quote, nil).Once()
+		mockQuoteRepo.On("MarkQuoteUsed", mock.Anything, quote.ID).Return(nil).Once()
+		mockAccountRepo.On("GetSystemAccountsForUpdate", mock.Anything, model.AccountKindFXPosition, []string{"USD", "TRY"}).Return(positions, nil).Once()
+		mockTxnRepo.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(tr *model.Transaction) bool {
+			return tr.FX != nil && tr.FX.QuoteID != nil && *tr.FX.QuoteID == quote.ID
+		})).Return(nil).Once()
+		mockLedgerRepo.On("PostEntry", mock.Anything, isFXEntry).Return(nil).Once()
+		dbMock.ExpectCommit()
+
+		_, err := transactionService.TransferMoney(ctx, sender.UserID, sender.ID, TransferRequest{ToAccountID: receiver.ID, Amount: "100.00", QuoteID: quote.ID})
+
+		assert.NoError(t, err)
+		mockQuoteRepo.AssertExpectations(t)
+		pricer.AssertNumberOfCalls(t, "Price", 1)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("expired quote", func(t *testing.T) {
+		quote := &model.FXQuote{ID: "0c1d2e3f-4a5b-4c6d-8e7f-8091a2b3c4d5", UserID: sender.UserID, SourceAmount: source,
+			DestinationAmount: details.DestinationAmount, ExpiresAt: time.Now().Add(-time.Second)}
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{sender.ID, receiver.ID}).Return(accountsByID(sender, receiver), nil).Once()
+		mockQuoteRepo.On("GetQuoteForUpdate", mock.Anything, quote.ID).Return(quote, nil).Once()
+		dbMock.ExpectRollback()
+
+		_, err := transactionService.TransferMoney(ctx, sender.UserID, sender.ID, TransferRequest{ToAccountID: receiver.ID, Amount: "100.00", QuoteID: quote.ID})
+
+		assert.Equal(t, ErrQuoteExpired, err)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("quote for another amount", func(t *testing.T) {
+		quote := &model.FXQuote{ID: "a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d", UserID: sender.UserID, SourceAmount: model.NewMoney(5000, "USD"),
+			DestinationAmount: model.NewMoney(160875, "TRY"), ExpiresAt: time.Now().Add(time.Minute)}
+
+		dbMock.ExpectBegin()
+		mockAccountRepo.On("GetAccountsForUpdate", mock.Anything, []int{sender.ID, receiver.ID}).Return(accountsByID(sender, receiver), nil).Once()
+		mockQuoteRepo.On("GetQuoteForUpdate", mock.Anything, quote.ID).Return(quote, nil).Once()
+		dbMock.ExpectRollback()
+
+		_, err := transactionService.TransferMoney(ctx, sender.UserID, sender.ID, TransferRequest{ToAccountID: receiver.ID, Amount: "100.00", QuoteID: quote.ID})
+
+		assert.Equal(t, ErrQuoteMismatch, err)
 		assert.NoError(t, dbMock.ExpectationsWereMet())
 	})
 }
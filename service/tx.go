@@ -0,0 +1,100 @@
+// file: service/tx.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// Postgres aborts one side of a deadlock or serialization conflict with one of
+// these SQLSTATE codes; the transaction can simply be run again.
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+)
+
+// maxTxAttempts bounds how many times runInTx runs a transaction that keeps
+// losing conflicts. txRetryBaseDelay is the wait before the first retry; it
+// doubles on every further attempt, plus random jitter.
+var (
+	maxTxAttempts    = 5
+	txRetryBaseDelay = 10 * time.Millisecond
+)
+
+// isRetryableTxError reports whether err is a deadlock or serialization failure.
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+}
+
+// runInTx runs fn inside a database transaction and commits it if fn succeeds.
+// If the transaction fails with a deadlock or serialization error, it is
+// rolled back and fn is run again in a fresh transaction, with a bounded
+// exponential backoff. fn must therefore re-read everything it depends on.
+func runInTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := runTxOnce(ctx, db, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= maxTxAttempts {
+			return err
+		}
+
+		delay := txRetryBaseDelay << (attempt - 1)
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		logger.Log.WithError(err).WithFields(logrus.Fields{
+			"attempt": attempt,
+			"delay":   delay,
+		}).Warn("Transaction conflict detected, retrying")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func runTxOnce(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// runInAuditedTx runs fn inside a transaction like runInTx and records the
+// audit event fn returns in the same transaction, as its last statement before
+// the commit. Recording an event locks the end of the audit log until the
+// commit, which makes every audited transaction wait for the one before it;
+// fn does all of its work before the lock is taken, so the lock is only held
+// for the append and the commit.
+func runInAuditedTx(ctx context.Context, db *sql.DB, audit IAuditRecorder, fn func(tx *sql.Tx) (*model.AuditEvent, error)) error {
+	return runInTx(ctx, db, func(tx *sql.Tx) error {
+		event, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		return audit.RecordTx(ctx, tx, event)
+	})
+}
@@ -0,0 +1,112 @@
+// file: service/tx_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunInTx(t *testing.T) {
+	txRetryBaseDelay = 0
+	ctx := context.Background()
+
+	t.Run("retries after a deadlock", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectRollback()
+		dbMock.ExpectBegin()
+		dbMock.ExpectCommit()
+
+		calls := 0
+		err = runInTx(ctx, db, func(tx *sql.Tx) error {
+			calls++
+			if calls == 1 {
+				return &pq.Error{Code: pqDeadlockDetected}
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("retries a failed commit on serialization failure", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectCommit().WillReturnError(&pq.Error{Code: pqSerializationFailure})
+		dbMock.ExpectBegin()
+		dbMock.ExpectCommit()
+
+		calls := 0
+		err = runInTx(ctx, db, func(tx *sql.Tx) error {
+			calls++
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("gives up after the maximum attempts", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		for i := 0; i < maxTxAttempts; i++ {
+			dbMock.ExpectBegin()
+			dbMock.ExpectRollback()
+		}
+
+		calls := 0
+		err = runInTx(ctx, db, func(tx *sql.Tx) error {
+			calls++
+			return &pq.Error{Code: pqDeadlockDetected}
+		})
+
+		assert.True(t, isRetryableTxError(err))
+		assert.Equal(t, maxTxAttempts, calls)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectRollback()
+
+		calls := 0
+		err = runInTx(ctx, db, func(tx *sql.Tx) error {
+			calls++
+			return ErrInsufficientFunds
+		})
+
+		assert.Equal(t, ErrInsufficientFunds, err)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	assert.True(t, isRetryableTxError(&pq.Error{Code: pqDeadlockDetected}))
+	assert.True(t, isRetryableTxError(fmt.Errorf("could not commit transaction: %w", &pq.Error{Code: pqSerializationFailure})))
+	assert.False(t, isRetryableTxError(&pq.Error{Code: "23505"}))
+	assert.False(t, isRetryableTxError(errors.New("boom")))
+}
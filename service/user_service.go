@@ -1,27 +1,137 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"go-bank-api/model"
 	"go-bank-api/repository"
 )
 
+var ErrInvalidRole = errors.New("invalid role specified")
+
 // UserService now depends on the IUserRepository interface, not the concrete struct.
+// It checks role assignments with the role granter.
 type UserService struct {
+	db       *sql.DB
 	userRepo repository.IUserRepository // UPDATED
+	roles    IRoleGranter
+	denylist ITokenDenylist
+	audit    IAuditRecorder
 }
 
 // NewUserService accepts the interface, allowing for mocks to be injected.
-func NewUserService(userRepo repository.IUserRepository) *UserService { // UPDATED
-	return &UserService{userRepo: userRepo}
+func NewUserService(db *sql.DB, userRepo repository.IUserRepository, roles IRoleGranter, denylist ITokenDenylist, audit IAuditRecorder) *UserService { // UPDATED
+	return &UserService{db: db, userRepo: userRepo, roles: roles, denylist: denylist, audit: audit}
+}
+
+// getRole returns a role by name, or ErrInvalidRole if there is no such role.
+func (s *UserService) getRole(ctx context.Context, name string) (*model.RoleDefinition, error) {
+	role, err := s.roles.GetRole(ctx, name)
+	if err == ErrRoleNotFound {
+		return nil, ErrInvalidRole
+	}
+	return role, err
+}
+
+// CheckRoleChange validates a change of the user's role on behalf of an actor
+// holding actorRole without making it. The actor must hold every permission
+// of both the user's current role and the new one, so they can neither raise
+// anyone above themselves nor demote someone who outranks them. It reports
+// whether the new role grants the user any permission they do not hold now.
+func (s *UserService) CheckRoleChange(ctx context.Context, actorRole string, userID int, newRole model.Role) (bool, error) {
+	_, escalates, err := s.checkRoleChange(ctx, actorRole, userID, newRole)
+	return escalates, err
+}
+
+// checkRoleChange is CheckRoleChange, also returning the user as it is before the change.
+func (s *UserService) checkRoleChange(ctx context.Context, actorRole string, userID int, newRole model.Role) (*model.User, bool, error) {
+	// We ensure that only existing roles can be assigned.
+	role, err := s.getRole(ctx, string(newRole))
+	if err != nil {
+		return nil, false, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	currentRole, err := s.getRole(ctx, user.Role)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.roles.CheckGrantable(ctx, actorRole, append(currentRole.Permissions, role.Permissions...)); err != nil {
+		return nil, false, err
+	}
+	return user, !currentRole.HasPermissions(role.Permissions...), nil
 }
 
-// UpdateUserRole validates the role and calls the repository to update it.
-func (s *UserService) UpdateUserRole(userID int, newRole model.Role) error {
-	// We ensure that only valid roles can be assigned.
-	if newRole != model.RoleAdmin && newRole != model.RoleUser {
-		return errors.New("invalid role specified")
+// UpdateUserRole validates the role change with CheckRoleChange, calls the
+// repository to make it and records it in the audit log, in one transaction.
+// The user's outstanding access tokens still carry the old role, so they are
+// revoked; the user gets the new role on the next refresh or login.
+func (s *UserService) UpdateUserRole(ctx context.Context, actorRole string, userID int, newRole model.Role) error {
+	err := runInAuditedTx(ctx, s.db, s.audit, func(tx *sql.Tx) (*model.AuditEvent, error) {
+		return s.updateUserRole(ctx, tx, actorRole, userID, newRole)
+	})
+	if err != nil {
+		return err
+	}
+	return s.RevokeUserTokens(ctx, userID)
+}
+
+// UpdateUserRoleTx is UpdateUserRole within tx, for role changes committed
+// together with other changes. It records the change in the audit log as its
+// last statement, so tx must be committed right after it, and then the user's
+// tokens revoked with RevokeUserTokens.
+func (s *UserService) UpdateUserRoleTx(ctx context.Context, tx *sql.Tx, actorRole string, userID int, newRole model.Role) error {
+	event, err := s.updateUserRole(ctx, tx, actorRole, userID, newRole)
+	if err != nil {
+		return err
+	}
+	return s.audit.RecordTx(ctx, tx, event)
+}
+
+// updateUserRole validates and makes the role change within tx and returns
+// the audit event to record.
+func (s *UserService) updateUserRole(ctx context.Context, tx *sql.Tx, actorRole string, userID int, newRole model.Role) (*model.AuditEvent, error) {
+	user, _, err := s.checkRoleChange(ctx, actorRole, userID, newRole)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateUserRole(ctx, tx, userID, string(newRole)); err != nil {
+		return nil, err
+	}
+	return newAuditEvent(model.AuditEventRoleChanged, model.AuditTargetUser, userID,
+		map[string]string{"role": user.Role}, map[string]string{"role": string(newRole)}), nil
+}
+
+// RevokeUserTokens revokes the user's outstanding access tokens after their
+// role changed.
+func (s *UserService) RevokeUserTokens(ctx context.Context, userID int) error {
+	return s.denylist.RevokeUser(ctx, userID)
+}
+
+// ListUsers returns one page of the users matching the filter, for the admin
+// listing.
+func (s *UserService) ListUsers(ctx context.Context, filter model.UserFilter) (*model.UserPage, error) {
+	if filter.Role != "" {
+		if _, err := s.getRole(ctx, filter.Role); err != nil {
+			return nil, err
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrInvalidDateRange
+	}
+	filter.Limit = pageLimit(filter.Limit)
+
+	users, total, err := s.userRepo.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.userRepo.UpdateUserRole(userID, string(newRole))
+	page := &model.UserPage{Total: total}
+	page.Users, page.NextCursor = paginate(users, filter.Limit, func(u *model.User) model.Cursor {
+		return model.Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+	})
+	return page, nil
 }
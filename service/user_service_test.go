@@ -3,78 +3,223 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"go-bank-api/model"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 type mockUserRepo struct{ mock.Mock }
 
-func (m *mockUserRepo) CreateUser(user *model.User) error {
+func (m *mockUserRepo) CreateUser(_ context.Context, user *model.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
-func (m *mockUserRepo) GetUserByEmail(email string) (*model.User, error) {
+func (m *mockUserRepo) GetUserByEmail(_ context.Context, email string) (*model.User, error) {
 	args := m.Called(email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
-func (m *mockUserRepo) GetAllUsers() ([]*model.User, error) {
-	args := m.Called()
-	return args.Get(0).([]*model.User), args.Error(1)
+func (m *mockUserRepo) ListUsers(_ context.Context, filter model.UserFilter) ([]*model.User, int, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]*model.User), args.Int(1), args.Error(2)
 }
-func (m *mockUserRepo) UpdateUserRole(userID int, newRole string) error {
+func (m *mockUserRepo) UpdateUserRole(_ context.Context, _ *sql.Tx, userID int, newRole string) error {
 	args := m.Called(userID, newRole)
 	return args.Error(0)
 }
 
 // GetUserByID is added to satisfy the IUserRepository interface.
-func (m *mockUserRepo) GetUserByID(id int) (*model.User, error) {
+func (m *mockUserRepo) GetUserByID(_ context.Context, id int) (*model.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
+func (m *mockUserRepo) UpdatePassword(_ context.Context, tx *sql.Tx, userID int, passwordHash string) error {
+	return m.Called(tx, userID, passwordHash).Error(0)
+}
+func (m *mockUserRepo) ReplacePasswordHash(_ context.Context, userID int, oldHash, newHash string) error {
+	return m.Called(userID, oldHash, newHash).Error(0)
+}
+func (m *mockUserRepo) MarkEmailVerified(_ context.Context, tx *sql.Tx, userID int) error {
+	return m.Called(tx, userID).Error(0)
+}
+
+// mockRoleGranter provides a mock for IRoleGranter.
+type mockRoleGranter struct{ mock.Mock }
+
+func (m *mockRoleGranter) GetRole(_ context.Context, name string) (*model.RoleDefinition, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleDefinition), args.Error(1)
+}
+func (m *mockRoleGranter) CheckGrantable(_ context.Context, actorRole string, permissions []model.Permission) error {
+	return m.Called(actorRole, permissions).Error(0)
+}
+
+var (
+	testUserRole    = &model.RoleDefinition{Name: model.RoleUser, BuiltIn: true, Permissions: []model.Permission{}}
+	testSupportRole = &model.RoleDefinition{Name: "support", Permissions: []model.Permission{model.PermissionUsersRead, model.PermissionAccountsRead}}
+)
 
 func TestUserService_UpdateUserRole(t *testing.T) {
+	ctx := context.Background()
+	db, dbMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
 	t.Run("success", func(t *testing.T) {
-		mockRepo := new(mockUserRepo)
-		mockRepo.On("UpdateUserRole", 1, "admin").Return(nil).Once()
+		mockRepo, roles, denylist := new(mockUserRepo), new(mockRoleGranter), new(mockDenylist)
+		roles.On("GetRole", "support").Return(testSupportRole, nil).Once()
+		mockRepo.On("GetUserByID", 1).Return(&model.User{ID: 1, Role: "user"}, nil).Once()
+		roles.On("GetRole", "user").Return(testUserRole, nil).Once()
+		roles.On("CheckGrantable", "admin", testSupportRole.Permissions).Return(nil).Once()
+		mockRepo.On("UpdateUserRole", 1, "support").Return(nil).Once()
+		denylist.On("RevokeUser", 1).Return(nil).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectCommit()
 
-		userService := NewUserService(mockRepo)
-		err := userService.UpdateUserRole(1, model.RoleAdmin)
+		audit := new(auditSink)
+		userService := NewUserService(db, mockRepo, roles, denylist, audit)
+		err := userService.UpdateUserRole(ctx, "admin", 1, "support")
 
 		assert.NoError(t, err)
+		assert.NoError(t, dbMock.ExpectationsWereMet(), "The change and its audit event are committed together")
+		if assert.Len(t, audit.events, 1) {
+			assert.Equal(t, model.AuditEventRoleChanged, audit.events[0].Event)
+			assert.JSONEq(t, `{"role":"user"}`, string(audit.events[0].Before))
+			assert.JSONEq(t, `{"role":"support"}`, string(audit.events[0].After))
+		}
 		mockRepo.AssertExpectations(t)
+		roles.AssertExpectations(t)
+		denylist.AssertExpectations(t)
 	})
 
 	t.Run("repository error", func(t *testing.T) {
-		mockRepo := new(mockUserRepo)
+		mockRepo, roles, denylist := new(mockUserRepo), new(mockRoleGranter), new(mockDenylist)
 		expectedError := errors.New("database error")
+		roles.On("GetRole", "user").Return(testUserRole, nil)
+		mockRepo.On("GetUserByID", 2).Return(&model.User{ID: 2, Role: "user"}, nil).Once()
+		roles.On("CheckGrantable", "admin", mock.Anything).Return(nil).Once()
 		mockRepo.On("UpdateUserRole", 2, "user").Return(expectedError).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectRollback()
 
-		userService := NewUserService(mockRepo)
-		err := userService.UpdateUserRole(2, model.RoleUser)
+		userService := NewUserService(db, mockRepo, roles, denylist, new(auditSink))
+		err := userService.UpdateUserRole(ctx, "admin", 2, model.RoleUser)
 
 		assert.Error(t, err)
 		assert.Equal(t, expectedError, err)
 		mockRepo.AssertExpectations(t)
+		denylist.AssertNotCalled(t, "RevokeUser", mock.Anything)
 	})
 
 	t.Run("invalid role", func(t *testing.T) {
-		mockRepo := new(mockUserRepo)
-		userService := NewUserService(mockRepo)
+		mockRepo, roles := new(mockUserRepo), new(mockRoleGranter)
+		roles.On("GetRole", "invalid_role").Return(nil, ErrRoleNotFound).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectRollback()
+		userService := NewUserService(db, mockRepo, roles, nil, new(auditSink))
 
-		err := userService.UpdateUserRole(3, "invalid_role")
+		err := userService.UpdateUserRole(ctx, "admin", 3, "invalid_role")
 
 		assert.Error(t, err)
 		assert.Equal(t, "invalid role specified", err.Error())
 		mockRepo.AssertNotCalled(t, "UpdateUserRole")
 	})
+
+	t.Run("demoting a user who outranks the actor", func(t *testing.T) {
+		mockRepo, roles := new(mockUserRepo), new(mockRoleGranter)
+		adminRole := &model.RoleDefinition{Name: model.RoleAdmin, BuiltIn: true, Permissions: []model.Permission{model.PermissionUsersRead, model.PermissionRolesWrite}}
+		roles.On("GetRole", "user").Return(testUserRole, nil).Once()
+		mockRepo.On("GetUserByID", 4).Return(&model.User{ID: 4, Role: "admin"}, nil).Once()
+		roles.On("GetRole", "admin").Return(adminRole, nil).Once()
+		roles.On("CheckGrantable", "support", adminRole.Permissions).Return(ErrPermissionEscalation).Once()
+		dbMock.ExpectBegin()
+		dbMock.ExpectRollback()
+
+		err := NewUserService(db, mockRepo, roles, nil, new(auditSink)).UpdateUserRole(ctx, "support", 4, model.RoleUser)
+
+		assert.Equal(t, ErrPermissionEscalation, err)
+		mockRepo.AssertNotCalled(t, "UpdateUserRole", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserService_CheckRoleChange(t *testing.T) {
+	ctx := context.Background()
+	mockRepo, roles := new(mockUserRepo), new(mockRoleGranter)
+	roles.On("GetRole", "support").Return(testSupportRole, nil)
+	roles.On("GetRole", "user").Return(testUserRole, nil)
+	roles.On("CheckGrantable", "admin", mock.Anything).Return(nil)
+	mockRepo.On("GetUserByID", 1).Return(&model.User{ID: 1, Role: "user"}, nil)
+	mockRepo.On("GetUserByID", 2).Return(&model.User{ID: 2, Role: "support"}, nil)
+	userService := NewUserService(nil, mockRepo, roles, nil, new(auditSink))
+
+	grants, err := userService.CheckRoleChange(ctx, "admin", 1, "support")
+	assert.NoError(t, err)
+	assert.True(t, grants, "Promotions grant new permissions")
+
+	grants, err = userService.CheckRoleChange(ctx, "admin", 2, model.RoleUser)
+	assert.NoError(t, err)
+	assert.False(t, grants, "Demotions grant none")
+	mockRepo.AssertNotCalled(t, "UpdateUserRole", mock.Anything, mock.Anything)
+}
+
+func TestUserService_ListUsers(t *testing.T) {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	users := []*model.User{
+		{ID: 3, Username: "carol", CreatedAt: createdAt.Add(2 * time.Hour)},
+		{ID: 2, Username: "bob", CreatedAt: createdAt.Add(time.Hour)},
+		{ID: 1, Username: "alice", CreatedAt: createdAt},
+	}
+
+	t.Run("page with more to come", func(t *testing.T) {
+		mockRepo, roles := new(mockUserRepo), new(mockRoleGranter)
+		filter := model.UserFilter{Search: "a", Role: "user", Limit: 2}
+		roles.On("GetRole", "user").Return(testUserRole, nil).Once()
+		mockRepo.On("ListUsers", filter).Return(users, 7, nil).Once()
+
+		page, err := NewUserService(nil, mockRepo, roles, nil, new(auditSink)).ListUsers(context.Background(), filter)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, page.Total)
+		assert.Len(t, page.Users, 2)
+		cursor, err := model.DecodeCursor(page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, cursor.ID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("last page uses the default limit", func(t *testing.T) {
+		mockRepo := new(mockUserRepo)
+		mockRepo.On("ListUsers", model.UserFilter{Limit: model.DefaultPageSize}).Return(users, 3, nil).Once()
+
+		page, err := NewUserService(nil, mockRepo, nil, nil, new(auditSink)).ListUsers(context.Background(), model.UserFilter{})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Users, 3)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		mockRepo, roles := new(mockUserRepo), new(mockRoleGranter)
+		roles.On("GetRole", "root").Return(nil, ErrRoleNotFound).Once()
+
+		_, err := NewUserService(nil, mockRepo, roles, nil, new(auditSink)).ListUsers(context.Background(), model.UserFilter{Role: "root"})
+
+		assert.Equal(t, ErrInvalidRole, err)
+		mockRepo.AssertNotCalled(t, "ListUsers")
+	})
 }
@@ -0,0 +1,229 @@
+// file: service/verification_service.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-bank-api/config"
+	"go-bank-api/logger"
+	"go-bank-api/model"
+	"go-bank-api/repository"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+	ErrInvalidUserToken     = errors.New("invalid or expired token")
+)
+
+const (
+	// passwordResetTTL is how long a password reset link works.
+	passwordResetTTL = time.Hour
+	// emailVerificationTTL is how long an email verification link works.
+	emailVerificationTTL = 48 * time.Hour
+)
+
+// IPasswordHasher hashes new passwords. PasswordHasher implements it.
+type IPasswordHasher interface {
+	HashPassword(password string) (string, error)
+}
+
+// ISessionRevoker logs a user out on every device. AuthService implements it.
+type ISessionRevoker interface {
+	RevokeAllSessions(ctx context.Context, userID int) error
+}
+
+// VerificationService handles the flows in which users prove they control
+// their email address by following a link mailed to them: verifying the
+// address and resetting a forgotten password. The links carry single-use,
+// expiring tokens of which only the hashes are stored.
+type VerificationService struct {
+	db        *sql.DB
+	userRepo  repository.IUserRepository
+	tokenRepo repository.IUserTokenRepository
+	hasher    IPasswordHasher
+	policy    IPasswordPolicy
+	sessions  ISessionRevoker
+	mailer    Mailer
+}
+
+// NewVerificationService creates a new VerificationService.
+func NewVerificationService(db *sql.DB, userRepo repository.IUserRepository, tokenRepo repository.IUserTokenRepository, hasher IPasswordHasher, policy IPasswordPolicy, sessions ISessionRevoker, mailer Mailer) *VerificationService {
+	return &VerificationService{
+		db:        db,
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		hasher:    hasher,
+		policy:    policy,
+		sessions:  sessions,
+		mailer:    mailer,
+	}
+}
+
+// issueToken creates a token for the purpose, replacing the user's earlier
+// unused ones, and returns it.
+func (s *VerificationService) issueToken(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	tokenString, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	err = runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.tokenRepo.InvalidateForUser(ctx, tx, userID, purpose); err != nil {
+			return err
+		}
+		return s.tokenRepo.Create(ctx, tx, &model.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
+
+// mailLink builds a frontend link carrying a token.
+func mailLink(path, token string) string {
+	return strings.TrimRight(config.AppConfig.Mail.LinkBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// SendEmailVerification mails the user a link to verify their email address.
+func (s *VerificationService) SendEmailVerification(ctx context.Context, user *model.User) error {
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	token, err := s.issueToken(ctx, user.ID, model.TokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, MailMessage{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease verify your email address by following this link:\n\n%s\n\n"+
+			"The link expires in %d hours. Until your address is verified you cannot make transfers.\n",
+			user.Username, mailLink("/verify-email", token), int(emailVerificationTTL.Hours())),
+	})
+	if err != nil {
+		return fmt.Errorf("could not send verification email: %w", err)
+	}
+	logger.Log.WithField("user_id", user.ID).Info("Email verification sent")
+	return nil
+}
+
+// RequestEmailVerification mails the user a new verification link.
+func (s *VerificationService) RequestEmailVerification(ctx context.Context, userID int) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.SendEmailVerification(ctx, user)
+}
+
+// VerifyEmail marks the email address of the user the token was mailed to as
+// verified. Access tokens issued before carry the old state until refreshed.
+func (s *VerificationService) VerifyEmail(ctx context.Context, token string) error {
+	var userID int
+	err := runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		userToken, err := s.tokenRepo.Consume(ctx, tx, model.TokenPurposeEmailVerification, hashOpaqueToken(token))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidUserToken
+			}
+			return err
+		}
+		userID = userToken.UserID
+		return s.userRepo.MarkEmailVerified(ctx, tx, userID)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Log.WithField("user_id", userID).Info("Email address verified")
+	return nil
+}
+
+// RequestPasswordReset mails a password reset link to the user with the email
+// address. Unknown addresses are ignored without an error, so that the
+// response does not reveal who has an account.
+func (s *VerificationService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logger.Log.WithField("email", email).Info("Password reset requested for unknown email")
+			return nil
+		}
+		return err
+	}
+	token, err := s.issueToken(ctx, user.ID, model.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, MailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password of your account. To choose a new password, follow this link:\n\n%s\n\n"+
+			"The link expires in %d minutes. If you did not ask for this, you can ignore this email.\n",
+			user.Username, mailLink("/reset-password", token), int(passwordResetTTL.Minutes())),
+	})
+	if err != nil {
+		return fmt.Errorf("could not send password reset email: %w", err)
+	}
+	logger.Log.WithField("user_id", user.ID).Info("Password reset email sent")
+	return nil
+}
+
+// ResetPassword sets a new password for the user the reset token was mailed
+// to, if it follows the password policy. Receiving the email proves the
+// address, so it is marked as verified too. The user is logged out on every
+// device, in case the old password was known to someone else.
+func (s *VerificationService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	var userID int
+	err := runInTx(ctx, s.db, func(tx *sql.Tx) error {
+		userToken, err := s.tokenRepo.Consume(ctx, tx, model.TokenPurposePasswordReset, hashOpaqueToken(token))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidUserToken
+			}
+			return err
+		}
+		userID = userToken.UserID
+
+		// A password the policy refuses rolls the transaction back, leaving
+		// the token usable for another try.
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.policy.Check(ctx, newPassword, user); err != nil {
+			return err
+		}
+		hashedPassword, err := s.hasher.HashPassword(newPassword)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.UpdatePassword(ctx, tx, userID, hashedPassword); err != nil {
+			return err
+		}
+		if err := s.userRepo.MarkEmailVerified(ctx, tx, userID); err != nil {
+			return err
+		}
+		return s.tokenRepo.InvalidateForUser(ctx, tx, userID, model.TokenPurposePasswordReset)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Log.WithField("user_id", userID).Info("Password reset")
+	if err := s.sessions.RevokeAllSessions(ctx, userID); err != nil {
+		return fmt.Errorf("password was reset but sessions could not be revoked: %w", err)
+	}
+	return nil
+}
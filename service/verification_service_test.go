@@ -0,0 +1,230 @@
+// file: service/verification_service_test.go
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"go-bank-api/model"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockUserTokenRepo provides a mock for IUserTokenRepository.
+type mockUserTokenRepo struct{ mock.Mock }
+
+func (m *mockUserTokenRepo) Create(_ context.Context, tx *sql.Tx, token *model.UserToken) error {
+	return m.Called(tx, token).Error(0)
+}
+func (m *mockUserTokenRepo) Consume(_ context.Context, tx *sql.Tx, purpose, tokenHash string) (*model.UserToken, error) {
+	args := m.Called(tx, purpose, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserToken), args.Error(1)
+}
+func (m *mockUserTokenRepo) InvalidateForUser(_ context.Context, tx *sql.Tx, userID int, purpose string) error {
+	return m.Called(tx, userID, purpose).Error(0)
+}
+
+// mockMailer records the messages sent through it.
+type mockMailer struct{ mock.Mock }
+
+func (m *mockMailer) Send(_ context.Context, msg MailMessage) error {
+	return m.Called(msg).Error(0)
+}
+
+// mockPasswordPolicy provides a mock for IPasswordPolicy.
+type mockPasswordPolicy struct{ mock.Mock }
+
+func (m *mockPasswordPolicy) Check(_ context.Context, password string, user *model.User) error {
+	return m.Called(password, user).Error(0)
+}
+
+// mockHasher provides a mock for IPasswordHasher.
+type mockHasher struct{ mock.Mock }
+
+func (m *mockHasher) HashPassword(password string) (string, error) {
+	args := m.Called(password)
+	return args.String(0), args.Error(1)
+}
+
+// mockSessionRevoker provides a mock for ISessionRevoker.
+type mockSessionRevoker struct{ mock.Mock }
+
+func (m *mockSessionRevoker) RevokeAllSessions(_ context.Context, userID int) error {
+	return m.Called(userID).Error(0)
+}
+
+var mailedToken = regexp.MustCompile(`\?token=(\S+)`)
+
+func TestVerificationService_RequestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("mails a token of which only the hash is stored", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		userRepo, tokenRepo, mailer := new(mockUserRepo), new(mockUserTokenRepo), new(mockMailer)
+		verificationService := NewVerificationService(db, userRepo, tokenRepo, nil, nil, nil, mailer)
+
+		userRepo.On("GetUserByEmail", "user@test.com").Return(&model.User{ID: 7, Username: "user", Email: "user@test.com"}, nil).Once()
+		dbMock.ExpectBegin()
+		tokenRepo.On("InvalidateForUser", mock.Anything, 7, model.TokenPurposePasswordReset).Return(nil).Once()
+		var stored *model.UserToken
+		tokenRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*model.UserToken)
+		}).Return(nil).Once()
+		dbMock.ExpectCommit()
+		var sent MailMessage
+		mailer.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(0).(MailMessage)
+		}).Return(nil).Once()
+
+		err = verificationService.RequestPasswordReset(ctx, "user@test.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user@test.com", sent.To)
+		match := mailedToken.FindStringSubmatch(sent.Body)
+		if assert.NotNil(t, match, "The email contains a link with the token") {
+			token, err := url.QueryUnescape(match[1])
+			assert.NoError(t, err)
+			assert.Equal(t, hashOpaqueToken(token), stored.TokenHash)
+			assert.NotContains(t, stored.TokenHash, token)
+		}
+		assert.Equal(t, 7, stored.UserID)
+		assert.WithinDuration(t, time.Now().Add(passwordResetTTL), stored.ExpiresAt, time.Minute)
+		tokenRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown email is ignored", func(t *testing.T) {
+		userRepo, mailer := new(mockUserRepo), new(mockMailer)
+		userRepo.On("GetUserByEmail", "nobody@test.com").Return(nil, sql.ErrNoRows).Once()
+
+		err := NewVerificationService(nil, userRepo, nil, nil, nil, nil, mailer).RequestPasswordReset(ctx, "nobody@test.com")
+
+		assert.NoError(t, err)
+		mailer.AssertNotCalled(t, "Send", mock.Anything)
+	})
+}
+
+func TestVerificationService_ResetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("valid token sets the password and logs the user out everywhere", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		userRepo, tokenRepo := new(mockUserRepo), new(mockUserTokenRepo)
+		hasher, policy, sessions := new(mockHasher), new(mockPasswordPolicy), new(mockSessionRevoker)
+		verificationService := NewVerificationService(db, userRepo, tokenRepo, hasher, policy, sessions, nil)
+
+		user := &model.User{ID: 7, Username: "user", Email: "user@test.com"}
+		dbMock.ExpectBegin()
+		tokenRepo.On("Consume", mock.Anything, model.TokenPurposePasswordReset, hashOpaqueToken("token")).
+			Return(&model.UserToken{ID: 1, UserID: 7}, nil).Once()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		policy.On("Check", "new-password", user).Return(nil).Once()
+		hasher.On("HashPassword", "new-password").Return("hashed", nil).Once()
+		userRepo.On("UpdatePassword", mock.Anything, 7, "hashed").Return(nil).Once()
+		userRepo.On("MarkEmailVerified", mock.Anything, 7).Return(nil).Once()
+		tokenRepo.On("InvalidateForUser", mock.Anything, 7, model.TokenPurposePasswordReset).Return(nil).Once()
+		dbMock.ExpectCommit()
+		sessions.On("RevokeAllSessions", 7).Return(nil).Once()
+
+		err = verificationService.ResetPassword(ctx, "token", "new-password")
+
+		assert.NoError(t, err)
+		userRepo.AssertExpectations(t)
+		tokenRepo.AssertExpectations(t)
+		sessions.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid, expired or used token", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		userRepo, tokenRepo := new(mockUserRepo), new(mockUserTokenRepo)
+		hasher, policy, sessions := new(mockHasher), new(mockPasswordPolicy), new(mockSessionRevoker)
+		verificationService := NewVerificationService(db, userRepo, tokenRepo, hasher, policy, sessions, nil)
+
+		dbMock.ExpectBegin()
+		tokenRepo.On("Consume", mock.Anything, model.TokenPurposePasswordReset, hashOpaqueToken("token")).Return(nil, sql.ErrNoRows).Once()
+		dbMock.ExpectRollback()
+
+		err = verificationService.ResetPassword(ctx, "token", "new-password")
+
+		assert.Equal(t, ErrInvalidUserToken, err)
+		userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+		sessions.AssertNotCalled(t, "RevokeAllSessions", mock.Anything)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("password refused by the policy leaves the token usable", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		userRepo, tokenRepo := new(mockUserRepo), new(mockUserTokenRepo)
+		hasher, policy, sessions := new(mockHasher), new(mockPasswordPolicy), new(mockSessionRevoker)
+		verificationService := NewVerificationService(db, userRepo, tokenRepo, hasher, policy, sessions, nil)
+
+		user := &model.User{ID: 7, Username: "user", Email: "user@test.com"}
+		policyErr := &PasswordPolicyError{Violations: []string{"must contain a digit"}}
+		dbMock.ExpectBegin()
+		tokenRepo.On("Consume", mock.Anything, model.TokenPurposePasswordReset, hashOpaqueToken("token")).
+			Return(&model.UserToken{ID: 1, UserID: 7}, nil).Once()
+		userRepo.On("GetUserByID", 7).Return(user, nil).Once()
+		policy.On("Check", "weak-password", user).Return(policyErr).Once()
+		dbMock.ExpectRollback()
+
+		err = verificationService.ResetPassword(ctx, "token", "weak-password")
+
+		assert.Equal(t, policyErr, err)
+		hasher.AssertNotCalled(t, "HashPassword", mock.Anything)
+		userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+		sessions.AssertNotCalled(t, "RevokeAllSessions", mock.Anything)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+}
+
+func TestVerificationService_VerifyEmail(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("valid token", func(t *testing.T) {
+		db, dbMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		userRepo, tokenRepo := new(mockUserRepo), new(mockUserTokenRepo)
+
+		dbMock.ExpectBegin()
+		tokenRepo.On("Consume", mock.Anything, model.TokenPurposeEmailVerification, hashOpaqueToken("token")).
+			Return(&model.UserToken{ID: 1, UserID: 7}, nil).Once()
+		userRepo.On("MarkEmailVerified", mock.Anything, 7).Return(nil).Once()
+		dbMock.ExpectCommit()
+
+		err = NewVerificationService(db, userRepo, tokenRepo, nil, nil, nil, nil).VerifyEmail(ctx, "token")
+
+		assert.NoError(t, err)
+		userRepo.AssertExpectations(t)
+		assert.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("already verified users get no new link", func(t *testing.T) {
+		mailer := new(mockMailer)
+		verifiedAt := time.Now()
+
+		err := NewVerificationService(nil, nil, nil, nil, nil, nil, mailer).
+			SendEmailVerification(ctx, &model.User{ID: 7, EmailVerifiedAt: &verifiedAt})
+
+		assert.Equal(t, ErrEmailAlreadyVerified, err)
+		mailer.AssertNotCalled(t, "Send", mock.Anything)
+	})
+}